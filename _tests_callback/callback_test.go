@@ -115,8 +115,7 @@ func TestCallback(t *testing.T) {
 		flags.DoLog = true
 		flags.DoCallback = true
 		flags.DoLevel = 1
-		myutil.DoEachMainMy(i, mainPkg)
-		result := pointer.GetMain2ResultWCtx()[mainPkg]
+		result := myutil.DoEachMainMy(i, mainPkg)
 
 		//verify
 		if got := verifyPTS(result, want); got != nil {
@@ -0,0 +1,22 @@
+// The leakcheck command reports the goroutine leaks and channel deadlocks in
+// the main packages of a project, using the origin-sensitive pointer analysis
+// (see go/leak). It accepts the same flags as the main command, e.g.,
+//
+//	leakcheck -main=xxx/cmd/server ./...
+//
+// runs in the project root directory with go.mod.
+package main
+
+import (
+	"github.com/april1989/origin-go-tools/go/myutil"
+	"github.com/april1989/origin-go-tools/go/myutil/flags"
+)
+
+func main() {
+	flags.DoLeak = true
+	mains := myutil.InitialMain()
+	if mains == nil {
+		return
+	}
+	myutil.DoEach(mains)
+}
@@ -0,0 +1,22 @@
+// The racecheck command reports the data races in the main packages of a
+// project, using the origin-sensitive pointer analysis (see go/race).
+// It accepts the same flags as the main command, e.g.,
+//
+//	racecheck -main=xxx/cmd/server ./...
+//
+// runs in the project root directory with go.mod.
+package main
+
+import (
+	"github.com/april1989/origin-go-tools/go/myutil"
+	"github.com/april1989/origin-go-tools/go/myutil/flags"
+)
+
+func main() {
+	flags.DoRace = true
+	mains := myutil.InitialMain()
+	if mains == nil {
+		return
+	}
+	myutil.DoEach(mains)
+}
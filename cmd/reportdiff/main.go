@@ -0,0 +1,49 @@
+// The reportdiff command compares two reports written by the -report flag
+// (see go/myutil/compare/report.go) from two versions of the origin-sensitive
+// pointer analysis, and exits with status 1 if the new version regresses.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/april1989/origin-go-tools/go/myutil/compare"
+)
+
+var slowdown = flag.Float64("slowdown", 1.2, "report a regression if time or peak heap grows by more than this factor; 0 to ignore cost")
+
+const usageFooter = `
+Each input file should be from:
+	main -report=[old,new].json ...
+
+Reportdiff compares the call graph edges, the pts of queries, time and
+peak heap of each main in old and new.
+`
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s old.json new.json\n\n", os.Args[0])
+		flag.PrintDefaults()
+		fmt.Fprint(os.Stderr, usageFooter)
+		os.Exit(2)
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+	}
+
+	old, err := compare.ReadReports(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "reportdiff: %v\n", err)
+		os.Exit(2)
+	}
+	cur, err := compare.ReadReports(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "reportdiff: %v\n", err)
+		os.Exit(2)
+	}
+	if compare.DiffReports(os.Stdout, old, cur, *slowdown) {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,275 @@
+// Package leak is a static checker of goroutine leaks and channel deadlocks on top of the origin-sensitive
+// pointer analysis (go/pointer with Config.Origin).
+//
+// It flags the operations that may block an origin forever:
+//   - a send on an unbuffered channel that no other origin receives from;
+//   - a receive from a channel that no other origin sends to or closes (any origin for a buffered channel);
+//   - a range over a channel that is never closed;
+//   - a select without default whose cases all block forever;
+//   - a sync.WaitGroup.Wait without any Done (or Add with a negative delta) on the same WaitGroup.
+//
+// The channels of an operation are the make(chan) sites, with heap contexts, in the points-to set of its
+// operand under the context of the origin (see pointer.ChanGraph). An operation whose channels are unknown
+// never blocks. The results are go/analysis diagnostics: a blocked main goroutine is a deadlock, and a blocked
+// goroutine is a leak, related to the make(chan) sites and the go statements that create its origin.
+package leak // import "github.com/april1989/origin-go-tools/go/leak"
+
+import (
+	"fmt"
+	"go/constant"
+	"go/token"
+	"sort"
+	"strings"
+
+	"github.com/april1989/origin-go-tools/go/analysis"
+	"github.com/april1989/origin-go-tools/go/pointer"
+	"github.com/april1989/origin-go-tools/go/ssa"
+)
+
+// The categories of the diagnostics.
+const (
+	Leak     = "leak"     // a goroutine blocked forever
+	Deadlock = "deadlock" // the main goroutine blocked forever
+)
+
+type reportKey struct {
+	pos     token.Pos
+	goInstr *ssa.Go //bz: the two origins of a go in a loop are reported once
+}
+
+type checker struct {
+	result   *pointer.Result
+	graph    *pointer.ChanGraph
+	reported map[reportKey]bool
+	diags    []analysis.Diagnostic
+}
+
+// Check returns the diagnostics of the program whose main package is analyzed by result, computed with
+// Config.Origin and Config.BuildCallGraph. The diagnostics are sorted by their positions.
+func Check(result *pointer.Result) []analysis.Diagnostic {
+	c := &checker{
+		result:   result,
+		graph:    result.ChanGraph(),
+		reported: make(map[reportKey]bool),
+	}
+	c.chanOps()
+	c.waitGroups()
+	sort.SliceStable(c.diags, func(i, j int) bool {
+		return c.diags[i].Pos < c.diags[j].Pos
+	})
+	return c.diags
+}
+
+// Format returns d as a line "file:line:col: category: message", followed by its related information.
+func Format(fset *token.FileSet, d analysis.Diagnostic) string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "%s: %s: %s", fset.Position(d.Pos), d.Category, d.Message)
+	for _, r := range d.Related {
+		fmt.Fprintf(&buf, "\n\t%s: %s", fset.Position(r.Pos), r.Message)
+	}
+	return buf.String()
+}
+
+//bz: report the operation at pos that blocks origin o forever, with the go statements from main to o (of all the
+// parents of each origin)
+func (c *checker) report(o *pointer.Origin, pos token.Pos, msg string, related []analysis.RelatedInformation) {
+	key := reportKey{pos, o.Go}
+	if c.reported[key] {
+		return
+	}
+	c.reported[key] = true
+	category := Leak
+	if o.Go == nil {
+		category = Deadlock
+	}
+	seen := make(map[*pointer.Origin]bool)
+	for work := []*pointer.Origin{o}; len(work) > 0; {
+		x := work[0]
+		work = work[1:]
+		if x.Go == nil || seen[x] {
+			continue
+		}
+		seen[x] = true
+		var parents []string
+		for _, p := range x.Parents {
+			parents = append(parents, p.String())
+		}
+		related = append(related, analysis.RelatedInformation{
+			Pos:     x.Go.Pos(),
+			Message: fmt.Sprintf("goroutine created in origin %s", strings.Join(parents, ", ")),
+		})
+		work = append(work, x.Parents...)
+	}
+	c.diags = append(c.diags, analysis.Diagnostic{
+		Pos:      pos,
+		Category: category,
+		Message:  fmt.Sprintf("%s in origin %s", msg, o),
+		Related:  related,
+	})
+}
+
+type selectKey struct {
+	origin *pointer.Origin
+	instr  ssa.Instruction
+}
+
+func (c *checker) chanOps() {
+	cases := make(map[selectKey][]*pointer.ChanOp)
+	var selects []selectKey
+	for _, op := range c.graph.Ops {
+		switch {
+		case op.Index >= 0:
+			key := selectKey{op.Origin, op.Instr}
+			if cases[key] == nil {
+				selects = append(selects, key)
+			}
+			cases[key] = append(cases[key], op)
+		case op.Kind == pointer.ChanSend:
+			if blocks(op) {
+				c.report(op.Origin, op.Pos(), "send blocks forever: no receiver in other goroutines", sites(op))
+			}
+		case op.Kind == pointer.ChanRecv:
+			if isRange(op) {
+				if !closed(op) {
+					c.report(op.Origin, op.Pos(), "range over a channel blocks forever: the channel is never closed", sites(op))
+				}
+			} else if blocks(op) {
+				c.report(op.Origin, op.Pos(), "receive blocks forever: no sender or close in other goroutines", sites(op))
+			}
+		}
+	}
+
+	for _, key := range selects {
+		if !key.instr.(*ssa.Select).Blocking {
+			continue
+		}
+		var related []analysis.RelatedInformation
+		dead := true
+		for _, op := range cases[key] {
+			if !blocks(op) {
+				dead = false
+				break
+			}
+			related = append(related, sites(op)...)
+		}
+		if dead {
+			c.report(key.origin, key.instr.Pos(), "select blocks forever: no case can proceed", related)
+		}
+	}
+}
+
+//bz: whether op blocks forever: no channel of op has a peer operation in another origin, or in any origin if
+// the channel is buffered or the peer is a close (a receive proceeds once the channel is closed); a send on a
+// buffered channel may not block
+func blocks(op *pointer.ChanOp) bool {
+	if len(op.Channels) == 0 { //unknown
+		return false
+	}
+	for _, ch := range op.Channels {
+		buffered := !unbuffered(ch.Site)
+		peers := ch.Senders()
+		if op.Kind == pointer.ChanSend {
+			if buffered {
+				return false
+			}
+			peers = ch.Receivers()
+		}
+		for _, peer := range peers {
+			if buffered || peer.Kind == pointer.ChanClose || peer.Origin != op.Origin {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func unbuffered(site *ssa.MakeChan) bool {
+	size, ok := site.Size.(*ssa.Const)
+	return ok && size.Value != nil && constant.Sign(size.Value) == 0
+}
+
+//bz: whether op is the receive of a range over a channel
+func isRange(op *pointer.ChanOp) bool {
+	return op.Instr.Block().Comment == "rangechan.loop"
+}
+
+//bz: whether a channel of op may be closed
+func closed(op *pointer.ChanOp) bool {
+	if len(op.Channels) == 0 { //unknown
+		return true
+	}
+	for _, ch := range op.Channels {
+		for _, peer := range ch.Ops {
+			if peer.Kind == pointer.ChanClose {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//bz: the make(chan) sites of the channels of op
+func sites(op *pointer.ChanOp) []analysis.RelatedInformation {
+	var related []analysis.RelatedInformation
+	for _, ch := range op.Channels {
+		related = append(related, analysis.RelatedInformation{Pos: ch.Site.Pos(), Message: "channel made here"})
+	}
+	return related
+}
+
+type waitGroupCall struct {
+	origin *pointer.Origin
+	call   ssa.CallInstruction
+	ptr    pointer.PointerWCtx
+}
+
+//bz: report the Wait calls without any Done on the same WaitGroup; a Done before Wait in the same origin also counts
+func (c *checker) waitGroups() {
+	var waits, dones []waitGroupCall
+	for _, o := range c.graph.Origins.Origins {
+		for _, n := range o.Nodes {
+			for _, b := range n.GetFunc().Blocks {
+				for _, instr := range b.Instrs {
+					call, ok := instr.(ssa.CallInstruction)
+					if !ok {
+						continue
+					}
+					common := call.Common()
+					typ, name, recv := pointer.SyncMethod(common)
+					if typ != "WaitGroup" {
+						continue
+					}
+					ptr := c.result.PointsToByGoWithLoopID(recv, o.Go, o.LoopID)
+					switch {
+					case name == "Wait":
+						waits = append(waits, waitGroupCall{o, call, ptr})
+					case name == "Done", name == "Add" && !positive(common.Args[1]):
+						dones = append(dones, waitGroupCall{o, call, ptr})
+					}
+				}
+			}
+		}
+	}
+
+	for _, w := range waits {
+		if w.ptr.PointsTo().IsNil() { //unknown
+			continue
+		}
+		done := false
+		for _, d := range dones {
+			if d.ptr.PointsTo().IsNil() || w.ptr.MayAlias(d.ptr) {
+				done = true
+				break
+			}
+		}
+		if !done {
+			c.report(w.origin, w.call.Pos(), "Wait blocks forever: no Done on the WaitGroup", nil)
+		}
+	}
+}
+
+//bz: whether the delta of Add is a positive constant
+func positive(delta ssa.Value) bool {
+	k, ok := delta.(*ssa.Const)
+	return ok && k.Value != nil && constant.Sign(k.Value) > 0
+}
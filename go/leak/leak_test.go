@@ -0,0 +1,152 @@
+package leak
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/april1989/origin-go-tools/go/buildutil"
+	"github.com/april1989/origin-go-tools/go/loader"
+	"github.com/april1989/origin-go-tools/go/pointer"
+	"github.com/april1989/origin-go-tools/go/ssa"
+	"github.com/april1989/origin-go-tools/go/ssa/ssautil"
+)
+
+// A fake package sync: the analysis does not load the standard library.
+const fakeSync = `
+package sync
+
+type WaitGroup struct{ n int }
+
+func (wg *WaitGroup) Add(n int) {}
+func (wg *WaitGroup) Done()     {}
+func (wg *WaitGroup) Wait()     {}
+`
+
+// The comments "want" mark the operations blocked forever.
+const leakTestProg = `
+package main
+
+import "sync"
+
+func spawn(lost chan int) {
+	go func() {
+		lost <- 1 // want
+	}()
+}
+
+func main() {
+	ok := make(chan int)
+	go func() { ok <- 1 }()
+	<-ok
+
+	go spawn(make(chan int))
+
+	never := make(chan int)
+	go func() {
+		<-never // want
+	}()
+
+	items := make(chan int)
+	go func() {
+		for range items { // want
+		}
+	}()
+	items <- 1
+
+	jobs := make(chan int, 1)
+	go func() {
+		for range jobs {
+		}
+	}()
+	jobs <- 1
+	close(jobs)
+
+	quit := make(chan int)
+	close(quit)
+	<-quit
+
+	a, b := make(chan int), make(chan int)
+	go func() {
+		select { // want
+		case <-a:
+		case b <- 1:
+		}
+	}()
+	go func() {
+		select {
+		case <-a:
+		default:
+		}
+	}()
+
+	buf := make(chan int, 1)
+	go func() { buf <- 1 }()
+	<-buf
+
+	var wg, wg2 sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+	}()
+	wg.Wait()
+	wg2.Add(1)
+	wg2.Wait() // want
+}
+`
+
+func TestCheck(t *testing.T) {
+	conf := loader.Config{Build: buildutil.FakeContext(map[string]map[string]string{
+		"sync": {"sync.go": fakeSync},
+		"main": {"main.go": leakTestProg},
+	})}
+	conf.Import("main")
+	iprog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog := ssautil.CreateProgram(iprog, 0)
+	prog.Build()
+	main := prog.Package(iprog.Imported["main"].Pkg)
+
+	result, err := pointer.Analyze(&pointer.Config{
+		Mains:          []*ssa.Package{main},
+		BuildCallGraph: true,
+		Origin:         true,
+		K:              1,
+		LimitScope:     true,
+		Scope:          []string{"main"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want []int
+	for i, line := range strings.Split(leakTestProg, "\n") {
+		if strings.HasSuffix(line, "// want") {
+			want = append(want, i+1)
+		}
+	}
+	diags := Check(result)
+	var got []int
+	for _, d := range diags {
+		got = append(got, prog.Fset.Position(d.Pos).Line)
+		t.Log(Format(prog.Fset, d))
+	}
+	if len(got) != len(want) {
+		t.Fatalf("diagnostics at lines %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("diagnostics at lines %v, want %v", got, want)
+			break
+		}
+	}
+
+	//the send in spawn is in a goroutine created by a goroutine; Wait blocks main
+	if d := diags[0]; d.Category != Leak || len(d.Related) != 3 || !strings.HasPrefix(d.Message, "send blocks forever") {
+		t.Errorf("send: %s", Format(prog.Fset, d))
+	}
+	if d := diags[len(diags)-1]; d.Category != Deadlock || len(d.Related) != 0 {
+		t.Errorf("Wait: %s", Format(prog.Fset, d))
+	}
+}
@@ -0,0 +1,299 @@
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/april1989/origin-go-tools/go/callgraph"
+	"github.com/april1989/origin-go-tools/go/pointer"
+	default_algo "github.com/april1989/origin-go-tools/go/pointer_default"
+	"github.com/april1989/origin-go-tools/go/ssa"
+)
+
+/**
+   This file creates a machine-readable (JSON) report of the comparison in comp.go for each main, and compares
+   two such reports from two versions of this tool (see DiffReports() and cmd/reportdiff).
+   call graph edges are compared on functions ("caller -> callee"), and pts on labels (Label.String()): the pts of
+   my pointers with different contexts are merged, like compareQueries().
+*/
+
+// A Report compares the result of my pointer analysis with the default one for one main.
+type Report struct {
+	Main      string        `json:"main"`
+	CallGraph EdgeDiff      `json:"callgraph"`
+	Queries   []QueryReport `json:"queries,omitempty"` // queries with different pts
+	My        Cost          `json:"my"`
+	Default   Cost          `json:"default"`
+}
+
+// An EdgeDiff is the difference between the call graph edges of my and the default pointer analysis.
+type EdgeDiff struct {
+	Common      int      `json:"common"`
+	OnlyMy      []string `json:"onlyMy,omitempty"`
+	OnlyDefault []string `json:"onlyDefault,omitempty"`
+}
+
+// A QueryReport is the difference between the pts of a query.
+type QueryReport struct {
+	Value       string   `json:"value"`
+	Indirect    bool     `json:"indirect,omitempty"`
+	MySize      int      `json:"mySize"`
+	DefaultSize int      `json:"defaultSize"`
+	OnlyMy      []string `json:"onlyMy,omitempty"`
+	OnlyDefault []string `json:"onlyDefault,omitempty"`
+}
+
+// A Cost is the cost and the size of the result of one pointer analysis.
+type Cost struct {
+	Time     time.Duration            `json:"time"`
+	Phases   []pointer.Phase          `json:"phases,omitempty"`
+	Edges    int                      `json:"edges"`
+	Queries  int                      `json:"queries"`
+	QueryPTS *pointer.PTSDistribution `json:"queryPTS"`      // of the queries
+	PTS      *pointer.PTSDistribution `json:"pts,omitempty"` // of all pointers; only for my pointer analysis
+}
+
+//bz: a readable key of v that is the same across runs
+func valueKey(v ssa.Value) string {
+	if fn := v.Parent(); fn != nil {
+		return v.Name() + " = " + v.String() + " @" + fn.String()
+	}
+	return v.String()
+}
+
+func funcName(fn *ssa.Function) string {
+	if fn == nil {
+		return "<root>"
+	}
+	return fn.String()
+}
+
+func sortedKeys(set map[string]bool) []string {
+	var keys []string
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//bz: the elements only in x, and only in y
+func diffSets(x, y map[string]bool) ([]string, []string) {
+	onlyX, onlyY := make(map[string]bool), make(map[string]bool)
+	for k := range x {
+		if !y[k] {
+			onlyX[k] = true
+		}
+	}
+	for k := range y {
+		if !x[k] {
+			onlyY[k] = true
+		}
+	}
+	return sortedKeys(onlyX), sortedKeys(onlyY)
+}
+
+// NewReport creates the report of main: myTime and defaultTime are the time of the two analyses.
+func NewReport(main string, result_default *default_algo.Result, result_my *pointer.ResultWCtx, defaultTime, myTime time.Duration) *Report {
+	r := &Report{Main: main}
+
+	//call graph
+	myEdges, defaultEdges := make(map[string]bool), make(map[string]bool)
+	for _, caller := range result_my.CallGraph.Nodes {
+		for _, out := range caller.Out {
+			myEdges[funcName(caller.GetFunc())+" -> "+funcName(out.Callee.GetFunc())] = true
+		}
+	}
+	callgraph.GraphVisitEdges(result_default.CallGraph, func(e *callgraph.Edge) error {
+		defaultEdges[funcName(e.Caller.Func)+" -> "+funcName(e.Callee.Func)] = true
+		return nil
+	})
+	r.CallGraph.OnlyMy, r.CallGraph.OnlyDefault = diffSets(myEdges, defaultEdges)
+	r.CallGraph.Common = len(myEdges) - len(r.CallGraph.OnlyMy)
+
+	//queries
+	r.My = Cost{Time: myTime, Phases: result_my.Phases, Edges: len(myEdges), QueryPTS: pointer.NewPTSDistribution(), PTS: result_my.PTSDistribution()}
+	r.Default = Cost{Time: defaultTime, Phases: defaultPhases(result_default.Phases), Edges: len(defaultEdges), QueryPTS: pointer.NewPTSDistribution()}
+	r.reportQueries(result_default.Queries, result_my.Queries, false)
+	r.reportQueries(result_default.IndirectQueries, result_my.IndirectQueries, true)
+	sort.Slice(r.Queries, func(i, j int) bool { return r.Queries[i].Value < r.Queries[j].Value })
+	return r
+}
+
+func (r *Report) reportQueries(default_queries map[ssa.Value]default_algo.Pointer, my_queries map[ssa.Value][]pointer.PointerWCtx, indirect bool) {
+	values := make(map[ssa.Value]bool)
+	for v := range my_queries {
+		values[v] = true
+	}
+	for v := range default_queries {
+		values[v] = true
+	}
+	for v := range values {
+		my_pts, default_pts := make(map[string]bool), make(map[string]bool)
+		for _, p := range my_queries[v] {
+			for _, l := range p.PointsTo().Labels() {
+				my_pts[l.String()] = true
+			}
+		}
+		if p, ok := default_queries[v]; ok {
+			for _, l := range p.PointsTo().Labels() {
+				default_pts[l.String()] = true
+			}
+		}
+		if _, ok := my_queries[v]; ok {
+			r.My.Queries++
+			r.My.QueryPTS.Add(len(my_pts))
+		}
+		if _, ok := default_queries[v]; ok {
+			r.Default.Queries++
+			r.Default.QueryPTS.Add(len(default_pts))
+		}
+
+		onlyMy, onlyDefault := diffSets(my_pts, default_pts)
+		if len(onlyMy) == 0 && len(onlyDefault) == 0 {
+			continue
+		}
+		r.Queries = append(r.Queries, QueryReport{
+			Value:       valueKey(v),
+			Indirect:    indirect,
+			MySize:      len(my_pts),
+			DefaultSize: len(default_pts),
+			OnlyMy:      onlyMy,
+			OnlyDefault: onlyDefault,
+		})
+	}
+}
+
+// WriteReports writes reports to the file at path as a JSON array.
+func WriteReports(path string, reports []*Report) error {
+	data, err := json.MarshalIndent(reports, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// ReadReports reads the reports written by WriteReports.
+func ReadReports(path string) ([]*Report, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var reports []*Report
+	if err := json.Unmarshal(data, &reports); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return reports, nil
+}
+
+func defaultPhases(phases []default_algo.Phase) []pointer.Phase {
+	result := make([]pointer.Phase, len(phases))
+	for i, p := range phases {
+		result[i] = pointer.Phase(p)
+	}
+	return result
+}
+
+//bz: the peak of HeapAlloc in phases
+func peakHeap(phases []pointer.Phase) uint64 {
+	var peak uint64
+	for _, p := range phases {
+		if p.HeapAlloc > peak {
+			peak = p.HeapAlloc
+		}
+	}
+	return peak
+}
+
+// DiffReports writes the differences of my pointer analysis between the reports of an old and a current version
+// to w, and returns whether the current version regresses: it is less precise (more call graph edges or larger
+// pts of queries), or slower/uses more memory by a factor of more than slowdown (e.g., 1.2), for any main.
+func DiffReports(w io.Writer, old, cur []*Report, slowdown float64) bool {
+	olds := make(map[string]*Report)
+	for _, r := range old {
+		olds[r.Main] = r
+	}
+	regressed := false
+	regress := func(format string, args ...interface{}) {
+		regressed = true
+		fmt.Fprintf(w, "  REGRESSION: "+format+"\n", args...)
+	}
+
+	for _, n := range cur {
+		o, ok := olds[n.Main]
+		if !ok {
+			fmt.Fprintf(w, "%s: only in new\n", n.Main)
+			continue
+		}
+		delete(olds, n.Main)
+		fmt.Fprintf(w, "%s:\n", n.Main)
+
+		//precision
+		if n.My.Edges > o.My.Edges {
+			regress("#call graph edges: %d -> %d", o.My.Edges, n.My.Edges)
+		} else if n.My.Edges != o.My.Edges {
+			fmt.Fprintf(w, "  #call graph edges: %d -> %d\n", o.My.Edges, n.My.Edges)
+		}
+		oldOnly, newOnly := diffSets(toSet(o.CallGraph.OnlyMy), toSet(n.CallGraph.OnlyMy))
+		for _, e := range newOnly {
+			fmt.Fprintf(w, "  + edge only in mine: %s\n", e)
+		}
+		for _, e := range oldOnly {
+			fmt.Fprintf(w, "  - edge only in mine: %s\n", e)
+		}
+		oldOnly, newOnly = diffSets(toSet(o.CallGraph.OnlyDefault), toSet(n.CallGraph.OnlyDefault))
+		for _, e := range newOnly {
+			fmt.Fprintf(w, "  + edge only in default: %s\n", e)
+		}
+		for _, e := range oldOnly {
+			fmt.Fprintf(w, "  - edge only in default: %s\n", e)
+		}
+		if on, nn := o.My.QueryPTS.TotalPTS, n.My.QueryPTS.TotalPTS; nn > on {
+			regress("total size of the pts of queries: %d -> %d", on, nn)
+		} else if nn != on {
+			fmt.Fprintf(w, "  total size of the pts of queries: %d -> %d\n", on, nn)
+		}
+		oldSizes := make(map[string]int)
+		for _, q := range o.Queries {
+			oldSizes[q.Value] = q.MySize
+		}
+		for _, q := range n.Queries {
+			if size, ok := oldSizes[q.Value]; ok && size != q.MySize {
+				fmt.Fprintf(w, "  |pts(%s)|: %d -> %d\n", q.Value, size, q.MySize)
+			}
+		}
+
+		//cost
+		if slowdown > 0 {
+			if o.My.Time > 0 && float64(n.My.Time) > float64(o.My.Time)*slowdown {
+				regress("time: %s -> %s", o.My.Time, n.My.Time)
+			}
+			if op, np := peakHeap(o.My.Phases), peakHeap(n.My.Phases); op > 0 && float64(np) > float64(op)*slowdown {
+				regress("peak heap: %d -> %d bytes", op, np)
+			}
+		}
+		fmt.Fprintf(w, "  time: %s -> %s\n", o.My.Time, n.My.Time)
+	}
+
+	var missing []string
+	for main := range olds {
+		missing = append(missing, main)
+	}
+	sort.Strings(missing)
+	for _, main := range missing {
+		fmt.Fprintf(w, "%s: only in old\n", main)
+	}
+	return regressed
+}
+
+func toSet(keys []string) map[string]bool {
+	set := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		set[k] = true
+	}
+	return set
+}
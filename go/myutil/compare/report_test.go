@@ -0,0 +1,197 @@
+package compare
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/april1989/origin-go-tools/go/loader"
+	"github.com/april1989/origin-go-tools/go/pointer"
+	default_algo "github.com/april1989/origin-go-tools/go/pointer_default"
+	"github.com/april1989/origin-go-tools/go/ssa"
+	"github.com/april1989/origin-go-tools/go/ssa/ssautil"
+)
+
+func buildMain(t *testing.T, src string) *ssa.Package {
+	conf := loader.Config{}
+	f, err := conf.ParseFile("main.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.CreateFromFiles("main", f)
+	iprog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog := ssautil.CreateProgram(iprog, 0)
+	prog.Build()
+	return prog.Package(iprog.Created[0].Pkg)
+}
+
+func TestNewReport(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   string
+		edges int // #call graph edges, the same in both
+	}{
+		{"call", `
+package main
+
+func id(p *int) *int { return p }
+
+func main() {
+	x := id(new(int))
+	_ = *x
+}
+`, 3}, //root -> main.init, root -> main.main, main.main -> main.id
+		{"go", `
+package main
+
+type T struct{ f func() }
+
+func a() {}
+func b() {}
+
+func call(t *T) { t.f() }
+
+func main() {
+	go call(&T{a})
+	go call(&T{b})
+}
+`, 5}, //root -> main.init, root -> main.main, main.main -> main.call, main.call -> main.a and main.b
+	}
+	for _, test := range tests {
+		main := buildMain(t, test.src)
+		rDefault, err := default_algo.Analyze(&default_algo.Config{
+			Mains:           []*ssa.Package{main},
+			BuildCallGraph:  true,
+			DoRecordQueries: true,
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		rMy, err := pointer.Analyze(&pointer.Config{
+			Mains:          []*ssa.Package{main},
+			BuildCallGraph: true,
+			Origin:         true,
+			K:              1,
+			LimitScope:     true,
+			Scope:          []string{"main"},
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		r := NewReport("main", rDefault, rMy.GetResult(), time.Second, 2*time.Second)
+		if r.Main != "main" || r.Default.Time != time.Second || r.My.Time != 2*time.Second {
+			t.Errorf("%s: report of %s, default time %s, my time %s", test.name, r.Main, r.Default.Time, r.My.Time)
+		}
+		want := EdgeDiff{Common: test.edges}
+		if !reflect.DeepEqual(r.CallGraph, want) || r.My.Edges != test.edges || r.Default.Edges != test.edges {
+			t.Errorf("%s: call graph = %+v, %d/%d edges, want %+v", test.name, r.CallGraph, r.My.Edges, r.Default.Edges, want)
+		}
+		if r.My.Queries == 0 || r.Default.Queries == 0 {
+			t.Errorf("%s: #queries = %d/%d", test.name, r.My.Queries, r.Default.Queries)
+		}
+		for i, q := range r.Queries {
+			if i > 0 && r.Queries[i-1].Value >= q.Value {
+				t.Errorf("%s: queries are not sorted: %s, %s", test.name, r.Queries[i-1].Value, q.Value)
+			}
+			if len(q.OnlyMy) == 0 && len(q.OnlyDefault) == 0 {
+				t.Errorf("%s: %s: no difference", test.name, q.Value)
+			}
+			if q.MySize-len(q.OnlyMy) != q.DefaultSize-len(q.OnlyDefault) {
+				t.Errorf("%s: %s: inconsistent sizes: %+v", test.name, q.Value, q)
+			}
+		}
+	}
+}
+
+func testReport(main string, edges, totalPTS int, t time.Duration, heap uint64) *Report {
+	r := &Report{Main: main}
+	r.My = Cost{
+		Time:     t,
+		Phases:   []pointer.Phase{{Name: "solve", Time: t, HeapAlloc: heap}},
+		Edges:    edges,
+		QueryPTS: pointer.NewPTSDistribution(),
+	}
+	r.My.QueryPTS.Add(totalPTS)
+	return r
+}
+
+func TestDiffReports(t *testing.T) {
+	old := []*Report{testReport("a", 10, 5, time.Second, 100)}
+	tests := []struct {
+		name      string
+		cur       *Report
+		slowdown  float64
+		regressed bool
+		output    string // a line in the output
+	}{
+		{"same", testReport("a", 10, 5, time.Second, 100), 1.2, false, "a:"},
+		{"fewer edges", testReport("a", 9, 5, time.Second, 100), 1.2, false, "#call graph edges: 10 -> 9"},
+		{"more edges", testReport("a", 11, 5, time.Second, 100), 1.2, true, "REGRESSION: #call graph edges: 10 -> 11"},
+		{"larger pts", testReport("a", 10, 6, time.Second, 100), 1.2, true, "REGRESSION: total size of the pts of queries: 5 -> 6"},
+		{"slower", testReport("a", 10, 5, 2*time.Second, 100), 1.2, true, "REGRESSION: time: 1s -> 2s"},
+		{"slower within the factor", testReport("a", 10, 5, 1100*time.Millisecond, 100), 1.2, false, "time: 1s -> 1.1s"},
+		{"more heap", testReport("a", 10, 5, time.Second, 200), 1.2, true, "REGRESSION: peak heap: 100 -> 200 bytes"},
+		{"cost ignored", testReport("a", 10, 5, 2*time.Second, 200), 0, false, "time: 1s -> 2s"},
+		{"only in new", testReport("b", 10, 5, time.Second, 100), 1.2, false, "b: only in new"},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if regressed := DiffReports(&buf, old, []*Report{test.cur}, test.slowdown); regressed != test.regressed {
+			t.Errorf("%s: regressed = %t, want %t; output:\n%s", test.name, regressed, test.regressed, &buf)
+		}
+		if !strings.Contains(buf.String(), test.output) {
+			t.Errorf("%s: output does not contain %q:\n%s", test.name, test.output, &buf)
+		}
+	}
+}
+
+func TestReadWriteReports(t *testing.T) {
+	dir, err := ioutil.TempDir("", "report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := testReport("a", 10, 5, time.Second, 100)
+	r.CallGraph = EdgeDiff{Common: 9, OnlyMy: []string{"main.main -> main.f"}}
+	r.Queries = []QueryReport{{Value: "t0 = new int (x) @main.main", MySize: 1, DefaultSize: 2, OnlyDefault: []string{"new@main.g"}}}
+	r.Default = Cost{Time: time.Second, QueryPTS: pointer.NewPTSDistribution()}
+	tests := []struct {
+		name    string
+		reports []*Report
+	}{
+		{"empty", []*Report{}},
+		{"one", []*Report{r}},
+		{"two", []*Report{r, testReport("b", 1, 1, time.Millisecond, 1)}},
+	}
+	for _, test := range tests {
+		path := filepath.Join(dir, test.name+".json")
+		if err := WriteReports(path, test.reports); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		reports, err := ReadReports(path)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(reports, test.reports) {
+			t.Errorf("%s: read %+v, want %+v", test.name, reports, test.reports)
+		}
+	}
+
+	path := filepath.Join(dir, "corrupted.json")
+	if err := ioutil.WriteFile(path, []byte("[{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadReports(path); err == nil {
+		t.Error("corrupted reports: no error")
+	}
+}
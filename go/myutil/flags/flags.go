@@ -10,15 +10,37 @@ var DoLog = false
 var Main = ""          //bz: run for a specific main in this pkg; start from 0
 var DoDefault = false  //bz: only Do default
 var DoCompare = false  //bz: this has a super long time
+var Report = ""        //bz: write the comparison of each main to this json file; DoCompare must be true
 var DoLevel = 0        //bz: set the analysis scope to level ? default = 0
 var DoCallback = false //bz: simplify callback fn + preSolve()
 var DoCollapse = false //bz: collapse the lib function with its callback, no matter what are the context of caller of lib func -> DoCallback must be true
 var DoTests = false    //bz: treat a test as a main to analyze
+var DoLibs = false     //bz: analyze each library pkg without a main by a synthetic main, see ssa.CreateLibMainPackage
 var DoCoverage = false //bz: compute (#analyzed fn/#total fn) in a program within the scope
 
-var PTSLimit int   //bz: limit the size of pts; if excess, skip its solving
+var PTSLimit int   //bz: limit the size of pts; if excess, collapse it to all objects of its type
 var DoDiff = false //bz: compute the diff functions when turn on/off ptsLimit
 
+var ParallelSolve int //bz: #goroutines to solve constraints; 0 or 1: sequential
+
+var SharedPTS = false //bz: use hash-consed pts shared by the nodes with the same pts, to use less memory
+
+var PTSummary = false //bz: use the default points-to summaries of lib functions instead of analyzing them
+
+var UnsoundPTSummary = false //bz: also use the points-to summaries of fmt and encoding/json, which ignore the methods of their args
+
+var SoundUnsafe = false //bz: model unsafe.Pointer and uintptr as universal pointers, see pointer.Config.SoundUnsafe
+
+var DoRace = false //bz: check data races on the result, see go/race
+
+var DoLeak = false //bz: check goroutine leaks and channel deadlocks on the result, see go/leak
+
+var InferCallbacks = "" //bz: infer the lib functions that invoke callbacks, write them to this yml file, and use them if DoCallback
+
+var Explain = "" //bz: explain the pts of the values at this position (file:line), see pointer.ResultWCtx.Explain
+
+var Platforms = "" //bz: analyze each main on these build configurations and merge the results, see pointer.ParsePlatforms
+
 var TimeLimit time.Duration //bz: time limit set by users, unit: ?h?m?s
 
 //my use
@@ -39,12 +61,24 @@ func ParseFlags() {
 	_doLog := flag.Bool("doLog", false, "Do log. ")
 	_doDefault := flag.Bool("doDefault", false, "Do default algo only. ")
 	_doComp := flag.Bool("doCompare", false, "Do compare with default pta. ")
+	_report := flag.String("report", "", "Write a JSON report comparing with default pta to this file (implies -doCompare). ")
 	_time := flag.String("timeLimit", "", "Set time limit to ?h?m?s or ?m?s or ?s, e.g. 1h15m30.918273645s. ")
 	_doLevel := flag.Int("doLevel", -1, "Set the analysis scope to level = ? .")
 	_doCB := flag.Bool("doCallback", false, "Use simplified and synthetic callback fn + preSolve(). ")
+	_inferCB := flag.String("inferCallbacks", "", "Infer the lib functions that invoke callbacks, write them to this file in the format of callback.yml, and use them with -doCallback. ")
 	_doCollapse := flag.Bool("doCollapse", false, "Collapse the context of lib function which has callbacks. ")
 	_doTests := flag.Bool("doTests", false, "Treat a test as a main to analyze. ")
-	_pts := flag.Int("ptsLimit", 0, "Set a number to limit the size of pts during the solver, e.g. 999. ")
+	_doLibs := flag.Bool("doLibs", false, "Analyze each library package without a main by a synthetic main calling its exported functions and methods. ")
+	_pts := flag.Int("ptsLimit", 0, "Set a number to limit the size of pts during the solver, e.g. 999; a larger pts is collapsed to all objects of its type. ")
+	_parallel := flag.Int("parallelSolve", 0, "Set the number of goroutines to solve constraints, e.g. 64. ")
+	_sharedPTS := flag.Bool("sharedPTS", false, "Use hash-consed pts shared by the nodes with the same pts in the solver, which uses less memory. ")
+	_soundUnsafe := flag.Bool("soundUnsafe", false, "Model unsafe.Pointer and uintptr conversions and offset arithmetic soundly, and warn the ones cannot be modeled. ")
+	_ptSummary := flag.Bool("ptSummary", false, "Use the default points-to summaries of strings, bytes, sort, encoding/json and net/http. ")
+	_unsoundPTSummary := flag.Bool("unsoundPTSummary", false, "Also use the points-to summaries of fmt.Print*, fmt.Sprint* and json.Marshal*, which ignore the methods of their args (unsound). ")
+	_doRace := flag.Bool("doRace", false, "Check data races on the result of my pta. ")
+	_doLeak := flag.Bool("doLeak", false, "Check goroutine leaks and channel deadlocks on the result of my pta. ")
+	_explain := flag.String("explain", "", "Explain why the values at this position (file:line) point to their labels, e.g. server.go:42. ")
+	_platforms := flag.String("platforms", "", "Analyze each main on these GOOS/GOARCH[:tag+tag...] and merge the results, e.g. linux/amd64,linux/arm64:netgo. ")
 
 	//my use
 	_printCGNodes := flag.Bool("printCGNodes", false, "Print #cgnodes (before solve()).")
@@ -68,6 +102,10 @@ func ParseFlags() {
 	if *_doComp {
 		DoCompare = true
 	}
+	if *_report != "" {
+		Report = *_report
+		DoCompare = true //prerequisite of Report: must be
+	}
 	if *_time != "" {
 		TimeLimit, _ = time.ParseDuration(*_time)
 	}
@@ -78,6 +116,9 @@ func ParseFlags() {
 		DoCallback = true
 		DoLevel = 1
 	}
+	if *_inferCB != "" {
+		InferCallbacks = *_inferCB
+	}
 	if *_doCollapse {
 		DoCollapse = true
 		DoCallback = true //prerequisite of DoCollapse: must be
@@ -85,6 +126,9 @@ func ParseFlags() {
 	if *_doTests {
 		DoTests = true
 	}
+	if *_doLibs {
+		DoLibs = true
+	}
 	if *_doCoverage {
 		DoCoverage = true
 	}
@@ -92,6 +136,33 @@ func ParseFlags() {
 		PTSLimit = *_pts
 		//DoDiff = true
 	}
+	if *_parallel > 1 {
+		ParallelSolve = *_parallel
+	}
+	if *_sharedPTS {
+		SharedPTS = true
+	}
+	if *_ptSummary {
+		PTSummary = true
+	}
+	if *_unsoundPTSummary {
+		UnsoundPTSummary = true
+	}
+	if *_soundUnsafe {
+		SoundUnsafe = true
+	}
+	if *_doRace {
+		DoRace = true
+	}
+	if *_doLeak {
+		DoLeak = true
+	}
+	if *_explain != "" {
+		Explain = *_explain
+	}
+	if *_platforms != "" {
+		Platforms = *_platforms
+	}
 
 	//my use
 	if *_printCGNodes {
@@ -5,15 +5,18 @@ import (
 	"flag"
 	"fmt"
 	"github.com/april1989/origin-go-tools/go/callgraph"
+	"github.com/april1989/origin-go-tools/go/leak"
 	"github.com/april1989/origin-go-tools/go/myutil/compare"
 	"github.com/april1989/origin-go-tools/go/myutil/flags"
 	"github.com/april1989/origin-go-tools/go/packages"
 	"github.com/april1989/origin-go-tools/go/pointer"
 	default_algo "github.com/april1989/origin-go-tools/go/pointer_default"
+	"github.com/april1989/origin-go-tools/go/race"
 	"github.com/april1989/origin-go-tools/go/ssa"
 	"github.com/april1989/origin-go-tools/go/ssa/ssautil"
 	"os"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -23,6 +26,12 @@ import (
 //bz: utility functions and var declared for my use
 
 var scope []string           //bz: now extract from pkgs, or add manually for debug
+var summaries *ssa.Summaries //bz: from callback.yml, see doCallback()
+
+//bz: from flags.Platforms: the initial packages are loaded for platforms[0], and each main is also analyzed on the
+// others by loading args again, see doPlatforms()
+var platforms []pointer.Platform
+var loadArgs []string
 var excludedPkgs = []string { //bz: excluded a lot of default constraints -> only works if a.config.Level == 1 or turn on DoCallback (check a.createForLevelX() for details)
 	//"runtime",
 	//"reflect", -> only consider when turn on a.config.Reflection or analyzing tests
@@ -36,6 +45,7 @@ var excludedPkgs = []string { //bz: excluded a lot of default constraints -> onl
 var MyMaxTime time.Duration
 var MyMinTime time.Duration
 var MyElapsed int64
+var myMu sync.Mutex //bz: guards the above and the results when DoParallel
 
 var DefaultMaxTime time.Duration
 var DefaultMinTime time.Duration
@@ -54,6 +64,16 @@ func InitialMain() []*ssa.Package {
 		Dir:   "",                     // directory in which to run the build system's query tool
 		Tests: flags.DoTests,          // setting Tests will include related test packages
 	}
+	if flags.Platforms != "" {
+		var err error
+		if platforms, err = pointer.ParsePlatforms(flags.Platforms); err != nil {
+			fmt.Println(err)
+			return nil
+		}
+		loadArgs = args
+		cfg.Env = append(os.Environ(), platforms[0].Env()...)
+		cfg.BuildFlags = platforms[0].BuildFlags()
+	}
 	return initial(args, cfg)
 }
 
@@ -67,9 +87,11 @@ func InitialTest() { //default scope of tests
 }
 
 //bz: for race checker of callback branch use only, or if want to use callback
+// pointer.Reanalyze() does not support callbacks: with config.DoCallback, it analyzes from scratch (see ResultWCtx.Fallback)
 func InitialChecker(filepath string, config *pointer.Config) {
 	if config.DoCallback {
 		doCallback(filepath)
+		config.Summaries = summaries
 	}
 }
 
@@ -88,9 +110,14 @@ func doCallback(filepath string) {
 		default:
 			panic("Not defined path for OS: " + os)
 		}
-		pointer.DecodeYaml(path)
+		filepath = path
 	}else{
-		pointer.DecodeYaml(filepath + "/callback.yml")
+		filepath = filepath + "/callback.yml"
+	}
+	var err error
+	summaries, err = ssa.LoadSummaries(filepath)
+	if err != nil {
+		panic(err)
 	}
 }
 
@@ -176,6 +203,10 @@ func initial(args []string, cfg *packages.Config) []*ssa.Package {
 		scope = append(scope, "github.com/ethereum/go-ethereum")
 	}
 
+	if flags.InferCallbacks != "" {
+		inferCallbacks(prog)
+	}
+
 	mains, tests, err := findMainPackages(pkgs)
 	if err != nil {
 		fmt.Println(err)
@@ -201,6 +232,131 @@ func initial(args []string, cfg *packages.Config) []*ssa.Package {
 	return mains
 }
 
+//bz: print why each value at flags.Explain (file:line) may point to each label in its pts, in each context
+func explain(result *pointer.Result, prog *ssa.Program) {
+	i := strings.LastIndex(flags.Explain, ":")
+	if i < 0 {
+		fmt.Println("Invalid -explain " + flags.Explain + ": want file:line")
+		return
+	}
+	file := flags.Explain[:i]
+	line, err := strconv.Atoi(flags.Explain[i+1:])
+	if err != nil {
+		fmt.Println("Invalid -explain " + flags.Explain + ": want file:line")
+		return
+	}
+
+	var vals []ssa.Value
+	for v := range result.Queries {
+		if pos := prog.Fset.Position(v.Pos()); pos.Line == line && strings.HasSuffix(pos.Filename, file) {
+			vals = append(vals, v)
+		}
+	}
+	sort.Slice(vals, func(i, j int) bool { return vals[i].Pos() < vals[j].Pos() })
+	for _, v := range vals {
+		for _, p := range result.Queries[v] {
+			for _, l := range p.PointsTo().Labels() {
+				fmt.Println("Explain: " + v.Name() + " = " + v.String() + " -> " + l.String() + " (" + p.String() + ")")
+				steps, err := result.GetResult().Explain(p, l)
+				if err != nil {
+					fmt.Println("  ", err)
+					continue
+				}
+				for _, s := range steps {
+					fmt.Println("  ", prog.Fset.Position(s.Pos), s)
+				}
+			}
+		}
+	}
+	fmt.Println("#Explained values: ", len(vals))
+}
+
+//bz: analyze main on platforms[1:] with config, merge them with result (of platforms[0]), and print the calls
+// that do not hold on all platforms
+func doPlatforms(config *pointer.Config, main *ssa.Package, result *pointer.Result) {
+	results := []*pointer.Result{result}
+	for _, p := range platforms[1:] {
+		pmain, err := loadPlatform(p, main.Pkg.Path())
+		if err != nil {
+			fmt.Println("Cannot load ", main, " on ", p, ": ", err)
+			return
+		}
+		_config := *config
+		_config.Mains = []*ssa.Package{pmain}
+		r, err := pointer.Analyze(&_config)
+		if err != nil {
+			fmt.Println("Cannot analyze ", main, " on ", p, ": ", err)
+			return
+		}
+		results = append(results, r)
+	}
+
+	merged := pointer.MergeResults(platforms, results)
+	partial := merged.Partial()
+	for _, e := range partial {
+		fmt.Println("Platform-specific call: ", e)
+	}
+	fmt.Println("#Calls: ", len(merged.Edges), " (#Platform-specific: ", len(partial), ") on ", len(platforms), " platforms")
+}
+
+//bz: load loadArgs for p, and return the package with path (a main, or the libmain of a library, see findMainPackages())
+func loadPlatform(p pointer.Platform, path string) (*ssa.Package, error) {
+	cfg := &packages.Config{
+		Mode:       packages.LoadAllSyntax,
+		Tests:      flags.DoTests,
+		Env:        append(os.Environ(), p.Env()...),
+		BuildFlags: p.BuildFlags(),
+	}
+	initial, err := packages.Load(cfg, loadArgs...)
+	if err != nil {
+		return nil, err
+	}
+	prog, pkgs := ssautil.AllPackages(initial, 0)
+	prog.Build()
+	lib := strings.TrimSuffix(path, "$libmain")
+	for _, pkg := range pkgs {
+		if pkg == nil || pkg.Pkg.Path() != lib {
+			continue
+		}
+		if lib != path {
+			if libmain := prog.CreateLibMainPackage(pkg); libmain != nil {
+				return libmain, nil
+			}
+			break
+		}
+		return pkg, nil
+	}
+	return nil, fmt.Errorf("no package %s", path)
+}
+
+//bz: infer the lib functions (out of scope) that invoke callbacks, write them to flags.InferCallbacks, and use them
+// with the summaries from callback.yml, which take precedence
+func inferCallbacks(prog *ssa.Program) {
+	inferred := ssa.InferSummaries(ssautil.AllFunctions(prog), func(fn *ssa.Function) bool {
+		for _, s := range scope {
+			if strings.HasPrefix(fn.Pkg.Pkg.Path(), s) {
+				return false
+			}
+		}
+		return true
+	})
+	f, err := os.Create(flags.InferCallbacks)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	if err := inferred.WriteYaml(f); err != nil {
+		panic(err)
+	}
+	fmt.Println("Done  -- " + strconv.Itoa(inferred.Len()) + " callback functions inferred, written to " + flags.InferCallbacks)
+
+	if summaries == nil {
+		summaries = inferred
+	} else {
+		summaries.Merge(inferred)
+	}
+}
+
 // mainPackages returns the main/test packages to analyze.
 // Each resulting package is named "main" and has a main function.
 func findMainPackages(pkgs []*ssa.Package) ([]*ssa.Package, []*ssa.Package, error) {
@@ -218,6 +374,12 @@ func findMainPackages(pkgs []*ssa.Package) ([]*ssa.Package, []*ssa.Package, erro
 						tests = append(tests, p)
 					}
 				}
+			} else if flags.DoLibs && p.Pkg.Name() != "main" && len(scope) > 0 && strings.HasPrefix(p.Pkg.Path(), scope[0]) &&
+				!strings.HasSuffix(p.Pkg.Path(), ".test") && !strings.HasSuffix(p.Pkg.Path(), "_test") {
+				//bz: a library: analyze its synthetic main
+				if libmain := p.Prog.CreateLibMainPackage(p); libmain != nil {
+					mains = append(mains, libmain)
+				}
 			}
 		}
 	}
@@ -270,6 +432,22 @@ func DoSeq(mains []*ssa.Package) {
 		TrackMore:     true,                //bz: track pointers with all types
 		Level:         level,               //bz: see pointer.Config
 		DoCallback:    flags.DoCallback,    //bz: sythesize callback
+		TimeLimit:     flags.TimeLimit,     //bz: stop and return an incomplete result if out of time
+		DoCollapse:    flags.DoCollapse,
+		DoTests:       flags.DoTests,
+		DoCompare:     flags.DoCompare,
+		DoCoverage:    flags.DoCoverage,
+		DoPerformance: flags.DoPerformance,
+		PrintCGNodes:  flags.PrintCGNodes,
+		PTSLimit:      flags.PTSLimit,
+		DoDiff:        flags.DoDiff,
+		Summaries:     summaries,
+		ParallelSolve: flags.ParallelSolve,
+		SharedPTS:     flags.SharedPTS,
+		SoundUnsafe:   flags.SoundUnsafe,
+
+		DefaultPTSummaries: flags.PTSummary,
+		UnsoundPTSummaries: flags.UnsoundPTSummary,
 	}
 
 	start := time.Now()                                    //performance
@@ -332,38 +510,38 @@ func doSameRootMy(mains []*ssa.Package) *pointer.Result {
 		TrackMore:     true,                              //bz: track pointers with all types
 		Level:         0,                                 //bz: see pointer.Config
 		DoCallback:    flags.DoCallback, //bz: sythesize callback
+		TimeLimit:     flags.TimeLimit,  //bz: stop and return an incomplete result if out of time
+		DoCollapse:    flags.DoCollapse,
+		DoTests:       flags.DoTests,
+		DoCompare:     flags.DoCompare,
+		DoCoverage:    flags.DoCoverage,
+		DoPerformance: flags.DoPerformance,
+		PrintCGNodes:  flags.PrintCGNodes,
+		PTSLimit:      flags.PTSLimit,
+		DoDiff:        flags.DoDiff,
+		Summaries:     summaries,
+		ParallelSolve: flags.ParallelSolve,
+		SharedPTS:     flags.SharedPTS,
+		SoundUnsafe:   flags.SoundUnsafe,
+
+		DefaultPTSummaries: flags.PTSummary,
+		UnsoundPTSummaries: flags.UnsoundPTSummary,
 	}
 
 	//*** compute pta here
 	start := time.Now() //performance
-	var result *pointer.Result
-	var r_err error
-	if flags.TimeLimit != 0 { //we set time limit
-		c := make(chan string, 1)
-
-		// Run the pta in it's own goroutine and pass back it's
-		// response into our channel.
-		go func() {
-			result, r_err = pointer.Analyze(ptaConfig) // conduct pointer analysis
-			c <- "done"
-		}()
-
-		// Listen on our channel AND a timeout channel - which ever happens first.
-		select {
-		case res := <-c:
-			fmt.Println(res)
-		case <-time.After(flags.TimeLimit):
-			fmt.Println("\n!! Out of time (", flags.TimeLimit, "). Kill it :(")
-			return nil
-		}
-	} else {
-		result, r_err = pointer.Analyze(ptaConfig) // conduct pointer analysis
-	}
+	result, r_err := pointer.Analyze(ptaConfig) // conduct pointer analysis; ptaConfig.TimeLimit stops it if out of time
 	t := time.Now()
 	elapsed := t.Sub(start)
 	if r_err != nil {
 		panic(fmt.Sprintln(r_err))
 	}
+	if result.Incomplete {
+		fmt.Println("\n!! Out of time (", flags.TimeLimit, "). The result is incomplete, #unsound functions: ", len(result.GetResult().UnsoundFns))
+	}
+	if len(result.Collapsed) > 0 {
+		fmt.Println("\n!! PTS Limit (", flags.PTSLimit, "). The result is imprecise, #collapsed nodes: ", len(result.Collapsed))
+	}
 
 	fmt.Println("\nDone  -- PTA/CG Build; Using " + elapsed.String() + ".\n ")
 
@@ -437,6 +615,7 @@ func doSameRootDefault(mains []*ssa.Package) []*default_algo.Result {
 
 //baseline: foreach
 func DoEach(mains []*ssa.Package) {
+	var reports []*compare.Report //bz: for flags.Report
 	for i, main := range mains {
 		if flags.Main != "" && flags.Main != main.Pkg.Path() { //run for IDX only
 			continue
@@ -446,13 +625,15 @@ func DoEach(mains []*ssa.Package) {
 		var r_default *default_algo.Result
 		var r_my *pointer.ResultWCtx
 
+		var defaultTime time.Duration
 		start := time.Now() //performance
 		if flags.DoCompare || flags.DoDefault {
 			//default
 			fmt.Println("Default Algo: ")
 			r_default = doEachMainDefault(i, main) //default pta
 			t := time.Now()
-			DefaultElapsed = DefaultElapsed + t.Sub(start).Milliseconds()
+			defaultTime = t.Sub(start)
+			DefaultElapsed = DefaultElapsed + defaultTime.Milliseconds()
 			start = time.Now()
 			fmt.Println("........................................\n........................................")
 		}
@@ -465,12 +646,16 @@ func DoEach(mains []*ssa.Package) {
 		fmt.Println("My Algo: ")
 		r_my = DoEachMainMy(i, main) //mypta
 		t := time.Now()
-		MyElapsed = MyElapsed + t.Sub(start).Milliseconds()
+		myTime := t.Sub(start)
+		MyElapsed = MyElapsed + myTime.Milliseconds()
 
 		if flags.DoCompare {
 			if r_default != nil && r_my != nil {
 				start = time.Now()
 				compare.Compare(r_default, r_my)
+				if flags.Report != "" {
+					reports = append(reports, compare.NewReport(main.Pkg.Path(), r_default, r_my, defaultTime, myTime))
+				}
 				t := time.Now()
 				comp_elapsed := t.Sub(start)
 				fmt.Println("Compare Total Time: ", comp_elapsed.String()+".")
@@ -480,6 +665,14 @@ func DoEach(mains []*ssa.Package) {
 		}
 		fmt.Println("=============================================================================")
 	}
+
+	if flags.Report != "" {
+		if err := compare.WriteReports(flags.Report, reports); err != nil {
+			fmt.Println("Cannot write the report: ", err)
+		} else {
+			fmt.Println("Write the report to: ", flags.Report)
+		}
+	}
 }
 
 func DoEachMainMy(i int, main *ssa.Package) *pointer.ResultWCtx {
@@ -495,8 +688,9 @@ func DoEachMainMy(i int, main *ssa.Package) *pointer.ResultWCtx {
 		panic(fmt.Sprintln(err))
 	}
 
+	myScope := scope[:len(scope):len(scope)] //bz: do not share the appended one with other mains (DoParallel)
 	if strings.EqualFold(main.String(), "package command-line-arguments") { //default .go input
-		scope = append(scope, "command-line-arguments")
+		myScope = append(myScope, "command-line-arguments")
 	}
 
 	var mains []*ssa.Package
@@ -513,43 +707,44 @@ func DoEachMainMy(i int, main *ssa.Package) *pointer.ResultWCtx {
 		K:             1,                   //bz: how many level of origins? default = 1
 		LimitScope:    true,                //bz: only consider app methods now -> no import will be considered
 		DEBUG:         false,               //bz: rm all printed out info in console
-		Scope:         scope,               //bz: analyze scope + input path
+		Scope:         myScope,             //bz: analyze scope + input path
 		Exclusion:     excludedPkgs,        //bz: copied from race_checker if any
 		TrackMore:     true,                //bz: track pointers with types declared in Analyze Scope
 		Level:         flags.DoLevel,       //bz: see pointer.Config
 		DoCallback:    flags.DoCallback,    //bz: sythesize callback
+		TimeLimit:     flags.TimeLimit,     //bz: stop and return an incomplete result if out of time
+		DoCollapse:    flags.DoCollapse,
+		DoTests:       flags.DoTests,
+		DoCompare:     flags.DoCompare,
+		DoCoverage:    flags.DoCoverage,
+		DoPerformance: flags.DoPerformance,
+		PrintCGNodes:  flags.PrintCGNodes,
+		PTSLimit:      flags.PTSLimit,
+		DoDiff:        flags.DoDiff,
+		Summaries:     summaries,
+		ParallelSolve: flags.ParallelSolve,
+		SharedPTS:     flags.SharedPTS,
+		SoundUnsafe:   flags.SoundUnsafe,
+		Provenance:    flags.Explain != "",
+
+		DefaultPTSummaries: flags.PTSummary,
+		UnsoundPTSummaries: flags.UnsoundPTSummary,
 	}
 
 	//*** compute pta here
 	start := time.Now() //performance
-	var result *pointer.Result
-	var rErr error
-	if flags.TimeLimit != 0 { //we set time limit
-		c := make(chan string, 1)
-
-		// Run the pta in it's own goroutine and pass back it's
-		// response into our channel.
-		go func() {
-			result, rErr = pointer.Analyze(ptaConfig) // conduct pointer analysis
-			c <- "done"
-		}()
-
-		// Listen on our channel AND a timeout channel - which ever happens first.
-		select {
-		case res := <-c:
-			fmt.Println(res)
-		case <-time.After(flags.TimeLimit):
-			fmt.Println("\n!! Out of time (", flags.TimeLimit, "). Kill it :(")
-			return nil
-		}
-	} else {
-		result, rErr = pointer.Analyze(ptaConfig) // conduct pointer analysis
-	}
+	result, rErr := pointer.Analyze(ptaConfig) // conduct pointer analysis; ptaConfig.TimeLimit stops it if out of time
 	t := time.Now()
 	elapsed := t.Sub(start)
 	if rErr != nil {
 		panic(fmt.Sprintln(rErr))
 	}
+	if result.Incomplete {
+		fmt.Println("\n!! Out of time (", flags.TimeLimit, "). The result is incomplete, #unsound functions: ", len(result.GetResult().UnsoundFns))
+	}
+	if len(result.Collapsed) > 0 {
+		fmt.Println("\n!! PTS Limit (", flags.PTSLimit, "). The result is imprecise, #collapsed nodes: ", len(result.Collapsed))
+	}
 	defer logfile.Close()
 
 	if flags.DoPerformance {
@@ -565,25 +760,44 @@ func DoEachMainMy(i int, main *ssa.Package) *pointer.ResultWCtx {
 
 	fmt.Println("\nDone  -- PTA/CG Build; Using " + elapsed.String() + ".\n ")
 
+	if flags.DoRace {
+		races := race.Check(result)
+		for _, r := range races {
+			fmt.Print("Data Race: ", r, "\n\n")
+		}
+		fmt.Println("#Data Races: ", len(races))
+	}
+	if flags.DoLeak {
+		diags := leak.Check(result)
+		for _, d := range diags {
+			fmt.Print(leak.Format(main.Prog.Fset, d), "\n\n")
+		}
+		fmt.Println("#Leaks/Deadlocks: ", len(diags))
+	}
+	if flags.Explain != "" {
+		explain(result, main.Prog)
+	}
+	if len(platforms) > 1 {
+		doPlatforms(ptaConfig, main, result)
+	}
+
+	myMu.Lock()
 	if MyMaxTime < elapsed {
 		MyMaxTime = elapsed
 	}
 	if MyMinTime > elapsed {
 		MyMinTime = elapsed
 	}
+	myMu.Unlock()
 
 	if ptaConfig.DEBUG {
 		result.DumpAll()
 	}
 
-	if flags.DoCompare || flags.DoParallel {
-		_r := result.GetResult()
-		_r.Queries = result.Queries
-		_r.IndirectQueries = result.IndirectQueries
-		return _r //bz: we only need this when comparing results/run in parallel
-	}
-
-	return nil
+	_r := result.GetResult()
+	_r.Queries = result.Queries
+	_r.IndirectQueries = result.IndirectQueries
+	return _r //bz: we need this when comparing results/run in parallel/testing
 }
 
 func doEachMainDefault(i int, main *ssa.Package) *default_algo.Result {
@@ -670,11 +884,12 @@ func doEachMainDefault(i int, main *ssa.Package) *default_algo.Result {
 //baseline: all main in parallel
 //Update: fatal error: concurrent map writes!! --> change to a.track = trackall, no more panic
 //go add lock @container/intsets/util.go for nodeset when doing this setting
+//Update: all options are in pointer.Config and all states are in each analysis now, no more shared state in pointer
 func DoParallel(mains []*ssa.Package) map[*ssa.Package]*pointer.ResultWCtx {
 	ret := make(map[*ssa.Package]*pointer.ResultWCtx) //record of result
 	var _wg sync.WaitGroup
 	start := time.Now()
-	for i, main := range mains[0:3] {
+	for i, main := range mains {
 		fmt.Println("Spawn ", i, ". ", main.String())
 		_wg.Add(1)
 		go func(i int, main *ssa.Package) {
@@ -682,10 +897,12 @@ func DoParallel(mains []*ssa.Package) map[*ssa.Package]*pointer.ResultWCtx {
 			fmt.Println("My Algo: ")
 			r_my := DoEachMainMy(i, main) //mypta
 			t := time.Now()
-			MyElapsed = MyElapsed + t.Sub(start).Milliseconds()
 
 			//update
+			myMu.Lock()
+			MyElapsed = MyElapsed + t.Sub(start).Milliseconds()
 			ret[main] = r_my
+			myMu.Unlock()
 			_wg.Done()
 
 			fmt.Println("Join ", i, ". ", main.String())
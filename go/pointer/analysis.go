@@ -7,8 +7,8 @@ package pointer
 // This file defines the main datatypes and Analyze function of the pointer analysis.
 
 import (
+	"context"
 	"fmt"
-	"github.com/april1989/origin-go-tools/go/myutil/flags"
 	"go/token"
 	"go/types"
 	"io"
@@ -44,28 +44,8 @@ const (
 	otFunction             // function object
 )
 
-//bz: a set of my config
-var (
-	// optimization options; enable all when committing
-	// bz: THIS IS ORIGINALLY DECLARED IN CONST ABOVE
-	//  only turn on these two opt when flags.DoCallback == true, since its not on-the-fly but presolve
-	optRenumber = false // enable renumbering optimization (makes logs hard to read)
-	optHVN      = false // enable pointer equivalence via Hash-Value Numbering
-
-	//bz: for my performance
-	maxTime time.Duration
-	minTime time.Duration
-	total   int64
-
-	//bz: for user uses
-	main2Result     map[*ssa.Package]*Result     //bz: return value of AnalyzeMultiMains(), skip redo everytime calls Analyze()
-	main2ResultWCtx map[*ssa.Package]*ResultWCtx //bz: return value of AnalyzeMultiMains(), skip redo everytime calls Analyze()
-
-	//bz: for my use: coverage
-	allFns          map[string]string //bz: when DoCoverage = true: store all funcs within the scope/app, use map instead of array for an easier existence check
-	cCmplFns        map[string]string //bz: c/c++ compiled functions, e.g., functions in xxx.pb.go, some of these functions will nolonger be used/invoked in the app
-	showUnCoveredFn = false           //bz: whether print out those functions that we did not analyze
-)
+//bz: for my use: coverage
+const showUnCoveredFn = false //bz: whether print out those functions that we did not analyze
 
 // An object represents a contiguous block of memory to which some
 // (generalized) pointer may point.
@@ -147,6 +127,7 @@ type analysis struct {
 	cgnodes     []*cgnode                   // all cgnodes       --> bz: nodes in cg; will copy to callgraph.cg at the end
 	genq        []*cgnode                   // queue of functions to generate constraints for
 	intrinsics  map[*ssa.Function]intrinsic // non-nil values are summaries for intrinsic fns
+	ptSummaries map[string]*ptSummary       //bz: see Config.PTSummaries
 	globalval   map[ssa.Value]nodeid        // node for each global ssa.Value          ---> bz: localval/globalval: only used in valueNode() and setValueNode() for each function, will be nil.
 	localval    map[ssa.Value]nodeid        // node for each local ssa.Value           ---> bz: BUT the key will be replaced if multiple ctx exist
 	globalobj   map[ssa.Value]nodeid        // maps v to sole member of pts(v), if singleton      ---> bz: for makeclosure, fn is not enough
@@ -195,7 +176,13 @@ type analysis struct {
 	curIter int //bz: for debug, the ith iteration of the loop in preSolve() TODO: maybe move to analysis as a field
 
 	//bz: test-related
-	isMain bool //whether this analysis obj is allocated for a main? otherwise, for a test
+	isMain    bool                      //whether this analysis obj is allocated for a main? otherwise, for a test
+	entries   map[*ssa.Function]bool    //bz: Config.Entries, see entries.go
+	entryCGNs map[*ssa.Function]*cgnode //bz: the only cgnode of each entry, which has its own origin context
+
+	objectCalls map[[2]nodeid]bool //bz: the (block, receiver) of the invokes that we connect by objectCallConstraint, see context.go
+
+	unsafeWarned map[Warning]bool //bz: the warnings reported by warnUnsafe(), see unsafe.go
 
 	/** bz:
 	  we do have panics when turn on hvn optimization. panics are due to that hvn wrongly computes sccs.
@@ -206,6 +193,39 @@ type analysis struct {
 	  we ONLY record this skipTypes when optHVN is on and mark indirect in genStaticCall()
 	*/
 	skipTypes map[string]string //bz: a record of skipped methods in generate() off-line
+
+	creators []*nodeCreator //bz: if Config.recordCreators: the creator of each node created during solving, see diffSolution()
+
+	incr    *incrState        //bz: non-nil if this is a Reanalyze(): what is borrowed from the previous analysis
+	owner   *cgnode           //bz: incremental: the cgnode whose constraints are being generated; nil for global ones
+	history []ownedConstraint //bz: incremental: every constraint added so far and its owner, see Reanalyze()
+
+	demand *demandState //bz: non-nil if Config.DemandDriven
+
+	prov  map[nodeid][]provEdge //bz: non-nil if Config.Provenance: the edges through which labels flow into each node
+	cause constraint            //bz: the complex constraint being solved, for prov
+
+	ptsTable *ptsTable //bz: non-nil if Config.SharedPTS: hash-conses the pts, see ptset.go
+
+	ctx     context.Context //bz: from Config.Context/TimeLimit; nil if no time limit
+	stopped bool            //bz: whether we ran out of time
+
+	timers map[string]time.Time //bz: start time of each phase, see start() in util.go
+
+	// optimization options; enable all when committing
+	// bz: THIS IS ORIGINALLY DECLARED IN CONST ABOVE
+	//  only turn on these two opt when a.config.DoCallback == true, since its not on-the-fly but presolve
+	optRenumber bool // enable renumbering optimization (makes logs hard to read)
+	optHVN      bool // enable pointer equivalence via Hash-Value Numbering
+	optCycles   bool // enable online cycle detection (HCD and LCD) in the sequential solver if Config.Cycles, see cycles.go
+
+	//bz: to limit the size of pts, see solveLimit()
+	collapsed *collapseState //bz: non-nil if Config.PTSLimit > 0: the nodes whose pts reach the limit, see collapse.go
+	cycles    *cycleState    //bz: non-nil if optCycles: the merged cycles of the sequential solver, see cycles.go
+
+	//bz: for my use: coverage
+	allFns   map[string]string //bz: when DoCoverage = true: store all funcs within the scope/app, use map instead of array for an easier existence check
+	cCmplFns map[string]string //bz: c/c++ compiled functions, e.g., functions in xxx.pb.go, some of these functions will nolonger be used/invoked in the app
 }
 
 //bz: for callback use only
@@ -287,16 +307,17 @@ func (a *analysis) computeTrackBits() {
 	}
 }
 
-var main2Analysis map[*ssa.Package]*Result //bz: skip redo everytime calls Analyze()
-
-//bz: expose to my test.go only
-func GetMain2ResultWCtx() map[*ssa.Package]*ResultWCtx {
-	return main2ResultWCtx
+//bz: record the cost of the phase that started at *start in ResultWCtx.Phases, and restart the clock
+func (a *analysis) phase(name string, start *time.Time) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	a.result.Phases = append(a.result.Phases, Phase{Name: name, Time: time.Since(*start), HeapAlloc: mem.HeapAlloc})
+	*start = time.Now()
 }
 
 //bz: fill in the result
 func translateQueries(val ssa.Value, id nodeid, cgn *cgnode, result *Result, _result *ResultWCtx) {
-	if cgn == nil && !flags.DoCompare { //global var
+	if cgn == nil && !_result.a.config.DoCompare { //global var
 		//bz: default algo only has Queries and IndirectQueries in its result, if we want to do
 		// comparison, we need to record these GlobalQueries to Queries/IndirectQueries
 		//Update: one val can map to multiple pts,
@@ -366,6 +387,9 @@ func printConfig(config *Config) {
 		mode = "CONTEXT-INSENSITIVE"
 	}
 	fmt.Println(" *** MODE: " + mode + " *** ")
+	if config.Selector != nil {
+		fmt.Printf(" *** Context Selector: %T *** \n", config.Selector)
+	}
 	fmt.Println(" *** Level: " + strconv.Itoa(config.Level) + " *** ")
 	//bz: change to default, remove flags
 	fmt.Println(" *** Use Queries/IndirectQueries *** ")
@@ -380,23 +404,21 @@ func printConfig(config *Config) {
 	} else {
 		fmt.Println(" *** No Callback *** ")
 	}
-	if flags.DoCallback { //bz: see comments of optHVN
-		optHVN = true
-		optRenumber = true
-	} else { //turn it off for on-the-fly
-		optHVN = false
-		optRenumber = false
+	if config.DefaultPTSummaries {
+		fmt.Println(" *** Use Default Points-to Summaries *** ")
 	}
-
-	if flags.DoPerformance { //bz: this is from my main, i want them to print out
-		if optRenumber {
+	if config.UnsoundPTSummaries {
+		fmt.Println(" *** Use Unsound Points-to Summaries of fmt and encoding/json *** ")
+	}
+	if config.DemandDriven {
+		fmt.Println(" *** Demand-Driven (budget: " + strconv.Itoa(config.DemandBudget) + ") *** ")
+	}
+	if config.DoPerformance { //bz: this is from my main, i want them to print out; see comments of analysis.optHVN
+		if config.DoCallback { //optRenumber and optHVN are on
 			fmt.Println(" *** optRenumber ON *** ")
-		} else {
-			fmt.Println(" *** optRenumber OFF *** ")
-		}
-		if optHVN {
 			fmt.Println(" *** optHVN ON *** ")
 		} else {
+			fmt.Println(" *** optRenumber OFF *** ")
 			fmt.Println(" *** optHVN OFF *** ")
 		}
 	}
@@ -451,12 +473,13 @@ func AnalyzeMultiMains(config *Config) (results map[*ssa.Package]*Result, err er
 	//	panic("This API is for analyzing MULTIPLE mains. If analyzing one main, please use pointer.Analyze().")
 	//}
 
-	maxTime = 0
-	minTime = 1000000000
+	//bz: for my performance
+	var maxTime, total time.Duration
+	minTime := time.Duration(1000000000)
 
 	printConfig(config)
 
-	if flags.DoTests {
+	if config.DoTests {
 		fmt.Println(" *** Multiple Mains/Tests ********** ")
 	} else {
 		fmt.Println(" *** Multiple Mains **************** ")
@@ -470,9 +493,13 @@ func AnalyzeMultiMains(config *Config) (results map[*ssa.Package]*Result, err er
 		//bz: !! turn on reflection if includes tests requires base objs, e.g., grpc/internal/cache/TestCacheExpire
 		doReflect := config.Reflection
 		isMain := true
-		if flags.DoTests && strings.HasSuffix(main.Pkg.Path(), ".test") {
+		entries := config.Entries
+		if config.DoTests && strings.HasSuffix(main.Pkg.Path(), ".test") {
 			doReflect = true
 			isMain = false
+			if entries == nil { //bz: analyze the tests together from the root
+				entries = testEntriesOf(main)
+			}
 		}
 
 		_config := &Config{
@@ -481,7 +508,8 @@ func AnalyzeMultiMains(config *Config) (results map[*ssa.Package]*Result, err er
 			BuildCallGraph: config.BuildCallGraph,
 			Log:            config.Log,
 			//CallSiteSensitive: true, //kcfa
-			Origin: config.Origin, //origin
+			Origin:   config.Origin,   //origin
+			Selector: config.Selector, //bz: see context.go
 			//shared config
 			K:          config.K,
 			LimitScope: config.LimitScope, //bz: only consider app methods now -> no import will be considered
@@ -491,9 +519,38 @@ func AnalyzeMultiMains(config *Config) (results map[*ssa.Package]*Result, err er
 			TrackMore:  config.TrackMore,  //bz: track pointers with all types
 			DoCallback: config.DoCallback, //bz: do callback
 			Level:      config.Level,      //bz: see pointer.Config
+			Context:    config.Context,    //bz: time limit
+			TimeLimit:  config.TimeLimit,  //bz: time limit, for each main
+
+			DoCollapse:    config.DoCollapse,
+			DoTests:       config.DoTests,
+			DoCompare:     config.DoCompare,
+			DoCoverage:    config.DoCoverage,
+			DoPerformance: config.DoPerformance,
+			PrintCGNodes:  config.PrintCGNodes,
+			PTSLimit:      config.PTSLimit,
+			Summaries:     config.Summaries,
+			PTSummaries:   config.PTSummaries,
+
+			DefaultPTSummaries: config.DefaultPTSummaries,
+			UnsoundPTSummaries: config.UnsoundPTSummaries,
+
+			ParallelSolve:      config.ParallelSolve,
+			CheckParallelSolve: config.CheckParallelSolve,
+
+			DemandDriven:    config.DemandDriven,
+			DemandBudget:    config.DemandBudget,
+			Provenance:      config.Provenance,
+			SharedPTS:       config.SharedPTS,
+			SoundUnsafe:     config.SoundUnsafe,
+			Sizes:           config.Sizes,
+			Cycles:          config.Cycles,
+			Entries:         entries,
+			Queries:         config.Queries,
+			IndirectQueries: config.IndirectQueries,
 		}
 
-		if flags.DoPerformance {
+		if config.DoPerformance {
 			fmt.Println("\n\n", i, ": "+main.String(), " ... ")
 		}
 
@@ -504,7 +561,7 @@ func AnalyzeMultiMains(config *Config) (results map[*ssa.Package]*Result, err er
 			return nil, err
 		}
 
-		translateResult(_result, main)
+		config.record(main, _result, translateResult(_result, main))
 		elapse := time.Now().Sub(start)
 		if maxTime < elapse {
 			maxTime = elapse
@@ -512,7 +569,7 @@ func AnalyzeMultiMains(config *Config) (results map[*ssa.Package]*Result, err er
 		if minTime > elapse {
 			minTime = elapse
 		}
-		total = total + elapse.Milliseconds()
+		total = total + elapse
 
 		//performance
 		if doReflect { //default off
@@ -521,16 +578,17 @@ func AnalyzeMultiMains(config *Config) (results map[*ssa.Package]*Result, err er
 			fmt.Println(i, ": "+main.String(), " (use "+elapse.String()+")")
 		}
 
-		if flags.PTSLimit != 0 && flags.DoDiff {
+		if config.PTSLimit != 0 && config.DoDiff {
 			//bz: i want to see the not covered functions when turn on/off ptsLimit,
-			// so do one time analysis with ptsLimit off and compare; turn this off by setting flags.DoDiff = false
-			ptsLimit := flags.PTSLimit //store original setting
-			flags.PTSLimit = 0         //turn off
+			// so do one time analysis with ptsLimit off and compare; turn this off by setting config.DoDiff = false
+			fullConfig := *_config
+			fullConfig.PTSLimit = 0 //turn off
+			fullConfig.imports = nil
 
 			//run analysis again
 			fmt.Println("\n\n", i, ": "+main.String(), " (No PTSLimit) ... ")
 			start := time.Now()
-			fullResult, err := AnalyzeWCtx(_config, false, isMain)
+			fullResult, err := AnalyzeWCtx(&fullConfig, false, isMain)
 			if err != nil {
 				return nil, err
 			}
@@ -559,24 +617,21 @@ func AnalyzeMultiMains(config *Config) (results map[*ssa.Package]*Result, err er
 				}
 			}
 			fmt.Println("Finish. #Total: ", s, " (#WithinScope: ", in, ")")
-
-			//done comparison
-			flags.PTSLimit = ptsLimit //set it back
 		}
 	}
 	fmt.Println(" *********************************** ")
 
 	//bz: i want this...
-	fmt.Println("Total: ", (time.Duration(total)*time.Millisecond).String()+".")
+	fmt.Println("Total: ", total.String()+".")
 	fmt.Println("Max: ", maxTime.String()+".")
 	fmt.Println("Min: ", minTime.String()+".")
-	fmt.Println("Avg: ", float32(total)/float32(len(config.Mains))/float32(1000), "s.")
+	fmt.Println("Avg: ", float32(total.Milliseconds())/float32(len(config.Mains))/float32(1000), "s.")
 
-	if flags.DoCoverage { //bz: total coverage
-		computeTotalCoverage()
+	if config.DoCoverage { //bz: total coverage
+		computeTotalCoverage(config.main2ResultWCtx)
 	}
 
-	results = main2Result
+	results = config.main2Result
 	return results, nil
 }
 
@@ -596,20 +651,18 @@ func Analyze(config *Config) (result *Result, err error) {
 	}()
 
 	main := config.Mains[0] //bz: currently only handle one main
-	if result, ok := main2Result[main]; ok {
+	if result, ok := config.main2Result[main]; ok {
 		//we already done the analysis, now find and wrap the result
 		return result, nil
 	}
 
-	//setting
-	if flags.DoCallback { //bz: see comments of optHVN
-		optHVN = true
-		optRenumber = true
-	}
 	//see if i'm analyzing a main or test; reflection should be set in config already
 	isMain := true
-	if flags.DoTests && strings.HasSuffix(main.Pkg.Path(), ".test") {
+	if config.DoTests && strings.HasSuffix(main.Pkg.Path(), ".test") {
 		isMain = false
+		if config.Entries == nil { //bz: analyze the tests together from the root
+			config.Entries = testEntriesOf(main)
+		}
 	}
 	//we initially run the analysis
 	_result, err := AnalyzeWCtx(config, true, isMain)
@@ -618,6 +671,7 @@ func Analyze(config *Config) (result *Result, err error) {
 	}
 
 	result = translateResult(_result, main)
+	config.record(main, _result, result)
 	return result, nil
 }
 
@@ -639,6 +693,100 @@ func AnalyzeWCtx(config *Config, doPrintConfig bool, isMain bool) (result *Resul
 		}
 	}()
 
+	if config.ParallelSolve > 1 && config.CheckParallelSolve { //bz: test mode
+		return checkParallelSolve(config, doPrintConfig, isMain)
+	}
+
+	a := newAnalysis(config, isMain)
+
+	if false {
+		a.log = os.Stderr // for debugging crashes; extremely verbose
+	}
+
+	//if len(a.config.Mains) > 1 {
+	//	panic("This API is for analyzing ONE main. If analyzing multiple mains, please use pointer.AnalyzeMultiMains().")
+	//}
+
+	cancel, err := a.prepare()
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	if doPrintConfig {
+		printConfig(a.config)
+	}
+
+	phaseStart := time.Now() //bz: see ResultWCtx.Phases
+	a.generate()             //bz: a preprocess for reflection/runtime/import libs
+	a.phase("generate", &phaseStart)
+	a.showCounts() //bz: print out size ...
+
+	if a.optRenumber { //bz: default true
+		fmt.Println("Renumbering ...")
+		start := time.Now() //bz: i add performance
+		a.renumber()
+		elapsed := time.Now().Sub(start)
+		fmt.Println("Renumber using ", elapsed)
+		a.phase("renumber", &phaseStart)
+	}
+
+	N := len(a.nodes) // excludes solver-created nodes
+
+	if a.optHVN { //bz: default true
+		if debugHVNCrossCheck { //default : false
+			// Cross-check: run the solver once without
+			// optimization, once with, and compare the
+			// solutions.
+			savedConstraints := a.constraints
+
+			a.solve()
+			a.dumpSolution("A.pts", N)
+
+			// Restore.
+			a.constraints = savedConstraints
+			for _, n := range a.nodes {
+				n.solve = a.newSolverState()
+			}
+			a.nodes = a.nodes[:N]
+
+			// rtypes is effectively part of the solver state.
+			a.rtypes = typeutil.Map{}
+			a.rtypes.SetHasher(a.hasher)
+		}
+
+		fmt.Println("HVNing ...")
+		start := time.Now() //bz: i add performance
+		a.hvn()             //default: do this hvn
+		elapsed := time.Now().Sub(start)
+		fmt.Println("HVN using ", elapsed) //bz: i want to know how slow it is ...
+		a.phase("hvn", &phaseStart)
+	}
+
+	if debugHVNCrossCheck {
+		runtime.GC()
+		runtime.GC()
+	}
+
+	phaseStart = time.Now()
+	a.solve() //bz: officially starts here
+	a.phase("solve", &phaseStart)
+
+	// Compare solutions.
+	if a.optHVN && debugHVNCrossCheck {
+		a.dumpSolution("B.pts", N)
+
+		if !diff("A.pts", "B.pts") {
+			return nil, fmt.Errorf("internal error: optimization changed solution")
+		}
+	}
+
+	a.finish()
+	return a.result, nil
+}
+
+//bz: a new analysis of the program of config, see AnalyzeWCtx()
+func newAnalysis(config *Config, isMain bool) *analysis {
 	a := &analysis{
 		config:      config,
 		log:         config.Log,
@@ -668,50 +816,10 @@ func AnalyzeWCtx(config *Config, doPrintConfig bool, isMain bool) (result *Resul
 		cb2Callers:   make(map[*ssa.Function]*callbackRecord),
 		curIter:      0,
 		isMain:       isMain,
-	}
-
-	if false {
-		a.log = os.Stderr // for debugging crashes; extremely verbose
-	}
-
-	//if len(a.config.Mains) > 1 {
-	//	panic("This API is for analyzing ONE main. If analyzing multiple mains, please use pointer.AnalyzeMultiMains().")
-	//}
-
-	UpdateDEBUG(a.config.DEBUG) //in pointer/callgraph, print out info changes
-
-	//update analysis import
-	imports := a.config.Mains[0].Pkg.Imports()
-	if len(imports) > 0 {
-		for _, _import := range imports {
-			a.config.imports = append(a.config.imports, _import.Name())
-		}
-	}
-
-	if doPrintConfig {
-		printConfig(a.config)
-	}
-
-	if flags.DoCoverage && allFns == nil {
-		a.collectFnsWScope()
-		//for fn, _ := range allFns { //bz: debug
-		//	fmt.Println(fn)
-		//}
-		//return nil, fmt.Errorf("done")
-	}
-
-	if a.log != nil {
-		fmt.Fprintln(a.log, "==== Starting analysis and logging: ")
-	}
-
-	// Pointer analysis requires a complete program for soundness.
-	// Check to prevent accidental misconfiguration.
-	for _, pkg := range a.prog.AllPackages() {
-		// (This only checks that the package scope is complete,
-		// not that func bodies exist, but it's a good signal.)
-		if !pkg.Pkg.Complete() {
-			return nil, fmt.Errorf(`pointer analysis requires a complete program yet package %q was incomplete`, pkg.Pkg.Path())
-		}
+		//bz: see comments of optHVN
+		optHVN:      config.DoCallback,
+		optRenumber: config.DoCallback,
+		optCycles:   config.Cycles, //bz: see Config.Cycles
 	}
 
 	if reflect := a.prog.ImportedPackage("reflect"); reflect != nil {
@@ -740,65 +848,84 @@ func AnalyzeWCtx(config *Config, doPrintConfig bool, isMain bool) (result *Resul
 
 	//a.computeTrackBits() //bz: use when there is input queries before running this analysis; -> update: we do not need this. just set a.track to trackAll below
 	a.track = trackAll
+	return a
+}
 
-	a.generate()   //bz: a preprocess for reflection/runtime/import libs
-	a.showCounts() //bz: print out size ...
-
-	if optRenumber { //bz: default true
-		fmt.Println("Renumbering ...")
-		start := time.Now() //bz: i add performance
-		a.renumber()
-		elapsed := time.Now().Sub(start)
-		fmt.Println("Renumber using ", elapsed)
+//bz: check a.config and set up the analysis before generating constraints; cancel stops the timer of
+// Config.TimeLimit
+func (a *analysis) prepare() (cancel context.CancelFunc, err error) {
+	if err = a.config.checkSelector(); err != nil {
+		return nil, err
 	}
 
-	N := len(a.nodes) // excludes solver-created nodes
-
-	if optHVN { //bz: default true
-		if debugHVNCrossCheck { //default : false
-			// Cross-check: run the solver once without
-			// optimization, once with, and compare the
-			// solutions.
-			savedConstraints := a.constraints
-
-			a.solve()
-			a.dumpSolution("A.pts", N)
-
-			// Restore.
-			a.constraints = savedConstraints
-			for _, n := range a.nodes {
-				n.solve = new(solverState)
-			}
-			a.nodes = a.nodes[:N]
+	if a.ptSummaries, err = a.config.ptSummaries(); err != nil {
+		return nil, err
+	}
 
-			// rtypes is effectively part of the solver state.
-			a.rtypes = typeutil.Map{}
-			a.rtypes.SetHasher(a.hasher)
+	if a.config.Provenance { //bz: see provenance.go
+		a.prov = make(map[nodeid][]provEdge)
+	}
+	if a.config.SharedPTS { //bz: see ptset.go
+		a.ptsTable = newPTSTable()
+	}
+	if a.config.Selector != nil { //bz: see context.go
+		a.objectCalls = make(map[[2]nodeid]bool)
+	}
+	if len(a.config.Entries) > 0 { //bz: see entries.go
+		a.entries = make(map[*ssa.Function]bool)
+		a.entryCGNs = make(map[*ssa.Function]*cgnode)
+		for _, fn := range a.config.Entries {
+			a.entries[fn] = true
 		}
+	}
+	if a.config.DemandDriven { //bz: demand-driven
+		a.demand = newDemandState(a.config)
+	}
 
-		fmt.Println("HVNing ...")
-		start := time.Now() //bz: i add performance
-		a.hvn()             //default: do this hvn
-		elapsed := time.Now().Sub(start)
-		fmt.Println("HVN using ", elapsed) //bz: i want to know how slow it is ...
+	//update analysis import
+	imports := a.config.Mains[0].Pkg.Imports()
+	if len(imports) > 0 {
+		for _, _import := range imports {
+			a.config.imports = append(a.config.imports, _import.Name())
+		}
 	}
 
-	if debugHVNCrossCheck {
-		runtime.GC()
-		runtime.GC()
+	if a.config.DoCoverage {
+		a.collectFnsWScope()
+		//for fn, _ := range allFns { //bz: debug
+		//	fmt.Println(fn)
+		//}
+		//return nil, fmt.Errorf("done")
 	}
 
-	a.solve() //bz: officially starts here
+	if a.log != nil {
+		fmt.Fprintln(a.log, "==== Starting analysis and logging: ")
+	}
 
-	// Compare solutions.
-	if optHVN && debugHVNCrossCheck {
-		a.dumpSolution("B.pts", N)
+	// Pointer analysis requires a complete program for soundness.
+	// Check to prevent accidental misconfiguration.
+	for _, pkg := range a.prog.AllPackages() {
+		// (This only checks that the package scope is complete,
+		// not that func bodies exist, but it's a good signal.)
+		if !pkg.Pkg.Complete() {
+			return nil, fmt.Errorf(`pointer analysis requires a complete program yet package %q was incomplete`, pkg.Pkg.Path())
+		}
+	}
 
-		if !diff("A.pts", "B.pts") {
-			return nil, fmt.Errorf("internal error: optimization changed solution")
+	//bz: time limit
+	cancel = func() {}
+	a.ctx = a.config.Context
+	if a.config.TimeLimit > 0 {
+		if a.ctx == nil {
+			a.ctx = context.Background()
 		}
+		a.ctx, cancel = context.WithTimeout(a.ctx, a.config.TimeLimit)
 	}
+	return cancel, nil
+}
 
+//bz: build the call graph and the rest of a.result after solving
+func (a *analysis) finish() {
 	// Create callgraph.Nodes in deterministic order.
 	if cg := a.result.CallGraph; cg != nil {
 		for _, caller := range a.cgnodes {
@@ -850,7 +977,7 @@ func AnalyzeWCtx(config *Config, doPrintConfig bool, isMain bool) (result *Resul
 	a.result.CallGraph.computeFn2CGNode() //bz: update Fn2CGNode for user API
 	a.result.a = a                        //bz: update
 
-	if flags.DoPerformance { //bz: performance test; dump info
+	if a.config.DoPerformance { //bz: performance test; dump info
 		fmt.Println("--------------------- Performance ------------------------")
 		fmt.Println("#Pre-generated cgnodes: ", len(a.preGens))
 		fmt.Println("#pts: ", len(a.nodes)) //this includes all kinds of pointers, e.g., cgnode, func, pointer
@@ -869,6 +996,12 @@ func AnalyzeWCtx(config *Config, doPrintConfig bool, isMain bool) (result *Resul
 		fmt.Println("#tracked types (totol num): trackAll")   //bz: updated a.track = trackAll, skip this number
 		fmt.Println("#origins (totol num): ", a.numOrigins+1) //bz: main is not included here
 		fmt.Println("#objs (totol num): ", a.numObjs)
+		if a.incr != nil {
+			fmt.Println("#reused pts (incremental): ", a.incr.numReused)
+		}
+		if a.demand != nil {
+			fmt.Println("#active nodes (demand-driven): ", len(a.demand.active))
+		}
 		fmt.Println("\nCall Graph: (cgnode based: function + context) \n#Nodes: ", len(a.result.CallGraph.Nodes))
 		fmt.Println("#Edges: ", a.result.CallGraph.GetNumEdges())
 
@@ -877,7 +1010,7 @@ func AnalyzeWCtx(config *Config, doPrintConfig bool, isMain bool) (result *Resul
 			fmt.Println("#Callback Fn: ", len(a.cb2Callers))
 		}
 
-		if flags.DoCoverage {
+		if a.config.DoCoverage {
 			a.computeCoverage()
 		}
 
@@ -890,11 +1023,9 @@ func AnalyzeWCtx(config *Config, doPrintConfig bool, isMain bool) (result *Resul
 		//	fmt.Println(s)
 		//}
 	}
-
-	return a.result, nil
 }
 
-//bz: translate to default return value, and update main2Result
+//bz: translate to default return value
 func translateResult(_result *ResultWCtx, main *ssa.Package) *Result {
 	result := &Result{
 		Queries:         make(map[ssa.Value][]PointerWCtx),
@@ -936,17 +1067,11 @@ func translateResult(_result *ResultWCtx, main *ssa.Package) *Result {
 	}
 
 	//upate
-	if main2Result == nil {
-		main2Result = make(map[*ssa.Package]*Result)
-	}
-	main2Result[main] = result
 	result.a = _result.a
-
-	//udpate: test only
-	if main2ResultWCtx == nil {
-		main2ResultWCtx = make(map[*ssa.Package]*ResultWCtx)
-	}
-	main2ResultWCtx[main] = _result
+	result.Warnings = _result.Warnings
+	result.Incomplete = _result.Incomplete
+	result.OverBudget = _result.OverBudget
+	result.Collapsed = _result.Collapsed
 
 	//also udpate _result for new api
 	_result.Queries = result.Queries
@@ -956,6 +1081,16 @@ func translateResult(_result *ResultWCtx, main *ssa.Package) *Result {
 	return result
 }
 
+//bz: record the results of main analyzed with c, so Analyze() will not redo it
+func (c *Config) record(main *ssa.Package, _result *ResultWCtx, result *Result) {
+	if c.main2Result == nil {
+		c.main2Result = make(map[*ssa.Package]*Result)
+		c.main2ResultWCtx = make(map[*ssa.Package]*ResultWCtx)
+	}
+	c.main2Result[main] = result
+	c.main2ResultWCtx[main] = _result //udpate: test only
+}
+
 //bz: used in race_checker
 func ContainStringRelax(s []string, e string) bool {
 	for _, a := range s {
@@ -1110,7 +1245,7 @@ func (a *analysis) showCounts() {
 		fmt.Fprintf(a.log, "#ptsets:\t%d\n", len(m))
 	}
 
-	if flags.DoCallback && optHVN { //bz: add showcount to console
+	if a.config.DoCallback && a.optHVN { //bz: add showcount to console
 		counts := make(map[reflect.Type]int)
 		for _, c := range a.constraints {
 			counts[reflect.TypeOf(c)]++
@@ -1140,8 +1275,8 @@ func (a *analysis) showCounts() {
 
 //bz: when DoCoverage = true: collect all functions in the scope, stored in allFns
 func (a *analysis) collectFnsWScope() {
-	allFns = make(map[string]string)
-	cCmplFns = make(map[string]string)
+	allFns := make(map[string]string)
+	cCmplFns := make(map[string]string)
 
 	tmp := make(map[*ssa.Function]*ssa.Function)
 	for _, T := range a.prog.RuntimeTypes() {
@@ -1201,10 +1336,13 @@ func (a *analysis) collectFnsWScope() {
 
 		allFns[s] = s
 	}
+	a.allFns = allFns
+	a.cCmplFns = cCmplFns
 }
 
 //bz: when DoCoverage = true: compute (#analyzed fn/#total fn) in a program for this main
 func (a *analysis) computeCoverage() {
+	allFns, cCmplFns := a.allFns, a.cCmplFns
 	covered := make(map[string]string)
 	closure := make(map[string]string)
 	other := make(map[string]string) //others can be lib, reflect, <root>
@@ -1249,12 +1387,17 @@ func (a *analysis) computeCoverage() {
 }
 
 //bz: when DoCoverage = true: compute (#analyzed fn/#total fn) in a program for ALL mains
-func computeTotalCoverage() {
+func computeTotalCoverage(results map[*ssa.Package]*ResultWCtx) {
 	covered := make(map[string]string)
 	closure := make(map[string]string)
 	other := make(map[string]string) //others can be lib, reflect, <root>
 
-	for _, result := range main2ResultWCtx {
+	var allFns, cCmplFns map[string]string //bz: all mains share the same program and scope
+	for _, result := range results {
+		allFns, cCmplFns = result.a.allFns, result.a.cCmplFns
+		break
+	}
+	for _, result := range results {
 		for fn, _ := range result.CallGraph.Fn2CGNode {
 			s := fn.String()
 			if _, ok := allFns[s]; ok {
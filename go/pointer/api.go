@@ -6,16 +6,18 @@ package pointer
 
 import (
 	"bytes"
+	"context"
 	"fmt"
-	"github.com/april1989/origin-go-tools/container/intsets"
 	"github.com/april1989/origin-go-tools/go/callgraph"
 	"github.com/april1989/origin-go-tools/go/ssa"
 	"github.com/april1989/origin-go-tools/go/types/typeutil"
 	"go/token"
+	"go/types"
 	"io"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // A Config formulates a pointer analysis problem for Analyze. It is
@@ -75,6 +77,8 @@ type Config struct {
 	CallSiteSensitive bool
 	//bz: origin-sensitive -> go routine as origin-entry
 	Origin bool
+	//bz: the context abstraction used with CallSiteSensitive/Origin, e.g., &ObjectSelector{K: 1}; see context.go
+	Selector ContextSelector
 	//bz: shared config by context-sensitive
 	K          int      //how many level? the most recent callsite/origin?
 	LimitScope bool     //only apply kcfa to app methods
@@ -82,10 +86,82 @@ type Config struct {
 	Scope      []string //analyzed scope -> from user input: -path
 	Exclusion  []string //excluded packages from this analysis -> from race_checker if any
 	TrackMore  bool     //bz: track pointers with all types
-	DoCallback bool     //bz: we synthesize for callback fn in app
+	DoCallback bool     //bz: we synthesize for callback fn in app; Reanalyze() does not support it, see Incremental
 
 	imports []string //bz: internal use: store all import pkgs in a main
 	Level   int      //bz: level == 0: traverse all app and lib, but with different ctx; level == 1: traverse 1 level lib call; level == 2: traverse 2 leve lib calls; no other option now
+
+	//bz: options that used to be read from go/myutil/flags; each analysis has its own
+	DoCollapse    bool           //bz: collapse the lib function with its callback, no matter what are the context of caller of lib func -> DoCallback must be true
+	DoTests       bool           //bz: treat a test as a main to analyze
+	DoCompare     bool           //bz: record global queries in Queries/IndirectQueries, to compare with the default algo
+	DoCoverage    bool           //bz: compute (#analyzed fn/#total fn) in a program within the scope
+	DoPerformance bool           //bz: print out all statistics (time, number)
+	PrintCGNodes  bool           //bz: print #cgnodes (before solve())
+	PTSLimit      int            //bz: limit the size of pts; if excess, collapse it to all objects of its type, see collapse.go
+	DoDiff        bool           //bz: compute the diff functions when turn on/off PTSLimit, used by AnalyzeMultiMains()
+	Summaries     *ssa.Summaries //bz: summaries of lib functions that invoke callbacks, used by DoCallback, see ssa.LoadSummaries()
+
+	//bz: points-to summaries of lib functions, keyed by Function.String(), e.g. "bytes.TrimSpace": "r0 = p0";
+	// the analysis uses them instead of analyzing the functions, see ptsummary.go
+	PTSummaries        map[string]string
+	DefaultPTSummaries bool //bz: also use the default points-to summaries of strings, bytes, sort, encoding/json and net/http
+	UnsoundPTSummaries bool //bz: also use the unsound points-to summaries of fmt.Print*, fmt.Sprint* and json.Marshal*, which ignore the methods of their args
+
+	main2Result     map[*ssa.Package]*Result     //bz: internal use: results of Analyze()/AnalyzeMultiMains() with this config, skip redo everytime calls Analyze()
+	main2ResultWCtx map[*ssa.Package]*ResultWCtx //bz: internal use: same as above
+	analyses        []*analysis                  //bz: internal use: solved analyses of AnalyzeMultiMains() with this config, see reuse.go
+
+	//bz: incremental: keep the constraint graph after solving, so that the result can be used by Reanalyze()
+	// Reanalyze() can only reuse it for plain configs: with DoCallback (e.g., set by myutil.InitialChecker),
+	// Reflection, DemandDriven, PTSLimit, Provenance, SharedPTS, ParallelSolve, DoCompare, Log or queries,
+	// it analyzes from scratch and returns why in ResultWCtx.Fallback
+	Incremental bool
+
+	//bz: stop the analysis when Context is done (e.g., its deadline is exceeded) or after TimeLimit (if > 0),
+	// and return a partial result marked as incomplete; see ResultWCtx.Incomplete
+	Context   context.Context
+	TimeLimit time.Duration
+
+	//bz: solve with ParallelSolve goroutines if > 1, see parallel.go; CheckParallelSolve is a test mode:
+	// also solve with the sequential solver, and return an error if the solutions differ.
+	// options that need the sequential solver (e.g., Log, Reflection) ignore ParallelSolve with a warning
+	ParallelSolve      int
+	CheckParallelSolve bool
+	recordCreators     bool //bz: internal use: record who creates the nodes during solving, see diffSolution()
+
+	//bz: demand-driven: only solve the constraints needed by Queries and IndirectQueries, see demand.go;
+	// the pts of other values and the dynamic calls of the call graph may be incomplete. if a query needs more than DemandBudget (if > 0) nodes,
+	// the solver falls back to solve the whole program; see ResultWCtx.OverBudget
+	DemandDriven bool
+	DemandBudget int
+
+	//bz: record the provenance of points-to facts in the solver (sequentially), so that ResultWCtx.Explain can
+	// explain why a pointer may point to a label; see provenance.go
+	Provenance bool
+
+	//bz: use hash-consed immutable pts, which are shared by the nodes with the same pts, instead of a sparse bit
+	// set for each node; this uses less memory, but the parallel solver is off. see ptset.go
+	SharedPTS bool
+
+	//bz: the entry functions (e.g., tests, benchmarks, examples and t.Run closures, see TestEntries) analyzed
+	// together from the root, which calls the init of Mains and each entry instead of main; each entry has its
+	// own origin context, so the pointers can be queried per entry by Result.PointsToByEntry(). see entries.go
+	Entries []*ssa.Function
+
+	//bz: model unsafe.Pointer and uintptr as universal pointers: the pts flows through their conversions, (*T)(p) only
+	// keeps the objects with a layout compatible with T, and uintptr arithmetic with a constant offset (e.g.,
+	// unsafe.Offsetof) moves to the field at that offset; the ones cannot be modeled are reported in
+	// Result.Warnings. see unsafe.go
+	SoundUnsafe bool
+
+	//bz: the sizes of the analyzed platform, for the byte offsets of fields in SoundUnsafe, e.g.,
+	// types.SizesFor("gc", "arm64") or Platform.Sizes(); nil means gc on amd64
+	Sizes types.Sizes
+
+	//bz: turn on the online cycle detection (HCD and LCD) of the sequential solver, which merges the nodes in a cycle
+	// of copies; see cycles.go
+	Cycles bool
 }
 
 //bz: user API: race checker
@@ -93,6 +169,11 @@ func (c *Config) GetImports() []string {
 	return c.imports
 }
 
+//bz: expose to my test.go only
+func (c *Config) GetMain2ResultWCtx() map[*ssa.Package]*ResultWCtx {
+	return c.main2ResultWCtx
+}
+
 type track uint32
 
 const (
@@ -185,9 +266,8 @@ type Warning struct {
 //
 // bz: updated
 type Result struct {
-	a           *analysis
-	testMainCtx []*callsite      //bz: used when this is for test (a.isMain == false), in order to confirm the correct ctx as main
-	CallGraph   *callgraph.Graph // discovered call graph
+	a         *analysis
+	CallGraph *callgraph.Graph // discovered call graph
 	////bz: default
 	//Queries         map[ssa.Value]Pointer // pts(v) for each v in Config.Queries.
 	//IndirectQueries map[ssa.Value]Pointer // pts(*v) for each v in Config.IndirectQueries.
@@ -196,6 +276,9 @@ type Result struct {
 	IndirectQueries map[ssa.Value][]PointerWCtx // pts(*v) for each v in setValueNode().
 	GlobalQueries   map[ssa.Value][]PointerWCtx // pts(v) for each freevar in setValueNode(). -> bz: used by api, will not expose to users
 	Warnings        []Warning                   // warnings of unsoundness
+	Incomplete      bool                        // bz: the analysis ran out of time, see ResultWCtx.UnsoundFns/UnsoundPointers
+	OverBudget      []ssa.Value                 // bz: see ResultWCtx.OverBudget
+	Collapsed       []*CollapsedNode            // bz: see ResultWCtx.Collapsed
 }
 
 //bz: same as default , but we want contexts
@@ -212,6 +295,25 @@ type ResultWCtx struct {
 	ExtendedQueries map[ssa.Value][]PointerWCtx // not used now
 	Warnings        []Warning                   // warnings of unsoundness
 
+	//bz: if the analysis ran out of time (see Config.Context/TimeLimit), the result is incomplete and
+	// the pts of the following functions (their pointers) and pointers may be unsound
+	Incomplete      bool
+	UnsoundFns      []*ssa.Function
+	UnsoundPointers []PointerWCtx
+
+	//bz: if Config.DemandDriven, the queries that exceeded Config.DemandBudget, so the whole program was solved
+	OverBudget []ssa.Value
+
+	//bz: if Config.PTSLimit > 0, the nodes whose pts reached the limit and were collapsed to all objects of their
+	// types; their pts, and the pts of the pointers they flow into (see PointerWCtx.Imprecise), are imprecise
+	Collapsed []*CollapsedNode
+
+	Phases []Phase //bz: the cost of each phase, in order
+
+	//bz: incremental: why Reanalyze() analyzed from scratch instead of reusing the previous solution,
+	// e.g., "Config.DoCallback is on"; "" if it reused it or this is not from Reanalyze()
+	Fallback string
+
 	DEBUG bool // bz: print out debug info; used in race checker to debug
 }
 
@@ -902,6 +1004,48 @@ var (
 	intervals2 = [12]int{16, 128, 256, 512, 768, 1024, 1536, 2048, 2560, 3072, 3584, 4096}
 )
 
+//bz: the cost of a phase of the analysis: "generate" (including preSolve() if DoCallback), "renumber", "hvn" and "solve"
+type Phase struct {
+	Name      string        `json:"name"`
+	Time      time.Duration `json:"time"`
+	HeapAlloc uint64        `json:"heapAlloc"` // bytes of allocated heap objects at the end of the phase
+}
+
+//bz: a distribution of the sizes of pts, classified by the same intervals as Statistics()
+type PTSDistribution struct {
+	NumPTS    int   `json:"numPTS"`   // #non-empty pts
+	TotalPTS  int   `json:"totalPTS"` // the sum of their sizes
+	Intervals []int `json:"intervals"`
+	Counts    []int `json:"counts"` // Counts[i]: #pts with Intervals[i-1] <= size < Intervals[i]; the last one: all others
+}
+
+func NewPTSDistribution() *PTSDistribution {
+	return &PTSDistribution{Intervals: intervals1[:], Counts: make([]int, len(intervals1)+1)}
+}
+
+//bz: add a pts of size s; empty pts are not counted
+func (d *PTSDistribution) Add(s int) {
+	if s == 0 {
+		return
+	}
+	d.NumPTS++
+	d.TotalPTS += s
+	i := 0
+	for i < len(d.Intervals) && s >= d.Intervals[i] {
+		i++
+	}
+	d.Counts[i]++
+}
+
+//bz: user api: the distribution of the sizes of all non-empty pts, as Statistics()
+func (r *ResultWCtx) PTSDistribution() *PTSDistribution {
+	d := NewPTSDistribution()
+	for _, n := range r.a.nodes {
+		d.Add(n.solve.pts.Len())
+	}
+	return d
+}
+
 //bz: for my use
 func (r *Result) Statistics() {
 	fmt.Println("\nStatistics of PTS: ")
@@ -1194,58 +1338,25 @@ func (r *Result) GetMySyntheticFn(fn *ssa.Function) *ssa.Function {
 	return r.a.GetMySyntheticFn(fn)
 }
 
-//bz: user API: return a map of (fn <-> cgnode) that are Testxxx, Examplexxx, Benchmarkxxx
-// from a test analyzed by this r *Result
+//bz: user API: return a map of (fn <-> cgnode) of the entries (e.g., Testxxx, Examplexxx, Benchmarkxxx and their
+// t.Run closures) analyzed by this r *Result, see Config.Entries
 func (r *Result) GetTests() map[*ssa.Function]*cgnode {
-	if r.a.isMain {
-		fmt.Println("This result is for the main entry:", r.a.config.Mains[0], ", not for a test. Return.")
+	if len(r.a.entryCGNs) == 0 {
+		fmt.Println("This result is for the main entry:", r.a.config.Mains[0], ", not for tests. Return.")
 		return nil
 	}
 
-	a := r.a
 	result := make(map[*ssa.Function]*cgnode)
-	testSig := a.config.Mains[0].Pkg.Path() // this is xx/xx/xxx.test, we need to remove the '.test'
-	testSig = testSig[0 : len(testSig)-5]
-
-	for v, id := range a.globalobj {
-		if fn, ok := v.(*ssa.Function); ok {
-			name := fn.Name()
-			pkg := fn.String()
-			if (strings.HasPrefix(pkg, testSig) || strings.HasPrefix(pkg, "("+testSig)) && //static/non-static functions
-				(strings.HasPrefix(name, "Test") || strings.HasPrefix(name, "Benchmark") || strings.HasPrefix(name, "Example")) && // test format
-				!strings.Contains(name, "$") { //exclude closure
-				obj := a.nodes[id].obj
-				if obj == nil {
-					panic(fmt.Sprintf("nil obj of %s: %s", id, a.nodes[id]))
-				}
-				cgn := obj.cgn
-				if cgn == nil {
-					panic(fmt.Sprintf("nil cgnode in obj: %s", obj))
-				}
-				result[fn] = cgn
-			}
-		}
+	for fn, cgn := range r.a.entryCGNs {
+		result[fn] = cgn
 	}
-
 	return result
 }
 
-//bz: user API: when analyzing test only, tell which test is used as main in order to confirm the correct main context
-func (r *Result) AnalyzeTest(test *ssa.Function) {
-	if r.a.isMain {
-		panic("This result is for analyzing a main entry, not a test entry: " + test.String())
-	}
-	cg := r.a.result.CallGraph
-	cgns := cg.Fn2CGNode[test]
-	if len(cgns) > 1 {
-		fmt.Println("cgn(test) > 1: something is wrong ... ")
-	}
-	r.testMainCtx = cgns[0].callersite
-}
-
 //bz: user API: return PointerWCtx for a ssa.Value used under context of *ssa.GO,
 //input: ssa.Value, *ssa.GO
 //output: PointerWCtx; this can be empty if we cannot match any v with its goInstr
+//if goInstr is nil, the context of main (or of any entry if there is no main); see PointsToByEntry() for an entry
 func (r *Result) PointsToByGo(v ssa.Value, goInstr *ssa.Go) PointerWCtx {
 	ptss := r.a.result.pointsToFreeVar(v)
 	if ptss != nil { // global var
@@ -1268,7 +1379,7 @@ func (r *Result) PointsToByGo(v ssa.Value, goInstr *ssa.Go) PointerWCtx {
 	for _, pts := range ptss {
 		if pts.cgn.fn == v.Parent() { //many same v (ssa.Value) from different functions, separate them
 			if v.Parent().IsFromApp {
-				if pts.MatchMyContext(goInstr, r.testMainCtx) {
+				if pts.MatchMyContext(goInstr, nil) {
 					return pts
 				}
 			} else {
@@ -1313,7 +1424,7 @@ func (r *Result) PointsToByGoWithLoopID(v ssa.Value, goInstr *ssa.Go, loopID int
 	for _, pts := range ptss {
 		if pts.cgn.fn == v.Parent() { //many same v (ssa.Value) from different functions, separate them
 			if v.Parent().IsFromApp {
-				if pts.MatchMyContextWithLoopID(goInstr, loopID, r.testMainCtx) {
+				if pts.MatchMyContextWithLoopID(goInstr, loopID, nil) {
 					return pts
 				}
 			} else {
@@ -1366,8 +1477,9 @@ func (r *Result) GetFreeVarFunc(caller *ssa.Function, call *ssa.Call, goInstr *s
 	return cgn.fn
 }
 
-//bz: filter labels when returning many targets
-func (r *Result) FilterTargets(labels []*Label, ptr PointerWCtx, location ssa.Value, goInstr *ssa.Go, theIns ssa.Instruction) []*Label {
+//bz: filter labels when returning many targets; if entry is not nil (see Config.Entries), only keep the targets
+// in the context of entry
+func (r *Result) FilterTargets(labels []*Label, ptr PointerWCtx, location ssa.Value, goInstr *ssa.Go, theIns ssa.Instruction, entry *ssa.Function) []*Label {
 	var result []*Label
 	if theIns.Parent() != nil && theIns.Parent().Name() == "withRetry" {
 		fmt.Println()
@@ -1377,9 +1489,9 @@ func (r *Result) FilterTargets(labels []*Label, ptr PointerWCtx, location ssa.Va
 		add := false
 		switch label.Value().(type) {
 		case *ssa.Function:
-			if !r.a.isMain { //match test context
+			if entry != nil { //match entry context
 				cgn := label.obj.cgn
-				add = r.filterByContext(cgn)
+				add = r.filterByContext(cgn, entry)
 			}
 		case *ssa.MakeInterface:
 			switch theIns.(type) {
@@ -1393,7 +1505,7 @@ func (r *Result) FilterTargets(labels []*Label, ptr PointerWCtx, location ssa.Va
 				fn := a.prog.LookupMethod(p, method.Pkg(), method.Name())
 				cgns := a.result.CallGraph.Fn2CGNode[fn]
 				for _, cgn := range cgns {
-					if r.filterByContext(cgn) {
+					if r.filterByContext(cgn, entry) {
 						add = true
 						break
 					}
@@ -1408,7 +1520,7 @@ func (r *Result) FilterTargets(labels []*Label, ptr PointerWCtx, location ssa.Va
 				if cgn == nil {
 					continue
 				}else{
-					add = r.filterByContext(cgn)
+					add = r.filterByContext(cgn, entry)
 				}
 			}
 		default:
@@ -1424,10 +1536,13 @@ func (r *Result) FilterTargets(labels []*Label, ptr PointerWCtx, location ssa.Va
 }
 
 //bz: used by FilterTargets, true -> add this to result
-func (r *Result) filterByContext(cgn *cgnode) bool {
+func (r *Result) filterByContext(cgn *cgnode, entry *ssa.Function) bool {
+	if entry == nil {
+		return true
+	}
 	if cgn.IsSharedContour() && !r.a.withinScope(cgn.fn.String()) { // lib fn call
 		return  true
-	}else if r.testMainCtx[0].equal(cgn.callersite[0]) { //same ctx as test
+	}else if r.a.inEntryContext(cgn, entry) { //same ctx as entry
 		return true
 	}
 	return false
@@ -1519,7 +1634,7 @@ type Pointer struct {
 // A PointsToSet is a set of labels (locations or allocations).
 type PointsToSet struct {
 	a   *analysis // may be nil if pts is nil
-	pts *nodeset
+	pts ptset
 }
 
 //bz: we created an empty PointerWCTx to return
@@ -1586,7 +1701,7 @@ func (s PointsToSet) DynamicTypes() *typeutil.Map {
 				pts = PointsToSet{s.a, new(nodeset)}
 				tmap.Set(tDyn, pts)
 			}
-			pts.pts.addAll(&s.a.nodes[v].solve.pts)
+			pts.pts.addAll(s.a.nodes[v].solve.pts)
 		}
 	}
 	return &tmap
@@ -1598,10 +1713,7 @@ func (s PointsToSet) Intersects(y PointsToSet) bool {
 	if s.pts == nil || y.pts == nil {
 		return false
 	}
-	// This takes Θ(|x|+|y|) time.
-	var z intsets.Sparse
-	z.Intersection(&s.pts.Sparse, &y.pts.Sparse)
-	return !z.IsEmpty()
+	return intersectsPTS(s.pts, y.pts)
 }
 
 func (p Pointer) String() string {
@@ -1613,7 +1725,7 @@ func (p Pointer) PointsTo() PointsToSet {
 	if p.n == 0 {
 		return PointsToSet{}
 	}
-	return PointsToSet{p.a, &p.a.nodes[p.n].solve.pts}
+	return PointsToSet{p.a, p.a.nodes[p.n].solve.pts}
 }
 
 // MayAlias reports whether the receiver pointer may alias
@@ -1634,9 +1746,10 @@ type PointerWCtx struct {
 	cgn *cgnode
 }
 
-//bz: whether goID is match with the contexts in this pointer
+//bz: whether goID is match with the contexts in this pointer; if go_instr is nil, whether this is in the main
+//context of entry (see Config.Entries), or of main if entry is nil
 //TODO: this does not match parent context if callsite.length > 1 (k > 1)
-func (p PointerWCtx) MatchMyContext(go_instr *ssa.Go, testMainCtx []*callsite) bool {
+func (p PointerWCtx) MatchMyContext(go_instr *ssa.Go, entry *ssa.Function) bool {
 	if p.cgn == nil || p.cgn.callersite == nil || p.cgn.callersite[0] == nil {
 		if go_instr == nil { //shared contour ~> when using test as entry
 			return true
@@ -1644,16 +1757,7 @@ func (p PointerWCtx) MatchMyContext(go_instr *ssa.Go, testMainCtx []*callsite) b
 		return false
 	}
 	if go_instr == nil { //when wants main context
-		if p.a.isMain { //when using main as entry
-			if p.cgn.callersite[0].targets == p.a.result.main.callersite[0].targets {
-				return true
-			}
-		} else { //when using test as entry
-			if p.cgn.callersite[0].targets == testMainCtx[0].targets {
-				return true
-			}
-		}
-		return false
+		return p.a.inEntryContext(p.cgn, entry)
 	}
 
 	my_go_instr := p.cgn.callersite[0].goInstr
@@ -1679,7 +1783,7 @@ func (p PointerWCtx) MatchMyContext(go_instr *ssa.Go, testMainCtx []*callsite) b
 //bz: whether goID is match with the contexts in this pointer + loopID
 //Update: go_instr can be nil for main go routine
 //TODO: this does not match parent context if callsite.length > 1 (k > 1)
-func (p PointerWCtx) MatchMyContextWithLoopID(go_instr *ssa.Go, loopID int, testMainCtx []*callsite) bool {
+func (p PointerWCtx) MatchMyContextWithLoopID(go_instr *ssa.Go, loopID int, entry *ssa.Function) bool {
 	if p.cgn == nil || p.cgn.callersite == nil || p.cgn.callersite[0] == nil {
 		if go_instr == nil && loopID == 0 { //shared contour ~> when using test as entry
 			return true
@@ -1687,16 +1791,7 @@ func (p PointerWCtx) MatchMyContextWithLoopID(go_instr *ssa.Go, loopID int, test
 		return false
 	}
 	if go_instr == nil { //when wants main context
-		if p.a.isMain { //when using main as entry
-			if p.cgn.callersite[0].targets == p.a.result.main.callersite[0].targets {
-				return true
-			}
-		} else { //when using test as entry
-			if p.cgn.callersite[0].targets == testMainCtx[0].targets {
-				return true
-			}
-		}
-		return false
+		return p.a.inEntryContext(p.cgn, entry)
 	}
 
 	my_cs := p.cgn.callersite[0]
@@ -1774,7 +1869,19 @@ func (p PointerWCtx) PointsTo() PointsToSet {
 	if p.n == 0 {
 		return PointsToSet{}
 	}
-	return PointsToSet{p.a, &p.a.nodes[p.n].solve.pts}
+	return PointsToSet{p.a, p.a.nodes[p.n].solve.pts}
+}
+
+//bz: whether p is (a subelement of) the object of its value (see translateQueries), not a pointer: its pts is the
+// content of the object, e.g., the pts of the *ssa.Alloc of a captured variable, like enclosingObj(); the params and
+// results in the block of a function object are pointers
+func (p PointerWCtx) IsObject() bool {
+	for i := p.n; i > 0; i-- {
+		if obj := p.a.nodes[i].obj; obj != nil {
+			return i+nodeid(obj.size) > p.n && obj.flags&otFunction == 0
+		}
+	}
+	return false
 }
 
 // MayAlias reports whether the receiver pointer may alias
@@ -2,97 +2,102 @@ package pointer
 
 import (
 	"fmt"
+	"go/types"
+
 	"github.com/april1989/origin-go-tools/go/ssa"
-	"gopkg.in/yaml.v2"
-	"io/ioutil"
-	"log"
 )
 
 /*
-bz: input is callback.yml
-    analyze yml file -> users specify what is the callback function
-           they want us to analyze.
- */
-
-var (
-	debugCB    = false             //debug
-	signatures = make([]string, 0) //record of all pkg + method signatures that we have to analyze
-	//TODO: further classify to map: pkg <-> methods[] ??
-)
+bz: input is Config.Summaries, loaded from callback.yml (or native.xml/native.json) by ssa.LoadSummaries()
+    users specify what is the callback function they want us to analyze, and how the lib function invokes it;
+    genCallBack() creates a synthetic function for such a lib function instead of analyzing the lib function.
+*/
 
-type CallBack struct {
-	CallBackCfgs []CallBackCfg `yaml:"callbackcfgs"`
+//bz: a callback invoked by a lib function
+type callback struct {
+	v        ssa.Value //the arg wrapping the callback
+	targetFn ssa.Value //the callback fn, see funcParam()
+	spawn    bool      //whether the callback is invoked in a new goroutine
 }
 
-func (cb *CallBack) String() string {
-	r := ""
-	for _, cfg := range cb.CallBackCfgs {
-		r = r + "Package: " + cfg.Package + "\n"
-		for _, m := range cfg.Methods {
-			r = r + " - Method: " + m.Name + ", Receiver: " + m.Receiver + "\n"
-		}
+//bz: the summary of fn in Config.Summaries, nil if none
+func (a *analysis) summaryOf(fn *ssa.Function) *ssa.Summary {
+	if fn == nil || fn.IsMySynthetic { //my synthetic has the same string as fn
+		return nil
 	}
-	return r
-}
-
-type CallBackCfg struct {
-	Package string `yaml:"package"`
-	Methods  []Method `yaml:"method"`
+	return a.config.Summaries.Lookup(fn)
 }
 
-type Method struct {
-	Name     string `yaml:"name"`
-	Receiver string `yaml:"receiver"`
-}
-
-//bz: decode yml file, path -> absolute path, where is the callback.yml
-func DecodeYaml(path string)  {
-	cbfile, err := ioutil.ReadFile(path)
-	if err != nil {
-		log.Fatal(err)
-	}
-	cbs := CallBack{}
-	err = yaml.Unmarshal(cbfile, &cbs)
-	if err != nil {
-		log.Fatalf("Yml Decode Error: %v", err)
-	}
-
-	if debugCB {
-		fmt.Println("------------------------------\nDump callback.yml: (lib calls spawn new goroutines)\n",
-			cbs.String(), "\n------------------------------")
+//bz: the callbacks invoked by fn in call: from the summary of fn if any; otherwise the first callback
+// in the args (see hasFuncParam()) invoked synchronously
+func (a *analysis) callbacksOf(fn *ssa.Function, call *ssa.CallCommon) []*callback {
+	s := a.summaryOf(fn)
+	if s == nil {
+		if v, targetFn, ok := a.hasFuncParam(call); ok {
+			return []*callback{{v: v, targetFn: targetFn}}
+		}
+		return nil
 	}
 
-	//assemble to the string format we used in pointer pkgs
-	for _, cfg := range cbs.CallBackCfgs {
-		pkg := cfg.Package //Package
-		for _, m := range cfg.Methods {
-			fn := m.Name
-			receiver := m.Receiver
-			var sig string
-			if receiver == "nil" { //static
-				sig = pkg + "." + fn
-			}else{ //virtual
-				sig = "(*" + receiver + ")." + fn
+	var cbs []*callback
+	for _, inv := range s.Invokes {
+		if inv.Param < 0 { //any param
+			if v, targetFn, ok := a.hasFuncParam(call); ok {
+				cbs = append(cbs, &callback{v: v, targetFn: targetFn, spawn: inv.Go})
 			}
-			signatures = append(signatures, sig)
+			continue
 		}
-	}
-
-	if debugCB {
-		fmt.Println("------------------------------\nDump Signatures: (#", len(signatures), ")")
-		for i, sig := range signatures {
-			fmt.Println(i, ". ", sig)
+		i := inv.Param
+		if !call.IsInvoke() && fn.Signature.Recv() != nil {
+			i++ //static call of a method: the receiver is call.Args[0]
+		}
+		if i >= len(call.Args) { //the loader checks the params only if the summary has a descriptor
+			a.warnf(call.Pos(), "summary %s: param %d out of range in %s", s, inv.Param, call)
+			continue
+		}
+		if targetFn, ok := a.funcParam(call.Args[i]); ok {
+			cbs = append(cbs, &callback{v: call.Args[i], targetFn: targetFn, spawn: inv.Go})
 		}
-		fmt.Println("------------------------------")
 	}
+	return cbs
 }
 
-//bz: match with fn signature from callback.yml --> they have go routines spawned
-func HasGoSpawn(fn *ssa.Function) bool {
-	for _, sig := range signatures {
-		if sig == fn.String() {
-			return true
+//bz: bind result, the value of call to the summarized fn, to the results of the synthetic body of fn (summary s), in caller;
+// used when fn is not analyzed. the results of the body are checked against the signature of fn: we warn and skip the
+// results that do not match
+func (a *analysis) genSummaryResults(caller *cgnode, fn *ssa.Function, s *ssa.Summary, call *ssa.CallCommon, result nodeid) {
+	if result == 0 || len(s.Results) == 0 {
+		return
+	}
+	results := fn.Signature.Results()
+	if len(s.Results) != results.Len() {
+		a.warnf(call.Pos(), "summary %s: returns %d values, but %s has %d results", s, len(s.Results), fn, results.Len())
+		return
+	}
+
+	r := result
+	for i, res := range s.Results {
+		tr := results.At(i).Type()
+		switch {
+		case a.sizeof(tr) == 0 || res.Param == ssa.ResultNil: //no pointers
+		case res.Param == ssa.ResultNew:
+			if !isNewable(tr) {
+				a.warnf(call.Pos(), "summary %s: cannot allocate an object for result %d of type %s", s, i, tr)
+				break
+			}
+			obj := a.newSummaryObject(tr, caller, fmt.Sprintf("<r%d of %s>", i, fn))
+			a.addressOf(tr, r, obj)
+		default:
+			j := res.Param
+			if !call.IsInvoke() && fn.Signature.Recv() != nil {
+				j++ //static call of a method: the receiver is call.Args[0]
+			}
+			if j >= len(call.Args) || !types.Identical(tr, call.Args[j].Type()) {
+				a.warnf(call.Pos(), "summary %s: result %d does not match param %d", s, i, res.Param)
+				break
+			}
+			a.copy(r, a.valueNode(call.Args[j]), a.sizeof(tr))
 		}
+		r += nodeid(a.sizeof(tr))
 	}
-	return false
 }
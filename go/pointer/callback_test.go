@@ -0,0 +1,228 @@
+package pointer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/april1989/origin-go-tools/go/buildutil"
+	"github.com/april1989/origin-go-tools/go/loader"
+	"github.com/april1989/origin-go-tools/go/ssa"
+	"github.com/april1989/origin-go-tools/go/ssa/ssautil"
+)
+
+const callbackTestSummaries = `
+callbackcfgs:
+  - package: main
+    method:
+      - name: after
+        receiver: nil
+        invoke:
+          - param: 0
+            mode: go
+      - name: rng
+        receiver: (m *M)
+        invoke:
+          - param: 0
+            mode: call
+`
+
+func TestSummaries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "callback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	_, main := loadTestProgram(t, "testdata/callback.go")
+	path := filepath.Join(dir, "callback.yml")
+	if err := ioutil.WriteFile(path, []byte(callbackTestSummaries), 0644); err != nil {
+		t.Fatal(err)
+	}
+	summaries, err := ssa.LoadSummaries(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//synthetic fn -> whether it invokes its callback in a go instruction
+	want := map[string]bool{"after": true, "rng": false}
+	for _, collapse := range []bool{false, true} {
+		for _, summarize := range []bool{false, true} {
+			config := originTestConfig(main)
+			config.DoCallback = true
+			config.DoCollapse = collapse
+			if summarize {
+				config.Summaries = summaries
+			}
+			r, err := AnalyzeWCtx(config, false, true)
+			if err != nil {
+				t.Fatalf("DoCollapse = %t: %v", collapse, err)
+			}
+
+			callbacks := 0 //callbacks with callers
+			got := make(map[string]bool)
+			for cgn, n := range r.CallGraph.Nodes {
+				fn := cgn.fn
+				if fn.Parent() == main.Func("main") && len(n.In) > 0 {
+					callbacks++
+				}
+				if !fn.IsMySynthetic {
+					continue
+				}
+				for _, b := range fn.Blocks {
+					for _, instr := range b.Instrs {
+						_, isGo := instr.(*ssa.Go)
+						got[fn.Name()] = got[fn.Name()] || isGo
+					}
+				}
+			}
+
+			if !summarize {
+				if callbacks != 0 || len(got) != 0 {
+					t.Errorf("DoCollapse = %t, no summaries: got %d callbacks and synthetic fns %v, want none", collapse, callbacks, got)
+				}
+				continue
+			}
+			if callbacks != 2 {
+				t.Errorf("DoCollapse = %t: got %d called callbacks, want 2", collapse, callbacks)
+			}
+			for name, isGo := range want {
+				if g, ok := got[name]; !ok || g != isGo {
+					t.Errorf("DoCollapse = %t: synthetic %s: got (go %t, exist %t), want go %t", collapse, name, g, ok, isGo)
+				}
+			}
+		}
+	}
+}
+
+// The result of a summarized fn is bound: by analyzing after as usual, and by the synthetic
+// body of time.AfterFunc, which is excluded from the analysis (Config.Level = 1).
+const fakeTime = `
+package time
+
+type Duration int64
+
+const Second Duration = 1e9
+
+type Timer struct{ f func() }
+
+func AfterFunc(d Duration, f func()) *Timer { return nil }
+`
+
+const callbackResultTestProg = `
+package main
+
+import "time"
+
+var sink *int
+
+type T struct{ f func() }
+
+func after(f func()) *T { return &T{f: f} }
+
+func main() {
+	x := new(int)
+	tm := time.AfterFunc(time.Second, func() { sink = x })
+	t := after(func() { sink = x })
+	print(tm, t)
+}
+`
+
+const callbackResultTestSummaries = `
+callbackcfgs:
+  - package: time
+    method:
+      - name: AfterFunc
+        receiver: nil
+        descriptor: (d Duration, f func()) *Timer
+        body: go p1(); return new
+  - package: main
+    method:
+      - name: after
+        receiver: nil
+        invoke:
+          - param: 0
+            mode: go
+`
+
+func TestSummaryResults(t *testing.T) {
+	conf := loader.Config{Build: buildutil.FakeContext(map[string]map[string]string{
+		"time": {"time.go": fakeTime},
+		"main": {"main.go": callbackResultTestProg},
+	})}
+	conf.Import("main")
+	iprog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog := ssautil.CreateProgram(iprog, 0)
+	prog.Build()
+	main := prog.Package(iprog.Imported["main"].Pkg)
+
+	dir, err := ioutil.TempDir("", "callback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "callback.yml")
+	if err := ioutil.WriteFile(path, []byte(callbackResultTestSummaries), 0644); err != nil {
+		t.Fatal(err)
+	}
+	summaries, err := ssa.LoadSummaries(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := originTestConfig(main)
+	config.DoCallback = true
+	config.Level = 1
+	config.Summaries = summaries
+	r, err := AnalyzeWCtx(config, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Warnings) != 0 {
+		t.Errorf("got warnings %v", r.Warnings)
+	}
+	sol := solution(r)
+	for _, test := range []struct {
+		call, want string
+	}{
+		{"time.AfterFunc(1000000000:time.Duration, t2)", "<r0 of time.AfterFunc>"},
+		{"after(t4)", "complit"},
+	} {
+		var got string
+		for k, pts := range sol {
+			if strings.HasPrefix(k, "main.main@") && strings.HasSuffix(k, " = "+test.call) {
+				got = pts
+			}
+		}
+		if !strings.HasPrefix(got, test.want) {
+			t.Errorf("pts(%s) = {%s}, want {%s...}", test.call, got, test.want)
+		}
+	}
+	called := make(map[string]bool) //non-synthetic fn -> whether it has callers
+	for cgn, n := range r.CallGraph.Nodes {
+		if !cgn.fn.IsMySynthetic {
+			called[cgn.fn.String()] = called[cgn.fn.String()] || len(n.In) > 0
+		}
+	}
+	for _, fn := range []string{"main.after", "main.main$1", "main.main$2"} {
+		if !called[fn] {
+			t.Errorf("%s is not called", fn)
+		}
+	}
+
+	//a summary without descriptor is not checked by the loader
+	summaries = ssa.NewSummaries()
+	summaries.Add(&ssa.Summary{Package: "main", Name: "after", Invokes: []ssa.Invoke{{Param: 3, Go: true}}})
+	config.Summaries = summaries
+	r, err = AnalyzeWCtx(config, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Warnings) != 1 || !strings.Contains(r.Warnings[0].Message, "param 3 out of range") {
+		t.Errorf("got warnings %v, want one for main.after", r.Warnings)
+	}
+}
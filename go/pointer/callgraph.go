@@ -10,23 +10,11 @@ import (
 	"fmt"
 	"go/token"
 	"strconv"
+	"strings"
 
 	"github.com/april1989/origin-go-tools/go/ssa"
 )
 
-var mainID nodeid //bz: record the target of root call to main method ID, NO REAL use, convenient for debug
-var DEBUG bool    //bz: do we debug ...
-
-//bz: update mainID, since opt.go will renumbering everything and change this
-func UpdateMainID(newid nodeid) {
-	mainID = newid
-}
-
-//bz: update DEBUG, whether we use mainID and UpdateMainID() in contourkFull()
-func UpdateDEBUG(debug bool) {
-	DEBUG = debug
-}
-
 //bz: this is the cgnode created by pointer analysis -> we force to use k callersite
 type cgnode struct {
 	fn         *ssa.Function
@@ -43,6 +31,8 @@ type cgnode struct {
 
 	localval map[ssa.Value]nodeid //bz: going to store things here and take away to get rid of queries
 	localobj map[ssa.Value]nodeid //bz: same as above
+
+	selected []string //bz: the context selected by Config.Selector, see context.go
 }
 
 //bz: my test use only
@@ -141,13 +131,10 @@ func contourK(n *cgnode, callersite []*callsite) string {
 		}
 		if n.fn.String() == "command-line-arguments.main" { //bz: the ctx is "called to synthetic/intrinsic func@n?"; which is root node calling to main.main
 			s = s + strconv.Itoa(idx) + ":root call to command-line-arguments.main; "
-			if DEBUG && mainID == 0 { //bz: initial just once; this has NO REAL use, just convenient for debug
-				mainID = cs.targets
-			}
 			continue
 		}
-		if DEBUG && cs.targets == mainID { //bz: same as above
-			s = s + strconv.Itoa(idx) + ":root call to command-line-arguments.main; "
+		if cs.main != nil { //bz: same as above, the root call to main in the ctx of all fns reached from main
+			s = s + strconv.Itoa(idx) + ":root call to " + cs.main.String() + "; "
 			continue
 		}
 		s = s + strconv.Itoa(idx) + ":" + cs.String() + "; " //":" + "called to synthetic func@" + cs.targets.String() + "; " //func id + cgnode id
@@ -161,6 +148,9 @@ func (n *cgnode) contourkFull() string {
 	s = s + "["
 	s = s + contourK(n, n.callersite)
 	s = s + "]"
+	if len(n.selected) > 0 { //bz: see context.go
+		s = s + "[" + strings.Join(n.selected, "; ") + "]"
+	}
 	return s
 }
 
@@ -189,6 +179,7 @@ type callsite struct {
 	instr   ssa.CallInstruction // the call instruction; nil for synthetic/intrinsic
 	loopID  int                 // bz: origin -> loop id, value is 1 or 2; 0 is default value and means no loop TODO: how to get rid of this in other contexts?
 	goInstr *ssa.Go             // TODO: bz: do we add this to match goID in race_checker ??
+	main    *ssa.Function       // bz: the main fn if this is the root call to it, see genRootCalls(); renumber() keeps it, unlike the old mainID
 }
 
 //bz: user api: race checke uses
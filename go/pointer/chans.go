@@ -0,0 +1,288 @@
+package pointer
+
+// This file defines the channel communication graph: the channel operations of each origin and the make(chan)
+// allocation sites they may operate on.
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
+)
+
+// A ChanOpKind is the kind of a channel operation.
+type ChanOpKind int
+
+const (
+	ChanSend ChanOpKind = iota
+	ChanRecv
+	ChanClose
+)
+
+func (k ChanOpKind) String() string {
+	switch k {
+	case ChanSend:
+		return "send"
+	case ChanRecv:
+		return "recv"
+	}
+	return "close"
+}
+
+// A ChanOp is a channel operation in an origin: a send, receive, close, or a case of a select.
+type ChanOp struct {
+	Kind     ChanOpKind
+	Instr    ssa.Instruction // *ssa.Send, *ssa.UnOp (<-ch), *ssa.Select, or the *ssa.Call of close
+	Index    int             // the index of the case in the *ssa.Select; -1 if Instr is not a select
+	Chan     ssa.Value       // the channel
+	Origin   *Origin
+	Channels []*Channel // the channels that Chan may point to
+}
+
+// Pos returns the position of op.
+func (op *ChanOp) Pos() token.Pos {
+	if sel, ok := op.Instr.(*ssa.Select); ok {
+		return sel.States[op.Index].Pos
+	}
+	return op.Instr.Pos()
+}
+
+func (op *ChanOp) String() string {
+	return fmt.Sprintf("%s %s in origin %s", op.Kind, op.Chan.Name(), op.Origin)
+}
+
+// A Channel is an allocation site of channels (*ssa.MakeChan) with its heap context.
+type Channel struct {
+	ID    int
+	Site  *ssa.MakeChan
+	Alloc *AllocSite
+	Ops   []*ChanOp // the operations that may operate on this channel
+	obj   nodeid
+}
+
+func (c *Channel) String() string {
+	return fmt.Sprintf("%s@%s", c.Site, c.Site.Parent())
+}
+
+// Senders returns the send and close operations on c.
+func (c *Channel) Senders() []*ChanOp {
+	var ops []*ChanOp
+	for _, op := range c.Ops {
+		if op.Kind != ChanRecv {
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
+
+// Receivers returns the receive operations on c.
+func (c *Channel) Receivers() []*ChanOp {
+	var ops []*ChanOp
+	for _, op := range c.Ops {
+		if op.Kind == ChanRecv {
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
+
+// A ChanEdge connects two origins that communicate through a channel: From sends to or closes the channel,
+// and To receives from it.
+type ChanEdge struct {
+	From, To *Origin
+	Channel  *Channel
+}
+
+// A ChanGraph is the channel communication graph of a program.
+type ChanGraph struct {
+	Origins  *OriginGraph
+	Channels []*Channel
+	Ops      []*ChanOp
+	Edges    []*ChanEdge // between two different origins
+	prog     *ssa.Program
+}
+
+// ChanGraph returns the channel communication graph of r, which requires Config.BuildCallGraph: every channel
+// operation in the functions reachable in each origin is matched to the channels its operand may point to.
+func (r *ResultWCtx) ChanGraph() *ChanGraph {
+	a := r.a
+	g := &ChanGraph{Origins: r.OriginGraph(), prog: a.prog}
+	channels := make(map[nodeid]*Channel)
+	addOp := func(op *ChanOp, cgn *cgnode) {
+		g.Ops = append(g.Ops, op)
+		id, ok := cgn.localval[op.Chan]
+		if !ok {
+			id = a.globalval[op.Chan]
+		}
+		if id == 0 {
+			return
+		}
+		var space [50]int
+		for _, o := range a.nodes[id].solve.pts.AppendTo(space[:0]) {
+			obj := a.nodes[o].obj
+			if obj == nil {
+				continue
+			}
+			site, ok := obj.data.(*ssa.MakeChan)
+			if !ok {
+				continue
+			}
+			c, ok := channels[nodeid(o)]
+			if !ok {
+				c = &Channel{ID: len(g.Channels), Site: site, obj: nodeid(o)}
+				if obj.cgn != nil {
+					c.Alloc = &AllocSite{Fn: obj.cgn.fn, Ctx: obj.cgn.callersite}
+				}
+				channels[nodeid(o)] = c
+				g.Channels = append(g.Channels, c)
+			}
+			c.Ops = append(c.Ops, op)
+			op.Channels = append(op.Channels, c)
+		}
+	}
+
+	for _, o := range g.Origins.Origins {
+		for _, n := range o.Nodes {
+			for _, b := range n.cgn.fn.Blocks {
+				for _, instr := range b.Instrs {
+					switch instr := instr.(type) {
+					case *ssa.Send:
+						addOp(&ChanOp{Kind: ChanSend, Instr: instr, Index: -1, Chan: instr.Chan, Origin: o}, n.cgn)
+					case *ssa.UnOp:
+						if instr.Op == token.ARROW {
+							addOp(&ChanOp{Kind: ChanRecv, Instr: instr, Index: -1, Chan: instr.X, Origin: o}, n.cgn)
+						}
+					case *ssa.Select:
+						for i, state := range instr.States {
+							kind := ChanRecv
+							if state.Dir == types.SendOnly {
+								kind = ChanSend
+							}
+							addOp(&ChanOp{Kind: kind, Instr: instr, Index: i, Chan: state.Chan, Origin: o}, n.cgn)
+						}
+					case ssa.CallInstruction:
+						common := instr.Common()
+						if fn, ok := common.Value.(*ssa.Builtin); ok && fn.Name() == "close" && len(common.Args) == 1 {
+							addOp(&ChanOp{Kind: ChanClose, Instr: instr, Index: -1, Chan: common.Args[0], Origin: o}, n.cgn)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	type edgeKey struct {
+		from, to *Origin
+		c        *Channel
+	}
+	seen := make(map[edgeKey]bool)
+	for _, c := range g.Channels {
+		for _, s := range c.Senders() {
+			for _, r := range c.Receivers() {
+				key := edgeKey{s.Origin, r.Origin, c}
+				if s.Origin != r.Origin && !seen[key] {
+					seen[key] = true
+					g.Edges = append(g.Edges, &ChanEdge{s.Origin, r.Origin, c})
+				}
+			}
+		}
+	}
+	return g
+}
+
+// ChanGraph returns the channel communication graph of r, see ResultWCtx.ChanGraph.
+func (r *Result) ChanGraph() *ChanGraph {
+	return r.a.result.ChanGraph()
+}
+
+type jsonChanOp struct {
+	Kind     string `json:"kind"`
+	Pos      string `json:"pos"`
+	Origin   int    `json:"origin"`
+	Channels []int  `json:"channels,omitempty"`
+}
+
+type jsonChannel struct {
+	ID   int      `json:"id"`
+	Site string   `json:"site"`
+	Pos  string   `json:"pos"`
+	Ctx  []string `json:"ctx,omitempty"` // the context of the allocation
+}
+
+type jsonChanEdge struct {
+	From    int `json:"from"`
+	To      int `json:"to"`
+	Channel int `json:"channel"`
+}
+
+type jsonChanGraph struct {
+	Channels []jsonChannel  `json:"channels"`
+	Ops      []jsonChanOp   `json:"ops"`
+	Edges    []jsonChanEdge `json:"edges"`
+}
+
+// WriteJSON writes g to w as JSON: the channels, the operations and the edges; origins are referred by their IDs
+// in g.Origins, see OriginGraph.WriteJSON.
+func (g *ChanGraph) WriteJSON(w io.Writer) error {
+	var jg jsonChanGraph
+	for _, c := range g.Channels {
+		jc := jsonChannel{ID: c.ID, Site: c.String(), Pos: g.prog.Fset.Position(c.Site.Pos()).String()}
+		if c.Alloc != nil {
+			for _, cs := range c.Alloc.Ctx {
+				if cs != nil {
+					jc.Ctx = append(jc.Ctx, cs.String())
+				}
+			}
+		}
+		jg.Channels = append(jg.Channels, jc)
+	}
+	for _, op := range g.Ops {
+		jo := jsonChanOp{Kind: op.Kind.String(), Pos: g.prog.Fset.Position(op.Pos()).String(), Origin: op.Origin.ID}
+		for _, c := range op.Channels {
+			jo.Channels = append(jo.Channels, c.ID)
+		}
+		jg.Ops = append(jg.Ops, jo)
+	}
+	for _, e := range g.Edges {
+		jg.Edges = append(jg.Edges, jsonChanEdge{e.From.ID, e.To.ID, e.Channel.ID})
+	}
+	data, err := json.MarshalIndent(jg, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// WriteDOT writes g to w in the DOT format of graphviz: a node is an origin, and an edge from a sender to a
+// receiver is labeled with the channels.
+func (g *ChanGraph) WriteDOT(w io.Writer) error {
+	var buf strings.Builder
+	buf.WriteString("digraph channels {\n\tnode [shape=box];\n")
+	for _, o := range g.Origins.Origins {
+		fmt.Fprintf(&buf, "\to%d [label=%q];\n", o.ID, o.String())
+	}
+	labels := make(map[[2]int][]string)
+	var keys [][2]int
+	for _, e := range g.Edges {
+		key := [2]int{e.From.ID, e.To.ID}
+		if labels[key] == nil {
+			keys = append(keys, key)
+		}
+		labels[key] = append(labels[key], fmt.Sprintf("%s %s", e.Channel.Site.Name(), g.prog.Fset.Position(e.Channel.Site.Pos())))
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i][0] < keys[j][0] || keys[i][0] == keys[j][0] && keys[i][1] < keys[j][1]
+	})
+	for _, key := range keys {
+		fmt.Fprintf(&buf, "\to%d -> o%d [label=%q];\n", key[0], key[1], strings.Join(labels[key], "\n"))
+	}
+	buf.WriteString("}\n")
+	_, err := io.WriteString(w, buf.String())
+	return err
+}
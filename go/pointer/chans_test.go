@@ -0,0 +1,74 @@
+package pointer
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestChanGraph(t *testing.T) {
+	_, main := loadTestProgram(t, "testdata/chans.go")
+
+	result, err := Analyze(originTestConfig(main))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := result.ChanGraph()
+
+	if len(g.Origins.Origins) != 3 {
+		t.Fatalf("#origins = %d, want 3", len(g.Origins.Origins))
+	}
+	sites := make(map[string][]*Channel) //by function and element type
+	for _, c := range g.Channels {
+		key := c.Site.Parent().Name() + " " + c.Site.Type().String()
+		sites[key] = append(sites[key], c)
+	}
+	if n := len(sites["mk chan int"]); n != 2 {
+		t.Errorf("#channels of mk = %d, want 2 (one per origin)", n)
+	}
+	for _, c := range sites["mk chan int"] {
+		if len(c.Senders()) != 1 || c.Alloc == nil || c.Alloc.Fn.Name() != "mk" {
+			t.Errorf("channel %s: senders %v, alloc %v", c, c.Senders(), c.Alloc)
+		}
+	}
+
+	ch, done := sites["main chan int"], sites["main chan bool"]
+	if len(ch) != 1 || len(done) != 1 {
+		t.Fatalf("channels of main = %v", g.Channels)
+	}
+	if s, r := len(ch[0].Senders()), len(ch[0].Receivers()); s != 1 || r != 2 {
+		t.Errorf("ch: %d senders, %d receivers, want 1 and 2", s, r)
+	}
+	if s, r := done[0].Senders(), done[0].Receivers(); len(s) != 1 || s[0].Kind != ChanClose || len(r) != 1 || r[0].Index != 1 {
+		t.Errorf("done: senders %v, receivers %v", s, r)
+	}
+
+	//ch: goroutine 1 -> main, goroutine 1 -> goroutine 2; done: main -> goroutine 2
+	edges := make(map[string]bool)
+	for _, e := range g.Edges {
+		edges[e.From.String()+" -> "+e.To.String()+" "+e.Channel.Site.Type().String()] = true
+	}
+	if len(edges) != 3 || !edges["main -> "+done[0].Ops[1].Origin.String()+" chan bool"] {
+		t.Errorf("edges = %v", edges)
+	}
+
+	var buf bytes.Buffer
+	if err := g.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var jg jsonChanGraph
+	if err := json.Unmarshal(buf.Bytes(), &jg); err != nil {
+		t.Fatal(err)
+	}
+	if len(jg.Channels) != len(g.Channels) || len(jg.Ops) != len(g.Ops) || len(jg.Edges) != 3 {
+		t.Errorf("JSON: %s", buf.String())
+	}
+	buf.Reset()
+	if err := g.WriteDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if dot := buf.String(); !strings.Contains(dot, "o0 -> o2") || !strings.Contains(dot, "t1 ") {
+		t.Errorf("DOT:\n%s", dot)
+	}
+}
@@ -0,0 +1,201 @@
+package pointer
+
+// This file collapses the nodes whose pts exceed Config.PTSLimit to a type-based abstraction, and records which
+// parts of the result are imprecise.
+
+import (
+	"fmt"
+	"go/types"
+	"sort"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
+	"github.com/april1989/origin-go-tools/go/types/typeutil"
+)
+
+/*
+bz: when the pts of a node reaches Config.PTSLimit, solveLimit() collapses the node: it adds all the labels of
+  the objects compatible with the type of the node (e.g., all objects of T for a *T, all tagged objects whose dynamic
+  types implement I for an I) to its pts, and keeps solving it. the objects created later are added to the pts of
+  the collapsed nodes in the same way, so the result stays sound. the pts of a collapsed node, and of every node
+  that its labels may flow into, are imprecise; they are reported by ResultWCtx.Collapsed and
+  PointerWCtx.Imprecise().
+*/
+
+// A CollapsedNode is a node whose pts reached Config.PTSLimit, and was collapsed to all the objects of its type.
+type CollapsedNode struct {
+	Pointer PointerWCtx // the pointer of the node if it is (a part of) a value; Pointer.PointsTo() is its pts
+	Object  *Label      // the object (field) of the node if it is in an object
+	Value   ssa.Value   // the value of the node if it is (a part of) a value; both are nil for a temporary node
+	Type    types.Type  // the type of the node
+	Size    int         // the size of its pts when it was collapsed
+}
+
+func (c *CollapsedNode) String() string {
+	if c.Value != nil {
+		return fmt.Sprintf("%s = %s : %s (%d labels)", c.Value.Name(), c.Value, c.Type, c.Size)
+	}
+	if c.Object != nil {
+		return fmt.Sprintf("%s : %s (%d labels)", c.Object, c.Type, c.Size)
+	}
+	return fmt.Sprintf("temporary : %s (%d labels)", c.Type, c.Size)
+}
+
+//bz: the collapsed nodes of a type, with the labels compatible with the type
+type collapseGroup struct {
+	labels nodeset
+	nodes  []nodeid
+}
+
+type collapseState struct {
+	groups    typeutil.Map   // types.Type -> *collapseGroup
+	sizes     map[nodeid]int // collapsed node -> the size of its pts when it was collapsed
+	scanned   nodeid         // the labels in nodes[:scanned] are in the groups
+	imprecise nodeset        // the nodes that the labels of collapsed nodes may flow into, see collectImprecise()
+}
+
+func newCollapseState(a *analysis) *collapseState {
+	s := &collapseState{sizes: make(map[nodeid]int), scanned: nodeid(len(a.nodes))}
+	s.groups.SetHasher(a.hasher)
+	return s
+}
+
+//bz: whether a node of type T may point to label l, the node at l being in an object
+func (a *analysis) compatible(T types.Type, l nodeid) bool {
+	n := a.nodes[l]
+	switch T := T.Underlying().(type) {
+	case *types.Pointer:
+		return sameUnderlying(n.typ, T.Elem())
+	case *types.Slice:
+		arr, ok := n.typ.Underlying().(*types.Array) //see sliceToArray()
+		return ok && sameUnderlying(arr.Elem(), T.Elem())
+	case *types.Map:
+		return n.obj != nil && sameUnderlying(n.typ, T.Key())
+	case *types.Chan:
+		return n.obj != nil && sameUnderlying(n.typ, T.Elem())
+	case *types.Signature:
+		return n.obj != nil && n.obj.flags&otFunction != 0 && types.Identical(n.typ, T)
+	case *types.Interface:
+		return n.obj != nil && n.obj.flags&otTagged != 0 && types.AssignableTo(n.typ, T)
+	case *types.Basic:
+		return n.obj != nil && T.Kind() == types.UnsafePointer
+	}
+	return false
+}
+
+//bz: the nodes of an object have the underlying types of its named types, see flatten()
+func sameUnderlying(x, y types.Type) bool {
+	return types.Identical(x.Underlying(), y.Underlying())
+}
+
+//bz: the labels compatible with T in nodes[from:to]
+func (a *analysis) scanLabels(T types.Type, from, to nodeid) []nodeid {
+	var labels []nodeid
+	var end nodeid //the end of the object of l
+	if obj, ok := a.containingObj(from); ok {
+		end = obj + nodeid(a.nodes[obj].obj.size)
+	}
+	for l := from; l < to; l++ {
+		if o := a.nodes[l].obj; o != nil {
+			end = l + nodeid(o.size)
+		}
+		if l < end && a.compatible(T, l) {
+			labels = append(labels, l)
+		}
+	}
+	return labels
+}
+
+//bz: collapse node id, whose pts reaches the limit
+func (a *analysis) collapse(id nodeid) {
+	s := a.collapsed
+	n := a.nodes[id]
+	s.sizes[id] = n.solve.pts.Len()
+	g, _ := s.groups.At(n.typ).(*collapseGroup)
+	if g == nil {
+		g = new(collapseGroup)
+		for _, l := range a.scanLabels(n.typ, 1, s.scanned) {
+			g.labels.add(l)
+		}
+		s.groups.Set(n.typ, g)
+	}
+	g.nodes = append(g.nodes, id)
+	var space [50]int
+	for _, l := range g.labels.AppendTo(space[:0]) {
+		a.addLabel(id, nodeid(l))
+	}
+	if a.log != nil {
+		fmt.Fprintf(a.log, "\t\tcollapse n%d : %s to %d labels\n", id, n.typ, n.solve.pts.Len())
+	}
+}
+
+//bz: add the labels of the objects created since the last scan to the pts of the collapsed nodes
+func (a *analysis) collapseNewNodes() {
+	s := a.collapsed
+	to := nodeid(len(a.nodes))
+	if s.scanned == to {
+		return
+	}
+	s.groups.Iterate(func(T types.Type, v interface{}) {
+		g := v.(*collapseGroup)
+		for _, l := range a.scanLabels(T, s.scanned, to) {
+			g.labels.add(l)
+			for _, id := range g.nodes {
+				if a.addLabel(id, l) {
+					a.addWork(id)
+				}
+			}
+		}
+	})
+	s.scanned = to
+}
+
+//bz: called by solve() before releasing the working state: report the collapsed nodes, and compute the nodes that
+// their labels may flow into: the nodes reachable from them in the constraint graph, and the fields that a store
+// through them may write to. if we cannot follow a constraint, everything is imprecise.
+func (a *analysis) collectImprecise() {
+	s := a.collapsed
+	vals := a.nodeValues()
+	var roots []nodeid
+	for id := range s.sizes {
+		roots = append(roots, id)
+	}
+	sort.Slice(roots, func(i, j int) bool { return roots[i] < roots[j] })
+	for _, id := range roots {
+		c := &CollapsedNode{Type: a.nodes[id].typ, Size: s.sizes[id]}
+		if val, ok := vals[id]; ok {
+			c.Pointer = PointerWCtx{a, id, val.cgn}
+			c.Value = val.v
+		} else if _, ok := a.containingObj(id); ok {
+			c.Object = a.labelFor(id)
+		}
+		a.result.Collapsed = append(a.result.Collapsed, c)
+	}
+
+	var space [50]int
+	for len(roots) > 0 {
+		if !a.forwardReach(roots, &s.imprecise) {
+			for i := range a.nodes {
+				s.imprecise.add(nodeid(i))
+			}
+			return
+		}
+		roots = nil
+		for _, x := range s.imprecise.AppendTo(nil) {
+			for _, c := range a.nodes[x].solve.complex {
+				if c, ok := c.(*storeConstraint); ok {
+					for _, k := range a.nodes[x].solve.pts.AppendTo(space[:0]) {
+						if koff := nodeid(k) + nodeid(c.offset); !s.imprecise.Has(int(koff)) {
+							roots = append(roots, koff)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// Imprecise reports whether the pts of p may include labels from a node collapsed by Config.PTSLimit, so it may be
+// imprecise (but not unsound).
+func (p PointerWCtx) Imprecise() bool {
+	return p.a != nil && p.a.collapsed != nil && p.a.collapsed.imprecise.Has(int(p.n))
+}
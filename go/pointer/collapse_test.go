@@ -0,0 +1,72 @@
+package pointer
+
+import (
+	"testing"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
+)
+
+func TestCollapse(t *testing.T) {
+	_, main := loadTestProgram(t, "testdata/collapse.go")
+
+	var p, u, i ssa.Value
+	for _, b := range main.Func("main").Blocks {
+		for _, instr := range b.Instrs {
+			switch instr := instr.(type) {
+			case *ssa.UnOp:
+				if _, ok := instr.X.(*ssa.IndexAddr); ok {
+					p = instr
+				}
+			case *ssa.Alloc:
+				switch instr.Comment {
+				case "complit":
+					u = instr //the last complit of T
+				case "new":
+					i = instr
+				}
+			}
+		}
+	}
+	if p == nil || u == nil || i == nil {
+		t.Fatalf("no p, u or i in %s", main.Func("main"))
+	}
+
+	result, err := Analyze(originTestConfig(main))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ptr := result.PointsToByGoWithLoopID(p, nil, 0)
+	if n := len(ptr.PointsTo().Labels()); n != 3 || ptr.Imprecise() || len(result.Collapsed) != 0 {
+		t.Fatalf("no limit: pts(p) has %d labels, imprecise %t, collapsed %v", n, ptr.Imprecise(), result.Collapsed)
+	}
+
+	config := originTestConfig(main)
+	config.PTSLimit = 3
+	if result, err = Analyze(config); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Collapsed) == 0 {
+		t.Fatal("no collapsed node")
+	}
+	for _, c := range result.Collapsed {
+		if c.Size < 3 || c.Type.String() != "*main.T" {
+			t.Errorf("collapsed: %s", c)
+		}
+	}
+	ptr = result.PointsToByGoWithLoopID(p, nil, 0)
+	var hasU bool
+	for _, l := range ptr.PointsTo().Labels() {
+		if l.Value() == u {
+			hasU = true
+		}
+		if l.Value() == i {
+			t.Errorf("pts(p) has %s, which is not a T", l)
+		}
+	}
+	if !hasU || !ptr.Imprecise() {
+		t.Errorf("limit: pts(p) = %v, imprecise %t; want u and imprecise", ptr.PointsTo().Labels(), ptr.Imprecise())
+	}
+	if result.PointsToByGoWithLoopID(i, nil, 0).Imprecise() {
+		t.Errorf("i is imprecise")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"go/types"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 //bz: compute the common paths in a set of mains from a pkg
@@ -17,10 +18,14 @@ var (
 	//result
 	sames = make(map[*ssa.Function][]int)
 	diffs = make(map[*ssa.Function][]string)
+
+	commonMu sync.Mutex //bz: guards all above, since candidates can be added by analyses in parallel
 )
 
 //bz: add to compute common parts
 func AddCandidate(res *ResultWCtx) {
+	commonMu.Lock()
+	defer commonMu.Unlock()
 	if len(cands) == size {
 		return
 	}
@@ -50,6 +55,8 @@ func selectBase() {
 // 5. if the same to all the above -> they are the same
 // 6. we check its callees next
 func ComputeCommonParts() {
+	commonMu.Lock()
+	defer commonMu.Unlock()
 	fmt.Println("\n\nCompute Common parts ... \n") //only shared contours
 
 	selectBase() //select base
@@ -326,8 +333,8 @@ func samePTSinBlock(nid nodeid, oid nodeid, sizeof uint32, na *analysis, oa *ana
 }
 
 func samePTS(nid nodeid, oid nodeid, na *analysis, oa *analysis) bool {
-	np := &PointsToSet{na, &na.nodes[nid].solve.pts}
-	op := &PointsToSet{oa, &oa.nodes[oid].solve.pts}
+	np := &PointsToSet{na, na.nodes[nid].solve.pts}
+	op := &PointsToSet{oa, oa.nodes[oid].solve.pts}
 	if np.pts.Len() == 0 && op.pts.Len() == 0 {
 		return true //skip this empty check
 	}
@@ -0,0 +1,102 @@
+package pointer
+
+import (
+	"fmt"
+	"go/types"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Analyses with different options run in parallel must not affect each other:
+// each one must compute the same solution as when it runs alone. The package
+// state shared by analyses (see commonpart.go and shares.go) must be safe to use
+// from them in parallel; run with -race to check it.
+func TestConcurrentAnalyses(t *testing.T) {
+	_, main := loadTestProgram(t, "testdata/origin.go")
+
+	configs := func() []*Config {
+		origin := originTestConfig(main)
+		insensitive := originTestConfig(main)
+		insensitive.Origin = false
+		limit := originTestConfig(main)
+		limit.PTSLimit = 1
+		callback := originTestConfig(main)
+		callback.DoCallback = true //turns on HVN and renumbering
+		return []*Config{origin, insensitive, limit, callback}
+	}
+
+	//alone
+	var want []map[string]string
+	for _, config := range configs() {
+		r, err := AnalyzeWCtx(config, false, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, solution(r))
+	}
+
+	//together
+	cs := configs()
+	got := make([]map[string]string, len(cs))
+	errs := make([]error, len(cs))
+	var wg sync.WaitGroup
+	for i, config := range cs {
+		wg.Add(1)
+		go func(i int, config *Config) {
+			defer wg.Done()
+			r, err := AnalyzeWCtx(config, false, true)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			got[i] = solution(r)
+			AddCandidate(r)
+			fn := main.Prog.NewFunction(fmt.Sprintf("shared%d", i), new(types.Signature), "config_test")
+			CreateSumForFunc(fn, r.a.cgnodes[0].obj, nil)
+			if !IsShared(fn) || GetSumForFunc(fn).fn != fn {
+				errs[i] = fmt.Errorf("no summary of the shared fn")
+			}
+		}(i, config)
+	}
+	wg.Wait()
+
+	for i := range cs {
+		if errs[i] != nil {
+			t.Errorf("config #%d: %v", i, errs[i])
+			continue
+		}
+		if g, w := fmt.Sprint(got[i]), fmt.Sprint(want[i]); g != w {
+			t.Errorf("config #%d: got %s, want %s", i, g, w)
+		}
+	}
+}
+
+// The root call to main is in the context of main and of all functions called
+// from main without a new context, but no other synthetic call site is.
+func TestContourRootCall(t *testing.T) {
+	_, main := loadTestProgram(t, "testdata/origin.go")
+
+	for _, doCallback := range []bool{false, true} {
+		config := originTestConfig(main)
+		config.DoCallback = doCallback //turns on renumbering
+		r, err := AnalyzeWCtx(config, false, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]bool{"main.main": true, "main.alloc": true, "main.main$1": false}
+		for _, cgn := range r.a.cgnodes {
+			root, ok := want[cgn.fn.String()]
+			if !ok {
+				continue
+			}
+			delete(want, cgn.fn.String())
+			if s := cgn.contourkFull(); strings.Contains(s, "root call to main.main") != root {
+				t.Errorf("DoCallback=%t: contour of %s = %s, root call: got %t, want %t", doCallback, cgn.fn, s, !root, root)
+			}
+		}
+		for fn := range want {
+			t.Errorf("DoCallback=%t: no cgnode for %s", doCallback, fn)
+		}
+	}
+}
@@ -5,7 +5,10 @@
 package pointer
 
 import (
+	"go/token"
 	"go/types"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
 )
 
 type constraint interface {
@@ -113,6 +116,43 @@ func (c *typeFilterConstraint) renumber(mapping []nodeid) {
 	c.src = mapping[c.src]
 }
 
+//bz: dst = (*typ)(src)  where src is an unsafe.Pointer (Config.SoundUnsafe)
+// A complex constraint attached to src.
+// No representation change: a label of src flows to dst, or to its first field
+// (recursively) whose layout is compatible with typ; see unsafe.go
+type unsafeConvConstraint struct {
+	typ types.Type // the pointee type of dst
+	dst nodeid
+	src nodeid // (ptr)
+	pos token.Pos
+}
+
+func (c *unsafeConvConstraint) ptr() nodeid { return c.src }
+func (c *unsafeConvConstraint) renumber(mapping []nodeid) {
+	c.dst = mapping[c.dst]
+	c.src = mapping[c.src]
+}
+
+//bz: dst = src + offset  where src and dst are uintptr (Config.SoundUnsafe)
+// A complex constraint attached to src.
+// A label of src flows to dst as the node at offset bytes from it; if !known
+// (offset is not a constant, or mask) or there is no such node, all the nodes
+// of its object flow to dst; see unsafe.go
+type unsafeOffsetConstraint struct {
+	offset int64
+	known  bool
+	mask   bool // dst = src & or | another value, e.g., clearing the tag bits of a pointer
+	dst    nodeid
+	src    nodeid // (ptr)
+	pos    token.Pos
+}
+
+func (c *unsafeOffsetConstraint) ptr() nodeid { return c.src }
+func (c *unsafeOffsetConstraint) renumber(mapping []nodeid) {
+	c.dst = mapping[c.dst]
+	c.src = mapping[c.src]
+}
+
 // dst = src.(typ)  where typ is a concrete type
 // A complex constraint attached to src (the interface).
 //
@@ -153,3 +193,20 @@ func (c *invokeConstraint) renumber(mapping []nodeid) {
 	c.iface = mapping[c.iface]
 	c.params = mapping[c.params]
 }
+
+//bz: recv.fn(params...) with an object-sensitive Config.Selector, see context.go: fn has one cgnode per object
+// that recv points to, whose receiver only points to that object.
+// A complex constraint attached to recv.
+type objectCallConstraint struct {
+	fn     *ssa.Function // the method, with a pointer receiver
+	recv   nodeid        // (ptr) the receiver
+	params nodeid        // the start of the identity/params/results block, like invokeConstraint
+	site   *callsite
+	caller *cgnode
+}
+
+func (c *objectCallConstraint) ptr() nodeid { return c.recv }
+func (c *objectCallConstraint) renumber(mapping []nodeid) {
+	c.recv = mapping[c.recv]
+	c.params = mapping[c.params]
+}
@@ -0,0 +1,326 @@
+package pointer
+
+// This file defines the pluggable context abstractions (Config.Selector).
+
+import (
+	"fmt"
+	"go/types"
+	"strings"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
+)
+
+/*
+bz: a context selector decides the context of a callee from its call and the context of its caller; the analysis
+  creates one cgnode for each distinct context of a function. a context is a list of elements (strings), the most
+  recent first, e.g., ["alloc t3 in main.main", "alloc t0 in main.init"] for 2-object-sensitivity.
+  how it works with the existing context-sensitivities:
+  - Config.CallSiteSensitive: the selected context replaces the k call sites, i.e., two cgnodes of a function are
+    the same iff they have the same selected context;
+  - Config.Origin: the selected context refines the origin (goroutine) context, i.e., two cgnodes of a function are
+    the same iff they have the same origin context and the same selected context; a new origin starts with an empty
+    selected context. e.g., HybridSelector is origin + k-callsite.
+  the selector is only used for the functions that we analyze with contexts (see considerMyContext()); the others
+  still have a shared contour. the built-in selectors: CallSiteSelector, ObjectSelector, TypeSelector,
+  HybridSelector and SelectiveSelector (a per-package policy).
+*/
+
+// A ContextCall describes a call for a ContextSelector.
+type ContextCall struct {
+	Caller   *ssa.Function
+	Callee   *ssa.Function
+	Instr    ssa.CallInstruction // nil for the call to a closure when it is created and for synthetic calls
+	Receiver ssa.Value           // the receiver of a method call (for invoke, the interface value), or nil
+	Object   *Label              // the object that the receiver points to, only for ObjectSelector and TypeSelector
+}
+
+// A ContextSelector selects the context of the callee of call, where caller is the context of the caller.
+// The result must not share its array with caller if it is different.
+type ContextSelector interface {
+	Select(call *ContextCall, caller []string) []string
+}
+
+//bz: push elem into ctx, keep at most k elements
+func pushContext(ctx []string, elem string, k int) []string {
+	if k <= 0 {
+		k = 1
+	}
+	if len(ctx) >= k {
+		ctx = ctx[:k-1]
+	}
+	return append([]string{elem}, ctx...)
+}
+
+func equalContext(x, y []string) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// CallSiteSelector is k-callsite sensitivity: the context is the most recent K call sites.
+type CallSiteSelector struct {
+	K int
+}
+
+func (s *CallSiteSelector) Select(call *ContextCall, caller []string) []string {
+	if call.Instr == nil {
+		return caller
+	}
+	elem := call.Instr.String() + "@" + call.Instr.Parent().String()
+	if v, ok := call.Instr.(ssa.Value); ok { //two calls can have the same string, e.g., "(*T).get(t0)"
+		elem = v.Name() + " = " + elem
+	}
+	return pushContext(caller, elem, s.K)
+}
+
+// HybridSelector is origin + k-callsite: within a goroutine, the context is the most recent K call sites.
+// It requires Config.Origin.
+type HybridSelector struct {
+	K int
+}
+
+func (s *HybridSelector) Select(call *ContextCall, caller []string) []string {
+	return (&CallSiteSelector{K: s.K}).Select(call, caller)
+}
+
+// ObjectSelector is K-object sensitivity: the context of a method with a pointer receiver is the allocation
+// site of the object that its receiver points to, followed by the context where the object is allocated, i.e.,
+// the analysis creates one context for each object in the points-to set of the receiver. Functions, methods
+// with value receivers and go/defer calls use the context of their callers.
+type ObjectSelector struct {
+	K int
+}
+
+func (s *ObjectSelector) Select(call *ContextCall, caller []string) []string {
+	if call.Object == nil {
+		return caller
+	}
+	elem := "alloc " + call.Object.String()
+	if v := call.Object.Value(); v != nil && v.Parent() != nil {
+		elem = "alloc " + v.Name() + call.Object.Path() + " in " + v.Parent().String()
+	}
+	return pushContext(heapContext(call.Object), elem, s.K)
+}
+
+// TypeSelector is K-type sensitivity: like ObjectSelector, but the element is the type of the receiver of the
+// function that allocates the object (or its package, if the function is not a method), instead of the
+// allocation site.
+type TypeSelector struct {
+	K int
+}
+
+func (s *TypeSelector) Select(call *ContextCall, caller []string) []string {
+	if call.Object == nil {
+		return caller
+	}
+	var fn *ssa.Function
+	if cgn := call.Object.obj.cgn; cgn != nil {
+		fn = cgn.fn
+	} else if v := call.Object.Value(); v != nil {
+		fn = v.Parent()
+	}
+	ctx := heapContext(call.Object)
+	switch {
+	case fn == nil:
+		if g, ok := call.Object.Value().(*ssa.Global); ok && g.Pkg != nil {
+			return pushContext(ctx, "package "+g.Pkg.Pkg.Path(), s.K)
+		}
+		return pushContext(ctx, "type ?", s.K)
+	case fn.Signature.Recv() != nil:
+		return pushContext(ctx, "type "+deref(fn.Signature.Recv().Type()).String(), s.K)
+	case fn.Pkg != nil:
+		return pushContext(ctx, "package "+fn.Pkg.Pkg.Path(), s.K)
+	}
+	return pushContext(ctx, "function "+fn.String(), s.K)
+}
+
+//bz: the selected context of the cgnode that allocates the object of l, nil for globals
+func heapContext(l *Label) []string {
+	if cgn := l.obj.cgn; cgn != nil {
+		return cgn.selected
+	}
+	return nil
+}
+
+// SelectiveSelector selects the context of a callee by the selector of its package in Policy, decided by the
+// longest package path prefix; a nil selector in Policy means context-insensitive, i.e., all calls to the
+// functions in this package share one context (under Config.Origin, one per origin). Packages not in Policy
+// use Default, or are context-insensitive if Default is nil.
+type SelectiveSelector struct {
+	Policy  map[string]ContextSelector
+	Default ContextSelector
+}
+
+func (s *SelectiveSelector) Select(call *ContextCall, caller []string) []string {
+	if sel := s.selectorOf(call.Callee); sel != nil {
+		return sel.Select(call, caller)
+	}
+	return nil
+}
+
+func (s *SelectiveSelector) selectorOf(fn *ssa.Function) ContextSelector {
+	var path string
+	if fn.Pkg != nil {
+		path = fn.Pkg.Pkg.Path()
+	} else if fn.Object() != nil && fn.Object().Pkg() != nil { //e.g., wrappers
+		path = fn.Object().Pkg().Path()
+	}
+	sel, best := s.Default, -1
+	for prefix, policy := range s.Policy {
+		if len(prefix) > best && (path == prefix || strings.HasPrefix(path, prefix+"/")) {
+			sel, best = policy, len(prefix)
+		}
+	}
+	return sel
+}
+
+//bz: whether the context of fn is selected from the objects that its receiver points to, see objectCallConstraint
+func (a *analysis) byObject(fn *ssa.Function) bool {
+	if a.config.Selector == nil || fn.Signature.Recv() == nil {
+		return false
+	}
+	if _, ok := fn.Signature.Recv().Type().Underlying().(*types.Pointer); !ok {
+		return false
+	}
+	return selectsByObject(a.config.Selector, fn)
+}
+
+func selectsByObject(sel ContextSelector, fn *ssa.Function) bool {
+	switch sel := sel.(type) {
+	case *ObjectSelector, *TypeSelector:
+		return true
+	case *SelectiveSelector:
+		if s := sel.selectorOf(fn); s != nil {
+			return selectsByObject(s, fn)
+		}
+	}
+	return false
+}
+
+//bz: the call of fn at site by caller for Config.Selector; site is nil for the call to a closure when it is created
+func contextCall(caller *cgnode, fn *ssa.Function, site *callsite) *ContextCall {
+	call := &ContextCall{Caller: caller.fn, Callee: fn}
+	if site != nil && site.instr != nil {
+		call.Instr = site.instr
+		common := site.instr.Common()
+		if common.IsInvoke() {
+			call.Receiver = common.Value
+		} else if fn.Signature.Recv() != nil && len(common.Args) > 0 {
+			call.Receiver = common.Args[0]
+		}
+	}
+	return call
+}
+
+//bz: the selected context (Config.Selector) of fn called at site by caller; site is nil for the call to a closure
+// when it is created
+func (a *analysis) selectContext(caller *cgnode, fn *ssa.Function, site *callsite) []string {
+	return a.selectFor(contextCall(caller, fn, site), caller.selected)
+}
+
+func (a *analysis) selectFor(call *ContextCall, caller []string) []string {
+	ctx := a.config.Selector.Select(call, caller)
+	if a.log != nil {
+		fmt.Fprintf(a.log, "     SELECTED -- %s: %v\n", call.Callee, ctx)
+	}
+	return ctx
+}
+
+//bz: the function object of the cgnode of c.fn whose receiver points to the object l, created if not exist;
+// the context is selected from l (see ObjectSelector), and under Config.Origin, the caller's origin
+func (a *analysis) objectCallee(c *objectCallConstraint, l nodeid) nodeid {
+	if cgn := a.entryCGNs[c.fn]; cgn != nil { //bz: an entry has only one cgnode, see entries.go
+		return cgn.obj
+	}
+	call := contextCall(c.caller, c.fn, c.site)
+	call.Object = a.labelFor(l)
+	selected := a.selectFor(call, c.caller.selected)
+
+	var existCGNIdx []int
+	var multiFn, isNew bool
+	var obj nodeid
+	if a.config.Origin {
+		existCGNIdx, multiFn, obj, isNew = a.existContextFor(c.fn, c.caller, selected)
+	} else {
+		existCGNIdx, multiFn, obj, isNew = a.existContextSelected(c.fn, selected)
+	}
+	if !isNew {
+		return obj
+	}
+
+	loopID := 0 //the context of a callee is consistent with its caller, see valueNodeInvoke()
+	if c.caller.callersite[0] != nil {
+		loopID = c.caller.callersite[0].loopID
+	}
+	obj, fnIdx := a.makeCGNodeAndRelated(c.fn, c.caller, c.site, nil, loopID)
+	a.cgnodes[fnIdx].selected = selected
+	a.updateFn2NodeID(c.fn, multiFn, []int{fnIdx}, existCGNIdx)
+
+	a.online = true
+	a.localval = c.caller.localval
+	a.localobj = c.caller.localobj
+	a.genConstraintsOnline()
+	return obj
+}
+
+//bz: for invoke c, the concrete method fn whose receiver is v (the payload of a tagged object): its contexts are
+// selected from the objects in pts(v), see objectCallConstraint
+func (a *analysis) genObjectInvoke(c *invokeConstraint, fn *ssa.Function, v nodeid) {
+	key := [2]nodeid{c.params, v}
+	if a.objectCalls[key] {
+		return
+	}
+	a.objectCalls[key] = true
+	a.addConstraint(&objectCallConstraint{fn: fn, recv: v, params: c.params, site: c.site, caller: c.caller})
+}
+
+//bz: for the static call of a method fn (with a pointer receiver) at site in caller: like genInvoke(), we create a
+// block of the identity, params and results, and connect it to the callees by objectCallConstraint
+func (a *analysis) genObjectCall(caller *cgnode, site *callsite, call *ssa.CallCommon, fn *ssa.Function, result nodeid) {
+	sig := fn.Signature
+	block := a.nextNode()
+	site.targets = a.addOneNode(sig, "objcall.targets", nil)
+	p := a.addNodes(sig.Params(), "objcall.params")
+	r := a.addNodes(sig.Results(), "objcall.results")
+
+	for i, arg := range call.Args[1:] {
+		sz := a.sizeof(sig.Params().At(i).Type())
+		a.copy(p, a.valueNode(arg), sz)
+		p += nodeid(sz)
+	}
+	if result != 0 {
+		a.copy(result, r, a.sizeof(sig.Results()))
+	}
+	a.addConstraint(&objectCallConstraint{fn: fn, recv: a.valueNode(call.Args[0]), params: block, site: site, caller: caller})
+}
+
+//bz: for Config.Selector without Config.Origin: if exist selected for fn? return isNew
+func (a *analysis) existContextSelected(fn *ssa.Function, selected []string) ([]int, bool, nodeid, bool) {
+	existCGNIdx, multiFn := a.fn2cgnodeIdx[fn]
+	for _, existIdx := range existCGNIdx {
+		if existCGNode := a.cgnodes[existIdx]; equalContext(existCGNode.selected, selected) {
+			return []int{existIdx}, multiFn, existCGNode.obj, false
+		}
+	}
+	return existCGNIdx, multiFn, 0, true
+}
+
+//bz: check Config.Selector
+func (c *Config) checkSelector() error {
+	if c.Selector == nil {
+		return nil
+	}
+	if !c.Origin && !c.CallSiteSensitive {
+		return fmt.Errorf("Config.Selector requires Config.Origin or Config.CallSiteSensitive")
+	}
+	if _, ok := c.Selector.(*HybridSelector); ok && !c.Origin {
+		return fmt.Errorf("HybridSelector requires Config.Origin")
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package pointer
+
+import (
+	"testing"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
+)
+
+func TestContextSelector(t *testing.T) {
+	_, main := loadTestProgram(t, "testdata/contextsel.go")
+
+	var calls []ssa.Value //x, y, z
+	for _, b := range main.Func("main").Blocks {
+		for _, instr := range b.Instrs {
+			if call, ok := instr.(*ssa.Call); ok {
+				calls = append(calls, call)
+			}
+		}
+	}
+	if len(calls) != 3 {
+		t.Fatalf("found %d calls, want 3", len(calls))
+	}
+
+	tests := []struct {
+		name     string
+		origin   bool
+		selector ContextSelector
+		numGet   int  // #cgnodes of get
+		xy       bool // whether pts(x) and pts(y) overlap
+	}{
+		{"callsite", false, &CallSiteSelector{K: 1}, 3, false},
+		{"object", false, &ObjectSelector{K: 1}, 2, false},
+		{"type", false, &TypeSelector{K: 1}, 1, true},
+		{"selective", false, &SelectiveSelector{Policy: map[string]ContextSelector{"main": nil}, Default: &ObjectSelector{K: 1}}, 1, true},
+		{"selective default", false, &SelectiveSelector{Policy: map[string]ContextSelector{"fmt": nil}, Default: &ObjectSelector{K: 1}}, 2, false},
+		{"hybrid", true, &HybridSelector{K: 1}, 3, false},
+		{"origin+object", true, &ObjectSelector{K: 1}, 2, false},
+	}
+	for _, test := range tests {
+		config := originTestConfig(main)
+		config.Origin = test.origin
+		config.CallSiteSensitive = !test.origin
+		config.Selector = test.selector
+		r, err := AnalyzeWCtx(config, false, true)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		numGet := 0
+		for _, cgn := range r.a.cgnodes {
+			if cgn.fn.Name() == "get" {
+				numGet++
+			}
+		}
+		if numGet != test.numGet {
+			t.Errorf("%s: #cgnodes of get = %d, want %d", test.name, numGet, test.numGet)
+		}
+
+		pts := make([]PointsToSet, len(calls))
+		for i, v := range calls {
+			for _, cgn := range r.a.cgnodes {
+				if id, ok := cgn.localval[v]; ok {
+					pts[i] = PointerWCtx{r.a, id, cgn}.PointsTo()
+				}
+			}
+			want := 1 //merged: pts(x) = pts(y) = both new(int)
+			if test.xy {
+				want = 2
+			}
+			if len(pts[i].Labels()) != want {
+				t.Errorf("%s: pts(%s) = %s, want %d labels", test.name, v.Name(), pts[i], want)
+			}
+		}
+		if xy := pts[0].Intersects(pts[1]); xy != test.xy {
+			t.Errorf("%s: pts(x) and pts(y) overlap: %t, want %t", test.name, xy, test.xy)
+		}
+	}
+
+	config := originTestConfig(main)
+	config.Origin = false
+	config.CallSiteSensitive = true
+	config.Selector = &HybridSelector{K: 1}
+	if _, err := AnalyzeWCtx(config, false, true); err == nil {
+		t.Error("HybridSelector without Origin: no error")
+	}
+}
+
+func TestObjectContexts(t *testing.T) {
+	_, main := loadTestProgram(t, "testdata/objcontext.go")
+
+	tests := []struct {
+		name     string
+		origin   bool
+		selector ContextSelector
+		numGet   int // #cgnodes of get
+		labels   int // #labels in pts of the result of each get
+	}{
+		{"callsite", false, &CallSiteSelector{K: 1}, 2, 2},
+		{"object", false, &ObjectSelector{K: 1}, 2, 1},
+		{"type", false, &TypeSelector{K: 1}, 1, 2},
+		{"origin+object", true, &ObjectSelector{K: 1}, 2, 1},
+	}
+	for _, test := range tests {
+		config := originTestConfig(main)
+		config.Origin = test.origin
+		config.CallSiteSensitive = !test.origin
+		config.Selector = test.selector
+		r, err := AnalyzeWCtx(config, false, true)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		numGet := 0
+		for _, cgn := range r.a.cgnodes {
+			if cgn.fn.Name() != "get" {
+				continue
+			}
+			numGet++
+			recv := r.a.funcParams(cgn.obj)
+			if pts := (PointerWCtx{r.a, recv + 1, cgn}).PointsTo(); len(pts.Labels()) != test.labels {
+				t.Errorf("%s: pts(result of %s) = %s, want %d labels", test.name, cgn, pts, test.labels)
+			}
+			if test.labels == 1 {
+				if pts := (PointerWCtx{r.a, recv, cgn}).PointsTo(); len(pts.Labels()) != 1 {
+					t.Errorf("%s: pts(receiver of %s) = %s, want 1 label", test.name, cgn, pts)
+				}
+			}
+		}
+		if numGet != test.numGet {
+			t.Errorf("%s: #cgnodes of get = %d, want %d", test.name, numGet, test.numGet)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package pointer
+
+// This file implements the online cycle detection of the sequential solver: hybrid cycle detection (HCD) and lazy
+// cycle detection (LCD), see Hardekopf & Lin, "The Ant and the Grasshopper", PLDI 2007.
+
+import (
+	"fmt"
+	"sort"
+)
+
+/*
+bz: the nodes in a cycle of copy edges have the same pts. like hvn.go, we merge them by linking their solver states
+  to one state instead of renumbering them, so the node ids in cgnode.localval/localobj, callsites, Queries and
+  PointerWCtx stay valid (no cgnode.renumberHVN() is needed), and a.nodes[id].solve is the state of the merged nodes.
+  - HCD: before solving, we compute the SCCs of the offline constraint graph, where a load dst = *(src+offset) is an
+    edge from the ref node *(src+offset) to dst, and a store *(dst+offset) = src is an edge from src to the ref node
+    *(dst+offset). the nodes of an SCC are merged right away; for an SCC with a ref node *(p+offset) and a node v,
+    every label k in pts(p) will be in a cycle with v once the load/store edges of k are added, so the solver merges
+    k+offset with v when it adds k to pts(p), without searching for the cycle.
+  - LCD: when the solver propagates along a copy edge n -> m and then pts(n) == pts(m), there may be a cycle through
+    the edge, so we search for the SCCs reachable from m (once per edge) and merge them.
+  a merged state has the union of the pts, copy edges, complex constraints and HCD merges of the states, and the
+  intersection of their prevPTS, so that difference propagation still applies every constraint to every label.
+  the parallel and demand-driven solvers do not detect cycles.
+*/
+
+//bz: the ref node *(p+offset) of an HCD SCC with a node v: each label k in pts(p) is merged with v
+type hcdMerge struct {
+	offset uint32
+	v      nodeid
+}
+
+type cycleState struct {
+	members map[*solverState][]nodeid   // the nodes of each state shared by two or more nodes
+	hcd     map[*solverState][]hcdMerge // the HCD merges of each pointer
+	checked map[[2]*solverState]bool    // the copy edges searched by LCD
+	pending []nodeid                    // the dst of the copy edges to search by LCD
+	merged  int                         // #nodes merged into others
+}
+
+func newCycleState(a *analysis) *cycleState {
+	c := &cycleState{
+		members: make(map[*solverState][]nodeid),
+		hcd:     make(map[*solverState][]hcdMerge),
+		checked: make(map[[2]*solverState]bool),
+	}
+	if a.optHVN || a.incr != nil { //bz: hvn and Reanalyze() link the states of equivalent nodes
+		first := make(map[*solverState]nodeid)
+		for id, n := range a.nodes {
+			if f, ok := first[n.solve]; !ok {
+				first[n.solve] = nodeid(id)
+			} else if c.members[n.solve] == nil {
+				c.members[n.solve] = []nodeid{f, nodeid(id)}
+			} else {
+				c.members[n.solve] = append(c.members[n.solve], nodeid(id))
+			}
+		}
+	}
+	return c
+}
+
+//bz: the nodes that share the state of id
+func (c *cycleState) membersOf(a *analysis, id nodeid) []nodeid {
+	if m := c.members[a.nodes[id].solve]; m != nil {
+		return m
+	}
+	return []nodeid{id}
+}
+
+//bz: the vertex of the state of id in the graph of states, i.e., its first node
+func (c *cycleState) canon(a *analysis, id nodeid) nodeid {
+	return c.membersOf(a, id)[0]
+}
+
+// mergeStates merges the solver states of the nodes ids into the state of ids[0].
+func (a *analysis) mergeStates(ids []nodeid) {
+	c := a.cycles
+	rid := ids[0]
+	rs := a.nodes[rid].solve
+	members := c.membersOf(a, rid)
+	var prev nodeset
+	prev.addAll(rs.prevPTS)
+	changed := false
+	for _, id := range ids[1:] {
+		s := a.nodes[id].solve
+		if s == rs {
+			continue
+		}
+		changed = true
+		rs.pts.addAll(s.pts)
+		rs.copyTo.addAll(&s.copyTo)
+		rs.complex = append(rs.complex, s.complex...)
+		var sprev nodeset
+		sprev.addAll(s.prevPTS)
+		prev.IntersectionWith(&sprev.Sparse)
+		if merges := c.hcd[s]; merges != nil {
+			c.hcd[rs] = append(c.hcd[rs], merges...)
+			delete(c.hcd, s)
+		}
+		ms := c.membersOf(a, id)
+		for _, m := range ms {
+			a.nodes[m].solve = rs
+		}
+		delete(c.members, s)
+		members = append(members, ms...)
+		c.merged += len(ms)
+	}
+	if !changed {
+		return
+	}
+	c.members[rs] = members
+	rs.prevPTS.assign(&prev)
+	if !rs.pts.equals(rs.prevPTS) {
+		a.addWork(rid)
+	}
+	if a.log != nil {
+		fmt.Fprintf(a.log, "\t\tmerge cycle %v into n%d\n", ids, rid)
+	}
+}
+
+//bz: the offline part of HCD, on the constraints generated before solving
+func (a *analysis) hcdOffline() {
+	type ref struct {
+		p      nodeid
+		offset uint32
+	}
+	N := len(a.nodes)
+	var refs []ref
+	refIDs := make(map[ref]int) //ref -> vertex, >= N
+	refOf := func(p nodeid, offset uint32) int {
+		r := ref{p, offset}
+		if v, ok := refIDs[r]; ok {
+			return v
+		}
+		refIDs[r] = N + len(refs)
+		refs = append(refs, r)
+		return N + len(refs) - 1
+	}
+	succs := make(map[int][]int)
+	var roots []int
+	edge := func(from, to int) {
+		if succs[from] == nil {
+			roots = append(roots, from)
+		}
+		succs[from] = append(succs[from], to)
+	}
+	for _, c := range a.constraints {
+		switch c := c.(type) {
+		case *copyConstraint:
+			edge(int(c.src), int(c.dst))
+		case *loadConstraint:
+			edge(refOf(c.src, c.offset), int(c.dst))
+		case *storeConstraint:
+			edge(int(c.src), refOf(c.dst, c.offset))
+		}
+	}
+
+	for _, scc := range findSCCs(roots, func(v int) []int { return succs[v] }) {
+		var nodes []nodeid
+		var rs []ref
+		for _, v := range scc {
+			if v < N {
+				nodes = append(nodes, nodeid(v))
+			} else {
+				rs = append(rs, refs[v-N])
+			}
+		}
+		if len(nodes) == 0 {
+			continue
+		}
+		sort.Slice(nodes, func(i, j int) bool { return nodes[i] < nodes[j] })
+		a.mergeStates(nodes)
+		for _, r := range rs {
+			s := a.nodes[r.p].solve
+			a.cycles.hcd[s] = append(a.cycles.hcd[s], hcdMerge{offset: r.offset, v: nodes[0]})
+		}
+	}
+}
+
+//bz: the online part of HCD: merge the labels in delta, which are added to the pts of n, by the HCD merges of n
+func (a *analysis) hcdOnline(n *node, delta *nodeset) {
+	merges := a.cycles.hcd[n.solve]
+	if merges == nil {
+		return
+	}
+	var space [50]int
+	for _, x := range delta.AppendTo(space[:0]) {
+		base, ok := a.containingObj(nodeid(x))
+		if !ok {
+			continue
+		}
+		end := base + nodeid(a.nodes[base].obj.size)
+		for _, m := range merges {
+			//k must be in the object of x, like offsetAddr: e.g., x is an object smaller than the field at offset
+			if k := nodeid(x) + nodeid(m.offset); k < end && a.nodes[k].solve != a.nodes[m.v].solve {
+				a.mergeStates([]nodeid{m.v, k})
+			}
+		}
+	}
+}
+
+//bz: called by solveConstraints() after propagating along the copy edge from n to dst
+func (a *analysis) lcdCandidate(n *node, dst nodeid) {
+	ds := a.nodes[dst].solve
+	if ds == n.solve || !ds.pts.equals(n.solve.pts) {
+		return
+	}
+	key := [2]*solverState{n.solve, ds}
+	if c := a.cycles; !c.checked[key] {
+		c.checked[key] = true
+		c.pending = append(c.pending, dst)
+	}
+}
+
+//bz: the online part of LCD: merge the SCCs reachable from the pending candidates
+func (a *analysis) lcd() {
+	c := a.cycles
+	if len(c.pending) == 0 {
+		return
+	}
+	roots := make([]int, len(c.pending))
+	for i, id := range c.pending {
+		roots[i] = int(c.canon(a, id))
+	}
+	c.pending = nil
+	sccs := findSCCs(roots, func(v int) []int {
+		var succs []int
+		var space [50]int
+		for _, x := range a.nodes[v].solve.copyTo.AppendTo(space[:0]) {
+			succs = append(succs, int(c.canon(a, nodeid(x))))
+		}
+		return succs
+	})
+	for _, scc := range sccs {
+		ids := make([]nodeid, len(scc))
+		for i, v := range scc {
+			ids[i] = nodeid(v)
+		}
+		a.mergeStates(ids)
+	}
+}
+
+//bz: the SCCs of two or more vertices reachable from roots, by tarjan's algorithm
+func findSCCs(roots []int, succs func(v int) []int) [][]int {
+	t := &tarjan{
+		succs: succs,
+		index: make(map[int]int),
+		low:   make(map[int]int),
+		on:    make(map[int]bool),
+	}
+	for _, v := range roots {
+		if _, ok := t.index[v]; !ok {
+			t.visit(v)
+		}
+	}
+	return t.sccs
+}
+
+type tarjan struct {
+	succs      func(v int) []int
+	index, low map[int]int
+	on         map[int]bool
+	stack      []int
+	sccs       [][]int
+}
+
+func (t *tarjan) visit(v int) {
+	t.index[v] = len(t.index)
+	t.low[v] = t.index[v]
+	t.stack = append(t.stack, v)
+	t.on[v] = true
+	for _, w := range t.succs(v) {
+		if _, ok := t.index[w]; !ok {
+			t.visit(w)
+			if t.low[w] < t.low[v] {
+				t.low[v] = t.low[w]
+			}
+		} else if t.on[w] && t.index[w] < t.low[v] {
+			t.low[v] = t.index[w]
+		}
+	}
+	if t.low[v] != t.index[v] {
+		return
+	}
+	var scc []int
+	for {
+		w := t.stack[len(t.stack)-1]
+		t.stack = t.stack[:len(t.stack)-1]
+		t.on[w] = false
+		scc = append(scc, w)
+		if w == v {
+			break
+		}
+	}
+	if len(scc) > 1 {
+		t.sccs = append(t.sccs, scc)
+	}
+}
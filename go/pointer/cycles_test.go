@@ -0,0 +1,85 @@
+package pointer
+
+import (
+	"go/types"
+	"testing"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
+)
+
+func TestCycles(t *testing.T) {
+	_, main := loadTestProgram(t, "testdata/cycles.go")
+
+	var phis []*ssa.Phi
+	var load *ssa.UnOp
+	for _, b := range main.Func("main").Blocks {
+		for _, instr := range b.Instrs {
+			switch instr := instr.(type) {
+			case *ssa.Phi:
+				if _, ok := instr.Type().(*types.Pointer); ok { //p, q and r, not i
+					phis = append(phis, instr)
+				}
+			case *ssa.UnOp:
+				load = instr
+			}
+		}
+	}
+	if len(phis) != 3 || load == nil {
+		t.Fatalf("phis %v, load %v in %s", phis, load, main.Func("main"))
+	}
+
+	config := originTestConfig(main)
+	config.Cycles = true
+	result, err := Analyze(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := result.a
+	if a.cycles == nil || a.cycles.merged == 0 {
+		t.Fatal("no cycle is merged")
+	}
+	p, q, r := result.PointsToByGoWithLoopID(phis[0], nil, 0), result.PointsToByGoWithLoopID(phis[1], nil, 0),
+		result.PointsToByGoWithLoopID(phis[2], nil, 0)
+	if len(p.PointsTo().Labels()) != 2 || len(q.PointsTo().Labels()) != 2 || a.nodes[p.n].solve != a.nodes[q.n].solve {
+		t.Errorf("p = %v, q = %v: want the same 2 labels from a merged cycle", p.PointsTo().Labels(), q.PointsTo().Labels())
+	}
+	labels := r.PointsTo().Labels()
+	if len(labels) != 1 || labels[0].Value().(*ssa.Alloc).Comment != "new" {
+		t.Errorf("r = %v, want c", labels)
+	}
+	s := result.PointsToByGoWithLoopID(load, nil, 0)
+	if a.nodes[s.n].solve != a.nodes[r.n].solve || !s.MayAlias(r) {
+		t.Errorf("s = %v is not merged with r = %v", s.PointsTo().Labels(), labels)
+	}
+
+	//hcdOnline merges only the nodes in the object of a label: c (new(int)) has no node at offset 1
+	c := nodeid(a.nodes[r.n].solve.pts.Min())
+	if size := a.nodes[c].obj.size; size != 1 {
+		t.Fatalf("size of c = %d, want 1", size)
+	}
+	var delta nodeset
+	delta.add(c)
+	a.cycles.hcd[a.nodes[p.n].solve] = []hcdMerge{{offset: 1, v: p.n}}
+	a.hcdOnline(a.nodes[p.n], &delta)
+	if a.nodes[c+1].solve == a.nodes[p.n].solve {
+		t.Errorf("n%d after c = %v is merged with p", c+1, labels[0])
+	}
+
+	//the same pts without merging (by default)
+	result, err = Analyze(originTestConfig(main))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.a.cycles != nil {
+		t.Error("cycles are detected without Config.Cycles")
+	}
+	p, q, r = result.PointsToByGoWithLoopID(phis[0], nil, 0), result.PointsToByGoWithLoopID(phis[1], nil, 0),
+		result.PointsToByGoWithLoopID(phis[2], nil, 0)
+	if len(p.PointsTo().Labels()) != 2 || len(q.PointsTo().Labels()) != 2 || len(r.PointsTo().Labels()) != 1 {
+		t.Errorf("p = %v, q = %v, r = %v without Config.Cycles: want 2, 2 and 1 labels",
+			p.PointsTo().Labels(), q.PointsTo().Labels(), r.PointsTo().Labels())
+	}
+	if s := result.PointsToByGoWithLoopID(load, nil, 0); !s.MayAlias(r) {
+		t.Errorf("s = %v does not alias r = %v without Config.Cycles", s.PointsTo().Labels(), r.PointsTo().Labels())
+	}
+}
@@ -0,0 +1,101 @@
+package pointer
+
+// This file stops an analysis that runs out of time (Config.Context and
+// Config.TimeLimit) and records which parts of the partial result may be unsound.
+
+import (
+	"go/token"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
+)
+
+//bz: whether we are out of time; checked in generate(), preSolve() and the loops of solve()
+func (a *analysis) outOfTime() bool {
+	if a.stopped {
+		return true
+	}
+	if a.ctx == nil {
+		return false
+	}
+	select {
+	case <-a.ctx.Done():
+		a.stopped = true
+		a.warnf(token.NoPos, "analysis is incomplete: %v", a.ctx.Err())
+		return true
+	default:
+		return false
+	}
+}
+
+//bz: called by solve() when stopped, before releasing the working state. the pts of the following may be unsound:
+// 1 functions whose constraints are not generated yet (still in a.genq or a.gencb), their params/results;
+// 2 nodes in the worklist, nodes with unprocessed constraints;
+// 3 everything reachable from 1 and 2 in the constraint graph, including the store targets and the callees of
+//   invokes whose deltas are not propagated yet, see complexEdges().
+func (a *analysis) collectUnsound() {
+	r := a.result
+	r.Incomplete = true
+
+	var roots []nodeid
+	fns := make(map[*ssa.Function]bool)
+	for _, cgns := range [][]*cgnode{a.genq, a.gencb} {
+		for _, cgn := range cgns {
+			if !fns[cgn.fn] {
+				fns[cgn.fn] = true
+				r.UnsoundFns = append(r.UnsoundFns, cgn.fn)
+			}
+			if obj := a.nodes[cgn.obj].obj; cgn.obj != 0 && obj != nil {
+				for i := uint32(0); i < obj.size; i++ {
+					roots = append(roots, cgn.obj+nodeid(i))
+				}
+			}
+		}
+	}
+
+	//attach unprocessed constraints to the graph without solving them
+	for _, c := range a.constraints {
+		switch c := c.(type) {
+		case *addrConstraint:
+			roots = append(roots, c.dst)
+		case *copyConstraint:
+			a.nodes[c.src].solve.copyTo.add(c.dst)
+			roots = append(roots, c.dst)
+		default:
+			id := c.ptr()
+			a.nodes[id].solve.complex = append(a.nodes[id].solve.complex, c)
+			roots = append(roots, id)
+		}
+	}
+	a.constraints = nil
+
+	var space [50]int
+	for _, x := range a.work.AppendTo(space[:0]) {
+		roots = append(roots, nodeid(x))
+	}
+
+	var unsound nodeset
+	if !a.forwardReach(roots, &unsound) {
+		for i := range a.nodes {
+			unsound.add(nodeid(i))
+		}
+	}
+
+	for _, cgn := range a.cgnodes {
+		hasUnsound := false
+		for _, id := range cgn.localval {
+			if id != 0 && unsound.Has(int(id)) {
+				hasUnsound = true
+				r.UnsoundPointers = append(r.UnsoundPointers, PointerWCtx{a, id, cgn})
+			}
+		}
+		if hasUnsound && !fns[cgn.fn] {
+			fns[cgn.fn] = true
+			r.UnsoundFns = append(r.UnsoundFns, cgn.fn)
+		}
+	}
+	for _, id := range a.globalval {
+		if id != 0 && unsound.Has(int(id)) {
+			r.UnsoundPointers = append(r.UnsoundPointers, PointerWCtx{a, id, nil})
+		}
+	}
+}
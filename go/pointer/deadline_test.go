@@ -0,0 +1,100 @@
+package pointer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
+)
+
+func TestDeadline(t *testing.T) {
+	_, main := loadTestProgram(t, "testdata/origin.go")
+
+	//enough time
+	config := originTestConfig(main)
+	config.TimeLimit = time.Minute
+	result, err := AnalyzeWCtx(config, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Incomplete || len(result.UnsoundFns) > 0 || len(result.UnsoundPointers) > 0 {
+		t.Errorf("got an incomplete result: %v, %v", result.UnsoundFns, result.UnsoundPointers)
+	}
+
+	//out of time before we start
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	config = originTestConfig(main)
+	config.Context = ctx
+	result, err = AnalyzeWCtx(config, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Incomplete {
+		t.Fatal("got a complete result after the context is canceled")
+	}
+	unsound := make(map[string]bool)
+	for _, fn := range result.UnsoundFns {
+		unsound[fn.String()] = true
+	}
+	//main is never generated, so worker is not even reachable
+	if !unsound["main.main"] {
+		t.Errorf("main.main is not reported as unsound: %v", result.UnsoundFns)
+	}
+	if len(result.Warnings) == 0 {
+		t.Errorf("no warning for the incomplete result")
+	}
+}
+
+func TestForwardReach(t *testing.T) {
+	_, main := loadTestProgram(t, "testdata/forwardreach.go")
+
+	config := originTestConfig(main)
+	config.Incremental = true //keep the constraint graph
+	result, err := AnalyzeWCtx(config, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := result.a
+
+	//as if the solver stopped before any delta is propagated: only the offline copy edges exist
+	for _, n := range a.nodes {
+		n.solve.copyTo.Clear()
+	}
+	for _, oc := range a.history {
+		if c, ok := oc.c.(*copyConstraint); ok {
+			a.nodes[c.src].solve.copyTo.add(c.dst)
+		}
+	}
+
+	var q nodeid
+	for _, cgn := range a.cgnodes {
+		for v, id := range cgn.localval {
+			if alloc, ok := v.(*ssa.Alloc); ok && alloc.Heap && alloc.Comment == "new" {
+				q = id
+			}
+		}
+	}
+	if q == 0 {
+		t.Fatal("no node for q")
+	}
+	label := a.nodes[q].solve.pts.AppendTo(nil)[0]
+
+	var reach nodeset
+	if !a.forwardReach([]nodeid{q}, &reach) {
+		t.Fatal("forwardReach gave up")
+	}
+	found := 0
+	for id, n := range a.nodes {
+		if nodeid(id) != q && n.solve.pts.Has(label) {
+			found++
+			if !reach.Has(id) {
+				t.Errorf("n%d (%s) points to q but is not reached", id, a.labelFor(nodeid(id)))
+			}
+		}
+	}
+	if found < 2 {
+		t.Errorf("q flows to %d nodes, want the store target and the param of the invoke", found)
+	}
+}
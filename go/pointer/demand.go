@@ -0,0 +1,331 @@
+package pointer
+
+// This file implements the demand-driven mode (Config.DemandDriven) of the solver.
+
+import (
+	"fmt"
+	"go/types"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
+	"github.com/april1989/origin-go-tools/go/types/typeutil"
+)
+
+/*
+bz: how it works: a node is active if its pts is needed by a query; the solver only takes active nodes from the
+  worklist, i.e., inactive nodes still receive labels but never propagate them. a node is activated by:
+  1 the nodes of Config.Queries/IndirectQueries (under all contexts), and the labels in the pts of the nodes of
+    IndirectQueries (pts(*v));
+  2 the sources of the edges to an active node: copy edges (including the dynamic ones from load/store/calls),
+    and load/offsetAddr/typeFilter/untag constraints (their ptr());
+  3 the ptr() of the store and invoke constraints that may add edges to an active node, once the slice reaches
+    such a node: a store "*p = v" writes nodes of the type of v, so its p is activated by the first active node
+    of the same (underlying) type (or of any type if Config.SoundUnsafe, where the types lie); an invoke "x.m()"
+    copies to its results block and to the recv/params of the methods named m, so its x is activated by them;
+  4 always: the ptr() of the other complex constraints (intrinsics, reflection), which are rare, and the targets
+    of the calls from the root to the entries.
+  this is a lazy version of the backward slice of the queries in the constraint graph, like the refinement-based
+  CFL-reachability: the slice grows with the pts of the pointers it needs, and the constraints outside the
+  slice are never solved. the pts of active nodes are the same as the exhaustive solver; the call graph only
+  has the dynamic calls that are solved, i.e., reached by the slices.
+  the budget: each query pays for the nodes it activates (nodes activated by 4 are free); if a query needs more
+  than Config.DemandBudget nodes, we give up and activate all nodes, i.e., the exhaustive solver.
+*/
+
+//bz: state of the demand-driven mode
+type demandState struct {
+	queries  []ssa.Value
+	indirect map[ssa.Value]bool        // the queries from Config.IndirectQueries
+	active   map[*solverState]int      // active -> the query that activates it; -1 if by 3 or out of budget
+	deref    map[*solverState]int      // nodes of IndirectQueries -> query
+	from     map[*solverState][]nodeid // reverse edges: dst -> src of copy edges and ptr() of constraints in 2
+	cost     []int                     // #nodes activated by each query
+	stack    []nodeid                  // newly activated nodes to process
+	numCGNs  int                       // the cgnodes visited so far, see visitCGNodes()
+	allOn    bool                      // out of budget: all nodes are active
+
+	stores   typeutil.Map            // 3: underlying type of the stored nodes -> *pending stores
+	anyStore *pending                // 3: the stores in Config.SoundUnsafe, or of unknown types
+	invokes  map[string]*pending     // 3: method name -> pending invokes
+	methodOf map[*solverState]string // 3: the recv/params of methods -> method name
+}
+
+//bz: the ptr() of the constraints in 3 that wait for a trigger
+type pending struct {
+	triggered bool
+	q         int // the query that triggered it
+	ptrs      []nodeid
+}
+
+func (p *pending) add(a *analysis, ptr nodeid) {
+	if p.triggered {
+		a.demand.activate(a, ptr, p.q)
+		return
+	}
+	p.ptrs = append(p.ptrs, ptr)
+}
+
+func (p *pending) trigger(a *analysis, q int) {
+	if p.triggered {
+		return
+	}
+	p.triggered, p.q = true, q
+	for _, ptr := range p.ptrs {
+		a.demand.activate(a, ptr, q)
+	}
+	p.ptrs = nil
+}
+
+func newDemandState(config *Config) *demandState {
+	d := &demandState{
+		indirect: make(map[ssa.Value]bool),
+		active:   make(map[*solverState]int),
+		deref:    make(map[*solverState]int),
+		from:     make(map[*solverState][]nodeid),
+		anyStore: &pending{},
+		invokes:  make(map[string]*pending),
+		methodOf: make(map[*solverState]string),
+	}
+	for v := range config.Queries {
+		d.queries = append(d.queries, v)
+	}
+	for v := range config.IndirectQueries {
+		if _, ok := config.Queries[v]; !ok {
+			d.queries = append(d.queries, v)
+		}
+		d.indirect[v] = true
+	}
+	d.cost = make([]int, len(d.queries))
+	return d
+}
+
+func (d *demandState) isActive(a *analysis, id nodeid) bool {
+	if d.allOn {
+		return true
+	}
+	_, ok := d.active[a.nodes[id].solve]
+	return ok
+}
+
+//bz: activate id for query q (-1: free)
+func (d *demandState) activate(a *analysis, id nodeid, q int) {
+	if id == 0 || d.allOn {
+		return
+	}
+	s := a.nodes[id].solve
+	if _, ok := d.active[s]; ok {
+		return
+	}
+	d.active[s] = q
+	d.stack = append(d.stack, id)
+	d.trigger(a, id, q)
+	if d.allOn || q < 0 {
+		return
+	}
+	d.cost[q]++
+	if budget := a.config.DemandBudget; budget > 0 && d.cost[q] > budget {
+		if a.log != nil {
+			fmt.Fprintf(a.log, "\tdemand: %s is out of budget\n", d.queries[q])
+		}
+		a.result.OverBudget = append(a.result.OverBudget, d.queries[q])
+		d.allOn = true
+		for i := range a.nodes {
+			if !a.nodes[i].solve.pts.IsEmpty() {
+				a.addWork(nodeid(i))
+			}
+		}
+	}
+}
+
+//bz: process newly activated nodes: 2 and add to worklist
+func (d *demandState) propagate(a *analysis) {
+	for len(d.stack) > 0 {
+		id := d.stack[len(d.stack)-1]
+		d.stack = d.stack[:len(d.stack)-1]
+		if d.allOn {
+			continue
+		}
+		s := a.nodes[id].solve
+		q := d.active[s]
+		for _, src := range d.from[s] {
+			d.activate(a, src, q)
+		}
+		if !s.pts.IsEmpty() {
+			a.addWork(id)
+		}
+	}
+	d.stack = d.stack[:0]
+}
+
+//bz: a new edge src -> dst, or a constraint in 2 with ptr() src and dst
+func (d *demandState) addEdge(a *analysis, dst, src nodeid) {
+	if d.allOn {
+		return
+	}
+	s := a.nodes[dst].solve
+	d.from[s] = append(d.from[s], src)
+	if q, ok := d.active[s]; ok {
+		d.activate(a, src, q)
+	}
+}
+
+//bz: index the new constraints before processNewConstraints()
+func (d *demandState) addConstraints(a *analysis, constraints []constraint) {
+	for _, c := range constraints {
+		switch c := c.(type) {
+		case *addrConstraint:
+		case *copyConstraint:
+			d.addEdge(a, c.dst, c.src)
+		case *loadConstraint:
+			d.addEdge(a, c.dst, c.src)
+		case *offsetAddrConstraint:
+			d.addEdge(a, c.dst, c.src)
+		case *typeFilterConstraint:
+			d.addEdge(a, c.dst, c.src)
+		case *untagConstraint:
+			d.addEdge(a, c.dst, c.src)
+		case *unsafeConvConstraint:
+			d.addEdge(a, c.dst, c.src)
+		case *unsafeOffsetConstraint:
+			d.addEdge(a, c.dst, c.src)
+		case *storeConstraint:
+			d.storesOf(a, c.src).add(a, c.dst)
+		case *invokeConstraint:
+			sig := c.method.Type().(*types.Signature)
+			results := c.params + 1 + nodeid(a.sizeof(sig.Params()))
+			for i := nodeid(0); i < nodeid(a.sizeof(sig.Results())); i++ {
+				d.addEdge(a, results+i, c.iface)
+			}
+			p := d.invokes[c.method.Name()]
+			if p == nil {
+				p = &pending{}
+				d.invokes[c.method.Name()] = p
+			}
+			p.add(a, c.iface)
+		default:
+			d.activate(a, c.ptr(), -1)
+		}
+	}
+}
+
+//bz: the pending stores that write nodes of the type of id, see 3
+func (d *demandState) storesOf(a *analysis, id nodeid) *pending {
+	t := a.nodes[id].typ
+	if t == nil || a.config.SoundUnsafe {
+		return d.anyStore
+	}
+	t = t.Underlying()
+	p, _ := d.stores.At(t).(*pending)
+	if p == nil {
+		p = &pending{}
+		d.stores.Set(t, p)
+	}
+	return p
+}
+
+//bz: 3 for the newly activated id
+func (d *demandState) trigger(a *analysis, id nodeid, q int) {
+	d.anyStore.trigger(a, q)
+	if !a.config.SoundUnsafe && a.nodes[id].typ != nil {
+		d.storesOf(a, id).trigger(a, q)
+	}
+	if m, ok := d.methodOf[a.nodes[id].solve]; ok {
+		if p := d.invokes[m]; p != nil {
+			p.trigger(a, q)
+		} else {
+			d.invokes[m] = &pending{triggered: true, q: q}
+		}
+	}
+}
+
+//bz: 1 and the recv/params of methods in 3 for new cgnodes
+func (d *demandState) visitCGNodes(a *analysis) {
+	for ; d.numCGNs < len(a.cgnodes); d.numCGNs++ {
+		cgn := a.cgnodes[d.numCGNs]
+		if cgn.obj == 0 { //the root: its calls to the entries are always needed
+			for _, site := range cgn.sites {
+				d.activate(a, site.targets, -1)
+			}
+		}
+		if sig := cgn.fn.Signature; sig.Recv() != nil && cgn.obj != 0 {
+			params := a.funcParams(cgn.obj)
+			for i := nodeid(0); i < nodeid(a.sizeof(sig.Recv().Type())+a.sizeof(sig.Params())); i++ {
+				s := a.nodes[params+i].solve
+				d.methodOf[s] = cgn.fn.Name()
+				if q, ok := d.active[s]; ok { //activated before we know it
+					d.trigger(a, params+i, q)
+				}
+			}
+		}
+		for q, v := range d.queries {
+			if v.Parent() != cgn.fn {
+				continue
+			}
+			d.activateValue(a, v, cgn.localval[v], q)
+		}
+	}
+	for q, v := range d.queries { //globals, created on demand
+		if v.Parent() == nil {
+			d.activateValue(a, v, a.globalval[v], q)
+		}
+	}
+}
+
+func (d *demandState) activateValue(a *analysis, v ssa.Value, id nodeid, q int) {
+	if id == 0 {
+		return
+	}
+	for i := nodeid(0); i < nodeid(a.sizeof(v.Type())); i++ {
+		d.activate(a, id+i, q)
+		if d.indirect[v] {
+			d.deref[a.nodes[id+i].solve] = q
+		}
+	}
+}
+
+//bz: solve(); use when a.config.DemandDriven
+func (a *analysis) solveDemand() {
+	d := a.demand
+	fmt.Println(" *** Demand-Driven:", len(d.queries), "queries *** ")
+
+	var delta nodeset
+	for {
+		if a.outOfTime() { //bz: see collectUnsound()
+			break
+		}
+
+		d.addConstraints(a, a.constraints)
+		a.processNewConstraints()
+		d.visitCGNodes(a)
+		d.propagate(a)
+
+		var x int
+		if !a.work.TakeMin(&x) {
+			break // empty worklist
+		}
+
+		id := nodeid(x)
+		if !d.isActive(a, id) {
+			continue //will be added to worklist again when activated
+		}
+		n := a.nodes[id]
+
+		// Difference propagation.
+		n.solve.pts.diff(n.solve.prevPTS, &delta)
+		if delta.IsEmpty() {
+			continue
+		}
+		if a.log != nil {
+			fmt.Fprintf(a.log, "\tnode n%d\n\t\tpts(n%d : %s) = %s + ... \n", id, id, n.typ, &delta)
+		}
+		n.solve.prevPTS.assign(n.solve.pts)
+
+		if q, ok := d.deref[n.solve]; ok { //pts(*v)
+			for _, l := range delta.AppendTo(a.deltaSpace) {
+				d.activate(a, nodeid(l), q)
+			}
+		}
+
+		// Apply all resolution rules attached to n.
+		a.solveConstraints(n, &delta)
+	}
+}
@@ -0,0 +1,147 @@
+package pointer
+
+import (
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
+)
+
+func TestDemandDriven(t *testing.T) {
+	_, main := loadTestProgram(t, "testdata/demand.go")
+
+	//queries: pts(q) and pts(*&r.f) in main
+	var q, r ssa.Value
+	for _, b := range main.Func("main").Blocks {
+		for _, instr := range b.Instrs {
+			if load, ok := instr.(*ssa.UnOp); ok && load.Op == token.MUL {
+				if addr, ok := load.X.(*ssa.FieldAddr); ok {
+					q, r = load, addr
+				}
+			}
+		}
+	}
+	if q == nil || r == nil {
+		t.Fatal("cannot find the queries")
+	}
+	query := func(config *Config) {
+		config.AddQuery(q)
+		config.AddIndirectQuery(r)
+	}
+
+	all := originTestConfig(main)
+	query(all)
+	want, err := AnalyzeWCtx(all, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(name string, got *ResultWCtx) {
+		wsol, gsol := solution(want), solution(got)
+		n := 0
+		for key, pts := range wsol {
+			if !strings.Contains(key, "main.main@") || !strings.Contains(key, "|"+q.Name()+" = ") && !strings.Contains(key, "|"+r.Name()+" = ") {
+				continue
+			}
+			if pts == "" {
+				t.Fatalf("pts(%s) is empty", key)
+			}
+			if gsol[key] != pts {
+				t.Errorf("%s: pts(%s) = {%s}, want {%s}", name, key, gsol[key], pts)
+			}
+			n++
+		}
+		if n != 2 {
+			t.Errorf("%s: %d queries checked, want 2", name, n)
+		}
+		if got.CallGraph.GetNumEdges() != want.CallGraph.GetNumEdges() {
+			t.Errorf("%s: #call graph edges = %d, want %d", name, got.CallGraph.GetNumEdges(), want.CallGraph.GetNumEdges())
+		}
+	}
+
+	demand := originTestConfig(main)
+	query(demand)
+	demand.DemandDriven = true
+	got, err := AnalyzeWCtx(demand, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("demand-driven", got)
+	if len(got.OverBudget) != 0 {
+		t.Errorf("OverBudget = %v, want none", got.OverBudget)
+	}
+	if active, n := len(got.a.demand.active), len(got.a.nodes); active >= n {
+		t.Errorf("#active nodes = %d, want < #nodes = %d", active, n)
+	}
+
+	//a tiny budget: fall back to solve the whole program
+	demand = originTestConfig(main)
+	query(demand)
+	demand.DemandDriven = true
+	demand.DemandBudget = 1
+	got, err = AnalyzeWCtx(demand, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("over budget", got)
+	if len(got.OverBudget) == 0 {
+		t.Error("OverBudget is empty, want the queries out of budget")
+	}
+}
+
+func TestDemandStores(t *testing.T) {
+	prog, main := loadTestProgram(t, "testdata/demandstore.go")
+
+	var x, bq ssa.Value //x = i.get(); &b.q in set
+	for _, b := range main.Func("main").Blocks {
+		for _, instr := range b.Instrs {
+			if call, ok := instr.(*ssa.Call); ok && call.Call.IsInvoke() {
+				x = call
+			}
+		}
+	}
+	set := prog.LookupMethod(types.NewPointer(main.Type("B").Type()), main.Pkg, "set")
+	for _, b := range set.Blocks {
+		for _, instr := range b.Instrs {
+			if addr, ok := instr.(*ssa.FieldAddr); ok {
+				bq = addr
+			}
+		}
+	}
+	if x == nil || bq == nil {
+		t.Fatal("cannot find the query and the store")
+	}
+
+	var want string
+	for _, demand := range []bool{false, true} {
+		config := originTestConfig(main)
+		config.AddQuery(x)
+		config.DemandDriven = demand
+		r, err := AnalyzeWCtx(config, false, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got string
+		for key, pts := range solution(r) {
+			if strings.HasPrefix(key, "main.main@") && strings.HasSuffix(key, "|"+x.Name()+" = "+x.String()) {
+				got = pts
+			}
+		}
+		if !demand {
+			if got == "" {
+				t.Fatalf("pts(%s) is empty", x)
+			}
+			want = got
+			continue
+		}
+		if got != want {
+			t.Errorf("demand-driven: pts(%s) = {%s}, want {%s}", x, got, want)
+		}
+		for _, cgn := range r.a.cgnodes {
+			if id := cgn.localval[bq]; id != 0 && r.a.demand.isActive(r.a, id) {
+				t.Errorf("the store to %s in %s is active", bq, set)
+			}
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package pointer
+
+// This file defines the entry functions of an analysis, e.g., the tests of a package, see Config.Entries.
+
+import (
+	"go/types"
+	"sort"
+	"strings"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
+)
+
+/*
+bz: the root of the call graph calls the init of the mains and each entry function of Config.Entries (instead of
+  main.main); the cgnode of an entry has its own origin context {targets: obj of the entry}, no matter where it is
+  called (by the root, or by its parent test if it is a t.Run closure), and an entry has only one cgnode. so the
+  pointers of the functions called by an entry (not in a new goroutine) are separated from the other entries, and
+  can be queried by Result.PointsToByEntry(), MatchMyContext() and FilterTargets() with the entry.
+*/
+
+// TestEntries returns the functions of the go test form in pkgs, i.e., TestXxx(*testing.T),
+// BenchmarkXxx(*testing.B) and ExampleXxx(), and the subtests of them: the function literals
+// passed to (*testing.T).Run or (*testing.B).Run, in the order of their names.
+func TestEntries(pkgs ...*ssa.Package) []*ssa.Function {
+	var entries []*ssa.Function
+	var subtests func(fn *ssa.Function)
+	subtests = func(fn *ssa.Function) {
+		for _, anon := range fn.AnonFuncs {
+			if isSubtest(anon) {
+				entries = append(entries, anon)
+			}
+			subtests(anon)
+		}
+	}
+	for _, pkg := range pkgs {
+		if pkg == nil {
+			continue
+		}
+		for _, mem := range pkg.Members {
+			if fn, ok := mem.(*ssa.Function); ok && isTestFunc(fn) {
+				entries = append(entries, fn)
+				subtests(fn)
+			}
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].String() < entries[j].String() })
+	return entries
+}
+
+//bz: whether fn is a TestXxx, BenchmarkXxx or ExampleXxx, see https://golang.org/pkg/testing/
+func isTestFunc(fn *ssa.Function) bool {
+	name, sig := fn.Name(), fn.Signature
+	if sig.Recv() != nil || sig.Results().Len() != 0 {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(name, "Test"):
+		return sig.Params().Len() == 1 && isTestingPtr(sig.Params().At(0).Type(), "T")
+	case strings.HasPrefix(name, "Benchmark"):
+		return sig.Params().Len() == 1 && isTestingPtr(sig.Params().At(0).Type(), "B")
+	case strings.HasPrefix(name, "Example"):
+		return sig.Params().Len() == 0
+	}
+	return false
+}
+
+//bz: whether the function literal fn is passed to (*testing.T).Run or (*testing.B).Run
+func isSubtest(fn *ssa.Function) bool {
+	var refs []ssa.Instruction
+	if fn.FreeVars == nil {
+		refs = referrersOf(fn)
+	} else {
+		for _, instr := range referrersOf(fn) {
+			if closure, ok := instr.(*ssa.MakeClosure); ok && closure.Referrers() != nil {
+				refs = append(refs, *closure.Referrers()...)
+			}
+		}
+	}
+	for _, instr := range refs {
+		call, ok := instr.(ssa.CallInstruction)
+		if !ok {
+			continue
+		}
+		callee := call.Common().StaticCallee()
+		if callee == nil || callee.Name() != "Run" || callee.Signature.Recv() == nil {
+			continue
+		}
+		recv := callee.Signature.Recv().Type()
+		if isTestingPtr(recv, "T") || isTestingPtr(recv, "B") {
+			return true
+		}
+	}
+	return false
+}
+
+//bz: the instructions in the parent of the function literal fn that refer to fn
+func referrersOf(fn *ssa.Function) []ssa.Instruction {
+	var refs []ssa.Instruction
+	for _, b := range fn.Parent().Blocks {
+		for _, instr := range b.Instrs {
+			var space [10]*ssa.Value
+			for _, op := range instr.Operands(space[:0]) {
+				if *op == fn {
+					refs = append(refs, instr)
+					break
+				}
+			}
+		}
+	}
+	return refs
+}
+
+//bz: whether T is *testing.<name>
+func isTestingPtr(T types.Type, name string) bool {
+	ptr, ok := T.(*types.Pointer)
+	if !ok {
+		return false
+	}
+	named, ok := ptr.Elem().(*types.Named)
+	return ok && named.Obj().Name() == name && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "testing"
+}
+
+//bz: the entry functions of the tests of a test main (xx/xx/xxx.test) generated by go test, i.e., of the
+// packages under test (xx/xx/xxx and xx/xx/xxx_test) imported by main
+func testEntriesOf(main *ssa.Package) []*ssa.Function {
+	path := strings.TrimSuffix(main.Pkg.Path(), ".test")
+	var pkgs []*ssa.Package
+	for _, imp := range main.Pkg.Imports() {
+		if imp.Path() == path || imp.Path() == path+"_test" {
+			pkgs = append(pkgs, main.Prog.Package(imp))
+		}
+	}
+	return TestEntries(pkgs...)
+}
+
+//bz: whether the context of cgn is the main context of entry (not a goroutine created by it); if entry is nil,
+// the context of main, or of any entry if there is no main
+func (a *analysis) inEntryContext(cgn *cgnode, entry *ssa.Function) bool {
+	if cgn == nil || len(cgn.callersite) == 0 || cgn.callersite[0] == nil {
+		return false
+	}
+	targets := cgn.callersite[0].targets
+	if entry != nil {
+		e := a.entryCGNs[entry]
+		return e != nil && targets == e.obj
+	}
+	if a.result.main != nil {
+		return targets == a.result.main.callersite[0].targets
+	}
+	for _, e := range a.entryCGNs {
+		if targets == e.obj {
+			return true
+		}
+	}
+	return false
+}
+
+// PointsToByEntry returns the pointers of v in the context of entry (one of Config.Entries), i.e.,
+// called by entry but not in a goroutine created by it.
+func (r *Result) PointsToByEntry(v ssa.Value, entry *ssa.Function) []PointerWCtx {
+	var ptrs []PointerWCtx
+	for _, p := range r.a.result.pointsToRegular(v) {
+		if r.a.inEntryContext(p.cgn, entry) {
+			ptrs = append(ptrs, p)
+		}
+	}
+	return ptrs
+}
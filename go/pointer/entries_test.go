@@ -0,0 +1,135 @@
+package pointer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/april1989/origin-go-tools/go/buildutil"
+	"github.com/april1989/origin-go-tools/go/loader"
+	"github.com/april1989/origin-go-tools/go/ssa"
+	"github.com/april1989/origin-go-tools/go/ssa/ssautil"
+)
+
+//bz: the real testing package does not build in ssa
+const fakeTesting = `
+package testing
+
+type T struct{ name string }
+
+type B struct{ N int }
+
+func (t *T) Run(name string, f func(t *T)) bool {
+	f(&T{name: name})
+	return true
+}
+
+func (b *B) Run(name string, f func(b *B)) bool {
+	f(&B{})
+	return true
+}
+`
+
+// two tests store different objects to the same global through the same helper, and a subtest closure stores
+// a third one; each entry has its own context, so the pts of the helper's param are separated by the entry.
+const entriesTestProg = `
+package main
+
+import "testing"
+
+type T struct{ f *int }
+
+var g *T
+
+func set(x *int) { g = &T{f: x} }
+
+func TestA(t *testing.T) {
+	a := new(int)
+	set(a)
+}
+
+func TestB(t *testing.T) {
+	b := new(int)
+	set(b)
+	t.Run("sub", func(t *testing.T) {
+		c := new(int)
+		set(c)
+		_ = b
+	})
+}
+
+func BenchmarkC(b *testing.B) {}
+
+func ExampleD() {}
+
+func helper(t *testing.T) {}
+
+func main() {}
+`
+
+func TestEntryContexts(t *testing.T) {
+	conf := loader.Config{Build: buildutil.FakeContext(map[string]map[string]string{
+		"testing": {"testing.go": fakeTesting},
+		"main":    {"main.go": entriesTestProg},
+	})}
+	conf.Import("main")
+	iprog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog := ssautil.CreateProgram(iprog, 0)
+	prog.Build()
+	main := prog.Package(iprog.Imported["main"].Pkg)
+
+	entries := TestEntries(main)
+	var names []string
+	for _, fn := range entries {
+		names = append(names, fn.String())
+	}
+	if got, want := strings.Join(names, " "), "main.BenchmarkC main.ExampleD main.TestA main.TestB main.TestB$1"; got != want {
+		t.Fatalf("TestEntries: got %s, want %s", got, want)
+	}
+
+	config := originTestConfig(main)
+	config.Entries = entries
+	result, err := Analyze(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := result.GetTests()
+	if len(tests) != len(entries) {
+		t.Errorf("got %d entry cgnodes, want %d", len(tests), len(entries))
+	}
+	if result.GetResult().main != nil {
+		t.Errorf("main is called with entries")
+	}
+
+	set := main.Func("set")
+	x := set.Params[0]
+	for _, test := range []struct {
+		entry string
+		want  string // the function of the alloc in pts(x)
+	}{
+		{"TestA", "TestA"},
+		{"TestB", "TestB"},
+		{"TestB$1", "TestB$1"},
+	} {
+		var entry *ssa.Function
+		for _, fn := range entries {
+			if fn.Name() == test.entry {
+				entry = fn
+			}
+		}
+		ptrs := result.PointsToByEntry(x, entry)
+		if len(ptrs) != 1 {
+			t.Errorf("%s: got %d pointers of x, want 1", test.entry, len(ptrs))
+			continue
+		}
+		labels := ptrs[0].PointsTo().Labels()
+		if len(labels) != 1 || labels[0].Value().Parent().Name() != test.want {
+			t.Errorf("%s: pts(x) = %v, want %s", test.entry, labels, test.want)
+		}
+		if !ptrs[0].MatchMyContext(nil, entry) || len(result.Queries[x]) != 3 {
+			t.Errorf("%s: %d pointers of x do not match the entry", test.entry, len(result.Queries[x]))
+		}
+	}
+}
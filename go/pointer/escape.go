@@ -0,0 +1,166 @@
+package pointer
+
+// This file defines the thread-escape analysis: the origins that may access the objects of each allocation site.
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
+)
+
+/*
+bz: an origin may access an object if a local value (a register, parameter or free variable) of a cgnode reachable
+  in the origin may point to the object or to a field of it, or the object is a global it uses: any load, store or
+  call on the object goes through such a pointer. an object escapes if it is a global, accessed by two or more
+  origins, or reachable (through the pts of its fields) from an escaped object; an object is origin-local if it
+  does not escape, i.e., no other goroutine can reach it. an origin with a loop ID stands for many goroutines, but
+  the objects allocated by one of them are separated from the others' by the loop IDs 1 and 2.
+*/
+
+// An AllocAccess is an allocation site with its heap context, and the origins that may access its objects.
+type AllocAccess struct {
+	Label   *Label
+	Site    *AllocSite // nil for a global
+	Origins []*Origin  // sorted by ID
+	Escaped bool       // a global, or reachable from a global or an object accessed by two or more origins
+}
+
+// Local reports whether the objects of a are never reachable from a goroutine other than the one that allocates
+// them.
+func (a *AllocAccess) Local() bool {
+	return !a.Escaped && len(a.Origins) <= 1
+}
+
+func (a *AllocAccess) String() string {
+	return fmt.Sprintf("%s accessed by %v", a.Label, a.Origins)
+}
+
+// A ThreadEscape is the result of the thread-escape analysis.
+type ThreadEscape struct {
+	Origins *OriginGraph
+	Objects []*AllocAccess // in the order of their nodes
+	objs    map[*object]*AllocAccess
+}
+
+// ThreadEscape computes the origins that may access the objects of each allocation site, which requires
+// Config.BuildCallGraph.
+func (r *ResultWCtx) ThreadEscape() *ThreadEscape {
+	a := r.a
+	e := &ThreadEscape{
+		Origins: r.OriginGraph(),
+		objs:    make(map[*object]*AllocAccess),
+	}
+	accessed := make(map[nodeid]map[*Origin]bool) //the first node of an object -> origins
+	mark := func(o *Origin, obj nodeid) {
+		if accessed[obj] == nil {
+			accessed[obj] = make(map[*Origin]bool)
+		}
+		accessed[obj][o] = true
+	}
+	access := func(o *Origin, id nodeid, size uint32) {
+		var space [50]int
+		for i := id; i < id+nodeid(size); i++ {
+			for _, x := range a.nodes[i].solve.pts.AppendTo(space[:0]) {
+				mark(o, a.enclosingObj(nodeid(x)))
+			}
+		}
+	}
+	for _, o := range e.Origins.Origins {
+		for _, n := range o.Nodes {
+			for v, id := range n.cgn.localval {
+				access(o, id, a.sizeof(v.Type()))
+			}
+			for _, b := range n.cgn.fn.Blocks {
+				for _, instr := range b.Instrs {
+					var rands [10]*ssa.Value
+					for _, rand := range instr.Operands(rands[:0]) {
+						if g, ok := (*rand).(*ssa.Global); ok && a.globalobj[g] != 0 {
+							mark(o, a.globalobj[g])
+						}
+					}
+				}
+			}
+		}
+	}
+
+	escaped := make(map[nodeid]bool)
+	var worklist []nodeid
+	for id, origins := range accessed {
+		if _, ok := a.nodes[id].obj.data.(*ssa.Global); ok || len(origins) > 1 {
+			escaped[id] = true
+			worklist = append(worklist, id)
+		}
+	}
+	for len(worklist) > 0 {
+		id := worklist[len(worklist)-1]
+		worklist = worklist[:len(worklist)-1]
+		var space [50]int
+		for i := id; i < id+nodeid(a.nodes[id].obj.size); i++ {
+			for _, x := range a.nodes[i].solve.pts.AppendTo(space[:0]) {
+				if obj := a.enclosingObj(nodeid(x)); !escaped[obj] {
+					escaped[obj] = true
+					worklist = append(worklist, obj)
+				}
+			}
+		}
+	}
+
+	ids := make([]nodeid, 0, len(accessed))
+	for id := range accessed {
+		ids = append(ids, id)
+	}
+	for id := range escaped {
+		if accessed[id] == nil { //reachable, but not accessed
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		obj := a.nodes[id].obj
+		acc := &AllocAccess{Label: a.labelFor(id), Escaped: escaped[id]}
+		if _, ok := obj.data.(*ssa.Global); !ok && obj.cgn != nil {
+			acc.Site = &AllocSite{Fn: obj.cgn.fn, Ctx: obj.cgn.callersite}
+		}
+		for o := range accessed[id] {
+			acc.Origins = append(acc.Origins, o)
+		}
+		sort.Slice(acc.Origins, func(i, j int) bool { return acc.Origins[i].ID < acc.Origins[j].ID })
+		e.Objects = append(e.Objects, acc)
+		e.objs[obj] = acc
+	}
+	return e
+}
+
+// ThreadEscape computes the origins that may access the objects of each allocation site, see
+// ResultWCtx.ThreadEscape.
+func (r *Result) ThreadEscape() *ThreadEscape {
+	return r.a.result.ThreadEscape()
+}
+
+// OriginsOf returns the origins that may access the object of l.
+func (e *ThreadEscape) OriginsOf(l *Label) []*Origin {
+	if acc := e.objs[l.obj]; acc != nil {
+		return acc.Origins
+	}
+	return nil
+}
+
+// IsOriginLocal reports whether the object of l is never reachable from a goroutine other than the one that
+// allocates it.
+func (e *ThreadEscape) IsOriginLocal(l *Label) bool {
+	acc := e.objs[l.obj]
+	return acc == nil || acc.Local()
+}
+
+// PointsToLocal reports whether all the objects that p may point to are origin-local; false if p points to nothing,
+// since the objects are unknown.
+func (e *ThreadEscape) PointsToLocal(p PointerWCtx) bool {
+	labels := p.PointsTo().Labels()
+	for _, l := range labels {
+		if !e.IsOriginLocal(l) {
+			return false
+		}
+	}
+	return len(labels) > 0
+}
@@ -0,0 +1,61 @@
+package pointer
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
+)
+
+func TestThreadEscape(t *testing.T) {
+	prog, main := loadTestProgram(t, "testdata/escape.go")
+
+	result, err := Analyze(originTestConfig(main))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := result.ThreadEscape()
+
+	//the allocation sites by their lines and the comments of their registers
+	allocs := make(map[string]*AllocAccess)
+	for _, acc := range e.Objects {
+		if v, ok := acc.Label.Value().(*ssa.Alloc); ok {
+			allocs[fmt.Sprintf("%d %s", prog.Fset.Position(v.Pos()).Line, v.Comment)] = acc
+		}
+	}
+	for name, local := range map[string]bool{
+		"12 new":     false, //reachable from shared
+		"15 new":     false, //reachable from global
+		"20 complit": false, //shared
+		"22 complit": true,
+		"22 new":     true,
+	} {
+		acc := allocs[name]
+		if acc == nil {
+			t.Errorf("no allocation %q in %v", name, e.Objects)
+			continue
+		}
+		if acc.Local() != local {
+			t.Errorf("%s: local = %t, want %t", acc, acc.Local(), local)
+		}
+	}
+	if acc := allocs["20 complit"]; acc != nil && len(acc.Origins) != 2 {
+		t.Errorf("origins of shared = %v, want main and work", acc.Origins)
+	}
+	if acc := allocs["15 new"]; acc != nil && len(acc.Origins) != 1 {
+		t.Errorf("origins of escaped = %v, want work", acc.Origins)
+	}
+	if acc := allocs["22 new"]; acc != nil && (len(acc.Origins) != 1 || acc.Origins[0] != e.Origins.Root || acc.Site.Fn != main.Func("main")) {
+		t.Errorf("origins of local.p = %v, want main", acc.Origins)
+	}
+
+	var global *AllocAccess
+	for _, acc := range e.Objects {
+		if acc.Label.Value() == main.Var("global") {
+			global = acc
+		}
+	}
+	if global == nil || global.Local() || !e.IsOriginLocal(allocs["22 new"].Label) || e.IsOriginLocal(allocs["12 new"].Label) {
+		t.Errorf("global: %v", global)
+	}
+}
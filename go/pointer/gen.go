@@ -13,7 +13,6 @@ package pointer
 import (
 	"fmt"
 	"github.com/april1989/origin-go-tools/container/intsets"
-	"github.com/april1989/origin-go-tools/go/myutil/flags"
 	"github.com/april1989/origin-go-tools/go/ssa"
 	"go/token"
 	"go/types"
@@ -78,7 +77,10 @@ func (a *analysis) addNodes(typ types.Type, comment string) nodeid {
 //
 func (a *analysis) addOneNode(typ types.Type, comment string, subelement *fieldInfo) nodeid {
 	id := a.nextNode()
-	a.nodes = append(a.nodes, &node{typ: typ, subelement: subelement, solve: new(solverState)})
+	a.nodes = append(a.nodes, &node{typ: typ, subelement: subelement, solve: a.newSolverState()})
+	if a.creators != nil { //bz: see diffSolution()
+		a.creators = append(a.creators, &nodeCreator{a.owner, comment})
+	}
 	if a.log != nil {
 		fmt.Fprintf(a.log, "\tcreate n%d %s for %s%s\n",
 			id, typ, comment, subelement.path())
@@ -253,13 +255,6 @@ func (a *analysis) makeFunctionObject(fn *ssa.Function, callersite *callsite) no
 	obj := a.nextNode()
 	cgn := a.makeCGNode(fn, obj, callersite)
 
-	if !a.isMain && a.isGoTestForm(fn.Name()) {
-		//bz: we create a new context for this test -> in order to separate call edges
-		//TODO: use itself as target? this pattern only works for grpc, not sure others
-		special := &callsite{targets: obj}
-		cgn.callersite = a.createSingleCallSite(special)
-	}
-
 	sig := fn.Signature
 	a.addOneNode(sig, "func.cgnode", nil) // (scalar with Signature type)
 	if recv := sig.Recv(); recv != nil {
@@ -295,6 +290,10 @@ func (a *analysis) makeFunctionObjectWithContext(caller *cgnode, fn *ssa.Functio
 	//	fmt.Printf("\t---- makeFunctionObjectWithContext (kcfa) for %s\n", fn)
 	//}
 
+	if cgn := a.entryCGNs[fn]; cgn != nil { //bz: an entry has only one cgnode, see entries.go
+		return cgn.obj, false
+	}
+
 	if a.config.Origin && callersite == nil && closure != nil {
 		//origin: case 2: fn is wrapped by make closure -> we checked before calling this, now needs to create it
 		// and will update the a.closures[] outside
@@ -316,7 +315,11 @@ func (a *analysis) makeFunctionObjectWithContext(caller *cgnode, fn *ssa.Functio
 			return existNodeID, isNew
 		}
 	} else if a.config.CallSiteSensitive { //bz: for kcfa
-		existCGNIdx, multiFn, existNodeID, isNew = a.existContextForComb(fn, callersite, caller, -1)
+		if a.config.Selector != nil { //bz: see context.go
+			existCGNIdx, multiFn, existNodeID, isNew = a.existContextSelected(fn, a.selectContext(caller, fn, callersite))
+		} else {
+			existCGNIdx, multiFn, existNodeID, isNew = a.existContextForComb(fn, callersite, caller, -1)
+		}
 		if !isNew {
 			return existNodeID, isNew
 		}
@@ -339,20 +342,30 @@ func (a *analysis) makeFunctionObjectWithContext(caller *cgnode, fn *ssa.Functio
 
 //bz: a summary of existContextXXX(); if loopID = -1 -> loopID is not needed; return isNew
 func (a *analysis) existContext(fn *ssa.Function, callersite *callsite, caller *cgnode, loopID int) ([]int, bool, nodeid, bool) {
+	if cgn := a.entryCGNs[fn]; cgn != nil { //bz: an entry has only one cgnode, see entries.go
+		return []int{cgn.idx}, true, cgn.obj, false
+	}
+	if a.config.Selector != nil && !a.config.Origin { //bz: see context.go
+		return a.existContextSelected(fn, a.selectContext(caller, fn, callersite))
+	}
 	if _, ok := callersite.instr.(*ssa.Go); callersite != nil && ok {
 		return a.existContextForComb(fn, callersite, caller, loopID)
 	} else {
-		return a.existContextFor(fn, caller)
+		var selected []string
+		if a.config.Selector != nil {
+			selected = a.selectContext(caller, fn, callersite)
+		}
+		return a.existContextFor(fn, caller, selected)
 	}
 }
 
-//bz: if exist this caller for fn? return isNew
-func (a *analysis) existContextFor(fn *ssa.Function, caller *cgnode) ([]int, bool, nodeid, bool) {
+//bz: if exist this caller (with selected, see context.go) for fn? return isNew
+func (a *analysis) existContextFor(fn *ssa.Function, caller *cgnode, selected []string) ([]int, bool, nodeid, bool) {
 	existCGNIdx, multiFn := a.fn2cgnodeIdx[fn]
 	if multiFn { //check if we already have the caller + callsite ?? recursive/duplicate call
 		for i, existIdx := range existCGNIdx { // idx -> index of fn cgnode in a.cgnodes[]
 			existCGNode := a.cgnodes[existIdx]
-			if equalCallSite(existCGNode.callersite, caller.callersite) { //check all callsites
+			if equalCallSite(existCGNode.callersite, caller.callersite) && equalContext(existCGNode.selected, selected) { //check all callsites
 				//duplicate combination, return this
 				if a.log != nil { //debug
 					fmt.Fprintf(a.log, "    EXIST**: "+strconv.Itoa(i+1)+"th: K-CALLSITE -- "+existCGNode.contourkFull()+"\n")
@@ -376,7 +389,7 @@ func (a *analysis) existContextForComb(fn *ssa.Function, callersite *callsite, c
 	if multiFn { //check if we already have the caller + callsite ?? recursive/duplicate call
 		for i, existIdx := range existCGNIdx { // idx -> index of fn cgnode in a.cgnodes[]
 			_fnCGNode := a.cgnodes[existIdx]
-			if a.equalContextForComb(_fnCGNode.callersite, callersite, caller.callersite, loopID) { //check all callsites
+			if a.equalContextForComb(_fnCGNode.callersite, callersite, caller.callersite, loopID) && _fnCGNode.selected == nil { //check all callsites; a new origin has no selected
 				//duplicate combination, return this
 				if a.log != nil { //debug
 					fmt.Fprintf(a.log, "    EXIST**: "+strconv.Itoa(i+1)+"th: K-CALLSITE -- "+_fnCGNode.contourkFull()+"\n")
@@ -424,6 +437,11 @@ func (a *analysis) makeCGNodeAndRelated(fn *ssa.Function, caller *cgnode, caller
 	if a.config.Mains[0].Func("main") == fn { //bz: give the main method a context, instead of using shared contour
 		single := a.createSingleCallSite(callersite)
 		cgn = &cgnode{fn: fn, obj: obj, callersite: single}
+	} else if a.entries[fn] { //bz: an entry has its own origin context, see entries.go
+		special := &callsite{targets: obj}
+		cgn = &cgnode{fn: fn, obj: obj, callersite: a.createSingleCallSite(special)}
+		a.entryCGNs[fn] = cgn
+		a.numOrigins++
 	} else {                 // other functions
 		if a.config.Origin { //bz: for origin-sensitive
 			if callersite == nil { //we only create new context for make closure and go instruction
@@ -440,6 +458,9 @@ func (a *analysis) makeCGNodeAndRelated(fn *ssa.Function, caller *cgnode, caller
 					fnkcs = caller.callersite
 				}
 				cgn = &cgnode{fn: fn, obj: obj, callersite: fnkcs}
+				if goInstr == nil && a.config.Selector != nil { //bz: see context.go
+					cgn.selected = a.selectContext(caller, fn, nil)
+				}
 			} else if goInstr, ok := callersite.instr.(*ssa.Go); ok { //case 1 and 3: this is a *ssa.GO without closure
 				special := callersite
 				special.goInstr = goInstr //update
@@ -454,6 +475,9 @@ func (a *analysis) makeCGNodeAndRelated(fn *ssa.Function, caller *cgnode, caller
 				//fmt.Println(cgn.String()) //bz: debug
 			} else { //use caller context
 				cgn = &cgnode{fn: fn, obj: obj, callersite: caller.callersite}
+				if a.config.Selector != nil { //bz: see context.go
+					cgn.selected = a.selectContext(caller, fn, callersite)
+				}
 			}
 		} else if a.config.CallSiteSensitive { //bz: for kcfa
 			if callersite == nil { //fn is make closure
@@ -467,6 +491,9 @@ func (a *analysis) makeCGNodeAndRelated(fn *ssa.Function, caller *cgnode, caller
 				fnkcs := a.createKCallSite(caller.callersite, callersite)
 				cgn = &cgnode{fn: fn, obj: obj, callersite: fnkcs}
 			}
+			if a.config.Selector != nil { //bz: see context.go
+				cgn.selected = a.selectContext(caller, fn, callersite)
+			}
 		} else {
 			panic("NO SELECTED MY CONTEXT IN a.config. GO SELECT ONE.")
 		}
@@ -603,6 +630,8 @@ func (a *analysis) valueNode(v ssa.Value) nodeid {
 	// Value nodes for globals are created on demand.
 	id, ok := a.globalval[v]
 	if !ok {
+		owner := a.owner
+		a.owner = nil //bz: incremental: shared by all cgnodes
 		var comment string
 		if a.log != nil {
 			comment = v.String()
@@ -612,6 +641,7 @@ func (a *analysis) valueNode(v ssa.Value) nodeid {
 			a.addressOf(v.Type(), id, obj)
 		}
 		a.setValueNode(v, id, nil)
+		a.owner = owner
 	}
 	return id
 }
@@ -808,6 +838,9 @@ func (a *analysis) typeAssert(T types.Type, dst, src nodeid, exact bool) {
 // addConstraint adds c to the constraint set.
 func (a *analysis) addConstraint(c constraint) {
 	a.constraints = append(a.constraints, c)
+	if a.config.Incremental { //bz: see Reanalyze()
+		a.history = append(a.history, ownedConstraint{c, a.owner})
+	}
 	if a.log != nil {
 		fmt.Fprintf(a.log, "\t%s\n", c)
 	}
@@ -843,6 +876,9 @@ func (a *analysis) genConv(conv *ssa.Convert, cgn *cgnode) {
 	case *types.Pointer:
 		// *T -> unsafe.Pointer?
 		if tDst.Underlying() == tUnsafePtr {
+			if a.config.SoundUnsafe { //bz: see unsafe.go
+				a.copy(res, a.valueNode(conv.X), 1)
+			}
 			return // we don't model unsafe aliasing (unsound)
 		}
 
@@ -852,6 +888,10 @@ func (a *analysis) genConv(conv *ssa.Convert, cgn *cgnode) {
 			// Treat unsafe.Pointer->*T conversions like
 			// new(T) and create an unaliased object.
 			if utSrc == tUnsafePtr {
+				if a.config.SoundUnsafe { //bz: see unsafe.go
+					a.addConstraint(&unsafeConvConstraint{typ: mustDeref(tDst), dst: res, src: a.valueNode(conv.X), pos: conv.Pos()})
+					return
+				}
 				obj := a.addNodes(mustDeref(tDst), "unsafe.Pointer conversion")
 				a.endObject(obj, cgn, conv)
 				a.addressOf(tDst, res, obj)
@@ -871,6 +911,9 @@ func (a *analysis) genConv(conv *ssa.Convert, cgn *cgnode) {
 			// All basic-to-basic type conversions are no-ops.
 			// This includes uintptr<->unsafe.Pointer conversions,
 			// which we (unsoundly) ignore.
+			if a.config.SoundUnsafe && isUnsafePtrOrUintptr(utSrc) && isUnsafePtrOrUintptr(tDst.Underlying()) {
+				a.copy(res, a.valueNode(conv.X), 1) //bz: see unsafe.go
+			}
 			return
 		}
 	}
@@ -1168,50 +1211,58 @@ func (a *analysis) createForLevelX(caller *ssa.Function, callee *ssa.Function) b
 //TODO: NOW ASSUME only one function in params
 func (a *analysis) hasFuncParam(call *ssa.CallCommon) (ssa.Value, ssa.Value, bool) {
 	for _, arg := range call.Args { //which params is callback fn?
-		switch v := arg.(type) {
-		case *ssa.MakeClosure: //most cases: create a make closure and pass as param
-			fn := v.Fn.(*ssa.Function)
-			if fn != nil && fn.IsFromApp { //we must seen this closure before reaching this point
-				return v, fn, true // if arg is callback fn and it is a function inside closure
-			}
-
-		//we might not seen the fn for the following cases, check if fn is in scope -> no then return
-		case *ssa.Function: //e.g., _tests/main/cg_namefn.go, may be directly pass a func as param
-			if a.withinScope(v.String()) {
-				return v, v, true
-			}
-
-		case *ssa.TypeAssert: //maybe create func first, then long call chain (need to cast to interface),
-			// finally pass to the caller and cast back
-			if sig, ok := v.Type().(*types.Signature); ok {
-				//from go1.15 doc: A Signature (*types.Signature) represents a (non-builtin) function or method type.
-				//-> then this should be a function pointer; however, I need to create a ssa.instruction of type change in IR before creating this sequence
-				//   of creating constraints
-				//TODO: what is exactly the target func here?
-				if a.withinScope(sig.String()) {
-					return v, v, true //bz: THIS IS THE ONLY ONE THAT IS NOT OF TYPE *ssa.Function
-				}
-			}
+		if targetFn, ok := a.funcParam(arg); ok {
+			return arg, targetFn, true
+		}
+	}
 
-		case *ssa.ChangeType: //e.g., _tests/main/cg_typefn.go
-			fn, ok := v.X.(*ssa.Function)
-			if ok && a.withinScope(fn.String()) {
-				return v, fn, true
-			}
+	return nil, nil, false
+}
+
+//bz: whether arg wraps a callback fn that is from app (or in scope); if so, also return the callback func (or pointer)
+func (a *analysis) funcParam(arg ssa.Value) (ssa.Value, bool) {
+	switch v := arg.(type) {
+	case *ssa.MakeClosure: //most cases: create a make closure and pass as param
+		fn := v.Fn.(*ssa.Function)
+		if fn != nil && fn.IsFromApp { //we must seen this closure before reaching this point
+			return fn, true // if arg is callback fn and it is a function inside closure
+		}
 
-		case *ssa.Call: //e.g., _tests/main/cg_long.go
-			if fn, ok := v.Call.Value.(*ssa.Function); ok && fn.Signature.Results().Len() == 0 && a.withinScope(fn.String()) {
-				//bz: special cases in google.golang.org/grpc/benchmark/server, the code is:
-				//    tr, ok := trace.FromContext(stream.Context())
-				//stream.Context() as a function is used as a parameter of FromContext,
-				//but actually FromContext requires the return value of stream.Context() as parameter
-				//but the generated ir is not clear here ...
-				return v, fn, true
+	//we might not seen the fn for the following cases, check if fn is in scope -> no then return
+	case *ssa.Function: //e.g., _tests/main/cg_namefn.go, may be directly pass a func as param
+		if a.withinScope(v.String()) {
+			return v, true
+		}
+
+	case *ssa.TypeAssert: //maybe create func first, then long call chain (need to cast to interface),
+		// finally pass to the caller and cast back
+		if sig, ok := v.Type().(*types.Signature); ok {
+			//from go1.15 doc: A Signature (*types.Signature) represents a (non-builtin) function or method type.
+			//-> then this should be a function pointer; however, I need to create a ssa.instruction of type change in IR before creating this sequence
+			//   of creating constraints
+			//TODO: what is exactly the target func here?
+			if a.withinScope(sig.String()) {
+				return v, true //bz: THIS IS THE ONLY ONE THAT IS NOT OF TYPE *ssa.Function
 			}
 		}
-	}
 
-	return nil, nil, false
+	case *ssa.ChangeType: //e.g., _tests/main/cg_typefn.go
+		fn, ok := v.X.(*ssa.Function)
+		if ok && a.withinScope(fn.String()) {
+			return fn, true
+		}
+
+	case *ssa.Call: //e.g., _tests/main/cg_long.go
+		if fn, ok := v.Call.Value.(*ssa.Function); ok && fn.Signature.Results().Len() == 0 && a.withinScope(fn.String()) {
+			//bz: special cases in google.golang.org/grpc/benchmark/server, the code is:
+			//    tr, ok := trace.FromContext(stream.Context())
+			//stream.Context() as a function is used as a parameter of FromContext,
+			//but actually FromContext requires the return value of stream.Context() as parameter
+			//but the generated ir is not clear here ...
+			return fn, true
+		}
+	}
+	return nil, false
 }
 
 //bz: link the callback here, return value can be nil
@@ -1227,20 +1278,23 @@ func (a *analysis) hasFuncParam(call *ssa.CallCommon) (ssa.Value, ssa.Value, boo
 //     if callback needs any input
 //Update: avoid redundant genInstr() that have been done in previous preSolve() loops
 //     -> put all instructions in a preSolve loop in one basic block
+//Update: the callbacks of fn and how they are invoked are from the summary of fn if any, see callbacksOf()
 //TODO: 1. why this change mess up the renumber phase?
 func (a *analysis) genCallBack(caller *cgnode, instr ssa.CallInstruction, fn *ssa.Function, site *callsite, call *ssa.CallCommon) []nodeid {
 	//relation: caller -(invoke)-> fn -(invoke)-> callback/targetFn
-	v, targetFn, isCallback := a.hasFuncParam(call) //v -> arg that wrapping callback; targetFn -> callback fn
-	if !isCallback {
+	cbs := a.callbacksOf(fn, call)
+	if len(cbs) == 0 {
 		return nil //not callback or not in scope
 	}
 
-	if targetFn == nil {
-		panic("No callback fn in *ssa.MakeClosure @" + call.String() + ". DEBUG or Adjust your callback.yml.")
+	for _, cb := range cbs {
+		if cb.targetFn == nil {
+			panic("No callback fn in *ssa.MakeClosure @" + call.String() + ". DEBUG or Adjust your callback.yml.")
+		}
 	}
 
-	if flags.DoCollapse {
-		id := a.genCallBackCollapse(caller, instr, fn, site, call, targetFn, v)
+	if a.config.DoCollapse {
+		id := a.genCallBackCollapse(caller, instr, fn, site, call, cbs)
 		result := make([]nodeid, 1)
 		result[0] = id
 		return result
@@ -1251,12 +1305,16 @@ func (a *analysis) genCallBack(caller *cgnode, instr ssa.CallInstruction, fn *ss
 	key := fn.String() + "@" + caller.contourkFull()
 
 	//bz: skip recursive relations between lib call <-> callback fn
-	if a.recursiveCallback(fn, caller.callersite[0], targetFn) {
+	var nonrec []*callback
+	for _, cb := range cbs {
+		if !a.recursiveCallback(fn, caller.callersite[0], cb.targetFn) {
+			nonrec = append(nonrec, cb)
+		}
+	}
+	if len(nonrec) == 0 {
 		return nil //skip it, already computed enough calls
 	}
-
-	//check if fake function has spawned its own go routine
-	spawn := HasGoSpawn(fn)
+	cbs = nonrec
 
 	//check if fake function already exists
 	fakeFn, okFn := a.globalcb[key] //check if fakeFn exist for this caller (fn) and context (caller's ctx)
@@ -1270,7 +1328,7 @@ func (a *analysis) genCallBack(caller *cgnode, instr ssa.CallInstruction, fn *ss
 	}
 
 	//may have duplicate targetFn added to ir -> move it in the front to check if is duplicate under the same context; if so, return
-	if okFn && okCS && a.existTargetFn(fakeFn, targetFn) { //everything is the same, return
+	if okFn && okCS && a.existTargetFns(fakeFn, cbs) { //everything is the same, return
 		if a.online {
 			objs := make([]nodeid, len(ids))
 			for i, id := range ids {
@@ -1358,11 +1416,16 @@ func (a *analysis) genCallBack(caller *cgnode, instr ssa.CallInstruction, fn *ss
 		}
 	}
 
-	//create fake callsite and constraint for this fakeFn
-	a.genFakeConstraints(fakeFn, v, call, fakeCgns)
+	for _, cb := range cbs {
+		if a.existTargetFn(fakeFn, cb.targetFn) {
+			continue //from a previous call of fn under the same context
+		}
+		//create fake callsite and constraint for this fakeFn
+		a.genFakeConstraints(fakeFn, cb.v, call, fakeCgns)
 
-	//create/add to a basic block to hold the invoke callback fn instruction
-	fakeFn.Pkg.CreateSyntheticCallForCallBack(fakeFn, targetFn, spawn)
+		//create/add to a basic block to hold the invoke callback fn instruction
+		fakeFn.Pkg.CreateSyntheticCallForCallBack(fakeFn, cb.targetFn, cb.spawn)
+	}
 
 	//TODO: why virtual calls (also be added in AnalyzeWCtx()) has duplicate edges?
 	objs := make([]nodeid, len(ids))
@@ -1389,17 +1452,16 @@ func (a *analysis) genCallBack(caller *cgnode, instr ssa.CallInstruction, fn *ss
 
 //bz: when DoCollapse = true, ignore context
 func (a *analysis) genCallBackCollapse(caller *cgnode, instr ssa.CallInstruction, fn *ssa.Function, site *callsite, call *ssa.CallCommon,
-	targetFn ssa.Value, v ssa.Value) nodeid {
+	cbs []*callback) nodeid {
 
-	key := fn.String()      //the key of a.globalcb
-	spawn := HasGoSpawn(fn) //check if fake function has spawned its own go routine
+	key := fn.String() //the key of a.globalcb
 
 	//check if fake function already exists
 	fakeFn, okFn := a.globalcb[key] //check if fakeFn exist for this caller (fn)
 	var fakeCgn *cgnode
 	var id nodeid
 	//may have duplicate targetFn added to ir -> move it in the front to check if is duplicate; if so, return
-	if okFn && a.existTargetFn(fakeFn, targetFn) { //everything is the same, return
+	if okFn && a.existTargetFns(fakeFn, cbs) { //everything is the same, return
 		id = a.globalval[fakeFn]
 		if a.online {
 			return id + 1
@@ -1468,10 +1530,15 @@ func (a *analysis) genCallBackCollapse(caller *cgnode, instr ssa.CallInstruction
 	//create fake callsite and constraint for this fakeFn
 	fakeCgns := make([]*cgnode, 1)
 	fakeCgns[0] = fakeCgn
-	a.genFakeConstraints(fakeFn, v, call, fakeCgns)
+	for _, cb := range cbs {
+		if a.existTargetFn(fakeFn, cb.targetFn) {
+			continue
+		}
+		a.genFakeConstraints(fakeFn, cb.v, call, fakeCgns)
 
-	//create/add to a basic block to hold the invoke callback fn instruction
-	fakeFn.Pkg.CreateSyntheticCallForCallBack(fakeFn, targetFn, spawn)
+		//create/add to a basic block to hold the invoke callback fn instruction
+		fakeFn.Pkg.CreateSyntheticCallForCallBack(fakeFn, cb.targetFn, cb.spawn)
+	}
 
 	//TODO: why virtual calls (also be added in AnalyzeWCtx()) has duplicate edges?
 	obj := id + 1
@@ -1510,6 +1577,16 @@ func (a *analysis) existTargetFn(fakeFn *ssa.Function, targetFn ssa.Value) bool
 	return false
 }
 
+//bz: check existence of calls to all callbacks in cbs in the ir of fakeFn
+func (a *analysis) existTargetFns(fakeFn *ssa.Function, cbs []*callback) bool {
+	for _, cb := range cbs {
+		if !a.existTargetFn(fakeFn, cb.targetFn) {
+			return false
+		}
+	}
+	return true
+}
+
 //bz: used by a.recursiveCallback(): record the relations among: callback fn, caller lib fn and its context to avoid recursive calls
 type callbackRecord struct {
 	caller2ctx map[*ssa.Function][]*callsite //TODO: bz: now k = 1 for origin, this is ok; if k>1, this will have problem
@@ -1614,11 +1691,23 @@ func (a *analysis) isFromReflect(fn *ssa.Function) bool {
 // genStaticCall generates constraints for a statically dispatched function call.
 func (a *analysis) genStaticCall(caller *cgnode, instr ssa.CallInstruction, site *callsite, call *ssa.CallCommon, result nodeid) {
 	fn := call.StaticCallee()
+	var summary *ssa.Summary
+	if a.config.DoCallback {
+		summary = a.summaryOf(fn)
+	}
+	if summary != nil { //bz: the callbacks of fn are from its summary; the call of fn itself is generated below as usual
+		if a.log != nil {
+			fmt.Fprintf(a.log, "Summarized: %s\n", fn.String())
+		}
+		a.genCallBack(caller, instr, fn, site, call)
+	}
 	if !a.createForLevelX(caller.fn, fn) {
 		if a.log != nil {
 			fmt.Fprintf(a.log, "Level excluded: %s\n", fn.String())
 		}
-		if a.config.DoCallback {
+		if summary != nil {
+			a.genSummaryResults(caller, fn, summary, call, result) //bz: fn is not analyzed, use the results of its synthetic body
+		} else if a.config.DoCallback {
 			a.genCallBack(caller, instr, fn, site, call)
 		}
 		return
@@ -1655,7 +1744,7 @@ func (a *analysis) genStaticCall(caller *cgnode, instr ssa.CallInstruction, site
 
 	//bz: check if in skip; only if we have not create it before -> optHVN only
 	//TODO: bz: how about fn that will have multiple contexts? we did not store them in a.globalobj ...
-	if _, ok := a.globalobj[fn]; optHVN && !ok {
+	if _, ok := a.globalobj[fn]; a.optHVN && !ok {
 		//TODO: bz: this name matching is not perfect ... and this is not a good solution
 		name := fn.String()
 		name = name[0:strings.LastIndex(name, ".")] //remove func name
@@ -1712,12 +1801,15 @@ func (a *analysis) genStaticCall(caller *cgnode, instr ssa.CallInstruction, site
 					obj = a.globalval[fn] + 1 //may be directly assign a function instead of make closure
 					if obj == 1 {
 						//bz: maybe a origin-sensitive function, not a shared contour
-						_, _, obj, _ = a.existContextFor(fn, caller)
+						_, _, obj, _ = a.existContextFor(fn, caller, caller.selected)
 						if obj == 0 {
 							panic("Nil callback makeclosure func in a.existClosure: " + fn.String())
 						}
 					}
 				}
+			} else if a.byObject(fn) { //bz: the contexts are from the objects that the receiver points to, see context.go
+				a.genObjectCall(caller, site, call, fn, result)
+				return
 			} else {
 				//for kcfa: we need a new contour
 				//for origin: whatever left, we use caller context
@@ -1753,7 +1845,7 @@ func (a *analysis) genStaticCall(caller *cgnode, instr ssa.CallInstruction, site
 	}
 
 	if obj != 0 {
-		if optHVN && id != 0 { //bz: we need to make up this missing constraints
+		if a.optHVN && id != 0 { //bz: we need to make up this missing constraints
 			a.addressOf(fn.Type(), id, obj)
 			//but do we set value in a.globalobj? since fn might have multiple contexts
 		}
@@ -2432,7 +2524,10 @@ func (a *analysis) genInstr(cgn *cgnode, instr ssa.Instruction) {
 		}
 
 	case *ssa.BinOp:
-		// All no-ops.
+		// All no-ops, except uintptr arithmetic if SoundUnsafe.
+		if a.config.SoundUnsafe {
+			a.genUnsafeOffset(instr)
+		}
 
 	case ssa.CallInstruction: // *ssa.Call, *ssa.Go, *ssa.Defer
 		a.genCall(cgn, instr) // for origin, creat new contexts for *ssa.GO
@@ -2760,45 +2855,52 @@ func (a *analysis) genRootCalls() *cgnode {
 	// call edges.
 
 	// For each main package, call main.init(), main.main().
+	//bz: if there are entries, call main.init() and each entry instead, see entries.go
 	for _, mainPkg := range a.config.Mains {
-		main := mainPkg.Func("main")
-		if main == nil {
-			panic(fmt.Sprintf("%s has no main function", mainPkg))
-		}
-
-		targets := a.addOneNode(main.Signature, "root.targets", nil)
-		site := &callsite{targets: targets}
-		root.sites = append(root.sites, site)
-		for _, fn := range [2]*ssa.Function{mainPkg.Func("init"), main} {
-			if a.log != nil {
-				fmt.Fprintf(a.log, "\troot call to %s:\n", fn)
-			}
-			if a.considerMyContext(fn.String()) { //bz: give the init/main method a context, instead of using shared contour
-				a.copy(targets, a.valueNodeInvoke(root, site, fn), 1)
-			} else {
-				a.copy(targets, a.valueNode(fn), 1)
+		fns := []*ssa.Function{mainPkg.Func("init")}
+		if len(a.config.Entries) == 0 {
+			main := mainPkg.Func("main")
+			if main == nil {
+				panic(fmt.Sprintf("%s has no main function", mainPkg))
 			}
+			fns = append(fns, main)
 		}
+		a.genRootCall(root, fns)
+	}
+	for _, entry := range a.config.Entries {
+		a.genRootCall(root, []*ssa.Function{entry})
 	}
 
 	return root
 }
 
-//bz: whether the function name follows the go test form: https://golang.org/pkg/testing/
-func (a *analysis) isGoTestForm(name string) bool {
-	if strings.Contains(name, "$") {
-		return false //closure
+//bz: the root calls fns (with the same signature) at one site
+func (a *analysis) genRootCall(root *cgnode, fns []*ssa.Function) {
+	targets := a.addOneNode(fns[len(fns)-1].Signature, "root.targets", nil)
+	site := &callsite{targets: targets}
+	if main := fns[len(fns)-1]; len(a.config.Entries) == 0 && main.Name() == "main" {
+		site.main = main //bz: see contourK()
 	}
-	if strings.HasPrefix(name, "Test") || strings.HasPrefix(name, "Benchmark") || strings.HasPrefix(name, "Example") {
-		return true
+	root.sites = append(root.sites, site)
+	for _, fn := range fns {
+		if a.log != nil {
+			fmt.Fprintf(a.log, "\troot call to %s:\n", fn)
+		}
+		if a.considerMyContext(fn.String()) { //bz: give the init/main method a context, instead of using shared contour
+			a.copy(targets, a.valueNodeInvoke(root, site, fn), 1)
+		} else {
+			a.copy(targets, a.valueNode(fn), 1)
+		}
 	}
-	return false
 }
 
 // genFunc generates constraints for function fn.
 //bz: update to avoid duplicate handling of my synthetic fn/cgn
 func (a *analysis) genFunc(cgn *cgnode) {
 	fn := cgn.fn
+	owner := a.owner
+	a.owner = cgn //bz: incremental: the constraints generated from here belong to cgn, see incremental.go
+	defer func() { a.owner = owner }()
 
 	impl := a.findIntrinsic(fn)
 
@@ -2969,7 +3071,7 @@ func (a *analysis) genMethodsOf(T types.Type) {
 
 // generate generates offline constraints for the entire program.
 func (a *analysis) generate() {
-	start("Constraint generation")
+	a.start("Constraint generation")
 	if a.log != nil {
 		fmt.Fprintln(a.log, "==== Generating constraints")
 	}
@@ -2984,7 +3086,7 @@ func (a *analysis) generate() {
 	// Create nodes and constraints
 	//for all methods of reflect.rtype.
 	// (Shared contours are used by dynamic calls to reflect.Type methods---typically just String().)
-	if flags.DoPerformance {
+	if a.config.DoPerformance {
 		a.recordPreGen = true
 	}
 	if rtype := a.reflectRtypePtr; rtype != nil {
@@ -3003,7 +3105,7 @@ func (a *analysis) generate() {
 	// default code: use a.genMethodsOf()
 	// bz: skip the following code to generate fn/cgn and their constraints for shared contour,
 	//   we want it on-the-fly, or at least semi-on-the-fly (i.e., callback)
-	if a.log != nil || optHVN {
+	if a.log != nil || a.optHVN {
 		skip := 0 //bz: assist a.skipTypes
 		for _, T := range a.prog.RuntimeTypes() {
 			typ := T.String()
@@ -3014,7 +3116,7 @@ func (a *analysis) generate() {
 					fmt.Fprintf(a.log, "EXCLUDE genMethodsOf() offline for type: "+T.String()+"\n")
 				}
 			}
-			if optHVN { //record
+			if a.optHVN { //record
 				a.skipTypes[typ] = typ
 			}
 			skip++
@@ -3023,7 +3125,7 @@ func (a *analysis) generate() {
 		if a.log != nil {
 			fmt.Fprintf(a.log, "\nDone genMethodsOf() offline. \n\n")
 
-			if optHVN {
+			if a.optHVN {
 				fmt.Fprintf(a.log, "\n#Excluded types in genMethodsOf() offline (not function): %d\n", skip)
 				fmt.Fprintf(a.log, "Dump out skipped types:  \n")
 				for _, typ := range a.skipTypes {
@@ -3040,6 +3142,9 @@ func (a *analysis) generate() {
 	//Update: bz: only generate if it is in app scope, since we have a lot of untagged obj panics happens
 	//if we create constraints for some lib function here
 	for len(a.genq) > 0 {
+		if a.outOfTime() { //bz: the rest in a.genq are reported as unsound in collectUnsound()
+			break
+		}
 		cgn := a.genq[0]
 		a.genq = a.genq[1:]
 		a.genFunc(cgn)
@@ -3067,7 +3172,7 @@ func (a *analysis) generate() {
 		a.preSolve()
 	}
 
-	stop("Constraint generation")
+	a.stop("Constraint generation")
 }
 
 //bz: the scalability problem now is: there is repeated pts update for the same pointer everytime when there is new obj discovered during genConstraintsOnline()
@@ -3076,7 +3181,7 @@ func (a *analysis) generate() {
 //        because probably this obj will be propagated to the receiver later during solve(); we do the renumbering and HVN afterwards, which probably will not mess up the two opts like before
 // This generates an over-approximate result than on-the-fly
 func (a *analysis) preSolve() {
-	start("My PreSolving")
+	a.start("My PreSolving")
 	if a.log != nil {
 		fmt.Fprintln(a.log, "\n\n\n==== PreSolving Constraints")
 	}
@@ -3091,6 +3196,9 @@ func (a *analysis) preSolve() {
 	newFn := true                                            //whether this iteration has new missing functions created
 
 	for cIdx < cNextIdx || newCons || newNodes || newCB || newFn {
+		if a.outOfTime() {
+			break
+		}
 		//update idx: traverse from cidx to len(a.xxx) for this iteration
 		cIdx = cNextIdx
 		cNextIdx = len(a.constraints)
@@ -3174,7 +3282,7 @@ func (a *analysis) preSolve() {
 		//bz: from genCallBack, we solve these at the end, since preSolve() may also add new calls (multiple time in the above loop) to the following cgns
 		if len(a.gencb) > 0 {
 			newCB = true
-			for len(a.gencb) > 0 {
+			for len(a.gencb) > 0 && !a.outOfTime() {
 				cgn := a.gencb[0]
 				a.gencb = a.gencb[1:]
 				a.genFunc(cgn)
@@ -3190,7 +3298,7 @@ func (a *analysis) preSolve() {
 		fmt.Println("end of iteration ", a.curIter-1, " ------------- #origins: ", a.numOrigins, " ------------- #constraints: ", len(a.constraints),
 			" ------------- #face2invokes: ", len(iface2invoke), " ------------- #nodes: ", len(a.nodes))
 	}
-	stop("My PreSolving")
+	a.stop("My PreSolving")
 }
 
 //bz: organize invoke constraints
@@ -0,0 +1,59 @@
+package pointer
+
+// This file builds the programs in testdata/*.go for the tests of this package,
+// in the same way as doOneInput in pointer_test.go.
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/april1989/origin-go-tools/go/loader"
+	"github.com/april1989/origin-go-tools/go/ssa"
+	"github.com/april1989/origin-go-tools/go/ssa/ssautil"
+)
+
+// loadTestProgram builds the SSA program for the single-file main package in filename.
+func loadTestProgram(t testing.TB, filename string) (*ssa.Program, *ssa.Package) {
+	var conf loader.Config
+	f, err := conf.ParseFile(filename, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.CreateFromFiles("main", f)
+	iprog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog := ssautil.CreateProgram(iprog, ssa.SanityCheckFunctions)
+	prog.Build()
+	return prog, prog.Package(iprog.Created[0].Pkg)
+}
+
+// copyTestProgram writes the source in filename, changed by edit if it is not nil, to a file
+// with the same name in dir, and returns its path; for the tests that edit a program.
+func copyTestProgram(t testing.TB, dir, filename string, edit func(src string) string) string {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edit != nil {
+		src = []byte(edit(string(src)))
+	}
+	path := filepath.Join(dir, filepath.Base(filename))
+	if err := ioutil.WriteFile(path, src, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func originTestConfig(main *ssa.Package) *Config {
+	return &Config{
+		Mains:          []*ssa.Package{main},
+		BuildCallGraph: true,
+		Origin:         true,
+		K:              1,
+		LimitScope:     true,
+		Scope:          []string{"main"},
+	}
+}
@@ -245,7 +245,7 @@ func (h *hvn) ref(id onodeid) onodeid {
 // Value Numbering (HVN) algorithm described in Hardekopf & Lin, SAS'07.
 //
 func (a *analysis) hvn() {
-	start("HVN")
+	a.start("HVN")
 
 	if a.log != nil {
 		fmt.Fprintf(a.log, "\n\n==== Pointer equivalence optimization\n\n")
@@ -338,7 +338,7 @@ func (a *analysis) hvn() {
 		fmt.Fprintf(h.log, "\n==== Pointer equivalence optimization is Done\n")
 	}
 
-	stop("HVN")
+	a.stop("HVN")
 }
 
 // ---- constraint-specific rules ----
@@ -426,6 +426,16 @@ func (c *typeFilterConstraint) presolve(h *hvn) {
 	h.markIndirect(onodeid(c.dst), "typeFilter result")
 }
 
+// dst = (*typ)(src)  where src is an unsafe.Pointer
+func (c *unsafeConvConstraint) presolve(h *hvn) {
+	h.markIndirect(onodeid(c.dst), "unsafeConv result")
+}
+
+// dst = src + offset  where src is a uintptr
+func (c *unsafeOffsetConstraint) presolve(h *hvn) {
+	h.markIndirect(onodeid(c.dst), "unsafeOffset result")
+}
+
 // dst = src.(typ)  where typ is concrete
 func (c *untagConstraint) presolve(h *hvn) {
 	odst := onodeid(c.dst)
@@ -455,6 +465,27 @@ func (c *invokeConstraint) presolve(h *hvn) {
 	}
 }
 
+//bz: recv.fn(c.params...), see objectCallConstraint
+func (c *objectCallConstraint) presolve(h *hvn) {
+	// The cgnodes of fn created so far are not address-taken, but
+	// their P/R-blocks will receive labels and dynamic copy edges.
+	sig := c.fn.Signature
+	size := nodeid(h.a.sizeof(sig.Recv().Type()) + h.a.sizeof(sig.Params()) + h.a.sizeof(sig.Results()))
+	for _, idx := range h.a.fn2cgnodeIdx[c.fn] {
+		params := h.a.funcParams(h.a.cgnodes[idx].obj)
+		for id := params; id < params+size; id++ {
+			h.markIndirect(onodeid(id), "objcall P/R-block")
+		}
+	}
+
+	// Mark the caller's targets node and R-block as indirect.
+	h.markIndirect(onodeid(c.params), "objcall targets node")
+	id := c.params + 1 + nodeid(h.a.sizeof(sig.Params()))
+	for end := id + nodeid(h.a.sizeof(sig.Results())); id < end; id++ {
+		h.markIndirect(onodeid(id), "objcall R-block")
+	}
+}
+
 // markIndirectNodes marks as indirect nodes whose points-to relations
 // are not entirely captured by the offline graph, including:
 //
@@ -0,0 +1,626 @@
+package pointer
+
+// This file implements incremental re-analysis: after a source edit, the
+// previous analysis is rebound to the edited program, the constraints of the
+// changed functions are generated again, and only the points-to sets that the
+// edit can influence are solved again.
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"os"
+	"reflect"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
+)
+
+/*
+bz: how it works:
+  1 the previous analysis keeps its constraint graph (Config.Incremental = true skips releasing
+    copyTo/complex at the end of solve()) and every constraint with the cgnode that generated it,
+    i.e., its owner (nil for global ones), see analysis.history.
+  2 the cgnodes of changed functions are regenerated; the cgnodes of their closures, the contexts that
+    contain a callsite of a changed function, and the cgnodes that only the changed functions kept
+    alive (not reached in the call graph, not in any valid pts, not used by any kept constraint) are
+    removed: if the edited program still calls them, they are created again.
+  3 the constraints owned by regenerated/removed cgnodes are dropped; the nodes they write to are
+    invalid, and so is every node reachable from an invalid node in the constraint graph (copy edges +
+    edges of complex constraints, see complexEdges()). 2 and 3 are repeated until nothing changes.
+  4 the nodes, cgnodes, kept constraints and maps of the previous analysis are translated to the
+    edited program (see rebind.go): node ids do not change, valid nodes keep their pts, invalid
+    nodes start empty.
+  5 genFunc() runs only for the regenerated cgnodes, then the solver applies the kept and the new
+    constraints: labels only change for invalid nodes.
+  if this is impossible (e.g., options or signatures changed, reflection, callbacks), Reanalyze() falls
+  back to a full analysis and says why in Result.Warnings.
+*/
+
+//bz: state of an incremental analysis, stored in a.incr; nil if not incremental
+type incrState struct {
+	prev      *analysis
+	changed   map[string]bool   // fn.String() of changed functions and their closures
+	regen     map[*cgnode]bool  // cgnodes of prev whose constraints are generated again
+	removed   map[*cgnode]bool  // cgnodes of prev that do not exist in the new analysis
+	dead      nodeset           // nodes of prev that do not exist in the new analysis
+	invalid   nodeset           // nodes of prev whose pts may not hold anymore
+	kept      []ownedConstraint // the constraints of prev that are not dropped
+	dups      map[string]bool   // keys used by more than one node, see nodeKeys()
+	numReused int               // valid nodes whose pts is reused
+}
+
+//bz: a constraint and the cgnode that generated it; nil for global ones (e.g., value nodes of globals, root calls)
+type ownedConstraint struct {
+	c     constraint
+	owner *cgnode
+}
+
+//bz: rebinding prev is impossible, see rebind.go; Reanalyze() falls back to a full analysis
+type rebindError struct {
+	reason string
+}
+
+func (e rebindError) Error() string {
+	return e.reason
+}
+
+//bz: user API: re-analyze the program of config after the functions in changed have been edited,
+// borrowing the solution of prev for everything the edit cannot influence.
+// prev must have been computed with Config.Incremental = true; config is the config for the edited
+// program, which must include every function whose body changed (including removed/renamed ones
+// of the old program by name). config is not modified.
+// If the solution of prev cannot be reused (see cannotReanalyze(), e.g., Config.DoCallback is on), the program
+// is analyzed from scratch, and the reason is in the Fallback of the result.
+func Reanalyze(prev *ResultWCtx, config *Config, changed []*ssa.Function, isMain bool) (*ResultWCtx, error) {
+	if prev == nil || prev.a == nil || !prev.a.config.Incremental {
+		return nil, fmt.Errorf("previous result was not computed with Config.Incremental")
+	}
+	_config := *config
+	_config.Incremental = true //bz: so that the new result can be reused again
+
+	reason := cannotReanalyze(prev.a, &_config)
+	if reason == "" {
+		result, err := reanalyze(prev.a, &_config, changed, isMain)
+		if e, ok := err.(rebindError); ok {
+			reason = e.reason
+		} else {
+			return result, err
+		}
+	}
+	result, err := AnalyzeWCtx(&_config, false, isMain)
+	if err != nil {
+		return nil, err
+	}
+	result.Warnings = append(result.Warnings, Warning{Pos: token.NoPos, Message: "incremental: analyzed from scratch: " + reason})
+	result.Fallback = reason
+	return result, nil
+}
+
+//bz: how many nodes reuse their pts from the previous analysis
+func (r *ResultWCtx) NumReused() int {
+	if r.a.incr == nil {
+		return 0
+	}
+	return r.a.incr.numReused
+}
+
+//bz: why the solution of prev cannot be reused for config; "" if it can
+func cannotReanalyze(prev *analysis, config *Config) string {
+	old := prev.config
+	switch {
+	case prev.stopped:
+		return "the previous analysis is incomplete"
+	case !old.BuildCallGraph || !config.BuildCallGraph:
+		return "Config.BuildCallGraph is off"
+	case old.K == 0 || config.K == 0:
+		return "Config.K is 0"
+	case old.DoCallback || config.DoCallback:
+		return "Config.DoCallback is on"
+	case old.Reflection || config.Reflection:
+		return "Config.Reflection is on"
+	case old.DemandDriven || config.DemandDriven:
+		return "Config.DemandDriven is on"
+	case old.PTSLimit > 0 || config.PTSLimit > 0:
+		return "Config.PTSLimit is set"
+	case old.Provenance || config.Provenance:
+		return "Config.Provenance is on"
+	case old.SharedPTS || config.SharedPTS:
+		return "Config.SharedPTS is on"
+	case old.ParallelSolve > 1 || config.ParallelSolve > 1:
+		return "Config.ParallelSolve is on"
+	case old.DoCompare || config.DoCompare:
+		return "Config.DoCompare is on"
+	case old.Log != nil || config.Log != nil:
+		return "Config.Log is set"
+	case len(old.Queries) > 0 || len(old.IndirectQueries) > 0 || len(old.extendedQueries) > 0 ||
+		len(config.Queries) > 0 || len(config.IndirectQueries) > 0 || len(config.extendedQueries) > 0:
+		return "queries are set"
+	case !reflect.DeepEqual(old.options(), config.options()):
+		return "the options changed"
+	}
+	return ""
+}
+
+//bz: the options that the constraints depend on, besides the program
+func (c *Config) options() []interface{} {
+	var mains, entries []string
+	for _, main := range c.Mains {
+		mains = append(mains, main.Pkg.Path())
+	}
+	for _, fn := range c.Entries {
+		entries = append(entries, fn.String())
+	}
+	return []interface{}{mains, entries, c.Origin, c.CallSiteSensitive, c.K, fmt.Sprintf("%#v", c.Selector),
+		c.LimitScope, c.Scope, c.Exclusion, c.TrackMore, c.Level, c.DoTests, c.PTSummaries, c.DefaultPTSummaries,
+		c.UnsoundPTSummaries, c.SoundUnsafe, c.Sizes}
+}
+
+//bz: re-analyze by rebinding prev, see steps 2-5 above; a rebindError if this is impossible
+func reanalyze(prev *analysis, config *Config, changed []*ssa.Function, isMain bool) (result *ResultWCtx, err error) {
+	a := newAnalysis(config, isMain)
+
+	defer func() {
+		if p := recover(); p != nil {
+			if e, ok := p.(rebindError); ok {
+				err = e
+				return
+			}
+			err = fmt.Errorf("internal error in pointer analysis: %v (please report this bug)", p)
+			fmt.Fprintln(os.Stderr, "Internal panic in pointer analysis:")
+			debug.PrintStack()
+		}
+	}()
+
+	cancel, err := a.prepare()
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	phaseStart := time.Now()
+	a.incr = newIncrState(prev, changed)
+	newRebinder(a).rebind()
+	a.phase("rebind", &phaseStart)
+
+	for len(a.genq) > 0 { //bz: the regenerated cgnodes, and the cgnodes they create
+		if a.outOfTime() {
+			break
+		}
+		cgn := a.genq[0]
+		a.genq = a.genq[1:]
+		a.genFunc(cgn)
+	}
+	a.phase("generate", &phaseStart)
+
+	a.solve()
+	a.phase("solve", &phaseStart)
+
+	a.finish()
+
+	//bz: the warnings of prev and the regenerated functions may repeat
+	seen := make(map[Warning]bool)
+	warnings := a.result.Warnings[:0]
+	for _, w := range a.result.Warnings {
+		if !seen[w] {
+			seen[w] = true
+			warnings = append(warnings, w)
+		}
+	}
+	a.result.Warnings = warnings
+	return a.result, nil
+}
+
+func newIncrState(prev *analysis, changed []*ssa.Function) *incrState {
+	s := &incrState{
+		prev:    prev,
+		changed: make(map[string]bool),
+		regen:   make(map[*cgnode]bool),
+		removed: make(map[*cgnode]bool),
+		dups:    make(map[string]bool),
+	}
+	var addChanged func(fn *ssa.Function)
+	addChanged = func(fn *ssa.Function) {
+		s.changed[fn.String()] = true
+		for _, anon := range fn.AnonFuncs { //closures are renamed when their parent changes
+			addChanged(anon)
+		}
+	}
+	for _, fn := range changed {
+		addChanged(fn)
+	}
+	s.computeInvalid()
+	return s
+}
+
+//////////////////////////////// keys ////////////////////////////////
+
+func (s *incrState) fnKey(fn *ssa.Function) string {
+	if fn == nil || s.changed[fn.String()] {
+		return ""
+	}
+	return fn.String()
+}
+
+func (s *incrState) callsiteKey(c *callsite) string {
+	if c == nil {
+		return "nil"
+	}
+	loop := "L" + strconv.Itoa(c.loopID)
+	if c.instr == nil {
+		return "synthetic" + loop //ambiguous if shared, dropped by dups
+	}
+	fk := s.fnKey(c.instr.Parent())
+	if fk == "" {
+		return ""
+	}
+	return c.instr.String() + "@" + fk + loop
+}
+
+//bz: fn + context; "" if it involves a changed function
+func (s *incrState) cgnKey(cgn *cgnode) string {
+	if cgn == nil {
+		return "global"
+	}
+	key := s.fnKey(cgn.fn)
+	ctx := s.contextKey(cgn.callersite)
+	if key == "" || ctx == "" {
+		return ""
+	}
+	return key + ctx
+}
+
+//bz: "" if the context has a callsite in a changed function
+func (s *incrState) contextKey(callersite []*callsite) string {
+	key := "["
+	for _, c := range callersite {
+		ck := s.callsiteKey(c)
+		if ck == "" {
+			return ""
+		}
+		key += ck + ";"
+	}
+	return key + "]"
+}
+
+func (s *incrState) valueKey(v ssa.Value) string {
+	switch v := v.(type) {
+	case *ssa.Function:
+		if fk := s.fnKey(v); fk != "" {
+			return "f:" + fk
+		}
+	case *ssa.Global:
+		return "g:" + v.String()
+	case *ssa.Parameter:
+		if fk := s.fnKey(v.Parent()); fk != "" {
+			for i, p := range v.Parent().Params {
+				if p == v {
+					return "p" + strconv.Itoa(i) + ":" + fk
+				}
+			}
+		}
+	case *ssa.FreeVar:
+		if fk := s.fnKey(v.Parent()); fk != "" {
+			for i, fv := range v.Parent().FreeVars {
+				if fv == v {
+					return "fv" + strconv.Itoa(i) + ":" + fk
+				}
+			}
+		}
+	case ssa.Instruction:
+		if fk := s.fnKey(v.Parent()); fk != "" {
+			return "i:" + v.(ssa.Value).Name() + "=" + v.String() + ":" + fk
+		}
+	}
+	return "" //e.g., *ssa.Const
+}
+
+func (s *incrState) dataKey(data interface{}) string {
+	switch d := data.(type) {
+	case ssa.Value:
+		return s.valueKey(d)
+	case types.Type:
+		return "t:" + typeString(d)
+	case string:
+		return "s:" + d
+	}
+	return "" //allocated by an intrinsic: no identity
+}
+
+//bz: compute the keys of the nodes in a that belong to: objects from node minNode on, cgnodes in cgns
+// (values and callsites), and a.globalval if globals; ambiguous keys are dropped.
+func (s *incrState) nodeKeys(a *analysis, cgns []*cgnode, minNode int, globals bool) map[nodeid]string {
+	keys := make(map[nodeid]string)
+	seen := make(map[string]nodeid)
+	add := func(id nodeid, key string) {
+		if _, ok := keys[id]; ok {
+			return //first key wins
+		}
+		if other, ok := seen[key]; ok {
+			delete(keys, other)
+			s.dups[key] = true
+			return
+		}
+		if s.dups[key] {
+			return
+		}
+		seen[key] = id
+		keys[id] = key
+	}
+	addValue := func(v ssa.Value, id nodeid, ck string) {
+		if id == 0 || ck == "" {
+			return
+		}
+		vk := s.valueKey(v)
+		if vk == "" {
+			return
+		}
+		size := a.sizeof(v.Type())
+		for i := uint32(0); i < size; i++ {
+			add(id+nodeid(i), "v:"+vk+"@"+ck+"+"+strconv.Itoa(int(i)))
+		}
+	}
+
+	for id := minNode; id < len(a.nodes); id++ {
+		o := a.nodes[id].obj
+		if o == nil {
+			continue
+		}
+		dk := s.dataKey(o.data)
+		ck := s.cgnKey(o.cgn)
+		if dk == "" || ck == "" {
+			continue
+		}
+		size := int(o.size)
+		if size == 0 {
+			size = 1 //padding
+		}
+		for i := 0; i < size; i++ {
+			add(nodeid(id+i), "o:"+dk+"@"+ck+"+"+strconv.Itoa(i))
+		}
+	}
+	for _, cgn := range cgns {
+		ck := s.cgnKey(cgn)
+		if ck == "" {
+			continue
+		}
+		for v, id := range cgn.localval {
+			addValue(v, id, ck)
+		}
+		for i, site := range cgn.sites {
+			add(site.targets, "t:"+ck+"#"+strconv.Itoa(i))
+		}
+	}
+	if globals {
+		for v, id := range a.globalval {
+			addValue(v, id, "global")
+		}
+	}
+	return keys
+}
+
+//////////////////////////////// invalidation ////////////////////////////////
+
+//bz: compute s.regen, s.removed, s.dead, s.invalid and s.kept for s.prev; see steps 2 and 3 above
+func (s *incrState) computeInvalid() {
+	a := s.prev
+	cg := a.result.CallGraph
+
+	//nodes of each cgnode, and the cgnode of each function block
+	members := make(map[*cgnode][]nodeid)
+	blockOf := make(map[nodeid]*cgnode)
+	for id, n := range a.nodes {
+		if o := n.obj; o != nil && o.cgn != nil {
+			size := int(o.size)
+			if size == 0 {
+				size = 1
+			}
+			for i := 0; i < size; i++ {
+				members[o.cgn] = append(members[o.cgn], nodeid(id+i))
+				if nodeid(id) == o.cgn.obj {
+					blockOf[nodeid(id+i)] = o.cgn
+				}
+			}
+		}
+	}
+	for _, cgn := range a.cgnodes {
+		for _, m := range []map[ssa.Value]nodeid{cgn.localval, cgn.localobj} {
+			for v, id := range m {
+				if id == 0 {
+					continue
+				}
+				size := a.sizeof(v.Type())
+				for i := uint32(0); i < size; i++ {
+					members[cgn] = append(members[cgn], id+nodeid(i))
+				}
+			}
+		}
+		for _, site := range cgn.sites {
+			members[cgn] = append(members[cgn], site.targets)
+		}
+	}
+
+	var roots []nodeid
+	remove := func(cgn *cgnode) {
+		s.removed[cgn] = true
+		for _, id := range members[cgn] {
+			s.dead.add(id)
+			roots = append(roots, id)
+		}
+	}
+	for _, cgn := range a.cgnodes {
+		switch {
+		case s.changed[cgn.fn.String()] && cgn.fn.Parent() != nil:
+			remove(cgn) //a closure of a changed function: created again by its parent
+		case s.contextKey(cgn.callersite) == "":
+			remove(cgn) //its context has a callsite in a changed function
+		case s.changed[cgn.fn.String()]:
+			s.regen[cgn] = true //everything but its block belongs to the old body
+			for _, id := range members[cgn] {
+				if blockOf[id] != cgn {
+					s.dead.add(id)
+					roots = append(roots, id)
+				}
+			}
+		}
+	}
+	forced := make(map[*cgnode]bool, len(s.removed))
+	for cgn := range s.removed {
+		forced[cgn] = true
+	}
+
+	dropped := make([]bool, len(a.history))
+	var held, referenced map[*cgnode]bool
+	for {
+		//drop the constraints of the regenerated/removed cgnodes: what they write is invalid
+		for i, oc := range a.history {
+			if dropped[i] || oc.owner == nil || !s.regen[oc.owner] && !s.removed[oc.owner] {
+				continue
+			}
+			dropped[i] = true
+			switch c := oc.c.(type) {
+			case *addrConstraint:
+				roots = append(roots, c.dst)
+			case *copyConstraint:
+				roots = append(roots, c.dst)
+			default:
+				if !a.complexEdges(c, func(_, dst nodeid) { roots = append(roots, dst) }) {
+					panic(rebindError{fmt.Sprintf("cannot follow constraint %s", c)})
+				}
+			}
+		}
+		if !a.forwardReach(roots, &s.invalid) {
+			panic(rebindError{"cannot follow the constraints of intrinsics"})
+		}
+		roots = nil
+
+		//cgnodes that are still called: reachable from the root by the edges of kept callers and valid callsites
+		reached := map[*cgnode]bool{cg.Root.cgn: true}
+		queue := []*Node{cg.Root}
+		for len(queue) > 0 {
+			n := queue[0]
+			queue = queue[1:]
+			if s.regen[n.cgn] || s.removed[n.cgn] {
+				continue //its callees may be gone
+			}
+			for _, out := range n.Out {
+				if out.Site != nil && s.siteInvalid(n.cgn, out.Site) {
+					continue
+				}
+				if callee := out.Callee.cgn; !reached[callee] {
+					reached[callee] = true
+					queue = append(queue, out.Callee)
+				}
+			}
+		}
+		//cgnodes whose function objects are in valid pts
+		held = make(map[*cgnode]bool)
+		for id, n := range a.nodes {
+			if s.invalid.Has(id) {
+				continue
+			}
+			for _, l := range n.solve.pts.AppendTo(nil) {
+				if cgn := blockOf[nodeid(l)]; cgn != nil && cgn.obj == nodeid(l) {
+					held[cgn] = true
+				}
+			}
+		}
+		//cgnodes whose blocks are used by the kept constraints of live owners
+		dying := func(cgn *cgnode) bool {
+			return s.removed[cgn] || !reached[cgn] && !held[cgn]
+		}
+		referenced = make(map[*cgnode]bool)
+		for i, oc := range a.history {
+			if dropped[i] || oc.owner != nil && dying(oc.owner) {
+				continue
+			}
+			a.constraintNodes(oc.c, func(id nodeid) {
+				if cgn := blockOf[id]; cgn != nil && cgn != oc.owner {
+					referenced[cgn] = true
+				}
+			})
+		}
+
+		n := len(s.removed)
+		for _, cgn := range a.cgnodes {
+			if cgn != cg.Root.cgn && !s.removed[cgn] && dying(cgn) && !referenced[cgn] {
+				remove(cgn)
+			}
+		}
+		if len(s.removed) == n {
+			break
+		}
+	}
+	for cgn := range forced {
+		if held[cgn] || referenced[cgn] {
+			panic(rebindError{fmt.Sprintf("%s is still used", cgn)})
+		}
+	}
+	for cgn := range s.regen {
+		if s.removed[cgn] {
+			delete(s.regen, cgn)
+		}
+	}
+	for i, oc := range a.history {
+		if !dropped[i] {
+			s.kept = append(s.kept, oc)
+		}
+	}
+}
+
+//bz: call f for each node that c reads or writes
+func (a *analysis) constraintNodes(c constraint, f func(id nodeid)) {
+	switch c := c.(type) {
+	case *addrConstraint:
+		f(c.dst)
+		f(c.src)
+	case *copyConstraint:
+		f(c.dst)
+		f(c.src)
+	case *invokeConstraint:
+		sig := c.method.Type().(*types.Signature)
+		for i := uint32(0); i <= a.sizeof(sig.Params())+a.sizeof(sig.Results()); i++ {
+			f(c.params + nodeid(i))
+		}
+		f(c.iface)
+	default:
+		f(c.ptr())
+		a.complexEdges(c, func(src, dst nodeid) {
+			f(src)
+			f(dst)
+		})
+	}
+}
+
+//bz: whether the targets of the call instr in caller may change
+func (s *incrState) siteInvalid(caller *cgnode, instr ssa.CallInstruction) bool {
+	for _, site := range caller.sites {
+		if site.instr == instr && s.invalid.Has(int(site.targets)) {
+			return true
+		}
+	}
+	return false
+}
+
+//bz: whether fn can reuse its previous solution: not changed and none of its nodes are invalid
+func (s *incrState) canReuse(fn *ssa.Function) bool {
+	if s.fnKey(fn) == "" {
+		return false
+	}
+	for _, cgn := range s.prev.cgnodes {
+		if cgn.fn.String() != fn.String() {
+			continue
+		}
+		if s.removed[cgn] || cgn.obj != 0 && s.invalid.Has(int(cgn.obj)) {
+			return false
+		}
+		for _, id := range cgn.localval {
+			if s.invalid.Has(int(id)) {
+				return false
+			}
+		}
+	}
+	return true
+}
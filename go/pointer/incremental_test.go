@@ -0,0 +1,153 @@
+package pointer
+
+import (
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
+)
+
+// the edits of testdata/incremental.go and the functions they change
+var incrTestEdits = []struct {
+	name    string
+	old     string
+	new     string
+	changed []string
+}{
+	{ //helper returns a different allocation
+		"helper", `	return new(int)`, `	x := new(int)
+	y := new(int)
+	_ = x
+	return y`, []string{"helper"},
+	},
+	{ //main does not call helper anymore, and starts its goroutines from a different loop
+		"main", `	for i := 0; i < 3; i++ {
+		t := &T{}
+		set(t, helper())`, `	for i := 0; i < 2; i++ {
+		t := &T{}
+		set(t, new(int))`, []string{"main"},
+	},
+}
+
+// solution returns a printable form of pts(v) for every local value v in every context;
+// contexts are identified without their index, which differs if cgnodes are removed.
+func solution(r *ResultWCtx) map[string]string {
+	a := r.a
+	sol := make(map[string]string)
+	for _, cgn := range a.cgnodes {
+		ctx := cgn.String()
+		ctx = ctx[strings.Index(ctx, ":")+1:]
+		for v, id := range cgn.localval {
+			if id == 0 {
+				continue
+			}
+			var labels []string
+			for _, l := range (PointerWCtx{a, id, cgn}).PointsTo().Labels() {
+				labels = append(labels, l.String()+"@"+a.prog.Fset.Position(l.Pos()).String())
+			}
+			sort.Strings(labels)
+			sol[ctx+"|"+v.Name()+" = "+v.String()] = strings.Join(labels, ", ")
+		}
+	}
+	return sol
+}
+
+func TestReanalyze(t *testing.T) {
+	dir, err := ioutil.TempDir("", "incremental")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, edit := range incrTestEdits {
+		//both versions are in the same file, as an edit in place
+		_, main1 := loadTestProgram(t, copyTestProgram(t, dir, "testdata/incremental.go", nil))
+		config := originTestConfig(main1)
+		config.Incremental = true
+		prev, err := AnalyzeWCtx(config, false, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		//the edited program: incremental vs. from scratch
+		apply := func(src string) string { return strings.Replace(src, edit.old, edit.new, 1) }
+		path := copyTestProgram(t, dir, "testdata/incremental.go", apply)
+		_, main2 := loadTestProgram(t, path)
+		var changed []*ssa.Function
+		for _, name := range edit.changed {
+			changed = append(changed, main2.Func(name))
+		}
+		config2 := originTestConfig(main2)
+		incr, err := Reanalyze(prev, config2, changed, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config2.Incremental {
+			t.Errorf("%s: Reanalyze changed the config", edit.name)
+		}
+		_, main3 := loadTestProgram(t, path)
+		full, err := AnalyzeWCtx(originTestConfig(main3), false, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(incr.Warnings) > 0 || incr.Fallback != "" {
+			t.Errorf("%s: unexpected warnings: %v, fallback: %q", edit.name, incr.Warnings, incr.Fallback)
+		}
+		if incr.NumReused() == 0 {
+			t.Errorf("%s: Reanalyze did not reuse any pts", edit.name)
+		}
+		for cgn := range incr.a.incr.regen {
+			if name := cgn.fn.Name(); name != edit.changed[0] {
+				t.Errorf("%s: %s is regenerated", edit.name, cgn)
+			}
+		}
+		got, want := solution(incr), solution(full)
+		for k, w := range want {
+			if g, ok := got[k]; !ok || g != w {
+				t.Errorf("%s: %s: got {%s}, want {%s}", edit.name, k, g, w)
+			}
+		}
+		for k, g := range got {
+			if _, ok := want[k]; !ok {
+				t.Errorf("%s: %s: got {%s}, want nothing", edit.name, k, g)
+			}
+		}
+	}
+}
+
+// Reanalyze does not support callbacks: it analyzes from scratch and says why.
+func TestReanalyzeFallback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "incremental")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	_, main1 := loadTestProgram(t, copyTestProgram(t, dir, "testdata/incremental.go", nil))
+	config := originTestConfig(main1)
+	config.Incremental = true
+	config.DoCallback = true
+	prev, err := AnalyzeWCtx(config, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edit := incrTestEdits[0]
+	apply := func(src string) string { return strings.Replace(src, edit.old, edit.new, 1) }
+	_, main2 := loadTestProgram(t, copyTestProgram(t, dir, "testdata/incremental.go", apply))
+	config2 := originTestConfig(main2)
+	config2.DoCallback = true
+	incr, err := Reanalyze(prev, config2, []*ssa.Function{main2.Func(edit.changed[0])}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Config.DoCallback is on"; incr.Fallback != want {
+		t.Errorf("Fallback = %q, want %q", incr.Fallback, want)
+	}
+	if incr.NumReused() != 0 {
+		t.Errorf("Reanalyze from scratch reused %d pts", incr.NumReused())
+	}
+}
@@ -166,6 +166,11 @@ func (a *analysis) findIntrinsic(fn *ssa.Function) intrinsic {
 	impl, ok := a.intrinsics[fn]
 	if !ok {
 		impl = intrinsicsByName[fn.String()] // may be nil
+		if impl == nil {
+			if summary := a.ptSummaries[fn.String()]; summary != nil {
+				impl = a.ptSummaryIntrinsic(fn, summary) // may be nil
+			}
+		}
 
 		if a.isReflect(fn) {
 			if !a.config.Reflection {
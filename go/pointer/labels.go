@@ -60,6 +60,24 @@ func (l Label) Path() string {
 	return l.subelement.path()
 }
 
+// Overlaps reports whether the memory of l and m overlaps: they are
+// in the same object, and the subelement of one contains the other,
+// e.g., x and x.y, or x.y[*] and x.y[*].z.
+//
+func (l Label) Overlaps(m Label) bool {
+	if l.obj != m.obj {
+		return false
+	}
+	p, q := l.Path(), m.Path()
+	if len(p) > len(q) {
+		p, q = q, p
+	}
+	if !strings.HasPrefix(q, p) {
+		return false
+	}
+	return len(q) == len(p) || strings.IndexByte(".[#", q[len(p)]) >= 0
+}
+
 // Pos returns the position of this label, if known, zero otherwise.
 func (l Label) Pos() token.Pos {
 	switch data := l.obj.data.(type) {
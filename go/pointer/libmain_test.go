@@ -0,0 +1,110 @@
+package pointer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/april1989/origin-go-tools/go/loader"
+	"github.com/april1989/origin-go-tools/go/ssa"
+	"github.com/april1989/origin-go-tools/go/ssa/ssautil"
+)
+
+// a library without a main: the handlers registered by Handle run in goroutines, and OnError is a callback.
+const libmainTestLib = `
+package lib
+
+type Handler interface{ Serve(r *Request) }
+
+type Request struct{ Body *string }
+
+type Server struct {
+	OnError  func(err error)
+	handlers []Handler
+}
+
+func NewServer() *Server { return &Server{OnError: logError} }
+
+func logError(err error) {}
+
+func (s *Server) Handle(h Handler) { s.handlers = append(s.handlers, h) }
+
+func (s *Server) Serve(r *Request) {
+	for _, h := range s.handlers {
+		go h.Serve(r)
+	}
+}
+
+type echo struct{ out *string }
+
+func (e *echo) Serve(r *Request) { e.out = r.Body }
+`
+
+func TestLibMain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "libmain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "lib.go")
+	if err := ioutil.WriteFile(path, []byte(libmainTestLib), 0644); err != nil {
+		t.Fatal(err)
+	}
+	conf := loader.Config{}
+	f, err := conf.ParseFile(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.CreateFromFiles("lib", f)
+	iprog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog := ssautil.CreateProgram(iprog, 0)
+	prog.Build()
+	lib := prog.Package(iprog.Created[0].Pkg)
+	libmain := prog.CreateLibMainPackage(lib)
+	if libmain == nil {
+		t.Fatal("no libmain")
+	}
+
+	config := originTestConfig(libmain)
+	config.Scope = []string{"lib"}
+	result, err := Analyze(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reached := make(map[string]bool)
+	for fn := range result.GetResult().CallGraph.Fn2CGNode {
+		if fn != nil {
+			reached[fn.String()] = true
+		}
+	}
+	for _, fn := range []string{"lib.NewServer", "(*lib.Server).Serve", "(*lib.echo).Serve", "lib.logError"} {
+		if !reached[fn] {
+			t.Errorf("%s is not reached from the harness", fn)
+		}
+	}
+
+	//e.out = r.Body in the goroutine: r is the abstract *Request from the harness
+	var body ssa.Value
+	for fn := range result.GetResult().CallGraph.Fn2CGNode {
+		if fn != nil && fn.String() == "(*lib.echo).Serve" {
+			for _, instr := range fn.Blocks[0].Instrs {
+				if store, ok := instr.(*ssa.Store); ok {
+					body = store.Val
+				}
+			}
+		}
+	}
+	if body == nil || len(result.Queries[body]) == 0 {
+		t.Fatalf("no query of r.Body")
+	}
+	for _, p := range result.Queries[body] {
+		labels := p.PointsTo().Labels()
+		if len(labels) != 1 || labels[0].Value().Parent() != libmain.Func("main") {
+			t.Errorf("pts(r.Body) = %v (%s), want the abstract string of the harness", labels, p)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package pointer
+
+// This file defines the mutex query: the mutex objects that a call to a method of sync.Mutex or sync.RWMutex
+// may lock or unlock under the context of an origin.
+
+import (
+	"fmt"
+	"go/types"
+	"strings"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
+)
+
+/*
+bz: a mutex object is the node of a sync.Mutex or sync.RWMutex: an object, or a field of an object if the mutex is
+  embedded or a field of a struct, the same node as the pts of the FieldAddr, see genOffsetAddr and
+  getPointerWithOffset. the receiver of a static call points to the mutex objects; for an invoke of an interface
+  (e.g., sync.Locker), the payload of each tagged object points to the objects of its dynamic type, and we follow
+  the (embedded) fields that promote the method to the mutex.
+*/
+
+// A MutexSet is the set of mutex objects that a call may lock or unlock.
+type MutexSet struct {
+	Method string   // the name of the method, e.g., Lock or RUnlock
+	Labels []*Label // the mutex objects, with the path of the field if it is in a struct, e.g., ".mu"
+	Must   bool     // the set is a singleton of an object that stands for only one mutex at runtime
+	objs   nodeset
+}
+
+// MayAlias reports whether the calls of s and t may lock the same mutex.
+func (s *MutexSet) MayAlias(t *MutexSet) bool {
+	var z nodeset
+	z.Intersection(&s.objs.Sparse, &t.objs.Sparse)
+	return !z.IsEmpty()
+}
+
+// MustAlias reports whether the calls of s and t must lock the same mutex.
+func (s *MutexSet) MustAlias(t *MutexSet) bool {
+	return s.Must && t.Must && s.objs.Equals(&t.objs.Sparse)
+}
+
+func (s *MutexSet) String() string {
+	return fmt.Sprintf("%s %v (must: %t)", s.Method, s.Labels, s.Must)
+}
+
+//bz: the methods of sync.Mutex and sync.RWMutex that lock or unlock
+var mutexMethods = map[string]bool{
+	"Lock":     true,
+	"Unlock":   true,
+	"RLock":    true,
+	"RUnlock":  true,
+	"TryLock":  true,
+	"TryRLock": true,
+}
+
+// SyncMethod returns the type name and the method name of common if it is a static call of a method of a type
+// in package sync, e.g., "Mutex" and "Lock" for mu.Lock(), and its receiver; it returns empty names and a nil
+// receiver otherwise.
+func SyncMethod(common *ssa.CallCommon) (typ, method string, recv ssa.Value) {
+	fn := common.StaticCallee()
+	if fn == nil || fn.Signature.Recv() == nil || len(common.Args) == 0 {
+		return "", "", nil
+	}
+	t := fn.Signature.Recv().Type()
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil || named.Obj().Pkg().Path() != "sync" {
+		return "", "", nil
+	}
+	return named.Obj().Name(), fn.Name(), common.Args[0]
+}
+
+//bz: whether fn is a lock/unlock method of sync.Mutex or sync.RWMutex
+func isMutexMethod(fn *types.Func) bool {
+	sig := fn.Type().(*types.Signature)
+	if sig.Recv() == nil || !mutexMethods[fn.Name()] {
+		return false
+	}
+	t := sig.Recv().Type()
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil || named.Obj().Pkg().Path() != "sync" {
+		return false
+	}
+	name := named.Obj().Name()
+	return name == "Mutex" || name == "RWMutex"
+}
+
+// MutexesOf returns the mutex objects that call may lock or unlock in the origin of goInstr (nil for main) with
+// loopID (1 or 2 if goInstr is in a loop, 0 otherwise). call is a static call of a method of sync.Mutex or
+// sync.RWMutex, e.g., mu.Lock() or t.Lock() with an embedded mutex, or an invoke of Lock, Unlock, RLock or RUnlock
+// of an interface, e.g., sync.Locker. The result is empty if the receiver has no record under this context.
+func (r *Result) MutexesOf(call ssa.CallInstruction, goInstr *ssa.Go, loopID int) (*MutexSet, error) {
+	a := r.a
+	common := call.Common()
+	s := &MutexSet{}
+	var space [50]int
+	if common.IsInvoke() {
+		if !mutexMethods[common.Method.Name()] {
+			return nil, fmt.Errorf("%s is not a call to lock or unlock a mutex", call)
+		}
+		s.Method = common.Method.Name()
+		ptr := r.PointsToByGoWithLoopID(common.Value, goInstr, loopID)
+		if ptr.n == 0 {
+			return s, nil
+		}
+		for _, o := range a.nodes[ptr.n].solve.pts.AppendTo(space[:0]) {
+			id := nodeid(o)
+			if !a.isTaggedObject(id) {
+				continue
+			}
+			tDyn, v, indirect := a.taggedValue(id)
+			if indirect {
+				continue
+			}
+			r.addPromotedMutexes(s, tDyn, common.Method, v)
+		}
+	} else {
+		fn := common.StaticCallee()
+		if fn == nil || fn.Object() == nil || len(common.Args) == 0 || !isMutexMethod(fn.Object().(*types.Func)) {
+			return nil, fmt.Errorf("%s is not a call to lock or unlock a mutex", call)
+		}
+		s.Method = fn.Name()
+		ptr := r.PointsToByGoWithLoopID(common.Args[0], goInstr, loopID)
+		if ptr.n == 0 {
+			return s, nil
+		}
+		s.objs.addAll(a.nodes[ptr.n].solve.pts)
+	}
+
+	for _, o := range s.objs.AppendTo(space[:0]) {
+		s.Labels = append(s.Labels, a.labelFor(nodeid(o)))
+	}
+	if len(s.Labels) == 1 {
+		s.Must = r.isSingleton(s.Labels[0])
+	}
+	return s, nil
+}
+
+//bz: add to s the mutexes of the objects pointed by ptr (of type tDyn) whose method is promoted from an embedded
+// sync.Mutex or sync.RWMutex; follow the embedded fields (and their pointers) from tDyn to the mutex
+func (r *Result) addPromotedMutexes(s *MutexSet, tDyn types.Type, method *types.Func, ptr nodeid) {
+	a := r.a
+	obj, index, _ := types.LookupFieldOrMethod(tDyn, true, method.Pkg(), method.Name())
+	fn, ok := obj.(*types.Func)
+	if !ok || !isMutexMethod(fn) {
+		return
+	}
+
+	ids := &nodeset{} //the objects of the (embedded) struct at the current step
+	ids.addAll(a.nodes[ptr].solve.pts)
+	t := tDyn
+	for _, i := range index[:len(index)-1] {
+		if p, ok := t.Underlying().(*types.Pointer); ok {
+			t = p.Elem()
+		}
+		offset := a.offsetOf(t, i)
+		field := t.Underlying().(*types.Struct).Field(i)
+		next := &nodeset{}
+		var space [50]int
+		for _, o := range ids.AppendTo(space[:0]) {
+			id := nodeid(o) + nodeid(offset)
+			if _, ok := field.Type().Underlying().(*types.Pointer); ok {
+				next.addAll(a.nodes[id].solve.pts) //embedded *sync.Mutex
+			} else {
+				next.add(id)
+			}
+		}
+		ids = next
+		t = field.Type()
+	}
+	s.objs.addAll(ids)
+}
+
+//bz: whether l stands for at most one object at runtime (approximated): not an element of an array or a slice
+// (path [*]), and the object is a global, or allocated outside loops in a cgnode that is created by a single chain
+// of calls and go instructions, none of which is in a loop, from main
+func (r *Result) isSingleton(l *Label) bool {
+	if strings.Contains(l.Path(), "[*]") {
+		return false
+	}
+	obj := l.obj
+	switch v := obj.data.(type) {
+	case *ssa.Global:
+		return true
+	case ssa.Instruction:
+		if inLoop(v.Block()) {
+			return false
+		}
+	default:
+		return false
+	}
+	if obj.cgn == nil {
+		return false
+	}
+
+	cg := r.a.result.CallGraph
+	n := cg.Nodes[obj.cgn]
+	visited := make(map[*Node]bool)
+	for n != nil && n != cg.Root {
+		if visited[n] || len(n.In) != 1 {
+			return false
+		}
+		visited[n] = true
+		e := n.In[0]
+		if e.Site != nil && inLoop(e.Site.Block()) {
+			return false
+		}
+		n = e.Caller
+	}
+	return n == cg.Root
+}
+
+//bz: whether b is in a loop, i.e., b reaches itself
+func inLoop(b *ssa.BasicBlock) bool {
+	if b == nil {
+		return false
+	}
+	seen := make(map[*ssa.BasicBlock]bool)
+	stack := append([]*ssa.BasicBlock(nil), b.Succs...)
+	for len(stack) > 0 {
+		cur := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if cur == b {
+			return true
+		}
+		if !seen[cur] {
+			seen[cur] = true
+			stack = append(stack, cur.Succs...)
+		}
+	}
+	return false
+}
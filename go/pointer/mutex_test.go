@@ -0,0 +1,140 @@
+package pointer
+
+import (
+	"go/token"
+	"testing"
+
+	"github.com/april1989/origin-go-tools/go/buildutil"
+	"github.com/april1989/origin-go-tools/go/loader"
+	"github.com/april1989/origin-go-tools/go/ssa"
+	"github.com/april1989/origin-go-tools/go/ssa/ssautil"
+)
+
+// A fake package sync: the analysis does not load the standard library.
+const mutexTestSync = `
+package sync
+
+type Locker interface {
+	Lock()
+	Unlock()
+}
+
+type Mutex struct{ state int32 }
+
+func (m *Mutex) Lock()   {}
+func (m *Mutex) Unlock() {}
+
+type RWMutex struct{ w Mutex }
+
+func (rw *RWMutex) Lock()    {}
+func (rw *RWMutex) Unlock()  {}
+func (rw *RWMutex) RLock()   {}
+func (rw *RWMutex) RUnlock() {}
+`
+
+// The calls are named by their lines, see TestMutexesOf.
+const mutexTestProg = `
+package main
+
+import "sync"
+
+type T struct {
+	sync.Mutex
+	n int
+}
+
+type U struct {
+	mu sync.RWMutex
+	p  *sync.Mutex
+}
+
+var g sync.Mutex
+
+func main() {
+	t := &T{}
+	t.Lock()
+	u := &U{p: &g}
+	u.mu.RLock()
+	u.p.Lock()
+	var l sync.Locker = t
+	l.Lock()
+	for i := 0; i < 2; i++ {
+		m := new(sync.Mutex)
+		m.Lock()
+	}
+	go func() {
+		t.Lock()
+	}()
+	println(t.n)
+	var mus [8]sync.Mutex
+	mus[t.n].Lock()
+	ms := make([]sync.Mutex, t.n)
+	ms[0].Lock()
+}
+`
+
+func TestMutexesOf(t *testing.T) {
+	conf := loader.Config{Build: buildutil.FakeContext(map[string]map[string]string{
+		"sync": {"sync.go": mutexTestSync},
+		"main": {"main.go": mutexTestProg},
+	})}
+	conf.Import("main")
+	iprog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog := ssautil.CreateProgram(iprog, 0)
+	prog.Build()
+	main := prog.Package(iprog.Imported["main"].Pkg)
+
+	result, err := Analyze(originTestConfig(main))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := make(map[int]ssa.CallInstruction) //by line
+	for _, fn := range append([]*ssa.Function{main.Func("main")}, main.Func("main").AnonFuncs...) {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				if call, ok := instr.(ssa.CallInstruction); ok && call.Pos() != token.NoPos {
+					calls[prog.Fset.Position(call.Pos()).Line] = call
+				}
+			}
+		}
+	}
+	goInstr := findGo(main.Func("main"))
+	query := func(line int, goInstr *ssa.Go) *MutexSet {
+		s, err := result.MutexesOf(calls[line], goInstr, 0)
+		if err != nil {
+			t.Fatalf("line %d: %v", line, err)
+		}
+		return s
+	}
+	embedded, rlock, global, iface, loop, spawned := query(20, nil), query(22, nil), query(23, nil), query(25, nil),
+		query(28, nil), query(31, goInstr)
+
+	if len(embedded.Labels) != 1 || embedded.Labels[0].Path() != ".Mutex" || !embedded.Must {
+		t.Errorf("t.Lock(): %s", embedded)
+	}
+	if !embedded.MustAlias(iface) || !embedded.MustAlias(spawned) {
+		t.Errorf("t.Lock() and l.Lock(), go t.Lock(): %s, %s, %s", embedded, iface, spawned)
+	}
+	if rlock.Method != "RLock" || rlock.Labels[0].Path() != ".mu" || rlock.MayAlias(embedded) {
+		t.Errorf("u.mu.RLock(): %s", rlock)
+	}
+	if len(global.Labels) != 1 || global.Labels[0].Value() != main.Var("g") || !global.Must {
+		t.Errorf("u.p.Lock(): %s", global)
+	}
+	if len(loop.Labels) != 1 || loop.Must || loop.MustAlias(loop) {
+		t.Errorf("m.Lock() in a loop: %s", loop)
+	}
+	for _, line := range []int{35, 37} { //an element of an array or a slice
+		elem := query(line, nil)
+		if len(elem.Labels) != 1 || elem.Labels[0].Path() != "[*]" || elem.Must || elem.MustAlias(elem) {
+			t.Errorf("line %d: %s", line, elem)
+		}
+	}
+	if _, err := result.MutexesOf(calls[33], nil, 0); err == nil {
+		t.Errorf("no error for a call that is not a mutex call")
+	}
+}
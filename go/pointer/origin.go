@@ -0,0 +1,237 @@
+package pointer
+
+// This file defines the origin graph: the goroutine origins of a program and where they are created.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
+)
+
+/*
+bz: an origin is the main goroutine, or the goroutines created by one go instruction (callsite.goInstr); a go
+  instruction in a loop creates two origins with loopID = 1 and 2, which stand for the goroutines created by
+  different iterations. the origin graph is rooted at the main origin: the parents of an origin are the origins that
+  execute its go instruction, which may be reachable in many origins (e.g., a function called in main and in a
+  goroutine); the callee of the go instruction is analyzed once under its context, so it is one origin with many
+  parents. we compute it from the call graph: each go edge (Edge.Site is *ssa.Go) starts a new origin, and the
+  other edges stay in the origin of their callers.
+*/
+
+// An Origin is a goroutine origin.
+type Origin struct {
+	ID       int
+	Go       *ssa.Go       // the go instruction; nil for the main goroutine
+	LoopID   int           // 1 or 2 if Go is in a loop (i.e., many goroutines), 0 otherwise
+	Parent   *Origin       // the first origin found that executes Go; nil for the main goroutine
+	Parents  []*Origin     // all the origins that execute Go, Parent first
+	Children []*Origin     // the origins created by this origin
+	Entry    *ssa.Function // the function called by Go; the main function for the main goroutine
+	Nodes    []*Node       // the call graph nodes reachable in this origin, including Entry's
+}
+
+// InLoop reports whether this origin stands for many goroutines created in a loop.
+func (o *Origin) InLoop() bool {
+	return o.LoopID > 0
+}
+
+func (o *Origin) String() string {
+	if o.Go == nil {
+		return "main"
+	}
+	s := fmt.Sprintf("%s@%s", o.Go, o.Go.Parent())
+	if o.LoopID > 0 {
+		s += fmt.Sprintf(" (loop %d)", o.LoopID)
+	}
+	return s
+}
+
+// An OriginGraph is the tree of the origins of a program.
+type OriginGraph struct {
+	Root    *Origin   // the main goroutine
+	Origins []*Origin // all origins, indexed by ID
+	prog    *ssa.Program
+}
+
+func (g *OriginGraph) newOrigin(goInstr *ssa.Go, loopID int, parent *Origin, entry *ssa.Function) *Origin {
+	o := &Origin{
+		ID:     len(g.Origins),
+		Go:     goInstr,
+		LoopID: loopID,
+		Parent: parent,
+		Entry:  entry,
+	}
+	if parent != nil {
+		o.addParent(parent)
+	}
+	g.Origins = append(g.Origins, o)
+	return o
+}
+
+func (o *Origin) addParent(parent *Origin) {
+	for _, p := range o.Parents {
+		if p == parent {
+			return
+		}
+	}
+	o.Parents = append(o.Parents, parent)
+	parent.Children = append(parent.Children, o)
+}
+
+type originNode struct {
+	origin *Origin
+	node   *Node
+}
+
+// OriginGraph returns the origin graph of r, which requires Config.BuildCallGraph. The functions called from
+// the root of the call graph (main and init, or the tests) are in the main goroutine.
+func (r *ResultWCtx) OriginGraph() *OriginGraph {
+	g := &OriginGraph{prog: r.a.prog}
+	g.Root = g.newOrigin(nil, 0, nil, nil)
+	if r.main != nil {
+		g.Root.Entry = r.main.fn
+	}
+
+	spawned := make(map[*Node]*Origin) //the origin started by a node: the node has the context of its go (and loopID)
+	visited := make(map[originNode]bool)
+	var worklist []originNode
+	add := func(o *Origin, n *Node) {
+		key := originNode{o, n}
+		if !visited[key] {
+			visited[key] = true
+			o.Nodes = append(o.Nodes, n)
+			worklist = append(worklist, key)
+		}
+	}
+	for _, e := range r.CallGraph.Root.Out {
+		add(g.Root, e.Callee)
+	}
+	for len(worklist) > 0 {
+		cur := worklist[0]
+		worklist = worklist[1:]
+		for _, e := range cur.node.Out {
+			goInstr, ok := e.Site.(*ssa.Go)
+			if !ok {
+				add(cur.origin, e.Callee)
+				continue
+			}
+			child, ok := spawned[e.Callee]
+			if !ok {
+				loopID := 0
+				if ctx := e.Callee.cgn.callersite; len(ctx) > 0 && ctx[0] != nil {
+					loopID = ctx[0].loopID
+				}
+				child = g.newOrigin(goInstr, loopID, cur.origin, e.Callee.cgn.fn)
+				spawned[e.Callee] = child
+			} else {
+				child.addParent(cur.origin)
+			}
+			add(child, e.Callee)
+		}
+	}
+	return g
+}
+
+// OriginGraph returns the origin graph of r, see ResultWCtx.OriginGraph.
+func (r *Result) OriginGraph() *OriginGraph {
+	return r.a.result.OriginGraph()
+}
+
+// OriginOf returns the origins where n is reachable.
+func (g *OriginGraph) OriginOf(n *Node) []*Origin {
+	var origins []*Origin
+	for _, o := range g.Origins {
+		for _, m := range o.Nodes {
+			if m == n {
+				origins = append(origins, o)
+				break
+			}
+		}
+	}
+	return origins
+}
+
+//bz: the position of the go instruction of o; "" for the main goroutine
+func (g *OriginGraph) pos(o *Origin) string {
+	if o.Go == nil {
+		return ""
+	}
+	return g.prog.Fset.Position(o.Go.Pos()).String()
+}
+
+type jsonOrigin struct {
+	ID       int      `json:"id"`
+	Parent   int      `json:"parent"` // -1 for the main goroutine
+	Parents  []int    `json:"parents,omitempty"`
+	Go       string   `json:"go,omitempty"`
+	Pos      string   `json:"pos,omitempty"`
+	LoopID   int      `json:"loopID,omitempty"`
+	Entry    string   `json:"entry,omitempty"`
+	Children []int    `json:"children,omitempty"`
+	CGNodes  []string `json:"cgnodes"` // the functions of the reachable cgnodes
+}
+
+// WriteJSON writes g to w as a JSON array of origins, indexed by ID.
+func (g *OriginGraph) WriteJSON(w io.Writer) error {
+	origins := make([]jsonOrigin, len(g.Origins))
+	for i, o := range g.Origins {
+		jo := jsonOrigin{ID: o.ID, Parent: -1, Pos: g.pos(o), LoopID: o.LoopID}
+		if o.Parent != nil {
+			jo.Parent = o.Parent.ID
+		}
+		for _, p := range o.Parents {
+			jo.Parents = append(jo.Parents, p.ID)
+		}
+		if o.Go != nil {
+			jo.Go = o.Go.String() + "@" + o.Go.Parent().String()
+		}
+		if o.Entry != nil {
+			jo.Entry = o.Entry.String()
+		}
+		for _, c := range o.Children {
+			jo.Children = append(jo.Children, c.ID)
+		}
+		for _, n := range o.Nodes {
+			jo.CGNodes = append(jo.CGNodes, n.cgn.fn.String())
+		}
+		sort.Strings(jo.CGNodes)
+		origins[i] = jo
+	}
+	data, err := json.MarshalIndent(origins, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// WriteDOT writes g to w in the DOT format of graphviz: a node is an origin with its entry function and
+// #cgnodes, and an edge is labeled with the position of the go instruction.
+func (g *OriginGraph) WriteDOT(w io.Writer) error {
+	var buf strings.Builder
+	buf.WriteString("digraph origins {\n\tnode [shape=box];\n")
+	for _, o := range g.Origins {
+		label := o.String()
+		if o.Entry != nil {
+			label += "\n" + o.Entry.String()
+		}
+		label += fmt.Sprintf("\n#cgnodes: %d", len(o.Nodes))
+		style := ""
+		if o.InLoop() {
+			style = ", style=bold"
+		}
+		fmt.Fprintf(&buf, "\to%d [label=%q%s];\n", o.ID, label, style)
+	}
+	for _, o := range g.Origins {
+		for _, p := range o.Parents {
+			fmt.Fprintf(&buf, "\to%d -> o%d [label=%q];\n", p.ID, o.ID, g.pos(o))
+		}
+	}
+	buf.WriteString("}\n")
+	_, err := io.WriteString(w, buf.String())
+	return err
+}
@@ -0,0 +1,76 @@
+package pointer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestOriginGraph(t *testing.T) {
+	_, main := loadTestProgram(t, "testdata/origingraph.go")
+
+	result, err := Analyze(originTestConfig(main))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := result.OriginGraph()
+
+	if len(g.Origins) != 5 {
+		t.Fatalf("#origins = %d, want 5: %v", len(g.Origins), g.Origins)
+	}
+	if g.Root.Go != nil || g.Root.Entry != main.Func("main") {
+		t.Errorf("root = %s with entry %s, want main", g.Root, g.Root.Entry)
+	}
+	entries := make(map[string][]*Origin)
+	for _, o := range g.Origins[1:] {
+		entries[o.Entry.Name()] = append(entries[o.Entry.Name()], o)
+	}
+	if loop := entries["main$1"]; len(loop) != 2 || !loop[0].InLoop() || loop[0].LoopID == loop[1].LoopID {
+		t.Errorf("origins of main$1 = %v, want loop 1 and 2", loop)
+	}
+	work, leaf := entries["work"], entries["leaf"]
+	if len(work) != 1 || work[0].Parent != g.Root || work[0].InLoop() {
+		t.Fatalf("origins of work = %v, want one created by main", work)
+	}
+	if len(leaf) != 1 || len(leaf[0].Parents) != 2 || leaf[0].Parents[0] != leaf[0].Parent || len(work[0].Children) != 1 {
+		t.Fatalf("origins of leaf = %v, want one created by work and main", leaf)
+	}
+	if p := leaf[0].Parents; !(p[0] == work[0] && p[1] == g.Root) && !(p[0] == g.Root && p[1] == work[0]) {
+		t.Errorf("parents of leaf = %v, want work and main", p)
+	}
+	if n := leaf[0].Nodes; len(n) != 1 || n[0].GetFunc().Name() != "leaf" {
+		t.Errorf("cgnodes of leaf = %v", n)
+	}
+	if o := g.OriginOf(leaf[0].Nodes[0]); len(o) != 1 || o[0] != leaf[0] {
+		t.Errorf("OriginOf(leaf) = %v", o)
+	}
+
+	var buf bytes.Buffer
+	if err := g.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var origins []jsonOrigin
+	if err := json.Unmarshal(buf.Bytes(), &origins); err != nil {
+		t.Fatal(err)
+	}
+	if len(origins) != 5 || origins[0].Parent != -1 || origins[leaf[0].ID].Parent != leaf[0].Parent.ID ||
+		len(origins[leaf[0].ID].Parents) != 2 || origins[leaf[0].ID].Pos == "" {
+		t.Errorf("JSON: %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := g.WriteDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dot := buf.String()
+	for _, want := range []string{"digraph origins {", "o0 -> o", "origingraph.go:13:2", "loop 2"} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT does not contain %q:\n%s", want, dot)
+		}
+	}
+	if n := strings.Count(dot, fmt.Sprintf("-> o%d [", leaf[0].ID)); n != 2 {
+		t.Errorf("DOT has %d edges to leaf, want 2:\n%s", n, dot)
+	}
+}
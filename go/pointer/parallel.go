@@ -0,0 +1,437 @@
+package pointer
+
+// This file implements the parallel solver (Config.ParallelSolve > 1) and
+// its test mode (Config.CheckParallelSolve).
+
+import (
+	"fmt"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+bz: how it works: the solver runs in rounds; each round takes all nodes in the worklist and
+  1 (parallel, partitioned by the nodes in the worklist) computes delta(n) = pts(n) - prevPTS(n) and
+    prevPTS(n) = pts(n), and evaluates the copy edges and the simple complex constraints (load, store,
+    offsetAddr, typeFilter) of n on delta(n) into effects: pts(dst) += set/label, or a new copy edge;
+  2 (parallel, partitioned by the owner of the node that an effect writes to) applies the effects.
+    a new copy edge src -> dst is pending: src propagates its whole prevPTS along it in the next round,
+    and its pts - prevPTS as usual;
+  3 (sequential, in the order of node ids) applies the other complex constraints (invoke, intrinsics,
+    reflection ...), which may create new nodes and constraints, exactly like solveDefault().
+  -> in each phase, a node has at most one writer. the fixed point is the same as solveDefault(), however,
+     the nodes created in step 3 can have different ids, since they are created in a different order;
+     this is why the test mode compares the solutions by the keys of nodes (see diffSolution()).
+*/
+
+//bz: rounds/effects smaller than this run on one goroutine
+const minParallelWork = 8
+
+//bz: what an effect does
+const (
+	effUnion = iota // pts(dst) += *set
+	effLabel        // pts(dst) += {x}
+	effEdge         // a new copy edge x -> dst; applied by the owner of x
+)
+
+type effect struct {
+	kind int
+	dst  nodeid
+	x    nodeid
+	set  ptset
+}
+
+//bz: state of the parallel solver
+type parSolver struct {
+	a       *analysis
+	n       int                         // #goroutines
+	owners  map[*solverState]int        // owner of each solverState, if they are shared after HVN
+	pending []map[*solverState][]nodeid // pending[owner(src)][src.solve]: new copy edges from src, see step 2
+	effects [][][]effect                // effects[worker][owner]
+	changed [][]nodeid                  // changed[owner]: nodes whose pts/copyTo changed in step 2
+}
+
+//bz: whether the solve() of c only reads the pts of its node, so it can run in step 1
+func isParallelSafe(c constraint) bool {
+	switch c.(type) {
+	case *loadConstraint, *storeConstraint, *offsetAddrConstraint, *typeFilterConstraint:
+		return true
+	}
+	return false
+}
+
+//bz: solve(); use when a.config.ParallelSolve > 1 and noParallelSolve() is ""
+func (a *analysis) solveParallel() {
+	p := &parSolver{
+		a:       a,
+		n:       a.config.ParallelSolve,
+		pending: make([]map[*solverState][]nodeid, a.config.ParallelSolve),
+		changed: make([][]nodeid, a.config.ParallelSolve),
+	}
+	for i := range p.pending {
+		p.pending[i] = make(map[*solverState][]nodeid)
+	}
+	if a.optHVN {
+		p.owners = make(map[*solverState]int)
+	}
+	fmt.Println(" *** Parallel Solve:", p.n, "*** ")
+
+	numOwned := 0 // nodes with an owner in p.owners
+	for {
+		if a.outOfTime() { //bz: see collectUnsound()
+			break
+		}
+
+		a.processNewConstraints()
+
+		if p.owners != nil { //new nodes since last round
+			for ; numOwned < len(a.nodes); numOwned++ {
+				if _, ok := p.owners[a.nodes[numOwned].solve]; !ok {
+					p.owners[a.nodes[numOwned].solve] = (numOwned >> 6) % p.n
+				}
+			}
+		}
+
+		work := a.work.AppendTo(nil)
+		if len(work) == 0 {
+			break // empty worklist
+		}
+		a.work.Clear()
+		p.round(work)
+	}
+}
+
+//bz: which option needs the sequential solver; "" if none.
+// the log is in the order of solving, reflection makes typeFilterConstraint.solve() depend on the order of labels,
+// ptsLimit depends on the order of solving, and provenance/shared pts are not safe for concurrent updates
+func (a *analysis) noParallelSolve() string {
+	switch {
+	case a.config.PTSLimit > 0:
+		return "Config.PTSLimit"
+	case a.demand != nil:
+		return "Config.DemandDriven"
+	case a.log != nil:
+		return "Config.Log"
+	case a.config.Reflection:
+		return "Config.Reflection"
+	case a.prov != nil:
+		return "Config.Provenance"
+	case a.ptsTable != nil:
+		return "Config.SharedPTS"
+	}
+	return ""
+}
+
+func (p *parSolver) owner(id nodeid) int {
+	if p.owners != nil {
+		return p.owners[p.a.nodes[id].solve]
+	}
+	return int(id>>6) % p.n
+}
+
+//bz: run f(0), ..., f(k-1) in parallel
+func parallelDo(k int, f func(i int)) {
+	if k == 1 {
+		f(0)
+		return
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < k; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			f(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func (p *parSolver) round(work []int) {
+	a := p.a
+
+	if p.owners != nil { //nodes sharing a solverState after HVN: only keep the first one
+		seen := make(map[*solverState]bool)
+		i := 0
+		for _, x := range work {
+			if s := a.nodes[x].solve; !seen[s] {
+				seen[s] = true
+				work[i] = x
+				i++
+			}
+		}
+		work = work[:i]
+	}
+
+	//step 1
+	deltas := make([]nodeset, len(work))
+	workers := (len(work) + minParallelWork - 1) / minParallelWork
+	if workers > p.n {
+		workers = p.n
+	}
+	p.effects = make([][][]effect, workers)
+	size := (len(work) + workers - 1) / workers
+	parallelDo(workers, func(w int) {
+		effects := make([][]effect, p.n)
+		emit := func(owner nodeid, e effect) {
+			o := p.owner(owner)
+			effects[o] = append(effects[o], e)
+		}
+		var space, kspace [50]int
+		end := (w + 1) * size
+		if end > len(work) {
+			end = len(work)
+		}
+		for i := w * size; i < end; i++ {
+			id := nodeid(work[i])
+			n := a.nodes[id]
+			delta := &deltas[i]
+			n.solve.pts.diff(n.solve.prevPTS, delta)
+			newEdges := p.pending[p.owner(id)][n.solve]
+			if delta.IsEmpty() {
+				if len(newEdges) == 0 || n.solve.prevPTS.IsEmpty() {
+					continue
+				}
+			} else {
+				n.solve.prevPTS.assign(n.solve.pts)
+			}
+
+			for _, dst := range newEdges {
+				emit(dst, effect{kind: effUnion, dst: dst, set: n.solve.prevPTS})
+			}
+			if delta.IsEmpty() {
+				continue
+			}
+			for _, x := range n.solve.copyTo.AppendTo(space[:0]) {
+				emit(nodeid(x), effect{kind: effUnion, dst: nodeid(x), set: delta})
+			}
+			for _, c := range n.solve.complex {
+				switch c := c.(type) {
+				case *loadConstraint:
+					for _, k := range delta.AppendTo(kspace[:0]) {
+						if koff := nodeid(k) + nodeid(c.offset); koff != c.dst {
+							emit(koff, effect{kind: effEdge, dst: c.dst, x: koff})
+						}
+					}
+				case *storeConstraint:
+					for _, k := range delta.AppendTo(kspace[:0]) {
+						if koff := nodeid(k) + nodeid(c.offset); koff != c.src {
+							emit(c.src, effect{kind: effEdge, dst: koff, x: c.src})
+						}
+					}
+				case *offsetAddrConstraint:
+					for _, k := range delta.AppendTo(kspace[:0]) {
+						emit(c.dst, effect{kind: effLabel, dst: c.dst, x: nodeid(k) + nodeid(c.offset)})
+					}
+				case *typeFilterConstraint:
+					for _, k := range delta.AppendTo(kspace[:0]) {
+						ifaceObj := nodeid(k)
+						tDyn, _, indirect := a.taggedValue(ifaceObj)
+						if indirect {
+							panic("indirect tagged object")
+						}
+						if types.AssignableTo(tDyn, c.typ) {
+							emit(c.dst, effect{kind: effLabel, dst: c.dst, x: ifaceObj})
+						}
+					}
+				}
+			}
+		}
+		p.effects[w] = effects
+	})
+	for _, id := range work { //propagated
+		delete(p.pending[p.owner(nodeid(id))], a.nodes[id].solve)
+	}
+
+	//step 2
+	total := 0
+	for _, effects := range p.effects {
+		for _, effs := range effects {
+			total += len(effs)
+		}
+	}
+	apply := func(o int) {
+		var changed []nodeid
+		for _, effects := range p.effects {
+			for _, e := range effects[o] {
+				switch e.kind {
+				case effUnion:
+					if a.nodes[e.dst].solve.pts.addAll(e.set) {
+						changed = append(changed, e.dst)
+					}
+				case effLabel:
+					if a.nodes[e.dst].solve.pts.add(e.x) {
+						changed = append(changed, e.dst)
+					}
+				case effEdge:
+					if src := a.nodes[e.x].solve; src.copyTo.add(e.dst) {
+						p.pending[o][src] = append(p.pending[o][src], e.dst)
+						changed = append(changed, e.x)
+					}
+				}
+			}
+		}
+		p.changed[o] = changed
+	}
+	if total < minParallelWork {
+		for o := 0; o < p.n; o++ {
+			apply(o)
+		}
+	} else {
+		parallelDo(p.n, apply)
+	}
+	p.effects = nil
+	for o, changed := range p.changed {
+		for _, id := range changed {
+			a.addWork(id)
+		}
+		p.changed[o] = nil
+	}
+
+	//step 3
+	for i, x := range work {
+		delta := &deltas[i]
+		if delta.IsEmpty() {
+			continue
+		}
+		for _, c := range a.nodes[x].solve.complex {
+			if !isParallelSafe(c) {
+				c.solve(a, delta)
+			}
+		}
+	}
+}
+
+//bz: test mode of the parallel solver: analyze with the sequential and the parallel solver,
+// and return an error if their solutions differ
+func checkParallelSolve(config *Config, doPrintConfig bool, isMain bool) (*ResultWCtx, error) {
+	seq, par := *config, *config
+	seq.ParallelSolve, seq.CheckParallelSolve, seq.imports = 0, false, nil
+	par.CheckParallelSolve, par.imports = false, nil
+	seq.recordCreators, par.recordCreators = true, true
+
+	want, err := AnalyzeWCtx(&seq, false, isMain)
+	if err != nil {
+		return nil, err
+	}
+	got, err := AnalyzeWCtx(&par, doPrintConfig, isMain)
+	if err != nil {
+		return nil, err
+	}
+	if err := diffSolution(want.a, got.a); err != nil {
+		return nil, fmt.Errorf("internal error: parallel solver changed solution: %v", err)
+	}
+	config.imports = par.imports
+	return got, nil
+}
+
+//bz: who creates a node during solving: the cgnode whose constraints are being generated (nil if none) and
+// the comment of the node, see addOneNode()
+type nodeCreator struct {
+	owner   *cgnode
+	comment string
+}
+
+//bz: compare the solutions of x and y, two analyses of the same program with the same config: the pts of every node
+// and every call graph edge. the nodes created during solving can have different ids: they are matched by their keys
+// (see incremental.go) or their creators (see nodeCreator); nodes with the same key are compared as a multiset
+func diffSolution(x, y *analysis) error {
+	if len(x.nodes) != len(y.nodes) {
+		return fmt.Errorf("#nodes: %d vs. %d", len(x.nodes), len(y.nodes))
+	}
+	if len(x.cgnodes) != len(y.cgnodes) {
+		return fmt.Errorf("#cgnodes: %d vs. %d", len(x.cgnodes), len(y.cgnodes))
+	}
+
+	s := &incrState{changed: make(map[string]bool), dups: make(map[string]bool)}
+	xkeys, ykeys := s.solutionKeys(x), s.solutionKeys(y)
+	xpts, ypts := make(map[string][]string), make(map[string][]string)
+	for _, sol := range []struct {
+		a    *analysis
+		keys []string
+		pts  map[string][]string
+	}{{x, xkeys, xpts}, {y, ykeys, ypts}} {
+		for id, n := range sol.a.nodes {
+			var labels []string
+			for _, l := range n.solve.pts.AppendTo(nil) {
+				labels = append(labels, sol.keys[l])
+			}
+			sort.Strings(labels)
+			key := sol.keys[id]
+			sol.pts[key] = append(sol.pts[key], strings.Join(labels, ", "))
+		}
+	}
+	if len(xpts) != len(ypts) {
+		return fmt.Errorf("#node keys: %d vs. %d", len(xpts), len(ypts))
+	}
+	var keys []string
+	for key := range xpts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		px, py := xpts[key], ypts[key]
+		if py == nil {
+			return fmt.Errorf("%s is missing", key)
+		}
+		sort.Strings(px)
+		sort.Strings(py)
+		if len(px) != len(py) {
+			return fmt.Errorf("#nodes of %s: %d vs. %d", key, len(px), len(py))
+		}
+		for i := range px {
+			if px[i] != py[i] {
+				return fmt.Errorf("pts(%s) = {%s} vs. {%s}", key, px[i], py[i])
+			}
+		}
+	}
+
+	ex, ey := s.callEdges(x), s.callEdges(y)
+	if len(ex) != len(ey) {
+		return fmt.Errorf("#call graph edges: %d vs. %d", len(ex), len(ey))
+	}
+	for i := range ex {
+		if ex[i] != ey[i] {
+			return fmt.Errorf("call graph edge %s vs. %s", ex[i], ey[i])
+		}
+	}
+	return nil
+}
+
+//bz: the key of every node of a, see diffSolution(): the nodes created by generate() have the same ids
+func (s *incrState) solutionKeys(a *analysis) []string {
+	structural := s.nodeKeys(a, a.cgnodes, 0, true)
+	keys := make([]string, len(a.nodes))
+	for id := range a.nodes {
+		var c *nodeCreator
+		if id < len(a.creators) {
+			c = a.creators[id]
+		}
+		if c == nil {
+			keys[id] = "n" + strconv.Itoa(id)
+		} else if key, ok := structural[nodeid(id)]; ok && !s.dups[key] {
+			keys[id] = key
+		} else {
+			keys[id] = "c:" + s.cgnKey(c.owner) + "|" + c.comment + a.nodes[id].subelement.path()
+		}
+	}
+	return keys
+}
+
+//bz: the sorted edges of the call graph of a, as caller|site|callee
+func (s *incrState) callEdges(a *analysis) []string {
+	var edges []string
+	for cgn, n := range a.result.CallGraph.Nodes {
+		for _, out := range n.Out {
+			site := "<nil>"
+			if out.Site != nil {
+				site = out.Site.String()
+			}
+			edges = append(edges, s.cgnKey(cgn)+"|"+site+"|"+s.cgnKey(out.Callee.cgn))
+		}
+	}
+	sort.Strings(edges)
+	return edges
+}
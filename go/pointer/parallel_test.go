@@ -0,0 +1,66 @@
+package pointer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParallelSolve(t *testing.T) {
+	_, main := loadTestProgram(t, "testdata/parallel.go")
+
+	for _, callback := range []bool{false, true} { //callback: with HVN
+		config := originTestConfig(main)
+		config.DoCallback = callback
+		config.ParallelSolve = 4
+		config.CheckParallelSolve = true
+		r, err := AnalyzeWCtx(config, false, true)
+		if err != nil {
+			t.Fatalf("DoCallback = %t: %v", callback, err)
+		}
+
+		//the check must notice a different solution
+		seq := originTestConfig(main)
+		seq.DoCallback = callback
+		seq.recordCreators = true
+		want, err := AnalyzeWCtx(seq, false, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := diffSolution(want.a, r.a); err != nil {
+			t.Errorf("DoCallback = %t: %v", callback, err)
+		}
+		for _, n := range r.a.result.CallGraph.Nodes {
+			if len(n.Out) > 0 {
+				n.Out = n.Out[:len(n.Out)-1]
+				break
+			}
+		}
+		if err := diffSolution(want.a, r.a); err == nil || !strings.Contains(err.Error(), "call graph edge") {
+			t.Errorf("DoCallback = %t: diffSolution on a different call graph: got %v, want an edge error", callback, err)
+		}
+		for _, cgn := range r.a.cgnodes {
+			if cgn.fn.Name() == "get" {
+				for _, id := range cgn.localval {
+					r.a.nodes[id].solve.pts.add(r.a.panicNode + 1)
+					break
+				}
+				break
+			}
+		}
+		if err := diffSolution(want.a, r.a); err == nil || !strings.Contains(err.Error(), "pts(") {
+			t.Errorf("DoCallback = %t: diffSolution on a different solution: got %v, want a pts error", callback, err)
+		}
+	}
+
+	//options that need the sequential solver are reported
+	config := originTestConfig(main)
+	config.ParallelSolve = 4
+	config.Provenance = true
+	r, err := AnalyzeWCtx(config, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Warnings) != 1 || !strings.Contains(r.Warnings[0].Message, "Config.Provenance") {
+		t.Errorf("got warnings %v, want one for Config.Provenance", r.Warnings)
+	}
+}
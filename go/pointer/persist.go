@@ -0,0 +1,1161 @@
+package pointer
+
+// This file defines a versioned on-disk encoding of a solved analysis,
+// so that a ResultWCtx can be computed once, saved, and reloaded against
+// a freshly built ssa.Program without re-running generate() and solve().
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
+	"github.com/april1989/origin-go-tools/go/ssa/ssautil"
+)
+
+/*
+bz: persistence of a solved analysis.
+    everything that refers to the ssa.Program (functions, instructions, values, types) is written as a
+    reference by name + position (instructions by block/instr index inside their function), all nodeids
+    are written as is, so pts(·) and cgnode.obj do not need to be renumbered when reloading.
+    source files of all cgnodes are hashed, and a result is rejected if any of them changed.
+*/
+
+//bz: bump this whenever persistResult changes its layout; files with other versions are rejected
+const persistVersion = 4
+
+//bz: the builder modes that change the built instructions, so instr indexes are only stable under the same modes
+const persistModes = ssa.NaiveForm | ssa.GlobalDebug | ssa.BareInits
+
+type persistResult struct {
+	Version   int               `json:"version"`
+	Config    persistConfig     `json:"config"`
+	Files     []persistFile     `json:"files"`     // source files and their sha256
+	Funcs     []persistFunc     `json:"funcs"`     // function table, referred by index
+	Callsites []persistCallsite `json:"callsites"` // callsite table, referred by index; shared by cgnodes
+	CGNodes   []persistCGNode   `json:"cgnodes"`   // in the order of a.cgnodes
+	Nodes     []persistNode     `json:"nodes"`     // in the order of a.nodes
+	GlobalVal []persistBinding  `json:"globalval"`
+	GlobalObj []persistBinding  `json:"globalobj"`
+	Graph     persistGraph      `json:"graph"`
+	Main      int               `json:"main"`              // cgnode idx of main, -1 if none
+	Entries   []int             `json:"entries,omitempty"` // cgnode idx of the entries, see Config.Entries
+	Callbacks map[string]int    `json:"callbacks,omitempty"`
+	Warnings  []persistWarning  `json:"warnings,omitempty"`
+	Queries   []persistQuery    `json:"queries,omitempty"`
+	Indirect  []persistQuery    `json:"indirect,omitempty"`
+	Globals   []persistQuery    `json:"globals,omitempty"`
+}
+
+type persistConfig struct {
+	Main              string   `json:"main"` // pkg path of config.Mains[0]
+	IsMain            bool     `json:"isMain"`
+	Reflection        bool     `json:"reflection"`
+	CallSiteSensitive bool     `json:"kcfa"`
+	Origin            bool     `json:"origin"`
+	K                 int      `json:"k"`
+	LimitScope        bool     `json:"limitScope"`
+	Scope             []string `json:"scope,omitempty"`
+	Exclusion         []string `json:"exclusion,omitempty"`
+	DoCallback        bool     `json:"callback"`
+	Level             int      `json:"level"`
+	Mode              string   `json:"mode"` // ssa.BuilderMode of prog & persistModes
+}
+
+type persistFile struct {
+	Name string `json:"name"`
+	Hash string `json:"sha256"`
+}
+
+type persistPos struct {
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+	Col  int    `json:"col,omitempty"`
+}
+
+type persistFunc struct {
+	Name      string     `json:"name"` // fn.String()
+	Pos       persistPos `json:"pos"`
+	FromApp   bool       `json:"app,omitempty"`
+	Synthetic bool       `json:"synthetic,omitempty"` // fn.IsMySynthetic
+}
+
+type persistInstr struct {
+	Fn    int `json:"fn"`
+	Block int `json:"block"`
+	Index int `json:"index"`
+}
+
+//bz: a reference to an ssa.Value; Kind is one of "func", "global", "param", "freevar", "instr"
+type persistValue struct {
+	Kind  string        `json:"kind"`
+	Fn    int           `json:"fn,omitempty"`
+	Pkg   string        `json:"pkg,omitempty"`
+	Name  string        `json:"name,omitempty"`
+	Index int           `json:"index,omitempty"`
+	Instr *persistInstr `json:"instr,omitempty"`
+}
+
+type persistBinding struct {
+	V persistValue `json:"v"`
+	N nodeid       `json:"n"`
+}
+
+//bz: a PointerWCtx of a query
+type persistPtr struct {
+	N   nodeid `json:"n"`
+	CGN int    `json:"cgn"` // -1 if nil
+}
+
+type persistQuery struct {
+	V    persistValue `json:"v"`
+	Ptrs []persistPtr `json:"ptrs"`
+}
+
+type persistCallsite struct {
+	Targets nodeid        `json:"targets"`
+	Instr   *persistInstr `json:"instr,omitempty"`
+	LoopID  int           `json:"loop,omitempty"`
+	Go      *persistInstr `json:"go,omitempty"`
+	Main    *int          `json:"main,omitempty"` // fn idx of callsite.main
+}
+
+type persistCGNode struct {
+	Fn       int              `json:"fn"`
+	Obj      nodeid           `json:"obj"`
+	Sites    []int            `json:"sites,omitempty"`
+	Callers  []int            `json:"callers,omitempty"` // -1 for a nil callsite
+	Actual   [][]int          `json:"actual,omitempty"`
+	LocalVal []persistBinding `json:"localval,omitempty"`
+	LocalObj []persistBinding `json:"localobj,omitempty"`
+	Selected []string         `json:"selected,omitempty"` // see Config.Selector
+}
+
+//bz: object data; Kind is one of "value", "type", "string"
+type persistData struct {
+	Kind  string        `json:"kind"`
+	Value *persistValue `json:"value,omitempty"`
+	Str   string        `json:"str,omitempty"`
+}
+
+type persistObject struct {
+	Flags uint32       `json:"flags,omitempty"`
+	Size  uint32       `json:"size"`
+	CGN   int          `json:"cgn"` // -1 if nil
+	Data  *persistData `json:"data,omitempty"`
+}
+
+type persistNode struct {
+	Typ     string         `json:"typ,omitempty"`
+	Path    []string       `json:"path,omitempty"` // subelement path, one op per element
+	Obj     *persistObject `json:"obj,omitempty"`
+	Pts     []nodeid       `json:"pts,omitempty"`
+	SolveOf *nodeid        `json:"solveOf,omitempty"` // shares solver state with this node (after HVN)
+}
+
+type persistEdge struct {
+	Caller int           `json:"caller"` // Node.ID
+	Callee int           `json:"callee"` // Node.ID
+	Site   *persistInstr `json:"site,omitempty"`
+}
+
+type persistGraph struct {
+	Root  int           `json:"root"`
+	Nodes []int         `json:"nodes"` // cgnode idx, indexed by Node.ID
+	Edges []persistEdge `json:"edges"`
+}
+
+type persistWarning struct {
+	Pos     persistPos `json:"pos"`
+	Message string     `json:"msg"`
+}
+
+//////////////////////////////// save ////////////////////////////////
+
+type persistEncoder struct {
+	a      *analysis
+	out    *persistResult
+	fnIdx  map[*ssa.Function]int
+	csIdx  map[*callsite]int
+	instrs map[ssa.Instruction]*persistInstr
+	done   map[*ssa.Function]bool // functions whose instrs are already indexed
+}
+
+//bz: user API: write the solved result r to w, it can be reloaded by LoadResult()
+func (r *ResultWCtx) Save(w io.Writer) error {
+	if r.a == nil {
+		return fmt.Errorf("cannot save the result: it has no analysis attached")
+	}
+	e := &persistEncoder{
+		a:      r.a,
+		out:    &persistResult{Version: persistVersion, Main: -1},
+		fnIdx:  make(map[*ssa.Function]int),
+		csIdx:  make(map[*callsite]int),
+		instrs: make(map[ssa.Instruction]*persistInstr),
+		done:   make(map[*ssa.Function]bool),
+	}
+	if err := e.encode(r); err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	return enc.Encode(e.out)
+}
+
+//bz: user API: the same as above
+func (r *Result) Save(w io.Writer) error {
+	return r.a.result.Save(w)
+}
+
+func (e *persistEncoder) encode(r *ResultWCtx) error {
+	a := e.a
+	cfg := a.config
+	e.out.Config = persistConfig{
+		Main:              cfg.Mains[0].Pkg.Path(),
+		IsMain:            a.isMain,
+		Reflection:        cfg.Reflection,
+		CallSiteSensitive: cfg.CallSiteSensitive,
+		Origin:            cfg.Origin,
+		K:                 cfg.K,
+		LimitScope:        cfg.LimitScope,
+		Scope:             cfg.Scope,
+		Exclusion:         cfg.Exclusion,
+		DoCallback:        cfg.DoCallback,
+		Level:             cfg.Level,
+		Mode:              (a.prog.Mode() & persistModes).String(),
+	}
+
+	//cgnodes go first, so that all functions/callsites are in the tables
+	for _, cgn := range a.cgnodes {
+		pc := persistCGNode{Fn: e.fn(cgn.fn), Obj: cgn.obj}
+		for _, site := range cgn.sites {
+			pc.Sites = append(pc.Sites, e.callsite(site))
+		}
+		pc.Callers = e.callsites(cgn.callersite)
+		for _, actual := range cgn.actualCallerSite {
+			pc.Actual = append(pc.Actual, e.callsites(actual))
+		}
+		pc.LocalVal = e.bindings(cgn.localval)
+		pc.LocalObj = e.bindings(cgn.localobj)
+		pc.Selected = cgn.selected
+		e.out.CGNodes = append(e.out.CGNodes, pc)
+	}
+	e.out.GlobalVal = e.bindings(a.globalval)
+	e.out.GlobalObj = e.bindings(a.globalobj)
+
+	shared := make(map[*solverState]nodeid)
+	for id, n := range a.nodes {
+		pn := persistNode{Typ: typeString(n.typ), Path: subelementPath(n.subelement)}
+		if o := n.obj; o != nil {
+			po := &persistObject{Flags: o.flags, Size: o.size, CGN: -1, Data: e.data(o.data)}
+			if o.cgn != nil {
+				po.CGN = o.cgn.idx
+			}
+			pn.Obj = po
+		}
+		if rep, ok := shared[n.solve]; ok {
+			rep := rep
+			pn.SolveOf = &rep
+		} else {
+			shared[n.solve] = nodeid(id)
+			var space [50]int
+			for _, l := range n.solve.pts.AppendTo(space[:0]) {
+				pn.Pts = append(pn.Pts, nodeid(l))
+			}
+		}
+		e.out.Nodes = append(e.out.Nodes, pn)
+	}
+
+	//call graph: nodes in the order of their IDs
+	if cg := r.CallGraph; cg != nil {
+		nodes := make([]*Node, len(cg.Nodes))
+		for _, n := range cg.Nodes {
+			nodes[n.ID] = n
+		}
+		e.out.Graph.Root = cg.Root.ID
+		for _, n := range nodes {
+			e.out.Graph.Nodes = append(e.out.Graph.Nodes, n.cgn.idx)
+		}
+		for _, n := range nodes {
+			for _, out := range n.Out {
+				pe := persistEdge{Caller: out.Caller.ID, Callee: out.Callee.ID}
+				if out.Site != nil {
+					pe.Site = e.instr(out.Site)
+				}
+				e.out.Graph.Edges = append(e.out.Graph.Edges, pe)
+			}
+		}
+	}
+
+	if r.main != nil {
+		e.out.Main = r.main.idx
+	}
+	for _, cgn := range a.entryCGNs {
+		e.out.Entries = append(e.out.Entries, cgn.idx)
+	}
+	sort.Ints(e.out.Entries)
+	if len(a.globalcb) > 0 {
+		e.out.Callbacks = make(map[string]int)
+		for name, fn := range a.globalcb {
+			e.out.Callbacks[name] = e.fn(fn)
+		}
+	}
+	for _, w := range r.Warnings {
+		e.out.Warnings = append(e.out.Warnings, persistWarning{Pos: e.pos(w.Pos), Message: w.Message})
+	}
+	e.out.Queries = e.queries(r.Queries)
+	e.out.Indirect = e.queries(r.IndirectQueries)
+	e.out.Globals = e.queries(r.GlobalQueries)
+
+	//hash all source files that contain an analyzed function
+	files := make(map[string]bool)
+	for _, pf := range e.out.Funcs {
+		if pf.Pos.File != "" {
+			files[pf.Pos.File] = true
+		}
+	}
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		hash, err := hashFile(name)
+		if err != nil {
+			return err
+		}
+		e.out.Files = append(e.out.Files, persistFile{Name: name, Hash: hash})
+	}
+	return nil
+}
+
+func (e *persistEncoder) pos(pos token.Pos) persistPos {
+	if !pos.IsValid() {
+		return persistPos{}
+	}
+	p := e.a.prog.Fset.Position(pos)
+	return persistPos{File: p.Filename, Line: p.Line, Col: p.Column}
+}
+
+func (e *persistEncoder) fn(fn *ssa.Function) int {
+	if idx, ok := e.fnIdx[fn]; ok {
+		return idx
+	}
+	idx := len(e.out.Funcs)
+	e.out.Funcs = append(e.out.Funcs, persistFunc{
+		Name:      fn.String(),
+		Pos:       e.pos(fn.Pos()),
+		FromApp:   fn.IsFromApp,
+		Synthetic: fn.IsMySynthetic,
+	})
+	e.fnIdx[fn] = idx
+	return idx
+}
+
+func (e *persistEncoder) instr(instr ssa.Instruction) *persistInstr {
+	fn := instr.Parent()
+	if fn == nil {
+		return nil
+	}
+	if !e.done[fn] {
+		e.done[fn] = true
+		idx := e.fn(fn)
+		for b, block := range fn.Blocks {
+			for i, in := range block.Instrs {
+				e.instrs[in] = &persistInstr{Fn: idx, Block: b, Index: i}
+			}
+		}
+	}
+	return e.instrs[instr]
+}
+
+func (e *persistEncoder) callsite(c *callsite) int {
+	if c == nil {
+		return -1
+	}
+	if idx, ok := e.csIdx[c]; ok {
+		return idx
+	}
+	pc := persistCallsite{Targets: c.targets, LoopID: c.loopID}
+	if c.instr != nil {
+		pc.Instr = e.instr(c.instr)
+	}
+	if c.goInstr != nil {
+		pc.Go = e.instr(c.goInstr)
+	}
+	if c.main != nil {
+		main := e.fn(c.main)
+		pc.Main = &main
+	}
+	idx := len(e.out.Callsites)
+	e.out.Callsites = append(e.out.Callsites, pc)
+	e.csIdx[c] = idx
+	return idx
+}
+
+func (e *persistEncoder) callsites(cs []*callsite) []int {
+	if cs == nil {
+		return nil
+	}
+	r := make([]int, len(cs))
+	for i, c := range cs {
+		r[i] = e.callsite(c)
+	}
+	return r
+}
+
+//bz: values that cannot be referred (e.g., *ssa.Const, *ssa.Builtin) are skipped; their nodes are never queried
+func (e *persistEncoder) value(v ssa.Value) *persistValue {
+	switch v := v.(type) {
+	case *ssa.Function:
+		return &persistValue{Kind: "func", Fn: e.fn(v)}
+	case *ssa.Global:
+		return &persistValue{Kind: "global", Pkg: v.Pkg.Pkg.Path(), Name: v.Name()}
+	case *ssa.Parameter:
+		fn := v.Parent()
+		for i, p := range fn.Params {
+			if p == v {
+				return &persistValue{Kind: "param", Fn: e.fn(fn), Index: i}
+			}
+		}
+	case *ssa.FreeVar:
+		fn := v.Parent()
+		for i, fv := range fn.FreeVars {
+			if fv == v {
+				return &persistValue{Kind: "freevar", Fn: e.fn(fn), Index: i}
+			}
+		}
+	case ssa.Instruction:
+		if ref := e.instr(v); ref != nil {
+			return &persistValue{Kind: "instr", Instr: ref}
+		}
+	}
+	return nil
+}
+
+func (e *persistEncoder) bindings(m map[ssa.Value]nodeid) []persistBinding {
+	var r []persistBinding
+	for v, id := range m {
+		if ref := e.value(v); ref != nil {
+			r = append(r, persistBinding{V: *ref, N: id})
+		}
+	}
+	//deterministic output
+	sort.Slice(r, func(i, j int) bool {
+		if r[i].N != r[j].N {
+			return r[i].N < r[j].N
+		}
+		return persistValueKey(r[i].V) < persistValueKey(r[j].V)
+	})
+	return r
+}
+
+//bz: the same as bindings(), values that cannot be referred have no pts
+func (e *persistEncoder) queries(m map[ssa.Value][]PointerWCtx) []persistQuery {
+	var r []persistQuery
+	for v, ptrs := range m {
+		ref := e.value(v)
+		if ref == nil {
+			continue
+		}
+		q := persistQuery{V: *ref}
+		for _, ptr := range ptrs {
+			pp := persistPtr{N: ptr.n, CGN: -1}
+			if ptr.cgn != nil {
+				pp.CGN = ptr.cgn.idx
+			}
+			q.Ptrs = append(q.Ptrs, pp)
+		}
+		r = append(r, q)
+	}
+	sort.Slice(r, func(i, j int) bool {
+		return persistValueKey(r[i].V) < persistValueKey(r[j].V)
+	})
+	return r
+}
+
+func (e *persistEncoder) data(d interface{}) *persistData {
+	switch d := d.(type) {
+	case nil:
+		return nil
+	case ssa.Value:
+		if ref := e.value(d); ref != nil {
+			return &persistData{Kind: "value", Value: ref}
+		}
+		return nil
+	case types.Type:
+		return &persistData{Kind: "type", Str: typeString(d)}
+	case string:
+		return &persistData{Kind: "string", Str: d}
+	}
+	return nil
+}
+
+func persistValueKey(v persistValue) string {
+	s := v.Kind + ":" + strconv.Itoa(v.Fn) + ":" + v.Pkg + "." + v.Name + "#" + strconv.Itoa(v.Index)
+	if v.Instr != nil {
+		s += fmt.Sprintf("@%d.%d.%d", v.Instr.Fn, v.Instr.Block, v.Instr.Index)
+	}
+	return s
+}
+
+func typeString(t types.Type) string {
+	if t == nil {
+		return ""
+	}
+	return types.TypeString(t, nil)
+}
+
+//bz: encode fi.path() op by op, so that we can rebuild a fieldInfo chain with the same path()
+func subelementPath(fi *fieldInfo) []string {
+	var ops []string
+	for p := fi; p != nil; p = p.tail {
+		switch op := p.op.(type) {
+		case bool:
+			ops = append(ops, "[*]")
+		case int:
+			ops = append(ops, "#"+strconv.Itoa(op))
+		case *types.Var:
+			ops = append(ops, "."+op.Name())
+		}
+	}
+	return ops
+}
+
+func hashFile(name string) (string, error) {
+	content, err := ioutil.ReadFile(name)
+	if err != nil {
+		return "", fmt.Errorf("cannot hash source file: %v", err)
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+//////////////////////////////// load ////////////////////////////////
+
+type persistDecoder struct {
+	in    *persistResult
+	prog  *ssa.Program
+	a     *analysis
+	funcs []*ssa.Function
+	fakes map[int]bool // idx of funcs that are placeholders, see bindFuncs()
+	sites []*callsite
+	types map[string]types.Type
+}
+
+//bz: prog does not have what the result refers to, e.g., it is built from other sources or with other options
+func errMismatch(format string, args ...interface{}) error {
+	return fmt.Errorf("analysis result does not match the program: "+format, args...)
+}
+
+//bz: user API: reload a result written by Save() and rebind it to prog, which must be built from the same
+// source files and with the same ssa.BuilderMode; otherwise an error is returned. The returned result supports
+// the same query APIs as Analyze(), and its Queries/IndirectQueries/GlobalQueries are the saved ones.
+func LoadResult(r io.Reader, prog *ssa.Program) (*Result, error) {
+	in := &persistResult{}
+	if err := json.NewDecoder(r).Decode(in); err != nil {
+		return nil, fmt.Errorf("cannot decode analysis result: %v", err)
+	}
+	if in.Version != persistVersion {
+		return nil, fmt.Errorf("analysis result has version %d, want %d", in.Version, persistVersion)
+	}
+	if mode := (prog.Mode() & persistModes).String(); in.Config.Mode != mode {
+		return nil, errMismatch("built with ssa mode %q, but the program has %q", in.Config.Mode, mode)
+	}
+	for _, f := range in.Files {
+		hash, err := hashFile(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		if hash != f.Hash {
+			return nil, fmt.Errorf("stale analysis result: %s has changed", f.Name)
+		}
+	}
+
+	var main *ssa.Package
+	for _, pkg := range prog.AllPackages() {
+		if pkg.Pkg.Path() == in.Config.Main {
+			main = pkg
+			break
+		}
+	}
+	if main == nil {
+		return nil, fmt.Errorf("main package %s is not in the program", in.Config.Main)
+	}
+
+	d := &persistDecoder{in: in, prog: prog, fakes: make(map[int]bool)}
+	d.a = d.newAnalysis(main)
+	if err := d.bindFuncs(); err != nil {
+		return nil, err
+	}
+	d.collectTypes()
+	if err := d.decode(); err != nil {
+		return nil, err
+	}
+	_result := d.a.result
+	return &Result{
+		a:               d.a,
+		Queries:         _result.Queries,
+		IndirectQueries: _result.IndirectQueries,
+		GlobalQueries:   _result.GlobalQueries,
+		Warnings:        _result.Warnings,
+	}, nil
+}
+
+func (d *persistDecoder) newAnalysis(main *ssa.Package) *analysis {
+	c := d.in.Config
+	config := &Config{
+		Mains:             []*ssa.Package{main},
+		Reflection:        c.Reflection,
+		BuildCallGraph:    true,
+		CallSiteSensitive: c.CallSiteSensitive,
+		Origin:            c.Origin,
+		K:                 c.K,
+		LimitScope:        c.LimitScope,
+		Scope:             c.Scope,
+		Exclusion:         c.Exclusion,
+		DoCallback:        c.DoCallback,
+		Level:             c.Level,
+	}
+	return &analysis{
+		config:      config,
+		prog:        d.prog,
+		globalval:   make(map[ssa.Value]nodeid),
+		globalobj:   make(map[ssa.Value]nodeid),
+		flattenMemo: make(map[types.Type][]*fieldInfo),
+		trackTypes:  make(map[types.Type]bool),
+		atFuncs:     make(map[*ssa.Function]bool),
+		intrinsics:  make(map[*ssa.Function]intrinsic),
+		result: &ResultWCtx{
+			Queries:         make(map[ssa.Value][]PointerWCtx),
+			IndirectQueries: make(map[ssa.Value][]PointerWCtx),
+			GlobalQueries:   make(map[ssa.Value][]PointerWCtx),
+			ExtendedQueries: make(map[ssa.Value][]PointerWCtx),
+		},
+		fn2cgnodeIdx: make(map[*ssa.Function][]int),
+		closures:     make(map[*ssa.Function]*Ctx2nodeid),
+		closureWOGo:  make(map[nodeid]nodeid),
+		skipTypes:    make(map[string]string),
+		callbacks:    make(map[*ssa.Function]*Ctx2nodeid),
+		globalcb:     make(map[string]*ssa.Function),
+		cb2Callers:   make(map[*ssa.Function]*callbackRecord),
+		isMain:       c.IsMain,
+		track:        trackAll,
+	}
+}
+
+//bz: match each recorded function by name and position; only functions that never exist in prog
+// (<root> and synthetic fns for callbacks) get a placeholder with the same name, any other missing
+// function is an error
+func (d *persistDecoder) bindFuncs() error {
+	byName := make(map[string][]*ssa.Function)
+	for fn := range ssautil.AllFunctions(d.prog) {
+		byName[fn.String()] = append(byName[fn.String()], fn)
+	}
+	for idx, pf := range d.in.Funcs {
+		var match *ssa.Function
+		for _, fn := range byName[pf.Name] {
+			if samePos(d.prog.Fset, fn.Pos(), pf.Pos) {
+				match = fn
+				break
+			}
+		}
+		if match == nil {
+			if !pf.Synthetic && pf.Name != "<root>" {
+				if pf.Pos.File == "" {
+					return errMismatch("no function %s", pf.Name)
+				}
+				return errMismatch("no function %s at %s:%d:%d", pf.Name, pf.Pos.File, pf.Pos.Line, pf.Pos.Col)
+			}
+			name := pf.Name
+			if i := strings.LastIndex(name, "."); i >= 0 && pf.Synthetic {
+				name = name[i+1:]
+			}
+			match = d.prog.NewFunction(name, new(types.Signature), "reloaded analysis result")
+			match.IsMySynthetic = pf.Synthetic
+			d.fakes[idx] = true
+		}
+		if pf.FromApp {
+			match.IsFromApp = true
+		}
+		d.funcs = append(d.funcs, match)
+	}
+	return nil
+}
+
+func samePos(fset *token.FileSet, pos token.Pos, pp persistPos) bool {
+	if !pos.IsValid() {
+		return pp.File == ""
+	}
+	p := fset.Position(pos)
+	return p.Filename == pp.File && p.Line == pp.Line && p.Column == pp.Col
+}
+
+//bz: index all types reachable from prog by their printed form, to rebind node.typ and type data of objects
+func (d *persistDecoder) collectTypes() {
+	d.types = make(map[string]types.Type)
+	for _, t := range types.Typ {
+		d.addType(t)
+	}
+	for _, T := range d.prog.RuntimeTypes() {
+		d.addType(T)
+	}
+	for _, pkg := range d.prog.AllPackages() {
+		for _, mem := range pkg.Members {
+			d.addType(mem.Type())
+		}
+	}
+	for _, fn := range d.funcs {
+		d.addType(fn.Signature)
+		for _, fv := range fn.FreeVars {
+			d.addType(fv.Type())
+		}
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				if v, ok := instr.(ssa.Value); ok {
+					d.addType(v.Type())
+				}
+				var rands [10]*ssa.Value
+				for _, rand := range instr.Operands(rands[:0]) {
+					if *rand != nil {
+						d.addType((*rand).Type())
+					}
+				}
+			}
+		}
+	}
+}
+
+func (d *persistDecoder) addType(t types.Type) {
+	if t == nil {
+		return
+	}
+	s := typeString(t)
+	if _, ok := d.types[s]; ok {
+		return
+	}
+	d.types[s] = t
+	switch t := t.(type) {
+	case *types.Named:
+		d.addType(t.Underlying())
+	case *types.Pointer:
+		d.addType(t.Elem())
+	case *types.Slice:
+		d.addType(t.Elem())
+		d.addType(sliceToArray(t))
+	case *types.Array:
+		d.addType(t.Elem())
+	case *types.Chan:
+		d.addType(t.Elem())
+	case *types.Map:
+		d.addType(t.Key())
+		d.addType(t.Elem())
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			d.addType(t.Field(i).Type())
+		}
+	case *types.Tuple:
+		for i := 0; i < t.Len(); i++ {
+			d.addType(t.At(i).Type())
+		}
+	case *types.Signature:
+		if recv := t.Recv(); recv != nil {
+			d.addType(recv.Type())
+		}
+		d.addType(t.Params())
+		d.addType(t.Results())
+	}
+}
+
+//bz: nil for a nil pi, or an instr of a placeholder, whose body is not reloaded
+func (d *persistDecoder) instr(pi *persistInstr) (ssa.Instruction, error) {
+	if pi == nil {
+		return nil, nil
+	}
+	fn, err := d.fn(pi.Fn)
+	if err != nil || d.fakes[pi.Fn] {
+		return nil, err
+	}
+	if pi.Block < 0 || pi.Block >= len(fn.Blocks) || pi.Index < 0 || pi.Index >= len(fn.Blocks[pi.Block].Instrs) {
+		return nil, errMismatch("%s has no instruction %d.%d", fn, pi.Block, pi.Index)
+	}
+	return fn.Blocks[pi.Block].Instrs[pi.Index], nil
+}
+
+//bz: nil for a param, freevar or instr of a placeholder, see instr()
+func (d *persistDecoder) value(pv *persistValue) (ssa.Value, error) {
+	switch pv.Kind {
+	case "func":
+		return d.fn(pv.Fn)
+	case "global":
+		for _, pkg := range d.prog.AllPackages() {
+			if pkg.Pkg.Path() == pv.Pkg {
+				if g, ok := pkg.Members[pv.Name].(*ssa.Global); ok {
+					return g, nil
+				}
+			}
+		}
+		return nil, errMismatch("no global %s.%s", pv.Pkg, pv.Name)
+	case "param":
+		fn, err := d.fn(pv.Fn)
+		if err != nil || d.fakes[pv.Fn] {
+			return nil, err
+		}
+		if pv.Index < 0 || pv.Index >= len(fn.Params) {
+			return nil, errMismatch("%s has no parameter %d", fn, pv.Index)
+		}
+		return fn.Params[pv.Index], nil
+	case "freevar":
+		fn, err := d.fn(pv.Fn)
+		if err != nil || d.fakes[pv.Fn] {
+			return nil, err
+		}
+		if pv.Index < 0 || pv.Index >= len(fn.FreeVars) {
+			return nil, errMismatch("%s has no free variable %d", fn, pv.Index)
+		}
+		return fn.FreeVars[pv.Index], nil
+	case "instr":
+		instr, err := d.instr(pv.Instr)
+		if instr == nil {
+			return nil, err
+		}
+		v, ok := instr.(ssa.Value)
+		if !ok {
+			return nil, errMismatch("%s of %s is not a value", instr, instr.Parent())
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("corrupted analysis result: unknown value kind %q", pv.Kind)
+}
+
+func (d *persistDecoder) bindings(bs []persistBinding, m map[ssa.Value]nodeid) error {
+	for _, b := range bs {
+		if int(b.N) >= len(d.in.Nodes) {
+			return fmt.Errorf("corrupted analysis result: no node n%d", b.N)
+		}
+		v, err := d.value(&b.V)
+		if err != nil {
+			return err
+		}
+		if v != nil {
+			m[v] = b.N
+		}
+	}
+	return nil
+}
+
+func (d *persistDecoder) queries(qs []persistQuery, m map[ssa.Value][]PointerWCtx) error {
+	for _, q := range qs {
+		v, err := d.value(&q.V)
+		if err != nil {
+			return err
+		}
+		if v == nil {
+			continue
+		}
+		for _, pp := range q.Ptrs {
+			if int(pp.N) >= len(d.in.Nodes) {
+				return fmt.Errorf("corrupted analysis result: no node n%d", pp.N)
+			}
+			ptr := PointerWCtx{a: d.a, n: pp.N}
+			if pp.CGN >= 0 {
+				cgn, err := d.cgnode(pp.CGN)
+				if err != nil {
+					return err
+				}
+				ptr.cgn = cgn
+			}
+			m[v] = append(m[v], ptr)
+		}
+	}
+	return nil
+}
+
+func (d *persistDecoder) callsites(idxs []int) ([]*callsite, error) {
+	if idxs == nil {
+		return nil, nil
+	}
+	r := make([]*callsite, len(idxs))
+	for i, idx := range idxs {
+		if idx >= len(d.sites) {
+			return nil, fmt.Errorf("corrupted analysis result: no call site %d", idx)
+		}
+		if idx >= 0 {
+			r[i] = d.sites[idx]
+		}
+	}
+	return r, nil
+}
+
+//bz: the checked d.funcs[idx]
+func (d *persistDecoder) fn(idx int) (*ssa.Function, error) {
+	if idx < 0 || idx >= len(d.funcs) {
+		return nil, fmt.Errorf("corrupted analysis result: no function %d", idx)
+	}
+	return d.funcs[idx], nil
+}
+
+//bz: the checked a.cgnodes[idx]
+func (d *persistDecoder) cgnode(idx int) (*cgnode, error) {
+	if idx < 0 || idx >= len(d.a.cgnodes) {
+		return nil, fmt.Errorf("corrupted analysis result: no cgnode %d", idx)
+	}
+	return d.a.cgnodes[idx], nil
+}
+
+func (d *persistDecoder) decode() error {
+	a, in := d.a, d.in
+
+	for _, pc := range in.Callsites {
+		c := &callsite{targets: pc.Targets, loopID: pc.LoopID}
+		instr, err := d.instr(pc.Instr)
+		if err != nil {
+			return err
+		}
+		if instr != nil {
+			call, ok := instr.(ssa.CallInstruction)
+			if !ok {
+				return errMismatch("%s of %s is not a call", instr, instr.Parent())
+			}
+			c.instr = call
+		}
+		goInstr, err := d.instr(pc.Go)
+		if err != nil {
+			return err
+		}
+		if goInstr != nil {
+			g, ok := goInstr.(*ssa.Go)
+			if !ok {
+				return errMismatch("%s of %s is not a go statement", goInstr, goInstr.Parent())
+			}
+			c.goInstr = g
+		}
+		if pc.Main != nil {
+			if c.main, err = d.fn(*pc.Main); err != nil {
+				return err
+			}
+		}
+		d.sites = append(d.sites, c)
+	}
+
+	for idx, pc := range in.CGNodes {
+		fn, err := d.fn(pc.Fn)
+		if err != nil {
+			return err
+		}
+		sites, err := d.callsites(pc.Sites)
+		if err != nil {
+			return err
+		}
+		callers, err := d.callsites(pc.Callers)
+		if err != nil {
+			return err
+		}
+		cgn := &cgnode{
+			fn:         fn,
+			obj:        pc.Obj,
+			sites:      sites,
+			callersite: callers,
+			idx:        idx,
+			localval:   make(map[ssa.Value]nodeid),
+			localobj:   make(map[ssa.Value]nodeid),
+			selected:   pc.Selected,
+		}
+		for _, actual := range pc.Actual {
+			actualSites, err := d.callsites(actual)
+			if err != nil {
+				return err
+			}
+			cgn.actualCallerSite = append(cgn.actualCallerSite, actualSites)
+		}
+		if err := d.bindings(pc.LocalVal, cgn.localval); err != nil {
+			return err
+		}
+		if err := d.bindings(pc.LocalObj, cgn.localobj); err != nil {
+			return err
+		}
+		a.cgnodes = append(a.cgnodes, cgn)
+		a.fn2cgnodeIdx[cgn.fn] = append(a.fn2cgnodeIdx[cgn.fn], idx)
+	}
+	if err := d.bindings(in.GlobalVal, a.globalval); err != nil {
+		return err
+	}
+	if err := d.bindings(in.GlobalObj, a.globalobj); err != nil {
+		return err
+	}
+
+	for id, pn := range in.Nodes {
+		n := &node{typ: d.types[pn.Typ], subelement: rebuildSubelement(pn.Path)}
+		if pn.Typ == typeString(tInvalid) {
+			n.typ = tInvalid
+		}
+		if pn.SolveOf != nil {
+			if int(*pn.SolveOf) >= id {
+				return fmt.Errorf("corrupted analysis result: n%d shares solver state with n%d", id, *pn.SolveOf)
+			}
+			n.solve = a.nodes[*pn.SolveOf].solve
+		} else {
+			n.solve = a.newSolverState()
+			for _, l := range pn.Pts {
+				if int(l) >= len(in.Nodes) {
+					return fmt.Errorf("corrupted analysis result: n%d points to n%d", id, l)
+				}
+				n.solve.pts.add(l)
+			}
+		}
+		if po := pn.Obj; po != nil {
+			if id+int(po.Size) > len(in.Nodes) {
+				return fmt.Errorf("corrupted analysis result: object n%d of size %d is out of bounds", id, po.Size)
+			}
+			o := &object{flags: po.Flags, size: po.Size}
+			if po.CGN >= 0 {
+				cgn, err := d.cgnode(po.CGN)
+				if err != nil {
+					return err
+				}
+				o.cgn = cgn
+			}
+			if pd := po.Data; pd != nil {
+				switch pd.Kind {
+				case "value":
+					v, err := d.value(pd.Value)
+					if err != nil {
+						return err
+					}
+					if v != nil {
+						o.data = v
+					}
+				case "type":
+					if t := d.types[pd.Str]; t != nil {
+						o.data = t
+					}
+				case "string":
+					o.data = pd.Str
+				}
+			}
+			n.obj = o
+		}
+		a.nodes = append(a.nodes, n)
+	}
+
+	cg := &GraphWCtx{Nodes: make(map[*cgnode]*Node), Fn2CGNode: make(map[*ssa.Function][]*cgnode)}
+	nodes := make([]*Node, len(in.Graph.Nodes))
+	for id, idx := range in.Graph.Nodes {
+		cgn, err := d.cgnode(idx)
+		if err != nil {
+			return err
+		}
+		nodes[id] = cg.CreateNodeWCtx(cgn)
+	}
+	if len(nodes) > 0 {
+		if in.Graph.Root < 0 || in.Graph.Root >= len(nodes) {
+			return fmt.Errorf("corrupted analysis result: no call graph root %d", in.Graph.Root)
+		}
+		cg.Root = nodes[in.Graph.Root]
+	}
+	for _, pe := range in.Graph.Edges {
+		if pe.Caller < 0 || pe.Caller >= len(nodes) || pe.Callee < 0 || pe.Callee >= len(nodes) {
+			return fmt.Errorf("corrupted analysis result: call graph edge %d -> %d", pe.Caller, pe.Callee)
+		}
+		instr, err := d.instr(pe.Site)
+		if err != nil {
+			return err
+		}
+		site, _ := instr.(ssa.CallInstruction)
+		cg.AddEdge(nodes[pe.Caller], site, nodes[pe.Callee])
+	}
+	cg.computeFn2CGNode()
+	a.result.CallGraph = cg
+
+	if in.Main >= 0 {
+		main, err := d.cgnode(in.Main)
+		if err != nil {
+			return err
+		}
+		a.result.main = main
+	}
+	if len(in.Entries) > 0 {
+		a.entries = make(map[*ssa.Function]bool)
+		a.entryCGNs = make(map[*ssa.Function]*cgnode)
+		for _, idx := range in.Entries {
+			cgn, err := d.cgnode(idx)
+			if err != nil {
+				return err
+			}
+			a.entries[cgn.fn] = true
+			a.entryCGNs[cgn.fn] = cgn
+		}
+	}
+	for name, idx := range in.Callbacks {
+		fn, err := d.fn(idx)
+		if err != nil {
+			return err
+		}
+		a.globalcb[name] = fn
+	}
+	for _, w := range in.Warnings {
+		a.result.Warnings = append(a.result.Warnings, Warning{Pos: d.pos(w.Pos), Message: w.Message})
+	}
+	if err := d.queries(in.Queries, a.result.Queries); err != nil {
+		return err
+	}
+	if err := d.queries(in.Indirect, a.result.IndirectQueries); err != nil {
+		return err
+	}
+	if err := d.queries(in.Globals, a.result.GlobalQueries); err != nil {
+		return err
+	}
+	a.result.a = a
+	return nil
+}
+
+//bz: map a recorded position back to a token.Pos of prog.Fset; a position without column (Col == 0) maps to its line start
+func (d *persistDecoder) pos(pp persistPos) token.Pos {
+	if pp.File == "" {
+		return token.NoPos
+	}
+	pos := token.NoPos
+	d.prog.Fset.Iterate(func(f *token.File) bool {
+		if f.Name() != pp.File {
+			return true
+		}
+		if pp.Line > 0 && pp.Line <= f.LineCount() {
+			pos = f.LineStart(pp.Line)
+			if pp.Col > 0 {
+				pos += token.Pos(pp.Col - 1)
+			}
+		}
+		return false
+	})
+	return pos
+}
+
+func rebuildSubelement(ops []string) *fieldInfo {
+	var head, last *fieldInfo
+	for _, op := range ops {
+		fi := &fieldInfo{}
+		switch {
+		case op == "[*]":
+			fi.op = true
+		case strings.HasPrefix(op, "#"):
+			i, _ := strconv.Atoi(op[1:])
+			fi.op = i
+		case strings.HasPrefix(op, "."):
+			fi.op = types.NewVar(token.NoPos, nil, op[1:], nil)
+		}
+		if head == nil {
+			head = fi
+		} else {
+			last.tail = fi
+		}
+		last = fi
+	}
+	return head
+}
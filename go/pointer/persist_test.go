@@ -0,0 +1,254 @@
+package pointer
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
+)
+
+// findGo returns the go instruction in fn.
+func findGo(fn *ssa.Function) *ssa.Go {
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			if g, ok := instr.(*ssa.Go); ok {
+				return g
+			}
+		}
+	}
+	return nil
+}
+
+func TestSaveLoadResult(t *testing.T) {
+	dir, err := ioutil.TempDir("", "persist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := copyTestProgram(t, dir, "testdata/origin.go", nil) //changed below
+	prog, main := loadTestProgram(t, path)
+	result, err := AnalyzeWCtx(originTestConfig(main), false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := translateResult(result, main)
+
+	var buf bytes.Buffer
+	if err := r.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	saved := buf.String()
+
+	//reload into a fresh program built from the same file
+	prog2, main2 := loadTestProgram(t, path)
+	r2, err := LoadResult(strings.NewReader(saved), prog2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cg, cg2 := r.GetResult().CallGraph, r2.GetResult().CallGraph
+	if len(cg.Nodes) != len(cg2.Nodes) || cg.GetNumEdges() != cg2.GetNumEdges() {
+		t.Errorf("call graph: got %d nodes/%d edges, want %d/%d",
+			len(cg2.Nodes), cg2.GetNumEdges(), len(cg.Nodes), cg.GetNumEdges())
+	}
+	for _, n := range cg.Nodes {
+		n2 := cg2.Nodes[r2.a.cgnodes[n.cgn.idx]]
+		if n2 == nil || n.String() != n2.String() {
+			t.Errorf("call graph node %s is not reloaded: %v", n, n2)
+		}
+	}
+
+	//the same query in both programs under the same origin
+	query := func(prog *ssa.Program, main *ssa.Package, r *Result) []string {
+		worker := main.Func("worker")
+		goInstr := findGo(main.Func("main"))
+		var labels []string
+		for _, loopID := range []int{1, 2} {
+			p := r.PointsToByGoWithLoopID(worker.Params[1], goInstr, loopID)
+			if p.a == nil {
+				continue
+			}
+			for _, l := range p.PointsTo().Labels() {
+				labels = append(labels, prog.Fset.Position(l.Pos()).String()+l.String())
+			}
+		}
+		return labels
+	}
+	want := query(prog, main, r)
+	got := query(prog2, main2, r2)
+	if len(want) == 0 || strings.Join(got, ";") != strings.Join(want, ";") {
+		t.Errorf("pts(worker.c) after reload = %v, want %v", got, want)
+	}
+
+	//the saved queries are restored, with the same pts
+	for _, test := range []struct {
+		name      string
+		want, got map[ssa.Value][]PointerWCtx
+	}{
+		{"Queries", r.Queries, r2.Queries},
+		{"IndirectQueries", r.IndirectQueries, r2.IndirectQueries},
+		{"GlobalQueries", r.GlobalQueries, r2.GlobalQueries},
+	} {
+		pts := func(prog *ssa.Program, m map[ssa.Value][]PointerWCtx) map[string]string {
+			r := make(map[string]string)
+			for v, ptrs := range m {
+				if _, ok := v.(*ssa.Const); ok {
+					continue //not saved
+				}
+				key := v.Name() + "@" + prog.Fset.Position(v.Pos()).String()
+				if fn := v.Parent(); fn != nil {
+					key = fn.String() + "." + key
+				}
+				var labels []string
+				for _, ptr := range ptrs {
+					for _, l := range ptr.PointsTo().Labels() {
+						labels = append(labels, prog.Fset.Position(l.Pos()).String()+l.String())
+					}
+				}
+				r[key] += strings.Join(labels, ";") + "|"
+			}
+			return r
+		}
+		want, got := pts(prog, test.want), pts(prog2, test.got)
+		if len(got) != len(want) {
+			t.Errorf("%s: got %d queries after reload, want %d", test.name, len(got), len(want))
+		}
+		for key, labels := range want {
+			if got[key] != labels {
+				t.Errorf("%s: pts(%s) after reload = %s, want %s", test.name, key, got[key], labels)
+			}
+		}
+	}
+	if len(r.Queries) == 0 {
+		t.Errorf("no queries to reload")
+	}
+
+	//stale source must be rejected
+	copyTestProgram(t, dir, "testdata/origin.go", func(src string) string { return src + "\nvar z int\n" })
+	if _, err := LoadResult(strings.NewReader(saved), prog2); err == nil || !strings.Contains(err.Error(), "stale") {
+		t.Errorf("LoadResult on changed source: got error %v, want stale", err)
+	}
+}
+
+func TestLoadMismatchedResult(t *testing.T) {
+	prog, main := loadTestProgram(t, "testdata/origin.go")
+	if err := (&ResultWCtx{}).Save(ioutil.Discard); err == nil || !strings.Contains(err.Error(), "no analysis attached") {
+		t.Errorf("Save without analysis: got error %v, want no analysis attached", err)
+	}
+	result, err := AnalyzeWCtx(originTestConfig(main), false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := translateResult(result, main).Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	saved := buf.Bytes()
+
+	for _, test := range []struct {
+		name    string
+		change  func(in *persistResult)
+		wantErr string
+	}{
+		{"mode", func(in *persistResult) { in.Config.Mode = ssa.GlobalDebug.String() }, "ssa mode"},
+		{"function", func(in *persistResult) {
+			for i := range in.Funcs {
+				if strings.HasSuffix(in.Funcs[i].Name, ".worker") {
+					in.Funcs[i].Name += "2"
+				}
+			}
+		}, "no function main.worker2"},
+		{"global", func(in *persistResult) {
+			in.GlobalVal = append(in.GlobalVal, persistBinding{V: persistValue{Kind: "global", Pkg: "main", Name: "z"}})
+		}, "no global main.z"},
+		{"param", func(in *persistResult) {
+			in.GlobalVal = append(in.GlobalVal, persistBinding{V: persistValue{Kind: "param", Fn: in.CGNodes[in.Main].Fn, Index: 3}})
+		}, "has no parameter 3"},
+		{"instr", func(in *persistResult) {
+			fn := in.CGNodes[in.Main].Fn
+			in.Queries = append(in.Queries, persistQuery{V: persistValue{Kind: "instr", Instr: &persistInstr{Fn: fn, Index: 1000}}})
+		}, "has no instruction 0.1000"},
+	} {
+		in := &persistResult{}
+		if err := json.Unmarshal(saved, in); err != nil {
+			t.Fatal(err)
+		}
+		test.change(in)
+		data, err := json.Marshal(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = LoadResult(bytes.NewReader(data), prog)
+		if err == nil || !strings.Contains(err.Error(), "does not match") || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%s: got error %v, want %s", test.name, err, test.wantErr)
+		}
+	}
+
+	//a position without column is the start of its line
+	d := &persistDecoder{prog: prog}
+	pos := d.pos(persistPos{File: "testdata/origin.go", Line: 4})
+	if p := prog.Fset.Position(pos); p.Line != 4 || p.Column != 1 {
+		t.Errorf("pos of line 4 without column = %s, want 4:1", p)
+	}
+}
+
+func TestLoadCorruptedResult(t *testing.T) {
+	prog, main := loadTestProgram(t, "testdata/origin.go")
+	result, err := AnalyzeWCtx(originTestConfig(main), false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := translateResult(result, main).Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	saved := buf.Bytes()
+
+	for _, test := range []struct {
+		name    string
+		corrupt func(in *persistResult)
+	}{
+		{"main", func(in *persistResult) { in.Main = len(in.CGNodes) }},
+		{"entry", func(in *persistResult) { in.Entries = []int{-2} }},
+		{"cgnode fn", func(in *persistResult) { in.CGNodes[0].Fn = len(in.Funcs) }},
+		{"callsite", func(in *persistResult) { in.CGNodes[0].Callers = []int{len(in.Callsites)} }},
+		{"binding", func(in *persistResult) { in.GlobalVal = append(in.GlobalVal, persistBinding{N: nodeid(len(in.Nodes))}) }},
+		{"object cgn", func(in *persistResult) {
+			for i := range in.Nodes {
+				if in.Nodes[i].Obj != nil {
+					in.Nodes[i].Obj.CGN = len(in.CGNodes)
+					return
+				}
+			}
+		}},
+		{"object size", func(in *persistResult) {
+			for i := range in.Nodes {
+				if in.Nodes[i].Obj != nil {
+					in.Nodes[i].Obj.Size = uint32(len(in.Nodes))
+					return
+				}
+			}
+		}},
+		{"pts", func(in *persistResult) { in.Nodes[1].Pts = append(in.Nodes[1].Pts, nodeid(len(in.Nodes))) }},
+		{"graph root", func(in *persistResult) { in.Graph.Root = len(in.Graph.Nodes) }},
+		{"graph edge", func(in *persistResult) { in.Graph.Edges[0].Callee = len(in.Graph.Nodes) }},
+		{"callback", func(in *persistResult) { in.Callbacks = map[string]int{"f": len(in.Funcs)} }},
+	} {
+		in := &persistResult{}
+		if err := json.Unmarshal(saved, in); err != nil {
+			t.Fatal(err)
+		}
+		test.corrupt(in)
+		data, err := json.Marshal(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadResult(bytes.NewReader(data), prog); err == nil || !strings.Contains(err.Error(), "corrupted") {
+			t.Errorf("%s: got error %v, want corrupted", test.name, err)
+		}
+	}
+}
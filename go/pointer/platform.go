@@ -0,0 +1,262 @@
+package pointer
+
+// This file defines the analysis of a main on a matrix of build configurations (platforms), and the merge of
+// their call graphs and points-to sets.
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
+)
+
+/*
+bz: the ssa of a program only has the files selected by one build configuration (GOOS, GOARCH and build tags, and
+  the default is the host's, e.g., linux, amd64), so the code of other platforms is not analyzed. AnalyzePlatforms()
+  loads and analyzes a main for each platform, and MergeResults() merges the results: the results are from different
+  ssa.Programs, so a function is identified by Function.String(), a call site and a label by their positions, and a
+  pointer by its function, position and kind of instruction (see PointerKey): the names of registers differ between
+  the platforms, e.g., a platform-specific conversion shifts all the following registers. each merged edge and label is annotated with the
+  platforms where it holds, so Partial() tells the platform-specific ones.
+*/
+
+// A Platform is a build configuration.
+type Platform struct {
+	GOOS   string
+	GOARCH string
+	Tags   []string // build tags
+}
+
+// String returns the platform in the form of ParsePlatforms, e.g., linux/arm64:netgo+osusergo.
+func (p Platform) String() string {
+	s := p.GOOS + "/" + p.GOARCH
+	if len(p.Tags) > 0 {
+		s += ":" + strings.Join(p.Tags, "+")
+	}
+	return s
+}
+
+// Env returns the environment variables that select p, e.g., for packages.Config.Env.
+func (p Platform) Env() []string {
+	return []string{"GOOS=" + p.GOOS, "GOARCH=" + p.GOARCH}
+}
+
+// Sizes returns the sizes of the gc compiler for p, e.g., for Config.Sizes; nil if GOARCH is unknown.
+func (p Platform) Sizes() types.Sizes {
+	return types.SizesFor("gc", p.GOARCH)
+}
+
+// BuildFlags returns the build flags that select the tags of p, e.g., for packages.Config.BuildFlags.
+func (p Platform) BuildFlags() []string {
+	if len(p.Tags) == 0 {
+		return nil
+	}
+	return []string{"-tags=" + strings.Join(p.Tags, ",")}
+}
+
+// ParsePlatforms parses a comma-separated list of platforms, each is GOOS/GOARCH with optional
+// build tags separated by "+", e.g., "linux/amd64,linux/arm64:netgo+osusergo".
+func ParsePlatforms(s string) ([]Platform, error) {
+	var platforms []Platform
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		var p Platform
+		if i := strings.Index(item, ":"); i >= 0 {
+			p.Tags = strings.Split(item[i+1:], "+")
+			item = item[:i]
+		}
+		parts := strings.Split(item, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid platform %q: want GOOS/GOARCH[:tag+tag...]", item)
+		}
+		p.GOOS, p.GOARCH = parts[0], parts[1]
+		platforms = append(platforms, p)
+	}
+	if len(platforms) == 0 {
+		return nil, fmt.Errorf("no platform in %q", s)
+	}
+	return platforms, nil
+}
+
+// A MergedEdge is a call edge of the merged call graph.
+type MergedEdge struct {
+	Caller    string     // Function.String(); "<root>" for the root
+	Site      string     // the position of the call site; "" if none (e.g., the root calls)
+	Callee    string     // Function.String()
+	Platforms []Platform // the platforms where this edge holds
+}
+
+func (e *MergedEdge) String() string {
+	return fmt.Sprintf("%s --(%s)--> %s %v", e.Caller, e.Site, e.Callee, e.Platforms)
+}
+
+// A MergedLabel is a label in the merged pts of a pointer.
+type MergedLabel struct {
+	Label     string     // Label.String() and its position
+	Platforms []Platform // the platforms where the pointer may point to this label
+}
+
+// A MergedResult merges the results of a main on many platforms.
+type MergedResult struct {
+	Platforms []Platform
+	Results   []*Result                 // the result of each platform, in the order of Platforms
+	Edges     []*MergedEdge             // sorted by caller, site and callee
+	PointsTo  map[string][]*MergedLabel // pointer (see PointerKey) -> its labels, sorted; the union of all contexts
+}
+
+// AnalyzePlatforms analyzes the main package returned by load for each platform with
+// config (except for its Mains), and merges the results.
+func AnalyzePlatforms(config *Config, platforms []Platform, load func(Platform) (*ssa.Package, error)) (*MergedResult, error) {
+	var results []*Result
+	for _, p := range platforms {
+		main, err := load(p)
+		if err != nil {
+			return nil, fmt.Errorf("platform %s: %v", p, err)
+		}
+		_config := *config
+		_config.Mains = []*ssa.Package{main}
+		if sizes := p.Sizes(); sizes != nil {
+			_config.Sizes = sizes
+		}
+		_config.main2Result = nil
+		_config.main2ResultWCtx = nil
+		_config.imports = nil
+		result, err := Analyze(&_config)
+		if err != nil {
+			return nil, fmt.Errorf("platform %s: %v", p, err)
+		}
+		results = append(results, result)
+	}
+	return MergeResults(platforms, results), nil
+}
+
+// MergeResults merges the call graphs and the points-to sets of results, where results[i] is
+// the result of platforms[i]; the results need Config.BuildCallGraph.
+func MergeResults(platforms []Platform, results []*Result) *MergedResult {
+	m := &MergedResult{
+		Platforms: platforms,
+		Results:   results,
+		PointsTo:  make(map[string][]*MergedLabel),
+	}
+	edges := make(map[[3]string]*MergedEdge)
+	labels := make(map[string]map[string]*MergedLabel)
+	for i, r := range results {
+		p := platforms[i]
+		fset := r.a.prog.Fset
+		for _, n := range r.GetResult().CallGraph.Nodes {
+			for _, e := range n.Out {
+				key := [3]string{e.Caller.cgn.fn.String(), "", e.Callee.cgn.fn.String()}
+				if e.Site != nil && e.Site.Pos().IsValid() {
+					key[1] = fset.Position(e.Site.Pos()).String()
+				}
+				me := edges[key]
+				if me == nil {
+					me = &MergedEdge{Caller: key[0], Site: key[1], Callee: key[2]}
+					edges[key] = me
+					m.Edges = append(m.Edges, me)
+				}
+				if n := len(me.Platforms); n == 0 || me.Platforms[n-1].String() != p.String() {
+					me.Platforms = append(me.Platforms, p)
+				}
+			}
+		}
+
+		for v, ptrs := range r.Queries {
+			key := PointerKey(fset, v)
+			if key == "" {
+				continue
+			}
+			for _, ptr := range ptrs {
+				for _, l := range ptr.PointsTo().Labels() {
+					lkey := l.String()
+					if l.Pos().IsValid() {
+						lkey += "@" + fset.Position(l.Pos()).String()
+					}
+					if labels[key] == nil {
+						labels[key] = make(map[string]*MergedLabel)
+					}
+					ml := labels[key][lkey]
+					if ml == nil {
+						ml = &MergedLabel{Label: lkey}
+						labels[key][lkey] = ml
+						m.PointsTo[key] = append(m.PointsTo[key], ml)
+					}
+					if n := len(ml.Platforms); n == 0 || ml.Platforms[n-1].String() != p.String() {
+						ml.Platforms = append(ml.Platforms, p)
+					}
+				}
+			}
+		}
+	}
+
+	sort.Slice(m.Edges, func(i, j int) bool {
+		x, y := m.Edges[i], m.Edges[j]
+		if x.Caller != y.Caller {
+			return x.Caller < y.Caller
+		}
+		if x.Site != y.Site {
+			return x.Site < y.Site
+		}
+		return x.Callee < y.Callee
+	})
+	for _, mls := range m.PointsTo {
+		sort.Slice(mls, func(i, j int) bool { return mls[i].Label < mls[j].Label })
+	}
+	return m
+}
+
+// PointerKey returns the key of the pointer v in MergedResult.PointsTo: its function, its kind
+// and its position, e.g., "main.f: UnOp(*)@/x/main.go:5:7". A parameter, a free variable or a
+// global is keyed by its name instead. A register (e.g., t0) without a position has no key (""):
+// its name is not the same on all the platforms, so it is not merged.
+func PointerKey(fset *token.FileSet, v ssa.Value) string {
+	var fn string
+	if v.Parent() != nil {
+		fn = v.Parent().String()
+	}
+	switch v := v.(type) {
+	case *ssa.Parameter, *ssa.FreeVar:
+		return fn + ": " + v.Name()
+	case *ssa.Global:
+		return v.String()
+	}
+	if !v.Pos().IsValid() {
+		return ""
+	}
+	return fn + ": " + pointerKind(v) + "@" + fset.Position(v.Pos()).String()
+}
+
+//bz: the kind of instruction v, with its operator, index or field if any, to tell the values at the same position
+func pointerKind(v ssa.Value) string {
+	kind := strings.TrimPrefix(fmt.Sprintf("%T", v), "*ssa.")
+	switch v := v.(type) {
+	case *ssa.UnOp:
+		return kind + "(" + v.Op.String() + ")"
+	case *ssa.BinOp:
+		return kind + "(" + v.Op.String() + ")"
+	case *ssa.Extract:
+		return fmt.Sprintf("%s(#%d)", kind, v.Index)
+	case *ssa.Field:
+		return fmt.Sprintf("%s(.%d)", kind, v.Field)
+	case *ssa.FieldAddr:
+		return fmt.Sprintf("%s(.%d)", kind, v.Field)
+	}
+	return kind
+}
+
+// Partial returns the edges that do not hold on all the platforms, i.e., the platform-specific calls.
+func (m *MergedResult) Partial() []*MergedEdge {
+	var partial []*MergedEdge
+	for _, e := range m.Edges {
+		if len(e.Platforms) < len(m.Platforms) {
+			partial = append(partial, e)
+		}
+	}
+	return partial
+}
@@ -0,0 +1,172 @@
+package pointer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/april1989/origin-go-tools/go/buildutil"
+	"github.com/april1989/origin-go-tools/go/loader"
+	"github.com/april1989/origin-go-tools/go/ssa"
+	"github.com/april1989/origin-go-tools/go/ssa/ssautil"
+)
+
+// setup() has one implementation for each GOOS, and the tag "fast" adds another call; id is an int64 on darwin,
+// so int(id) is a conversion there, which shifts the registers of main.
+var platformTestPkg = map[string]string{
+	"main.go": `
+package main
+
+type T struct{ f *int }
+
+var g *T
+
+func use(t *T) {}
+
+func main() {
+	setup()
+	println(int(id))
+	use(g)
+}
+`,
+	"setup_linux.go": `
+package main
+
+var id int
+
+func setup() { g = &T{}; epoll() }
+
+func epoll() {}
+`,
+	"setup_darwin.go": `
+package main
+
+var id int64
+
+func setup() { g = &T{}; kqueue() }
+
+func kqueue() {}
+`,
+	"fast.go": `// +build fast
+
+package main
+
+func init() { fastpath() }
+
+func fastpath() {}
+`,
+}
+
+func TestParsePlatforms(t *testing.T) {
+	platforms, err := ParsePlatforms("linux/amd64, darwin/arm64:fast+netgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Platform{{GOOS: "linux", GOARCH: "amd64"}, {GOOS: "darwin", GOARCH: "arm64", Tags: []string{"fast", "netgo"}}}
+	if !reflect.DeepEqual(platforms, want) {
+		t.Errorf("got %v, want %v", platforms, want)
+	}
+	if got := platforms[1].String(); got != "darwin/arm64:fast+netgo" {
+		t.Errorf("String: got %s", got)
+	}
+	if got := platforms[1].BuildFlags(); len(got) != 1 || got[0] != "-tags=fast,netgo" {
+		t.Errorf("BuildFlags: got %v", got)
+	}
+	for _, bad := range []string{"", "linux", "linux/", "/amd64:x"} {
+		if _, err := ParsePlatforms(bad); err == nil {
+			t.Errorf("ParsePlatforms(%q): no error", bad)
+		}
+	}
+}
+
+func TestAnalyzePlatforms(t *testing.T) {
+	platforms, err := ParsePlatforms("linux/amd64,linux/arm64:fast,darwin/arm64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var progs []*ssa.Program
+	var mains []*ssa.Package
+	load := func(p Platform) (*ssa.Package, error) {
+		ctxt := buildutil.FakeContext(map[string]map[string]string{"main": platformTestPkg})
+		ctxt.GOOS, ctxt.GOARCH, ctxt.BuildTags = p.GOOS, p.GOARCH, p.Tags
+		conf := loader.Config{Build: ctxt}
+		conf.Import("main")
+		iprog, err := conf.Load()
+		if err != nil {
+			return nil, err
+		}
+		prog := ssautil.CreateProgram(iprog, 0)
+		prog.Build()
+		progs = append(progs, prog)
+		mains = append(mains, prog.Package(iprog.Imported["main"].Pkg))
+		return mains[len(mains)-1], nil
+	}
+	m, err := AnalyzePlatforms(originTestConfig(nil), platforms, load)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	on := func(e *MergedEdge) string {
+		var s []string
+		for _, p := range e.Platforms {
+			s = append(s, p.String())
+		}
+		return strings.Join(s, ",")
+	}
+	got := make(map[string]string)
+	for _, e := range m.Edges {
+		got[e.Caller+" -> "+e.Callee] = on(e)
+	}
+	for edge, want := range map[string]string{
+		"main.main -> main.setup":      "linux/amd64,linux/arm64:fast,darwin/arm64",
+		"main.setup -> main.epoll":     "linux/amd64,linux/arm64:fast",
+		"main.setup -> main.kqueue":    "darwin/arm64",
+		"main.init#1 -> main.fastpath": "linux/arm64:fast",
+	} {
+		if got[edge] != want {
+			t.Errorf("%s: got platforms %q, want %q", edge, got[edge], want)
+		}
+	}
+	if partial := m.Partial(); len(partial) != 4 {
+		t.Errorf("got %d partial edges, want 4: %v", len(partial), partial)
+	}
+
+	//pts(t) in use: the T allocated by setup of each GOOS
+	use := m.Results[0].a.config.Mains[0].Func("use")
+	checkT := func(key string) {
+		labels := m.PointsTo[key]
+		if len(labels) != 2 {
+			t.Fatalf("%s: got %d labels, want 2", key, len(labels))
+		}
+		for _, l := range labels {
+			want := 2
+			if strings.Contains(l.Label, "setup_darwin.go") {
+				want = 1
+			}
+			if len(l.Platforms) != want {
+				t.Errorf("%s: got platforms %v, want %d", l.Label, l.Platforms, want)
+			}
+		}
+	}
+	checkT(PointerKey(progs[0].Fset, use.Params[0]))
+
+	//the load of g in main: the same key on all the platforms, although its register is not
+	var keys, names []string
+	for i, main := range mains {
+		for _, b := range main.Func("main").Blocks {
+			for _, instr := range b.Instrs {
+				if load, ok := instr.(*ssa.UnOp); ok && load.X == main.Var("g") {
+					keys = append(keys, PointerKey(progs[i].Fset, load))
+					names = append(names, load.Name())
+				}
+			}
+		}
+	}
+	if len(keys) != 3 || keys[0] == "" || keys[1] != keys[0] || keys[2] != keys[0] {
+		t.Fatalf("got keys %q of the loads of g, want one", keys)
+	}
+	if names[0] == names[2] {
+		t.Errorf("the load of g is %s on linux and darwin", names[0])
+	}
+	checkT(keys[0])
+}
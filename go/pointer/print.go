@@ -30,6 +30,20 @@ func (c *typeFilterConstraint) String() string {
 	return fmt.Sprintf("typeFilter n%d <- n%d.(%s)", c.dst, c.src, c.typ)
 }
 
+func (c *unsafeConvConstraint) String() string {
+	return fmt.Sprintf("unsafeConv n%d <- (*%s)(n%d)", c.dst, c.typ, c.src)
+}
+
+func (c *unsafeOffsetConstraint) String() string {
+	if c.mask {
+		return fmt.Sprintf("unsafeOffset n%d <- n%d & ?", c.dst, c.src)
+	}
+	if !c.known {
+		return fmt.Sprintf("unsafeOffset n%d <- n%d + ?", c.dst, c.src)
+	}
+	return fmt.Sprintf("unsafeOffset n%d <- n%d + %d", c.dst, c.src, c.offset)
+}
+
 func (c *untagConstraint) String() string {
 	return fmt.Sprintf("untag n%d <- n%d.(%s)", c.dst, c.src, c.typ)
 }
@@ -38,6 +52,10 @@ func (c *invokeConstraint) String() string {
 	return fmt.Sprintf("invoke n%d.%s(n%d ...)", c.iface, c.method.Name(), c.params)
 }
 
+func (c *objectCallConstraint) String() string {
+	return fmt.Sprintf("objcall n%d.%s(n%d ...)", c.recv, c.fn.Name(), c.params)
+}
+
 func (n nodeid) String() string {
 	return fmt.Sprintf("n%d", n)
 }
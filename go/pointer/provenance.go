@@ -0,0 +1,238 @@
+package pointer
+
+// This file records the provenance of the points-to facts in the solver, and explains why a pointer may point to
+// a label.
+
+import (
+	"fmt"
+	"go/token"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
+)
+
+/*
+bz: when Config.Provenance is set, the solver records, for each node, the edges through which labels flow into it:
+  - addr:   a label is added to the pts of the node by an addr constraint, or by a complex constraint (e.g., an
+            invoke adds the function object to the pts of its params) or an intrinsic;
+  - copy:   pts(node) ⊇ pts(src), by a copy constraint or an online copy of a load, store, untag, call, ...;
+  - offset: pts(node) ⊇ {k + offset | k ∈ pts(src)}, by an offsetAddr constraint (a field or an element address);
+  - filter: pts(node) ⊇ {k ∈ pts(src) | k has the type}, by a typeFilter constraint (a type assertion).
+  since a pts only grows, a label l may flow into a node n through an edge from src if l (or l - offset) is in
+  the final pts of src. Explain searches the edges backward from (n, l) by bfs until an addr edge of the label,
+  which gives the shortest chain of constraints through which l flows into n.
+  the parallel solver does not record the edges, so Config.Provenance implies the sequential solver.
+*/
+
+type provKind int
+
+const (
+	provAddr provKind = iota
+	provCopy
+	provOffset
+	provFilter
+)
+
+var provKinds = [...]string{"addr", "copy", "offset", "filter"}
+
+//bz: an edge through which labels flow into a node
+type provEdge struct {
+	kind   provKind
+	src    nodeid     // the label for provAddr; the source node otherwise
+	offset uint32     // for provOffset
+	cause  constraint // nil if the edge is added by an intrinsic or during generation
+}
+
+func (a *analysis) record(dst nodeid, e provEdge) {
+	a.prov[dst] = append(a.prov[dst], e)
+}
+
+//bz: the edge of a complex constraint that adds labels to its dst without a copy
+func (a *analysis) recordComplex(c constraint) {
+	switch c := c.(type) {
+	case *offsetAddrConstraint:
+		a.record(c.dst, provEdge{kind: provOffset, src: c.src, offset: c.offset, cause: c})
+	case *typeFilterConstraint:
+		a.record(c.dst, provEdge{kind: provFilter, src: c.src, cause: c})
+	}
+}
+
+// A ProvenanceStep is a step of the flow of a label: the label flows into a node (a value or a field of an
+// object) through a constraint.
+type ProvenanceStep struct {
+	Kind       string          // "addr", "copy", "offset" or "filter"
+	Label      *Label          // the label that flows into the node; changes after an offset step
+	Value      ssa.Value       // the value of the node; nil if the node is in an object
+	Object     *Label          // the object (field) of the node; nil if the node is a value
+	Instr      ssa.Instruction // the instruction that defines Value or allocates Object, if any
+	Fn         *ssa.Function   // the function of Value or Object; nil for a global
+	Context    string          // the context of Fn
+	Pos        token.Pos
+	Constraint string // the constraint through which the label flows; empty if it is added by an intrinsic
+}
+
+func (s *ProvenanceStep) String() string {
+	var where string
+	if s.Value != nil {
+		where = s.Value.Name() + " = " + s.Value.String()
+	} else if s.Object != nil {
+		where = "object " + s.Object.String()
+	}
+	if s.Fn != nil {
+		where += " in " + s.Fn.String() + "@" + s.Context
+	}
+	via := s.Constraint
+	if via == "" {
+		via = "an intrinsic"
+	}
+	return fmt.Sprintf("%s %s: %s via %s", s.Kind, s.Label, where, via)
+}
+
+//bz: a label in the pts of a node, a state of the bfs in Explain
+type provFact struct {
+	n, l nodeid
+}
+
+// Explain returns the shortest chain of steps through which label l flows into the pts of p, from the step that
+// adds l to the step into p; it requires Config.Provenance.
+func (r *ResultWCtx) Explain(p PointerWCtx, l *Label) ([]*ProvenanceStep, error) {
+	a := r.a
+	if a.prov == nil {
+		return nil, fmt.Errorf("no provenance is recorded: set Config.Provenance")
+	}
+	if p.a != a {
+		return nil, fmt.Errorf("pointer %s is not from this result", p)
+	}
+	start := provFact{n: p.n} //l = 0 is never a label
+	var space [50]int
+	for _, x := range a.nodes[p.n].solve.pts.AppendTo(space[:0]) {
+		if lx := a.labelFor(nodeid(x)); lx.obj == l.obj && lx.subelement == l.subelement {
+			start.l = nodeid(x)
+			break
+		}
+	}
+	if start.l == 0 {
+		return nil, fmt.Errorf("%s may not point to %s", p, l)
+	}
+
+	type link struct {
+		to provFact // the fact that the label flows into
+		e  provEdge
+	}
+	parent := map[provFact]link{start: {}}
+	queue := []provFact{start}
+	for len(queue) > 0 {
+		f := queue[0]
+		queue = queue[1:]
+		for _, e := range a.prov[f.n] {
+			var from provFact
+			switch e.kind {
+			case provAddr:
+				if e.src != f.l {
+					continue
+				}
+				return a.provSteps(f, e, func(f provFact) (provFact, provEdge, bool) {
+					if f == start {
+						return f, provEdge{}, false
+					}
+					ln := parent[f]
+					return ln.to, ln.e, true
+				}), nil
+			case provCopy, provFilter:
+				from = provFact{n: e.src, l: f.l}
+			case provOffset:
+				if f.l < nodeid(e.offset) {
+					continue
+				}
+				from = provFact{n: e.src, l: f.l - nodeid(e.offset)}
+			}
+			if _, ok := parent[from]; ok || !a.nodes[from.n].solve.pts.Has(int(from.l)) {
+				continue
+			}
+			parent[from] = link{to: f, e: e}
+			queue = append(queue, from)
+		}
+	}
+	return nil, fmt.Errorf("no provenance of %s in %s: it is not added by the sequential solver", l, p)
+}
+
+//bz: the steps from the fact base (added by the addr edge e) to the start of the search, following next
+func (a *analysis) provSteps(base provFact, e provEdge, next func(provFact) (provFact, provEdge, bool)) []*ProvenanceStep {
+	vals := a.nodeValues()
+	var steps []*ProvenanceStep
+	for f, ok := base, true; ok; {
+		steps = append(steps, a.provStep(f, e, vals))
+		f, e, ok = next(f)
+	}
+	return steps
+}
+
+//bz: the value (and its cgnode) of each value node
+type nodeValue struct {
+	v   ssa.Value
+	cgn *cgnode
+}
+
+func (a *analysis) nodeValues() map[nodeid]nodeValue {
+	vals := make(map[nodeid]nodeValue)
+	for v, id := range a.globalval {
+		for i := uint32(0); i < a.sizeof(v.Type()); i++ {
+			vals[id+nodeid(i)] = nodeValue{v: v}
+		}
+	}
+	for _, cgn := range a.cgnodes {
+		for v, id := range cgn.localval {
+			for i := uint32(0); i < a.sizeof(v.Type()); i++ {
+				vals[id+nodeid(i)] = nodeValue{v: v, cgn: cgn}
+			}
+		}
+	}
+	return vals
+}
+
+//bz: the object node containing id, if any; unlike enclosingObj, it does not panic
+func (a *analysis) containingObj(id nodeid) (nodeid, bool) {
+	for i := id; ; i-- {
+		if obj := a.nodes[i].obj; obj != nil {
+			return i, i+nodeid(obj.size) > id
+		}
+		if i == 0 {
+			return 0, false
+		}
+	}
+}
+
+func (a *analysis) provStep(f provFact, e provEdge, vals map[nodeid]nodeValue) *ProvenanceStep {
+	s := &ProvenanceStep{Kind: provKinds[e.kind], Label: a.labelFor(f.l)}
+	if e.cause != nil {
+		s.Constraint = e.cause.String()
+	}
+	var cgn *cgnode
+	if val, ok := vals[f.n]; ok {
+		s.Value, cgn = val.v, val.cgn
+		s.Instr, _ = val.v.(ssa.Instruction)
+		s.Pos = val.v.Pos()
+	} else if obj, ok := a.containingObj(f.n); ok {
+		s.Object = a.labelFor(f.n)
+		s.Instr, _ = s.Object.Value().(ssa.Instruction)
+		s.Pos = s.Object.Pos()
+		cgn = a.nodes[obj].obj.cgn
+	}
+	if s.Instr != nil && s.Instr.Pos().IsValid() {
+		s.Pos = s.Instr.Pos()
+	}
+	if cgn != nil {
+		s.Fn = cgn.fn
+		s.Context = cgn.contourkFull()
+	}
+	return s
+}
+
+// Explain returns the shortest chain of steps through which label l flows into the pts of v in the context of
+// goInstr and loopID, see ResultWCtx.Explain.
+func (r *Result) Explain(v ssa.Value, goInstr *ssa.Go, loopID int, l *Label) ([]*ProvenanceStep, error) {
+	p := r.PointsToByGoWithLoopID(v, goInstr, loopID)
+	if p.a == nil {
+		return nil, fmt.Errorf("no pointer of %s in the context of %v", v, goInstr)
+	}
+	return r.a.result.Explain(p, l)
+}
@@ -0,0 +1,86 @@
+package pointer
+
+import (
+	"testing"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
+)
+
+func TestExplain(t *testing.T) {
+	_, main := loadTestProgram(t, "testdata/provenance.go")
+
+	var x, tt, y ssa.Value
+	for _, b := range main.Func("main").Blocks {
+		for _, instr := range b.Instrs {
+			switch instr := instr.(type) {
+			case *ssa.Alloc:
+				if instr.Comment == "new" {
+					x = instr
+				} else {
+					tt = instr
+				}
+			case *ssa.UnOp:
+				if _, ok := instr.X.(*ssa.FieldAddr); ok {
+					y = instr
+				}
+			}
+		}
+	}
+	if x == nil || tt == nil || y == nil {
+		t.Fatalf("no x, t or y in %s", main.Func("main"))
+	}
+
+	config := originTestConfig(main)
+	config.Provenance = true
+	result, err := Analyze(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := result.PointsToByGoWithLoopID(y, nil, 0).PointsTo().Labels()
+	if len(labels) != 1 || labels[0].Value() != x {
+		t.Fatalf("pts(y) = %v, want x", labels)
+	}
+	steps, err := result.Explain(y, nil, 0, labels[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range steps {
+		t.Log(s)
+	}
+	if first := steps[0]; first.Kind != "addr" || first.Value != x || first.Fn != main.Func("main") {
+		t.Errorf("first step: %s, want the addr of x", first)
+	}
+	if last := steps[len(steps)-1]; last.Value != y || last.Instr != y.(ssa.Instruction) || !last.Pos.IsValid() {
+		t.Errorf("last step: %s, want y", last)
+	}
+	var viaID, viaField bool
+	for _, s := range steps {
+		if s.Label.Value() != x {
+			t.Errorf("step %s: label is not x", s)
+		}
+		if s.Fn != nil && s.Fn.Name() == "id" {
+			viaID = true
+		}
+		if s.Object != nil && s.Object.Value() == tt {
+			viaField = true
+		}
+	}
+	if !viaID || !viaField {
+		t.Errorf("the steps do not go through id (%t) and t.f (%t)", viaID, viaField)
+	}
+
+	objT := result.PointsToByGoWithLoopID(tt, nil, 0).PointsTo().Labels()
+	if len(objT) != 1 {
+		t.Fatalf("pts(t) = %v", objT)
+	}
+	if _, err := result.Explain(y, nil, 0, objT[0]); err == nil {
+		t.Errorf("explained y -> t, want an error")
+	}
+	config = originTestConfig(main)
+	if result, err = Analyze(config); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := result.Explain(y, nil, 0, labels[0]); err == nil {
+		t.Errorf("explained without provenance, want an error")
+	}
+}
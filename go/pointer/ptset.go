@@ -0,0 +1,376 @@
+package pointer
+
+// This file defines the representations of the points-to sets of the solver states.
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/april1989/origin-go-tools/container/intsets"
+)
+
+/*
+bz: the solver, hvn.go and the PointsToSet API access pts(n) and prevPTS(n) of a solverState through the ptset
+  interface, with two representations selected by Config.SharedPTS:
+  - *nodeset (default): a mutable intsets.Sparse for each set;
+  - *sharedPTS: a reference to an immutable set (a sorted array of nodeids), which is shared by all the pts with
+    the same labels: an update makes a new set; the solver hash-conses the set of pts(n) in a ptsTable when it copies
+    pts(n) to prevPTS(n) (so they share one set, and prevPTS(n) costs nothing), and the final pts after solving. since
+    most pts are small and many nodes have the same pts (e.g., along the copy edges), this uses much less memory than
+    a Sparse, whose block has 256 bits, for each pts and prevPTS. an update of a large set is slower (it copies
+    the array), so this is slower if there are many large pts.
+  working sets (e.g., delta, copyTo) are still nodesets.
+*/
+
+// A ptset is the points-to set of a solver state.
+type ptset interface {
+	IsEmpty() bool
+	Len() int
+	Has(x int) bool
+	Min() int
+	Max() int
+	AppendTo(slice []int) []int
+	String() string
+	Clear()
+
+	add(n nodeid) bool
+	addAll(y ptset) bool
+	equals(y ptset) bool
+	assign(y ptset)                  // s = y, called by the solver for prevPTS(n) = pts(n)
+	diff(prev ptset, delta *nodeset) // delta = s - prev
+}
+
+//bz: the solver state of a new node, with the pts representation of this analysis
+func (a *analysis) newSolverState() *solverState {
+	if a.ptsTable != nil {
+		return &solverState{pts: &sharedPTS{tab: a.ptsTable}, prevPTS: &sharedPTS{tab: a.ptsTable}}
+	}
+	return &solverState{pts: new(nodeset), prevPTS: new(nodeset)}
+}
+
+// Sparse representation ------------------------------------------------------
+
+func (ns *nodeset) equals(y ptset) bool {
+	if y, ok := y.(*nodeset); ok {
+		return ns.Equals(&y.Sparse)
+	}
+	return equalPTS(ns, y)
+}
+
+func (ns *nodeset) assign(y ptset) {
+	if y, ok := y.(*nodeset); ok {
+		ns.Copy(&y.Sparse)
+		return
+	}
+	ns.Clear()
+	ns.addAll(y)
+}
+
+func (ns *nodeset) diff(prev ptset, delta *nodeset) {
+	if prev, ok := prev.(*nodeset); ok {
+		delta.Difference(&ns.Sparse, &prev.Sparse)
+		return
+	}
+	delta.Clear()
+	var space [50]int
+	for _, x := range ns.AppendTo(space[:0]) {
+		if !prev.Has(x) {
+			delta.Insert(x)
+		}
+	}
+}
+
+// Shared representation ------------------------------------------------------
+
+//bz: an immutable set of labels, see sharedPTS
+type hcSet struct {
+	elems []nodeid // sorted
+}
+
+// A sharedPTS refers to an immutable set of labels, which may be shared by other sharedPTS.
+type sharedPTS struct {
+	set *hcSet // nil if empty
+	tab *ptsTable
+}
+
+func (s *sharedPTS) elems() []nodeid {
+	if s.set == nil {
+		return nil
+	}
+	return s.set.elems
+}
+
+func (s *sharedPTS) IsEmpty() bool {
+	return s.set == nil
+}
+
+func (s *sharedPTS) Len() int {
+	return len(s.elems())
+}
+
+func (s *sharedPTS) Has(x int) bool {
+	elems := s.elems()
+	i := sort.Search(len(elems), func(i int) bool { return int(elems[i]) >= x })
+	return i < len(elems) && int(elems[i]) == x
+}
+
+//bz: same as intsets.Sparse
+func (s *sharedPTS) Min() int {
+	if s.set == nil {
+		return intsets.MaxInt
+	}
+	return int(s.set.elems[0])
+}
+
+func (s *sharedPTS) Max() int {
+	if s.set == nil {
+		return intsets.MinInt
+	}
+	return int(s.set.elems[len(s.set.elems)-1])
+}
+
+func (s *sharedPTS) AppendTo(slice []int) []int {
+	for _, x := range s.elems() {
+		slice = append(slice, int(x))
+	}
+	return slice
+}
+
+func (s *sharedPTS) String() string {
+	var buf bytes.Buffer
+	buf.WriteRune('{')
+	for i, n := range s.elems() {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		fmt.Fprintf(&buf, "n%d", n)
+	}
+	buf.WriteRune('}')
+	return buf.String()
+}
+
+func (s *sharedPTS) Clear() {
+	s.set = nil
+}
+
+func (s *sharedPTS) add(n nodeid) bool {
+	elems := s.elems()
+	i := sort.Search(len(elems), func(i int) bool { return elems[i] >= n })
+	if i < len(elems) && elems[i] == n {
+		return false
+	}
+	set := &hcSet{elems: make([]nodeid, len(elems)+1)}
+	copy(set.elems, elems[:i])
+	set.elems[i] = n
+	copy(set.elems[i+1:], elems[i:])
+	s.set = set
+	return true
+}
+
+func (s *sharedPTS) addAll(y ptset) bool {
+	var ys []nodeid
+	shared, ok := y.(*sharedPTS)
+	if ok {
+		if shared.set == nil || shared.set == s.set {
+			return false
+		}
+		if s.set == nil { //share it
+			s.set = shared.set
+			return true
+		}
+		ys = shared.set.elems
+	} else {
+		if y.IsEmpty() {
+			return false
+		}
+		var space [50]int
+		for _, x := range y.AppendTo(space[:0]) {
+			ys = append(ys, nodeid(x))
+		}
+	}
+
+	//merge
+	xs := s.elems()
+	merged := make([]nodeid, 0, len(xs)+len(ys))
+	i, j := 0, 0
+	for i < len(xs) && j < len(ys) {
+		switch {
+		case xs[i] < ys[j]:
+			merged = append(merged, xs[i])
+			i++
+		case xs[i] > ys[j]:
+			merged = append(merged, ys[j])
+			j++
+		default:
+			merged = append(merged, xs[i])
+			i++
+			j++
+		}
+	}
+	merged = append(merged, xs[i:]...)
+	merged = append(merged, ys[j:]...)
+	if len(merged) == len(xs) {
+		return false
+	}
+	if ok && len(merged) == len(ys) { //s is a subset of y: share it
+		s.set = shared.set
+	} else {
+		s.set = &hcSet{elems: merged}
+	}
+	return true
+}
+
+func (s *sharedPTS) equals(y ptset) bool {
+	if y, ok := y.(*sharedPTS); ok && y.set == s.set {
+		return true
+	}
+	return equalPTS(s, y)
+}
+
+//bz: also hash-conses the set of y, so that s and y share the set
+func (s *sharedPTS) assign(y ptset) {
+	if y, ok := y.(*sharedPTS); ok {
+		y.set = s.tab.intern(y.set)
+		s.set = y.set
+		return
+	}
+	s.set = nil
+	s.addAll(y)
+	s.set = s.tab.intern(s.set)
+}
+
+func (s *sharedPTS) diff(prev ptset, delta *nodeset) {
+	delta.Clear()
+	if p, ok := prev.(*sharedPTS); ok {
+		if p.set == s.set {
+			return
+		}
+		xs, ps := s.elems(), p.elems()
+		j := 0
+		for _, x := range xs {
+			for j < len(ps) && ps[j] < x {
+				j++
+			}
+			if j == len(ps) || ps[j] != x {
+				delta.add(x)
+			}
+		}
+		return
+	}
+	for _, x := range s.elems() {
+		if !prev.Has(int(x)) {
+			delta.add(x)
+		}
+	}
+}
+
+//bz: the max #sets in a ptsTable; if more, the table drops its sets (which are still used by the pts), so that it
+// does not keep the sets that are no longer used
+const maxTableSets = 1 << 20
+
+// A ptsTable hash-conses the sets of sharedPTS.
+type ptsTable struct {
+	sets   map[uint64][]*hcSet // hash -> the sets
+	num    int                 // #sets in sets
+	hits   int                 // #interned sets that were in the table, for DoPerformance
+	misses int
+}
+
+func newPTSTable() *ptsTable {
+	return &ptsTable{sets: make(map[uint64][]*hcSet)}
+}
+
+//bz: return the set in the table with the same labels as set, or add set to the table
+func (t *ptsTable) intern(set *hcSet) *hcSet {
+	if set == nil {
+		return nil
+	}
+	h := uint64(14695981039346656037) //fnv-1a
+	for _, x := range set.elems {
+		h ^= uint64(x)
+		h *= 1099511628211
+	}
+	for _, s := range t.sets[h] {
+		if s == set {
+			return s
+		}
+		if equalElems(s.elems, set.elems) {
+			t.hits++
+			return s
+		}
+	}
+	t.misses++
+	if t.num >= maxTableSets {
+		t.reset()
+	}
+	t.sets[h] = append(t.sets[h], set)
+	t.num++
+	return set
+}
+
+//bz: drop all the sets
+func (t *ptsTable) reset() {
+	t.sets = make(map[uint64][]*hcSet)
+	t.num = 0
+}
+
+//bz: hash-cons the final pts after solving, then release the table
+func (a *analysis) internPTS() {
+	for _, n := range a.nodes {
+		if s, ok := n.solve.pts.(*sharedPTS); ok {
+			s.set = a.ptsTable.intern(s.set)
+		}
+	}
+	if a.config.DoPerformance {
+		fmt.Println("#shared pts (hit/miss): ", a.ptsTable.hits, "/", a.ptsTable.misses)
+	}
+	a.ptsTable.reset()
+}
+
+func equalElems(x, y []nodeid) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+//bz: for two ptsets of different representations
+func equalPTS(x, y ptset) bool {
+	if x.Len() != y.Len() {
+		return false
+	}
+	var space [50]int
+	for _, l := range x.AppendTo(space[:0]) {
+		if !y.Has(l) {
+			return false
+		}
+	}
+	return true
+}
+
+//bz: whether x and y have a common label
+func intersectsPTS(x, y ptset) bool {
+	if x, ok := x.(*nodeset); ok {
+		if y, ok := y.(*nodeset); ok { // This takes Θ(|x|+|y|) time.
+			var z intsets.Sparse
+			z.Intersection(&x.Sparse, &y.Sparse)
+			return !z.IsEmpty()
+		}
+	}
+	if x.Len() > y.Len() {
+		x, y = y, x
+	}
+	var space [50]int
+	for _, l := range x.AppendTo(space[:0]) {
+		if y.Has(l) {
+			return true
+		}
+	}
+	return false
+}
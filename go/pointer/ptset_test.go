@@ -0,0 +1,146 @@
+package pointer
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
+)
+
+func TestSharedPTSOps(t *testing.T) {
+	tab := newPTSTable()
+	x, y := &sharedPTS{tab: tab}, &sharedPTS{tab: tab}
+	for _, l := range []nodeid{5, 1, 3, 1} {
+		x.add(l)
+	}
+	if got := x.String(); got != "{n1, n3, n5}" || x.Min() != 1 || x.Max() != 5 || !x.Has(3) || x.Has(2) {
+		t.Errorf("x = %s", got)
+	}
+	if !y.addAll(x) || y.set != x.set || y.addAll(x) {
+		t.Errorf("y = %s is not shared with x", y)
+	}
+	var sparse nodeset
+	sparse.add(3)
+	sparse.add(7)
+	if !y.addAll(&sparse) || y.String() != "{n1, n3, n5, n7}" || !x.addAll(y) || x.set != y.set {
+		t.Errorf("x = %s, y = %s, want {n1, n3, n5, n7} shared", x, y)
+	}
+
+	prev := &sharedPTS{tab: tab}
+	var delta nodeset
+	x.diff(prev, &delta)
+	if delta.Len() != 4 {
+		t.Errorf("delta = %s, want x", &delta)
+	}
+	prev.assign(x)
+	if x.diff(prev, &delta); !delta.IsEmpty() || prev.set != x.set {
+		t.Errorf("delta = %s after assign", &delta)
+	}
+	z := &sharedPTS{tab: tab}
+	z.addAll(&sparse)
+	z.add(1)
+	z.add(5)
+	if !z.equals(x) || x.equals(&sparse) {
+		t.Errorf("z = %s, x = %s", z, x)
+	}
+	var w sharedPTS
+	w.tab = tab
+	w.assign(z) //hash-consed: the same set as x
+	if w.set != x.set || z.set != x.set {
+		t.Errorf("z = %s is not hash-consed", z)
+	}
+	if !intersectsPTS(x, &sparse) || intersectsPTS(x, &sharedPTS{}) {
+		t.Errorf("intersects")
+	}
+}
+
+func TestSharedPTS(t *testing.T) {
+	_, main := loadTestProgram(t, "testdata/parallel.go")
+
+	for _, callback := range []bool{false, true} { //callback: with HVN
+		config := originTestConfig(main)
+		config.DoCallback = callback
+		want, err := AnalyzeWCtx(config, false, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		config = originTestConfig(main)
+		config.DoCallback = callback
+		config.SharedPTS = true
+		config.ParallelSolve = 4 //not used
+		r, err := AnalyzeWCtx(config, false, true)
+		if err != nil {
+			t.Fatalf("DoCallback = %t: %v", callback, err)
+		}
+		if err := diffSolution(want.a, r.a); err != nil {
+			t.Errorf("DoCallback = %t: %v", callback, err)
+		}
+
+		sets := make(map[*hcSet]bool)
+		nonempty := 0
+		for _, n := range r.a.nodes {
+			if s := n.solve.pts.(*sharedPTS); s.set != nil {
+				sets[s.set] = true
+				nonempty++
+			}
+		}
+		if len(sets) >= nonempty {
+			t.Errorf("DoCallback = %t: %d sets for %d nonempty pts, want shared sets", callback, len(sets), nonempty)
+		}
+	}
+}
+
+// BenchmarkPTSRep compares the time and the memory of the pts representations on the testdata programs without
+// imports; heap-B is the live heap after the analysis, which holds the result.
+func BenchmarkPTSRep(b *testing.B) {
+	files, err := filepath.Glob("testdata/*.go")
+	if err != nil {
+		b.Fatal(err)
+	}
+	var mains []*ssa.Package
+	for _, file := range files {
+		src, err := ioutil.ReadFile(file)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if !strings.HasPrefix(string(src), "// +build ignore\n\npackage main\n") || strings.Contains(string(src), "\nimport") {
+			continue
+		}
+		_, main := loadTestProgram(b, file)
+		mains = append(mains, main)
+	}
+
+	for _, rep := range []struct {
+		name   string
+		shared bool
+	}{{"sparse", false}, {"shared", true}} {
+		b.Run(rep.name, func(b *testing.B) {
+			b.ReportAllocs()
+			var heap uint64
+			for i := 0; i < b.N; i++ {
+				var results []*ResultWCtx
+				for _, main := range mains {
+					config := originTestConfig(main)
+					config.SharedPTS = rep.shared
+					r, err := AnalyzeWCtx(config, false, true)
+					if err != nil {
+						b.Fatal(err)
+					}
+					results = append(results, r)
+				}
+				b.StopTimer()
+				var stats runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&stats)
+				heap += stats.HeapAlloc
+				runtime.KeepAlive(results)
+				b.StartTimer()
+			}
+			b.ReportMetric(float64(heap)/float64(b.N), "heap-B")
+		})
+	}
+}
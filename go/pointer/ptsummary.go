@@ -0,0 +1,296 @@
+package pointer
+
+// This file defines the points-to summaries of library functions (Config.PTSummaries,
+// Config.DefaultPTSummaries, Config.UnsoundPTSummaries), which are used instead of analyzing the functions.
+
+import (
+	"fmt"
+	"go/types"
+	"strconv"
+	"strings"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
+)
+
+/*
+bz: a points-to summary is a declarative description of the effects of a library function on pts,
+    written as effects separated by ",":
+      "noescape" -> no effects: the function does not create aliases among its params/results/globals,
+                    does not call any function and does not create any labels (e.g. strings.Contains);
+      "rI = pJ"  -> result I may point to what param J points to (e.g. bytes.TrimSpace: "r0 = p0");
+      "rI = new" -> result I points to a new object allocated by the function (e.g. strings.Split: "r0 = new").
+    params are numbered as fn.Params, i.e. the receiver of a method is p0; anything not mentioned has no effect.
+    a summary is used like an intrinsic (see findIntrinsic()): the analysis uses the summary instead of genFunc.
+    if a summary does not match the signature of the function, e.g. "r0 = p0" with different types, we warn and
+    analyze the function as usual.
+*/
+
+//bz: rI = pJ or rI = new
+type ptEffect struct {
+	result int
+	param  int // -1 -> new
+}
+
+//bz: a parsed points-to summary; nil effects -> noescape
+type ptSummary struct {
+	effects []ptEffect
+}
+
+func (s *ptSummary) String() string {
+	if len(s.effects) == 0 {
+		return "noescape"
+	}
+	var effects []string
+	for _, e := range s.effects {
+		if e.param < 0 {
+			effects = append(effects, fmt.Sprintf("r%d = new", e.result))
+		} else {
+			effects = append(effects, fmt.Sprintf("r%d = p%d", e.result, e.param))
+		}
+	}
+	return strings.Join(effects, ", ")
+}
+
+//bz: parse a points-to summary, see above
+func parsePTSummary(summary string) (*ptSummary, error) {
+	index := func(s, prefix string) (int, bool) {
+		if !strings.HasPrefix(s, prefix) {
+			return 0, false
+		}
+		i, err := strconv.Atoi(s[len(prefix):])
+		return i, err == nil && i >= 0
+	}
+
+	s := &ptSummary{}
+	for _, effect := range strings.Split(summary, ",") {
+		effect = strings.TrimSpace(effect)
+		if effect == "noescape" {
+			continue
+		}
+		lr := strings.Split(effect, "=")
+		if len(lr) != 2 {
+			return nil, fmt.Errorf("invalid effect %q in points-to summary %q", effect, summary)
+		}
+		lhs, rhs := strings.TrimSpace(lr[0]), strings.TrimSpace(lr[1])
+		r, ok := index(lhs, "r")
+		if !ok {
+			return nil, fmt.Errorf("invalid result %q in points-to summary %q", lhs, summary)
+		}
+		p := -1
+		if rhs != "new" {
+			if p, ok = index(rhs, "p"); !ok {
+				return nil, fmt.Errorf("invalid param %q in points-to summary %q", rhs, summary)
+			}
+		}
+		s.effects = append(s.effects, ptEffect{result: r, param: p})
+	}
+	return s, nil
+}
+
+//bz: the points-to summaries of this config: Config.PTSummaries overrides the default ones
+func (c *Config) ptSummaries() (map[string]*ptSummary, error) {
+	summaries := make(map[string]*ptSummary)
+	add := func(all map[string]string) error {
+		for fn, summary := range all {
+			s, err := parsePTSummary(summary)
+			if err != nil {
+				return fmt.Errorf("%s: %v", fn, err)
+			}
+			summaries[fn] = s
+		}
+		return nil
+	}
+	if c.DefaultPTSummaries {
+		if err := add(defaultPTSummaries); err != nil {
+			return nil, err
+		}
+	}
+	if c.UnsoundPTSummaries {
+		if err := add(unsoundPTSummaries); err != nil {
+			return nil, err
+		}
+	}
+	if err := add(c.PTSummaries); err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}
+
+//bz: the intrinsic for the summary s of fn; nil if s does not match the signature of fn
+func (a *analysis) ptSummaryIntrinsic(fn *ssa.Function, s *ptSummary) intrinsic {
+	sig := fn.Signature
+	var params []types.Type
+	if recv := sig.Recv(); recv != nil {
+		params = append(params, recv.Type())
+	}
+	for i := 0; i < sig.Params().Len(); i++ {
+		params = append(params, sig.Params().At(i).Type())
+	}
+	results := sig.Results()
+
+	for _, e := range s.effects {
+		if e.result >= results.Len() {
+			a.warnf(fn.Pos(), "points-to summary %q of %s: no result r%d", s, fn, e.result)
+			return nil
+		}
+		tr := results.At(e.result).Type()
+		if e.param < 0 {
+			if !isNewable(tr) {
+				a.warnf(fn.Pos(), "points-to summary %q of %s: cannot allocate an object for r%d of type %s", s, fn, e.result, tr)
+				return nil
+			}
+		} else if e.param >= len(params) {
+			a.warnf(fn.Pos(), "points-to summary %q of %s: no param p%d", s, fn, e.param)
+			return nil
+		} else if !types.Identical(tr, params[e.param]) {
+			a.warnf(fn.Pos(), "points-to summary %q of %s: r%d and p%d have different types", s, fn, e.result, e.param)
+			return nil
+		}
+	}
+
+	return func(a *analysis, cgn *cgnode) {
+		offset := func(first nodeid, ts []types.Type, i int) nodeid {
+			for _, t := range ts[:i] {
+				first += nodeid(a.sizeof(t))
+			}
+			return first
+		}
+		var rs []types.Type
+		for i := 0; i < results.Len(); i++ {
+			rs = append(rs, results.At(i).Type())
+		}
+		p0 := a.funcParams(cgn.obj)
+		r0 := a.funcResults(cgn.obj)
+		for _, e := range s.effects {
+			tr := rs[e.result]
+			r := offset(r0, rs, e.result)
+			if a.sizeof(tr) == 0 {
+				continue //no pointers
+			}
+			if e.param >= 0 {
+				a.copy(r, offset(p0, params, e.param), a.sizeof(tr))
+				continue
+			}
+
+			obj := a.newSummaryObject(tr, cgn, fmt.Sprintf("<r%d of %s>", e.result, fn))
+			a.addressOf(tr, r, obj)
+		}
+	}
+}
+
+//bz: whether a summary can allocate a new object for a result of type t
+func isNewable(t types.Type) bool {
+	switch t.Underlying().(type) {
+	case *types.Pointer, *types.Slice, *types.Map, *types.Chan:
+		return true
+	}
+	return false
+}
+
+//bz: allocate the object of a new result of type t (see isNewable()) in cgn
+func (a *analysis) newSummaryObject(t types.Type, cgn *cgnode, data interface{}) nodeid {
+	obj := a.nextNode()
+	switch t := t.Underlying().(type) { //same as the objects of ssa.Alloc/MakeSlice/MakeMap/MakeChan
+	case *types.Pointer:
+		a.addNodes(t.Elem(), "summary.new")
+	case *types.Slice:
+		a.addNodes(sliceToArray(t), "summary.new")
+	case *types.Map:
+		a.addNodes(t.Key(), "summary.new.key")
+		elem := a.addNodes(t.Elem(), "summary.new.value")
+		for id, end := elem, elem+nodeid(a.sizeof(t.Elem())); id < end; id++ {
+			a.mapValues = append(a.mapValues, id)
+		}
+	case *types.Chan:
+		a.addNodes(t.Elem(), "summary.new")
+	}
+	a.endObject(obj, cgn, data)
+	return obj
+}
+
+//bz: the default points-to summaries (Config.DefaultPTSummaries = true) of strings, bytes, sort, encoding/json and
+// net/http, keyed by Function.String(). they are exact: the functions call no method of their args (no callback).
+// the functions that store into the objects of their args are not here, since a summary cannot describe a store:
+// e.g. (*strings.Builder).Write* and json.Compact/Indent/HTMLEscape grow the buffer of their receiver/dst
+var defaultPTSummaries = map[string]string{
+	// strings
+	"strings.Compare":           "noescape",
+	"strings.Contains":          "noescape",
+	"strings.ContainsAny":       "noescape",
+	"strings.ContainsRune":      "noescape",
+	"strings.Count":             "noescape",
+	"strings.EqualFold":         "noescape",
+	"strings.Fields":            "r0 = new",
+	"strings.HasPrefix":         "noescape",
+	"strings.HasSuffix":         "noescape",
+	"strings.Index":             "noescape",
+	"strings.IndexAny":          "noescape",
+	"strings.IndexRune":         "noescape",
+	"strings.Join":              "noescape",
+	"strings.LastIndex":         "noescape",
+	"strings.NewReader":         "r0 = new",
+	"strings.Repeat":            "noescape",
+	"strings.Replace":           "noescape",
+	"strings.ReplaceAll":        "noescape",
+	"strings.Split":             "r0 = new",
+	"strings.SplitN":            "r0 = new",
+	"strings.Title":             "noescape",
+	"strings.ToLower":           "noescape",
+	"strings.ToUpper":           "noescape",
+	"strings.Trim":              "noescape",
+	"strings.TrimLeft":          "noescape",
+	"strings.TrimPrefix":        "noescape",
+	"strings.TrimRight":         "noescape",
+	"strings.TrimSpace":         "noescape",
+	"strings.TrimSuffix":        "noescape",
+	"(*strings.Builder).Len":    "noescape",
+	"(*strings.Builder).String": "noescape",
+	"(*strings.Builder).Reset":  "noescape",
+	// bytes
+	"bytes.Compare":          "noescape",
+	"bytes.Contains":         "noescape",
+	"bytes.HasPrefix":        "noescape",
+	"bytes.HasSuffix":        "noescape",
+	"bytes.Index":            "noescape",
+	"bytes.Trim":             "r0 = p0",
+	"bytes.TrimLeft":         "r0 = p0",
+	"bytes.TrimPrefix":       "r0 = p0",
+	"bytes.TrimRight":        "r0 = p0",
+	"bytes.TrimSpace":        "r0 = p0",
+	"bytes.TrimSuffix":       "r0 = p0",
+	"(*bytes.Buffer).Len":    "noescape",
+	"(*bytes.Buffer).Reset":  "noescape",
+	"(*bytes.Buffer).String": "noescape",
+	// sort
+	"sort.Float64s":         "noescape",
+	"sort.Ints":             "noescape",
+	"sort.IntsAreSorted":    "noescape",
+	"sort.SearchInts":       "noescape",
+	"sort.SearchStrings":    "noescape",
+	"sort.Strings":          "noescape",
+	"sort.StringsAreSorted": "noescape",
+	// encoding/json
+	"encoding/json.Valid": "noescape",
+	// net/http
+	"net/http.CanonicalHeaderKey": "noescape",
+	"net/http.DetectContentType":  "noescape",
+	"net/http.ParseHTTPVersion":   "noescape",
+	"net/http.StatusText":         "noescape",
+	"(net/http.Header).Get":       "noescape",
+}
+
+//bz: the points-to summaries (Config.UnsoundPTSummaries = true) that ignore the calls to the methods of their args
+// (e.g. String(), Error(), Format(), MarshalJSON()), which is where most huge pts of interface{} come from (e.g.
+// fmt.pp.printArg()); this is unsound: the goroutines and aliases created by those methods are lost
+var unsoundPTSummaries = map[string]string{
+	// fmt
+	"fmt.Print":    "noescape",
+	"fmt.Printf":   "noescape",
+	"fmt.Println":  "noescape",
+	"fmt.Sprint":   "noescape",
+	"fmt.Sprintf":  "noescape",
+	"fmt.Sprintln": "noescape",
+	// encoding/json
+	"encoding/json.Marshal":       "r0 = new",
+	"encoding/json.MarshalIndent": "r0 = new",
+}
@@ -0,0 +1,75 @@
+package pointer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPTSummaries(t *testing.T) {
+	_, main := loadTestProgram(t, "testdata/ptsummary.go")
+
+	//pts of the value v = call in main
+	ptsOf := func(sol map[string]string, call string) string {
+		for k, pts := range sol {
+			if strings.HasPrefix(k, "main.main@") && strings.HasSuffix(k, " = "+call) {
+				return pts
+			}
+		}
+		t.Fatalf("no value for %s", call)
+		return ""
+	}
+
+	config := originTestConfig(main)
+	config.PTSummaries = map[string]string{
+		"main.trim": "r0 = p0",
+		"main.mk":   "r0 = new",
+		"main.leak": "noescape",
+		"main.conv": "r0 = p1", //no such param: analyze conv as usual
+	}
+	r, err := AnalyzeWCtx(config, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sol := solution(r)
+	for _, test := range []struct {
+		call, want string
+	}{
+		{"trim(t1)", "makeslice"},
+		{"mk()", "<r0 of main.mk>"},
+		{"conv(t6)", "new"},
+		{"*global", ""},
+	} {
+		if got := ptsOf(sol, test.call); !strings.HasPrefix(got, test.want) || (test.want == "") != (got == "") {
+			t.Errorf("pts(%s) = {%s}, want {%s...}", test.call, got, test.want)
+		}
+	}
+	if len(r.Warnings) != 1 || !strings.Contains(r.Warnings[0].Message, "no param p1") {
+		t.Errorf("got warnings %v, want one for main.conv", r.Warnings)
+	}
+
+	//invalid summary
+	config = originTestConfig(main)
+	config.PTSummaries = map[string]string{"main.trim": "r0 = *p0"}
+	if _, err := AnalyzeWCtx(config, false, true); err == nil || !strings.Contains(err.Error(), "invalid param") {
+		t.Errorf("invalid summary: got %v, want an invalid param error", err)
+	}
+
+	//the default summaries
+	for fn, summary := range defaultPTSummaries {
+		if _, err := parsePTSummary(summary); err != nil {
+			t.Errorf("%s: %v", fn, err)
+		}
+	}
+	for fn, summary := range unsoundPTSummaries {
+		if _, err := parsePTSummary(summary); err != nil {
+			t.Errorf("%s: %v", fn, err)
+		}
+	}
+	//they call the methods of their args, or store into the objects of their args
+	for _, fn := range []string{"fmt.Println", "fmt.Sprintf", "encoding/json.Marshal", "(*strings.Builder).WriteString",
+		"(*bytes.Buffer).Write", "encoding/json.Compact", "encoding/json.Indent"} {
+		if summary, ok := defaultPTSummaries[fn]; ok {
+			t.Errorf("%s: got default summary %q, want none", fn, summary)
+		}
+	}
+}
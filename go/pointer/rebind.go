@@ -0,0 +1,596 @@
+package pointer
+
+// This file translates the state of a previous analysis to the edited
+// program of a new one, see Reanalyze() in incremental.go.
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"reflect"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
+	"github.com/april1989/origin-go-tools/go/ssa/ssautil"
+)
+
+//bz: translates the functions, values, types, callsites and cgnodes of a.incr.prev to a.prog
+type rebinder struct {
+	a, prev *analysis
+	s       *incrState
+
+	byName map[string]*ssa.Function // the functions of a.prog by name; nil if ambiguous
+	pkgs   map[string]*ssa.Package  // the packages of a.prog by path
+	fns    map[*ssa.Function]*ssa.Function
+	values map[ssa.Value]ssa.Value             // params, free vars and instructions of unchanged functions
+	instrs map[ssa.Instruction]ssa.Instruction // same as above
+	pos    map[token.Pos]token.Pos
+	types  map[types.Type]types.Type
+	sites  map[*callsite]*callsite
+	cgns   map[*cgnode]*cgnode
+}
+
+func newRebinder(a *analysis) *rebinder {
+	r := &rebinder{
+		a:      a,
+		prev:   a.incr.prev,
+		s:      a.incr,
+		byName: make(map[string]*ssa.Function),
+		pkgs:   make(map[string]*ssa.Package),
+		fns:    make(map[*ssa.Function]*ssa.Function),
+		values: make(map[ssa.Value]ssa.Value),
+		instrs: make(map[ssa.Instruction]ssa.Instruction),
+		pos:    make(map[token.Pos]token.Pos),
+		types:  make(map[types.Type]types.Type),
+		sites:  make(map[*callsite]*callsite),
+		cgns:   make(map[*cgnode]*cgnode),
+	}
+	for fn := range ssautil.AllFunctions(a.prog) {
+		name := fn.String()
+		if _, ok := r.byName[name]; ok {
+			r.byName[name] = nil
+		} else {
+			r.byName[name] = fn
+		}
+	}
+	for _, pkg := range a.prog.AllPackages() {
+		r.pkgs[pkg.Pkg.Path()] = pkg
+	}
+	if r.prev.reflectRtypePtr != nil && a.reflectRtypePtr != nil {
+		r.types[r.prev.reflectRtypePtr] = a.reflectRtypePtr
+	}
+	return r
+}
+
+func (r *rebinder) fail(format string, args ...interface{}) {
+	panic(rebindError{fmt.Sprintf(format, args...)})
+}
+
+//bz: step 4 of Reanalyze(): fill in a from prev and s
+func (r *rebinder) rebind() {
+	a, prev, s := r.a, r.prev, r.s
+
+	//the root is synthetic: create it again
+	prevRoot := prev.result.CallGraph.Root.cgn
+	r.fns[prevRoot.fn] = a.prog.NewFunction("<root>", new(types.Signature), "root of callgraph")
+
+	//cgnodes: the removed ones are skipped, the regenerated ones start from scratch
+	for _, cgn := range prev.cgnodes {
+		if s.removed[cgn] {
+			continue
+		}
+		ncgn := &cgnode{fn: r.fn(cgn.fn), obj: cgn.obj, idx: len(a.cgnodes), selected: cgn.selected}
+		if s.regen[cgn] {
+			r.checkSignature(cgn.fn, ncgn.fn)
+			a.genq = append(a.genq, ncgn)
+		}
+		r.cgns[cgn] = ncgn
+		a.cgnodes = append(a.cgnodes, ncgn)
+	}
+	for cgn, ncgn := range r.cgns {
+		ncgn.callersite = r.callsites(cgn.callersite)
+		for _, cs := range cgn.actualCallerSite {
+			ncgn.actualCallerSite = append(ncgn.actualCallerSite, r.callsites(cs))
+		}
+		if s.regen[cgn] {
+			continue
+		}
+		ncgn.sites = r.callsites(cgn.sites)
+		ncgn.localval = r.bindings(cgn.localval)
+		ncgn.localobj = r.bindings(cgn.localobj)
+	}
+
+	//nodes: same ids; a solver state is reused if all of its nodes are valid
+	validState := make(map[*solverState]bool)
+	for id, n := range prev.nodes {
+		if v, ok := validState[n.solve]; !ok || v {
+			validState[n.solve] = !s.invalid.Has(id) && !s.dead.Has(id)
+		}
+	}
+	states := make(map[*solverState]*solverState)
+	a.nodes = make([]*node, len(prev.nodes))
+	for id, n := range prev.nodes {
+		dead := s.dead.Has(id)
+		nn := &node{subelement: n.subelement}
+		if dead {
+			nn.typ = r.typeOrInvalid(n.typ)
+		} else {
+			nn.typ = r.typ(n.typ)
+			nn.callsite = r.callsites(n.callsite)
+		}
+		if o := n.obj; o != nil {
+			nn.obj = &object{flags: o.flags, size: o.size}
+			if !dead {
+				nn.obj.cgn = r.cgns[o.cgn]
+				nn.obj.data = r.data(o.data)
+			}
+		}
+		if !validState[n.solve] {
+			nn.solve = a.newSolverState()
+		} else if st, ok := states[n.solve]; ok {
+			nn.solve = st
+		} else {
+			nn.solve = a.newSolverState()
+			nn.solve.pts.addAll(n.solve.pts)
+			states[n.solve] = nn.solve
+			for _, l := range n.solve.pts.AppendTo(nil) {
+				if s.dead.Has(l) {
+					r.fail("n%d points to n%d, which is removed", id, l)
+				}
+			}
+		}
+		if !nn.solve.pts.IsEmpty() {
+			s.numReused++
+		}
+		a.nodes[id] = nn
+	}
+
+	//maps
+	for v, id := range prev.globalval {
+		if s.dead.Has(int(id)) {
+			continue
+		}
+		if nv := r.global(v); nv != nil {
+			a.globalval[nv] = id
+		}
+	}
+	for v, id := range prev.globalobj {
+		if id == 0 || s.dead.Has(int(id)) {
+			continue //0: recomputed
+		}
+		if nv := r.global(v); nv != nil {
+			a.globalobj[nv] = id
+		}
+	}
+	for fn := range prev.atFuncs {
+		if !s.changed[fn.String()] {
+			a.atFuncs[r.fn(fn)] = true
+		}
+	}
+	for _, id := range prev.mapValues {
+		if !s.dead.Has(int(id)) {
+			a.mapValues = append(a.mapValues, id)
+		}
+	}
+	for _, cgn := range prev.cgnodes {
+		if ncgn := r.cgns[cgn]; ncgn != nil && prev.fn2cgnodeIdx[cgn.fn] != nil {
+			a.fn2cgnodeIdx[ncgn.fn] = append(a.fn2cgnodeIdx[ncgn.fn], ncgn.idx)
+		}
+	}
+	for fn, c2id := range prev.closures {
+		if s.changed[fn.String()] {
+			continue
+		}
+		nc2id := &Ctx2nodeid{ctx2nodeid: make(map[*callsite][]nodeid)}
+		for site, ids := range c2id.ctx2nodeid {
+			var nids []nodeid
+			for _, id := range ids {
+				if !s.dead.Has(int(id)) {
+					nids = append(nids, id)
+				}
+			}
+			if nids != nil {
+				nc2id.ctx2nodeid[r.callsite(site)] = nids
+			}
+		}
+		if len(nc2id.ctx2nodeid) > 0 {
+			a.closures[r.fn(fn)] = nc2id
+		}
+	}
+	for id, x := range prev.closureWOGo {
+		if !s.dead.Has(int(id)) && !s.dead.Has(int(x)) {
+			a.closureWOGo[id] = x
+		}
+	}
+	for _, cgn := range prev.entryCGNs {
+		if ncgn := r.cgns[cgn]; ncgn != nil {
+			a.entryCGNs[ncgn.fn] = ncgn
+		}
+	}
+	prev.rtypes.Iterate(func(T types.Type, id interface{}) {
+		if !s.dead.Has(int(id.(nodeid))) {
+			a.rtypes.Set(r.typ(T), id)
+		}
+	})
+	prev.reflectZeros.Iterate(func(T types.Type, id interface{}) {
+		if !s.dead.Has(int(id.(nodeid))) {
+			a.reflectZeros.Set(r.typ(T), id)
+		}
+	})
+	a.panicNode = prev.panicNode
+	a.numObjs = prev.numObjs
+	a.numOrigins = prev.numOrigins
+	for _, w := range prev.result.Warnings {
+		a.result.Warnings = append(a.result.Warnings, Warning{Pos: r.position(w.Pos), Message: w.Message})
+	}
+
+	//call graph: the static edges of the kept callers; finish() adds the dynamic ones
+	a.result.CallGraph = NewWCtx(r.cgns[prevRoot])
+	cg := a.result.CallGraph
+	for _, cgn := range prev.cgnodes {
+		ncgn := r.cgns[cgn]
+		if ncgn == nil || s.regen[cgn] {
+			continue
+		}
+		for _, out := range prev.result.CallGraph.Nodes[cgn].Out {
+			callee := r.cgns[out.Callee.cgn]
+			if callee == nil || isDynamic(cgn, out.Site) {
+				continue
+			}
+			var instr ssa.CallInstruction
+			if out.Site != nil {
+				instr = r.instr(out.Site).(ssa.CallInstruction)
+			}
+			cg.AddEdge(cg.CreateNodeWCtx(ncgn), instr, cg.CreateNodeWCtx(callee))
+		}
+	}
+
+	//constraints: the kept ones in their order; the solver applies them again to the reused pts
+	for _, oc := range s.kept {
+		nc := r.constraint(oc.c)
+		a.constraintNodes(nc, func(id nodeid) {
+			if s.dead.Has(int(id)) {
+				r.fail("%s uses n%d, which is removed", oc.c, id)
+			}
+		})
+		a.constraints = append(a.constraints, nc)
+		a.history = append(a.history, ownedConstraint{nc, r.cgns[oc.owner]})
+	}
+}
+
+//bz: whether the call instr of caller has dynamic targets, i.e., its edges are added by finish()
+func isDynamic(caller *cgnode, instr ssa.CallInstruction) bool {
+	for _, site := range caller.sites {
+		if site.instr == instr && site.targets != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+//bz: the block of a regenerated cgnode is reused: fn must have the same layout
+func (r *rebinder) checkSignature(old, fn *ssa.Function) {
+	sig := r.typ(old.Signature).(*types.Signature)
+	if !types.Identical(sig, fn.Signature) ||
+		(sig.Recv() == nil) != (fn.Signature.Recv() == nil) ||
+		sig.Recv() != nil && !types.Identical(sig.Recv().Type(), fn.Signature.Recv().Type()) ||
+		len(old.FreeVars) != len(fn.FreeVars) {
+		r.fail("the signature of %s changed", fn)
+	}
+}
+
+//////////////////////////////// functions and values ////////////////////////////////
+
+func (r *rebinder) fn(fn *ssa.Function) *ssa.Function {
+	if nfn, ok := r.fns[fn]; ok {
+		return nfn
+	}
+	nfn := r.byName[fn.String()]
+	if nfn == nil {
+		r.fail("cannot find %s in the edited program", fn)
+	}
+	if !r.s.changed[fn.String()] {
+		r.bindBody(fn, nfn)
+	}
+	r.fns[fn] = nfn
+	return nfn
+}
+
+//bz: map the params, free vars and instructions of fn to nfn, which must have the same body
+func (r *rebinder) bindBody(fn, nfn *ssa.Function) {
+	if len(fn.Params) != len(nfn.Params) || len(fn.FreeVars) != len(nfn.FreeVars) || len(fn.Blocks) != len(nfn.Blocks) {
+		r.fail("%s changed, but it is not in the changed functions", fn)
+	}
+	r.pos[fn.Pos()] = nfn.Pos()
+	for i, p := range fn.Params {
+		r.values[p] = nfn.Params[i]
+		r.pos[p.Pos()] = nfn.Params[i].Pos()
+	}
+	for i, fv := range fn.FreeVars {
+		r.values[fv] = nfn.FreeVars[i]
+	}
+	for i, b := range fn.Blocks {
+		nb := nfn.Blocks[i]
+		if len(b.Instrs) != len(nb.Instrs) {
+			r.fail("%s changed, but it is not in the changed functions", fn)
+		}
+		for j, instr := range b.Instrs {
+			ninstr := nb.Instrs[j]
+			if reflect.TypeOf(instr) != reflect.TypeOf(ninstr) || instr.String() != ninstr.String() {
+				r.fail("%s changed, but it is not in the changed functions", fn)
+			}
+			r.instrs[instr] = ninstr
+			if v, ok := instr.(ssa.Value); ok {
+				r.values[v] = ninstr.(ssa.Value)
+			}
+			r.pos[instr.Pos()] = ninstr.Pos()
+		}
+	}
+}
+
+func (r *rebinder) instr(instr ssa.Instruction) ssa.Instruction {
+	r.fn(instr.Parent())
+	ninstr := r.instrs[instr]
+	if ninstr == nil {
+		r.fail("cannot find %s of %s in the edited program", instr, instr.Parent())
+	}
+	return ninstr
+}
+
+func (r *rebinder) value(v ssa.Value) ssa.Value {
+	switch v := v.(type) {
+	case *ssa.Function:
+		return r.fn(v)
+	case *ssa.Global:
+		return r.globalVar(v)
+	case *ssa.Const:
+		return ssa.NewConst(v.Value, r.typ(v.Type()))
+	case *ssa.Parameter, *ssa.FreeVar, ssa.Instruction:
+		r.fn(v.Parent())
+		if nv := r.values[v]; nv != nil {
+			return nv
+		}
+	}
+	r.fail("cannot find %s in the edited program", v)
+	return nil
+}
+
+//bz: the key of a.globalval/a.globalobj; nil if it is dropped (e.g., a closure of a changed function)
+func (r *rebinder) global(v ssa.Value) ssa.Value {
+	switch v := v.(type) {
+	case *ssa.Const:
+		return nil //recomputed
+	case *ssa.Function:
+		if r.s.changed[v.String()] && v.Parent() != nil {
+			return nil
+		}
+	}
+	return r.value(v)
+}
+
+func (r *rebinder) globalVar(g *ssa.Global) *ssa.Global {
+	if pkg := r.pkgs[g.Pkg.Pkg.Path()]; pkg != nil {
+		if ng, ok := pkg.Members[g.Name()].(*ssa.Global); ok {
+			return ng
+		}
+	}
+	r.fail("cannot find %s in the edited program", g)
+	return nil
+}
+
+func (r *rebinder) bindings(m map[ssa.Value]nodeid) map[ssa.Value]nodeid {
+	if m == nil {
+		return nil
+	}
+	nm := make(map[ssa.Value]nodeid, len(m))
+	for v, id := range m {
+		nm[r.value(v)] = id
+	}
+	return nm
+}
+
+func (r *rebinder) data(data interface{}) interface{} {
+	switch d := data.(type) {
+	case nil:
+		return nil
+	case ssa.Value:
+		return r.value(d)
+	case types.Type:
+		return r.typ(d)
+	case string:
+		return d
+	}
+	r.fail("unexpected object data %T", data)
+	return nil
+}
+
+func (r *rebinder) position(pos token.Pos) token.Pos {
+	return r.pos[pos] //NoPos if unknown
+}
+
+//////////////////////////////// callsites and constraints ////////////////////////////////
+
+func (r *rebinder) callsite(c *callsite) *callsite {
+	if c == nil {
+		return nil
+	}
+	if nc, ok := r.sites[c]; ok {
+		return nc
+	}
+	nc := &callsite{targets: c.targets, loopID: c.loopID}
+	if c.main != nil {
+		nc.main = r.fn(c.main)
+	}
+	if c.instr != nil {
+		nc.instr = r.instr(c.instr).(ssa.CallInstruction)
+	}
+	if c.goInstr != nil {
+		nc.goInstr = r.instr(c.goInstr).(*ssa.Go)
+	}
+	r.sites[c] = nc
+	return nc
+}
+
+func (r *rebinder) callsites(cs []*callsite) []*callsite {
+	if cs == nil {
+		return nil
+	}
+	ncs := make([]*callsite, len(cs))
+	for i, c := range cs {
+		ncs[i] = r.callsite(c)
+	}
+	return ncs
+}
+
+func (r *rebinder) constraint(c constraint) constraint {
+	switch c := c.(type) {
+	case *addrConstraint:
+		return &addrConstraint{dst: c.dst, src: c.src}
+	case *copyConstraint:
+		return &copyConstraint{dst: c.dst, src: c.src}
+	case *loadConstraint:
+		return &loadConstraint{offset: c.offset, dst: c.dst, src: c.src}
+	case *storeConstraint:
+		return &storeConstraint{offset: c.offset, dst: c.dst, src: c.src}
+	case *offsetAddrConstraint:
+		return &offsetAddrConstraint{offset: c.offset, dst: c.dst, src: c.src}
+	case *typeFilterConstraint:
+		return &typeFilterConstraint{typ: r.typ(c.typ), dst: c.dst, src: c.src}
+	case *untagConstraint:
+		return &untagConstraint{typ: r.typ(c.typ), dst: c.dst, src: c.src, exact: c.exact}
+	case *unsafeConvConstraint:
+		return &unsafeConvConstraint{typ: r.typ(c.typ), dst: c.dst, src: c.src, pos: r.position(c.pos)}
+	case *unsafeOffsetConstraint:
+		return &unsafeOffsetConstraint{offset: c.offset, known: c.known, mask: c.mask, dst: c.dst, src: c.src,
+			pos: r.position(c.pos)}
+	case *invokeConstraint:
+		return &invokeConstraint{method: r.method(c.method), iface: c.iface, params: c.params,
+			site: r.callsite(c.site), caller: r.cgns[c.caller]}
+	case *objectCallConstraint:
+		return &objectCallConstraint{fn: r.fn(c.fn), recv: c.recv, params: c.params,
+			site: r.callsite(c.site), caller: r.cgns[c.caller]}
+	}
+	r.fail("cannot rebind constraint %s", c)
+	return nil
+}
+
+//////////////////////////////// types ////////////////////////////////
+
+func (r *rebinder) pkg(pkg *types.Package) *types.Package {
+	if pkg == nil {
+		return nil
+	}
+	if npkg := r.pkgs[pkg.Path()]; npkg != nil {
+		return npkg.Pkg
+	}
+	r.fail("cannot find package %s in the edited program", pkg.Path())
+	return nil
+}
+
+//bz: the abstract method m of an interface
+func (r *rebinder) method(m *types.Func) *types.Func {
+	recv := r.typ(m.Type().(*types.Signature).Recv().Type())
+	obj, _, _ := types.LookupFieldOrMethod(recv, false, r.pkg(m.Pkg()), m.Name())
+	nm, ok := obj.(*types.Func)
+	if !ok {
+		r.fail("cannot find method %s in the edited program", m)
+	}
+	return nm
+}
+
+//bz: the type of a removed node, which is never used again
+func (r *rebinder) typeOrInvalid(T types.Type) (nT types.Type) {
+	defer func() {
+		if p := recover(); p != nil {
+			if _, ok := p.(rebindError); !ok {
+				panic(p)
+			}
+			nT = tInvalid
+		}
+	}()
+	return r.typ(T)
+}
+
+func (r *rebinder) typ(T types.Type) types.Type {
+	if T == nil {
+		return nil
+	}
+	if nT, ok := r.types[T]; ok {
+		return nT
+	}
+	var nT types.Type
+	switch T := T.(type) {
+	case *types.Basic:
+		nT = T
+	case *types.Named:
+		obj := T.Obj()
+		if obj.Pkg() == nil {
+			nT = T //error
+			break
+		}
+		if obj.Parent() != obj.Pkg().Scope() {
+			r.fail("cannot rebind local type %s", T)
+		}
+		tn, ok := r.pkg(obj.Pkg()).Scope().Lookup(obj.Name()).(*types.TypeName)
+		if !ok {
+			r.fail("cannot find type %s in the edited program", T)
+		}
+		nT = tn.Type()
+	case *types.Pointer:
+		nT = types.NewPointer(r.typ(T.Elem()))
+	case *types.Slice:
+		nT = types.NewSlice(r.typ(T.Elem()))
+	case *types.Array:
+		nT = types.NewArray(r.typ(T.Elem()), T.Len())
+	case *types.Map:
+		nT = types.NewMap(r.typ(T.Key()), r.typ(T.Elem()))
+	case *types.Chan:
+		nT = types.NewChan(T.Dir(), r.typ(T.Elem()))
+	case *types.Struct:
+		fields := make([]*types.Var, T.NumFields())
+		tags := make([]string, T.NumFields())
+		for i := range fields {
+			f := T.Field(i)
+			fields[i] = types.NewField(token.NoPos, r.pkg(f.Pkg()), f.Name(), r.typ(f.Type()), f.Anonymous())
+			tags[i] = T.Tag(i)
+		}
+		nT = types.NewStruct(fields, tags)
+	case *types.Tuple:
+		nT = r.tuple(T)
+	case *types.Signature:
+		var recv *types.Var
+		if T.Recv() != nil {
+			recv = types.NewVar(token.NoPos, r.pkg(T.Recv().Pkg()), T.Recv().Name(), r.typ(T.Recv().Type()))
+		}
+		nT = types.NewSignature(recv, r.tuple(T.Params()), r.tuple(T.Results()), T.Variadic())
+	case *types.Interface:
+		if T == tEface {
+			nT = T
+			break
+		}
+		methods := make([]*types.Func, T.NumExplicitMethods())
+		for i := range methods {
+			m := T.ExplicitMethod(i)
+			sig := m.Type().(*types.Signature) //its receiver is T: skip it
+			methods[i] = types.NewFunc(token.NoPos, r.pkg(m.Pkg()), m.Name(),
+				types.NewSignature(nil, r.tuple(sig.Params()), r.tuple(sig.Results()), sig.Variadic()))
+		}
+		embeddeds := make([]types.Type, T.NumEmbeddeds())
+		for i := range embeddeds {
+			embeddeds[i] = r.typ(T.EmbeddedType(i))
+		}
+		nT = types.NewInterfaceType(methods, embeddeds).Complete()
+	default:
+		r.fail("cannot rebind type %s", T)
+	}
+	r.types[T] = nT
+	return nT
+}
+
+func (r *rebinder) tuple(t *types.Tuple) *types.Tuple {
+	vars := make([]*types.Var, t.Len())
+	for i := range vars {
+		v := t.At(i)
+		vars[i] = types.NewVar(token.NoPos, r.pkg(v.Pkg()), v.Name(), r.typ(v.Type()))
+	}
+	return types.NewTuple(vars...)
+}
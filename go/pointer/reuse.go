@@ -7,18 +7,14 @@ import "github.com/april1989/origin-go-tools/go/ssa"
    including pts created in this fn, call edges, etc. Then we copy solved state to unsolved.
 */
 
-var (
-	analyses []*analysis //bz: utilized by AnalyzeMultiMains(), to store computed *analysis to save solving time
-)
-
-//bz: see above
+//bz: see above; the computed analyses are in a.config.analyses, to save solving time
 func reuse(a *analysis) {
 	//the ones that can be reused (shared contour) only shown in a.globalobj
 	for val, obj := range a.globalobj {
 		if fn, ok := val.(*ssa.Function); ok {
 			cgn := a.nodes[obj].obj.cgn //my cgn
 			//see existing analyses
-			for _, othera := range analyses {
+			for _, othera := range a.config.analyses {
 				oobj := othera.globalobj[fn]
 				if oobj == 0 {
 					continue //no such fn in this analysis
@@ -1,6 +1,10 @@
 package pointer
 
-import "github.com/april1989/origin-go-tools/go/ssa"
+import (
+	"sync"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
+)
 
 //bz:
 // i want to share func/cgnode/enclosing pointers, objs and constraints
@@ -25,6 +29,7 @@ import "github.com/april1989/origin-go-tools/go/ssa"
 
 var (
 	fn2obj = make(map[*ssa.Function] *FnSummary) //map function <-> function summary
+	sharesMu sync.RWMutex //bz: guards fn2obj, which is shared by all analyses
 
 )
 
@@ -36,18 +41,23 @@ type FnSummary struct {
 }
 
 //bz: can we apply everything in summary to another analysis?
+// only when another is a Reanalyze() (see incremental.go) and sum.fn is not changed/invalidated there
 func (sum *FnSummary) CanApply(another *analysis) bool {
-	return false
+	return another.incr != nil && another.incr.canReuse(sum.fn)
 }
 
 
 //is this func in our share map > fn2obj?
 func IsShared(fn *ssa.Function) bool {
+	sharesMu.RLock()
+	defer sharesMu.RUnlock()
 	return fn2obj[fn] != nil
 }
 
 //create summary for fn
 func CreateSumForFunc(fn *ssa.Function, obj nodeid, c []constraint) {
+	sharesMu.Lock()
+	defer sharesMu.Unlock()
 	assert(fn2obj[fn] == nil, "Summary exists: " + fn.String())
 	fn2obj[fn] = &FnSummary{
 		obj: obj,
@@ -58,6 +68,8 @@ func CreateSumForFunc(fn *ssa.Function, obj nodeid, c []constraint) {
 
 
 func GetSumForFunc(fn *ssa.Function) *FnSummary {
+	sharesMu.RLock()
+	defer sharesMu.RUnlock()
 	return fn2obj[fn]
 }
 
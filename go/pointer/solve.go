@@ -9,31 +9,27 @@ package pointer
 
 import (
 	"fmt"
-	"github.com/april1989/origin-go-tools/go/myutil/flags"
+	"go/token"
 	"go/types"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
 )
 
 type solverState struct {
 	complex []constraint // complex constraints attached to this node
 	copyTo  nodeset      // simple copy constraint edges
-	pts     nodeset      // points-to set of this node
-	prevPTS nodeset      // pts(n) in previous iteration (for difference propagation)
+	pts     ptset        // points-to set of this node, see ptset.go
+	prevPTS ptset        // pts(n) in previous iteration (for difference propagation)
 }
 
-//bz: to limit the size of pts
-var (
-	ptsLimit int
-	skipIDs  map[int]int //these pts reach the ptsLimit, skip their solving if added to worklist
-)
-
 func (a *analysis) solve() {
-	if flags.DoPerformance { //bz: performance dump info
+	if a.config.DoPerformance { //bz: performance dump info
 		a.num_constraints = 0
 		fmt.Println("#constraints (before solve()): ", len(a.constraints))
 		fmt.Println("#cgnodes (before solve()): ", len(a.cgnodes))
 		fmt.Println("#nodes (before solve()): ", len(a.nodes))
 
-		if flags.PrintCGNodes { //bz: debug
+		if a.config.PrintCGNodes { //bz: debug
 			fmt.Println("\nDump cgnodes (before solve()): ")
 			for i, cgn := range a.cgnodes {
 				fmt.Println(i, ". ", cgn.String())
@@ -41,28 +37,60 @@ func (a *analysis) solve() {
 		}
 	}
 
-	start("Solving")
+	a.start("Solving")
 	if a.log != nil {
 		fmt.Fprintf(a.log, "\n\n==== Solving constraints\n\n")
 	}
+	if a.config.recordCreators { //bz: the nodes so far are created by generate(), see diffSolution()
+		a.creators = make([]*nodeCreator, len(a.nodes))
+	}
+
+	parallel := a.config.ParallelSolve > 1
+	if parallel {
+		if reason := a.noParallelSolve(); reason != "" {
+			a.warnf(token.NoPos, "Config.ParallelSolve is ignored: %s needs the sequential solver", reason)
+			parallel = false
+		}
+	}
 
 	// Solver main loop: separate to avoid frequent bool check of whether we do ptslimit
-	if flags.PTSLimit == 0 {
-		a.solveDefault()
+	if a.config.PTSLimit == 0 {
+		if a.demand != nil { //bz: see solveDemand()
+			a.solveDemand()
+		} else if parallel { //bz: see solveParallel()
+			a.solveParallel()
+		} else {
+			a.solveDefault()
+		}
 	} else {
 		a.solveLimit()
 	}
 
 	//bz: back to normal workflow
 	if !a.nodes[0].solve.pts.IsEmpty() {
-		panic(fmt.Sprintf("pts(0) is nonempty: %s", &a.nodes[0].solve.pts))
+		panic(fmt.Sprintf("pts(0) is nonempty: %s", a.nodes[0].solve.pts))
+	}
+
+	if a.stopped { //bz: out of time, before we lose the constraint graph
+		a.collectUnsound()
+	}
+	if a.collapsed != nil { //bz: same as above, see collapse.go
+		a.collectImprecise()
+	}
+	if a.cycles != nil && a.config.DoPerformance {
+		fmt.Println("#nodes merged by HCD/LCD: ", a.cycles.merged)
+	}
+	if a.ptsTable != nil { //bz: see ptset.go
+		a.internPTS()
 	}
 
 	// Release working state (but keep final PTS).
-	for _, n := range a.nodes {
-		n.solve.complex = nil
-		n.solve.copyTo.Clear()
-		n.solve.prevPTS.Clear()
+	if !a.config.Incremental { //bz: incremental: Reanalyze() needs the constraint graph
+		for _, n := range a.nodes {
+			n.solve.complex = nil
+			n.solve.copyTo.Clear()
+			n.solve.prevPTS.Clear()
+		}
 	}
 
 	if a.log != nil {
@@ -71,18 +99,27 @@ func (a *analysis) solve() {
 		// Dump solution.
 		for i, n := range a.nodes {
 			if !n.solve.pts.IsEmpty() {
-				fmt.Fprintf(a.log, "pts(n%d) = %s : %s\n", i, &n.solve.pts, n.typ)
+				fmt.Fprintf(a.log, "pts(n%d) = %s : %s\n", i, n.solve.pts, n.typ)
 			}
 		}
 	}
-	stop("Solving")
+	a.stop("Solving")
 }
 
-//bz: default solve(); use when flags.PTSLimit == 0
+//bz: default solve(); use when a.config.PTSLimit == 0
 func (a *analysis) solveDefault() {
+	if a.optCycles { //bz: see cycles.go
+		a.cycles = newCycleState(a)
+		a.hcdOffline()
+	}
+
 	// Solver main loop.
 	var delta nodeset
 	for {
+		if a.outOfTime() { //bz: see collectUnsound()
+			break
+		}
+
 		// Add new constraints to the graph:
 		// static constraints from SSA on round 1,
 		// dynamic constraints from reflection thereafter.
@@ -101,7 +138,7 @@ func (a *analysis) solveDefault() {
 		n := a.nodes[id]
 
 		// Difference propagation.
-		delta.Difference(&n.solve.pts.Sparse, &n.solve.prevPTS.Sparse)
+		n.solve.pts.diff(n.solve.prevPTS, &delta)
 		if delta.IsEmpty() {
 			continue
 		}
@@ -109,41 +146,50 @@ func (a *analysis) solveDefault() {
 			//fmt.Fprintf(a.log, "\t\tpts(n%d : %s) = %s + %s\n", id, n.typ, &delta, &n.solve.prevPTS)  //bz: too verbose
 			fmt.Fprintf(a.log, "\t\tpts(n%d : %s) = %s + ... \n", id, n.typ, &delta)
 		}
-		n.solve.prevPTS.Copy(&n.solve.pts.Sparse)
+		n.solve.prevPTS.assign(n.solve.pts)
 
 		// Apply all resolution rules attached to n.
 		a.solveConstraints(n, &delta)
+		if a.cycles != nil { //bz: see cycles.go
+			a.hcdOnline(n, &delta)
+			a.lcd()
+		}
 
 		if a.log != nil {
-			fmt.Fprintf(a.log, "\t\tpts(n%d) = %s\n", id, &n.solve.pts)
+			fmt.Fprintf(a.log, "\t\tpts(n%d) = %s\n", id, n.solve.pts)
 		}
 	}
 }
 
-//bz: solve(); use when flags.PTSLimit > 0
+//bz: solve(); use when a.config.PTSLimit > 0
 func (a *analysis) solveLimit() {
 	//setting
-	ptsLimit = flags.PTSLimit
-	skipIDs = make(map[int]int)
+	ptsLimit := a.config.PTSLimit
+	a.collapsed = newCollapseState(a)
 	fmt.Println(" *** PTS Limit:", ptsLimit, "*** ")
+	if a.optCycles { //bz: see cycles.go
+		a.cycles = newCycleState(a)
+		a.hcdOffline()
+	}
 
 	// Solver main loop.
 	var delta nodeset
 	for {
+		if a.outOfTime() { //bz: see collectUnsound()
+			break
+		}
+
 		// Add new constraints to the graph:
 		// static constraints from SSA on round 1,
 		// dynamic constraints from reflection thereafter.
 		a.processNewConstraints()
+		a.collapseNewNodes() //bz: the new objects flow to the collapsed nodes
 
 		var x int
 		if !a.work.TakeMin(&x) {
 			break // empty worklist
 		}
 
-		if _, ok := skipIDs[x]; ok { //bz: skip its solving for those pts size reach limit
-			continue
-		}
-
 		id := nodeid(x)
 		if a.log != nil {
 			fmt.Fprintf(a.log, "\tnode n%d\n", id)
@@ -151,8 +197,12 @@ func (a *analysis) solveLimit() {
 
 		n := a.nodes[id]
 
+		if _, ok := a.collapsed.sizes[id]; !ok && n.solve.pts.Len() >= ptsLimit { //bz: collapse it, then keep solving
+			a.collapse(id)
+		}
+
 		// Difference propagation.
-		delta.Difference(&n.solve.pts.Sparse, &n.solve.prevPTS.Sparse)
+		n.solve.pts.diff(n.solve.prevPTS, &delta)
 		if delta.IsEmpty() {
 			continue
 		}
@@ -161,25 +211,17 @@ func (a *analysis) solveLimit() {
 			fmt.Fprintf(a.log, "\t\tpts(n%d : %s) = %s + ... \n", id, n.typ, &delta)
 		}
 
-		//n.solve.prevPTS.Copy(&n.solve.pts.Sparse) //bz: copy then check
-		//if n.solve.pts.Len() >= ptsLimit {
-		//	skipIDs[x] = x
-		//}
-
-		if n.solve.pts.Len() >= ptsLimit { //bz: check then copy
-			skipIDs[x] = x
-			n.solve.prevPTS.Clear()
-			continue
-		}else {
-			n.solve.prevPTS.Copy(&n.solve.pts.Sparse)
-		}
-
+		n.solve.prevPTS.assign(n.solve.pts)
 
 		// Apply all resolution rules attached to n.
 		a.solveConstraints(n, &delta)
+		if a.cycles != nil { //bz: see cycles.go
+			a.hcdOnline(n, &delta)
+			a.lcd()
+		}
 
 		if a.log != nil {
-			fmt.Fprintf(a.log, "\t\tpts(n%d) = %s\n", id, &n.solve.pts)
+			fmt.Fprintf(a.log, "\t\tpts(n%d) = %s\n", id, n.solve.pts)
 		}
 	}
 }
@@ -192,7 +234,7 @@ func (a *analysis) solveLimit() {
 func (a *analysis) processNewConstraints() {
 	// Take the slice of new constraints.
 	// (May grow during call to solveConstraints.)
-	if flags.DoPerformance && len(a.constraints) > 0 {
+	if a.config.DoPerformance && len(a.constraints) > 0 {
 		a.num_constraints = a.num_constraints + len(a.constraints)
 		//if len(a.constraints) > 0 { //bz: debug: changed
 		//	fmt.Println("#constraints (during solve()): ", a.num_constraints)
@@ -210,6 +252,9 @@ func (a *analysis) processNewConstraints() {
 		if c, ok := c.(*addrConstraint); ok {
 			dst := a.nodes[c.dst]
 			dst.solve.pts.add(c.src)
+			if a.prov != nil { //bz: see provenance.go
+				a.record(c.dst, provEdge{kind: provAddr, src: c.src, cause: c})
+			}
 
 			// Populate the worklist with nodes that point to
 			// something initially (due to addrConstraints) and
@@ -233,11 +278,17 @@ func (a *analysis) processNewConstraints() {
 			// simple (copy) constraint
 			id = c.src
 			a.nodes[id].solve.copyTo.add(c.dst)
+			if a.prov != nil {
+				a.record(c.dst, provEdge{kind: provCopy, src: c.src, cause: c})
+			}
 		default:
 			// complex constraint
 			id = c.ptr()
 			solve := a.nodes[id].solve
 			solve.complex = append(solve.complex, c)
+			if a.prov != nil {
+				a.recordComplex(c)
+			}
 		}
 
 		if n := a.nodes[id]; !n.solve.pts.IsEmpty() {
@@ -256,9 +307,11 @@ func (a *analysis) processNewConstraints() {
 	for _, id := range stale.AppendTo(space[:0]) {
 		n := a.nodes[nodeid(id)]
 		if a.config.Log != nil {
-			fmt.Fprintf(a.log, "\t\tstale %d: pts(%s) = %s + %s\n", id, n.typ, &n.solve.prevPTS, &n.solve.prevPTS)
+			fmt.Fprintf(a.log, "\t\tstale %d: pts(%s) = %s + %s\n", id, n.typ, n.solve.prevPTS, n.solve.prevPTS)
 		}
-		a.solveConstraints(n, &n.solve.prevPTS)
+		var prev nodeset
+		prev.addAll(n.solve.prevPTS)
+		a.solveConstraints(n, &prev)
 	}
 }
 
@@ -276,8 +329,10 @@ func (a *analysis) solveConstraints(n *node, delta *nodeset) {
 		if a.log != nil {
 			fmt.Fprintf(a.log, "\t\tconstraint %s\n", c)
 		}
+		a.cause = c //bz: see provenance.go
 		c.solve(a, delta)
 	}
+	a.cause = nil
 
 	// Process copy constraints.
 	var copySeen nodeset
@@ -287,6 +342,9 @@ func (a *analysis) solveConstraints(n *node, delta *nodeset) {
 			if a.nodes[mid].solve.pts.addAll(delta) {
 				a.addWork(mid)
 			}
+			if a.cycles != nil { //bz: see cycles.go
+				a.lcdCandidate(n, mid)
+			}
 		}
 	}
 }
@@ -297,6 +355,11 @@ func (a *analysis) addLabel(ptr, label nodeid) bool {
 	if b && a.log != nil {
 		fmt.Fprintf(a.log, "\t\tpts(n%d) += n%d\n", ptr, label)
 	}
+	if b && a.prov != nil {
+		if _, ok := a.cause.(*typeFilterConstraint); !ok { //bz: recorded as a filter edge
+			a.record(ptr, provEdge{kind: provAddr, src: label, cause: a.cause})
+		}
+	}
 	return b
 }
 
@@ -324,11 +387,17 @@ func (a *analysis) onlineCopy(dst, src nodeid) bool {
 			if a.log != nil {
 				fmt.Fprintf(a.log, "\t\t\tdynamic copy n%d <- n%d\n", dst, src)
 			}
+			if a.demand != nil { //bz: see solveDemand()
+				a.demand.addEdge(a, dst, src)
+			}
+			if a.prov != nil { //bz: see provenance.go
+				a.record(dst, provEdge{kind: provCopy, src: src, cause: a.cause})
+			}
 			// TODO(adonovan): most calls to onlineCopy
 			// are followed by addWork, possibly batched
 			// via a 'changed' flag; see if there's a
 			// noticeable penalty to calling addWork here.
-			return a.nodes[dst].solve.pts.addAll(&nsrc.solve.pts)
+			return a.nodes[dst].solve.pts.addAll(nsrc.solve.pts)
 		}
 	}
 	return false
@@ -450,6 +519,50 @@ func (c *untagConstraint) solve(a *analysis, delta *nodeset) {
 	}
 }
 
+//bz: drop the labels whose layout is incompatible with c.typ, see unsafe.go
+func (c *unsafeConvConstraint) solve(a *analysis, delta *nodeset) {
+	for _, x := range delta.AppendTo(a.deltaSpace) {
+		k := nodeid(x)
+		target, ok := a.unsafeTarget(k, c.typ)
+		if !ok {
+			a.warnUnsafe(c.pos, "cannot model unsafe.Pointer conversion of %s to *%s: incompatible layout",
+				a.labelFor(k), c.typ)
+			continue
+		}
+		if a.addLabel(c.dst, target) {
+			a.addWork(c.dst)
+		}
+	}
+}
+
+func (c *unsafeOffsetConstraint) solve(a *analysis, delta *nodeset) {
+	for _, x := range delta.AppendTo(a.deltaSpace) {
+		k := nodeid(x)
+		if c.known {
+			if target, ok := a.unsafeOffset(k, c.offset); ok {
+				if a.addLabel(c.dst, target) {
+					a.addWork(c.dst)
+				}
+				continue
+			}
+			a.warnUnsafe(c.pos, "cannot model uintptr arithmetic on %s with offset %d", a.labelFor(k), c.offset)
+		} else if c.mask {
+			a.warnUnsafe(c.pos, "cannot model uintptr masking on %s", a.labelFor(k))
+		} else {
+			a.warnUnsafe(c.pos, "cannot model uintptr arithmetic on %s with a non-constant offset", a.labelFor(k))
+		}
+		changed := false
+		for _, n := range a.unsafeObjectNodes(k) {
+			if a.addLabel(c.dst, n) {
+				changed = true
+			}
+		}
+		if changed {
+			a.addWork(c.dst)
+		}
+	}
+}
+
 //bz: this solves for invoke calls, needs to be updated for kcfa
 func (c *invokeConstraint) solve(a *analysis, delta *nodeset) {
 	for _, x := range delta.AppendTo(a.deltaSpace) {
@@ -466,6 +579,10 @@ func (c *invokeConstraint) solve(a *analysis, delta *nodeset) {
 		if fn == nil {
 			panic(fmt.Sprintf("n%d: no ssa.Function for %s", c.iface, c.method))
 		}
+		if c.caller != nil && a.byObject(fn) && a.considerMyContext(fn.String()) { //bz: the contexts are from the objects in pts(v)
+			a.genObjectInvoke(c, fn, v)
+			continue
+		}
 		sig := fn.Signature
 		fnObj := a.globalobj[fn] // dynamic calls use shared contour  ---> bz: fnObj is nodeid
 
@@ -513,6 +630,186 @@ func (c *invokeConstraint) eachSolve(a *analysis, fnObj nodeid, sig *types.Signa
 	a.onlineCopyN(src, dst, resultsSize)
 }
 
+//bz: add to reach all nodes that can be reached from roots in the constraint graph, i.e., copy edges and the
+// edges of complex constraints (see complexEdges); only valid before the working state is released in solve().
+// return false if a constraint that we cannot follow (e.g., from intrinsics/reflection) is met, then
+// everything can be reached.
+func (a *analysis) forwardReach(roots []nodeid, reach *nodeset) bool {
+	//the edges of complex constraints, including the online copy edges that are not in copyTo yet, e.g., the writes of
+	//a store from its src, which the constraint is not attached to
+	succs := make(map[nodeid][]nodeid)
+	seen := make(map[*solverState]bool)
+	for _, n := range a.nodes {
+		if seen[n.solve] {
+			continue
+		}
+		seen[n.solve] = true
+		for _, c := range n.solve.complex {
+			if !a.complexEdges(c, func(src, dst nodeid) { succs[src] = append(succs[src], dst) }) {
+				return false
+			}
+		}
+	}
+
+	var stack []nodeid
+	for _, id := range roots {
+		if reach.add(id) {
+			stack = append(stack, id)
+		}
+	}
+	var space [50]int
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, x := range a.nodes[id].solve.copyTo.AppendTo(space[:0]) {
+			if reach.add(nodeid(x)) {
+				stack = append(stack, nodeid(x))
+			}
+		}
+		for _, succ := range succs[id] {
+			if reach.add(succ) {
+				stack = append(stack, succ)
+			}
+		}
+	}
+	return true
+}
+
+//bz: call edge(src, dst) for each edge of the complex constraint c: from c.ptr() to the nodes whose pts depend on
+// pts(c.ptr()), and the online copy edges that c adds for the labels in pts(c.ptr()), solved or not yet (e.g., the
+// solver ran out of time, see collectUnsound()). return false if we do not know the edges of c.
+func (a *analysis) complexEdges(c constraint, edge func(src, dst nodeid)) bool {
+	pts := a.nodes[c.ptr()].solve.pts
+	switch c := c.(type) {
+	case *loadConstraint:
+		edge(c.src, c.dst)
+		for _, k := range pts.AppendTo(nil) {
+			edge(nodeid(k)+nodeid(c.offset), c.dst)
+		}
+	case *storeConstraint:
+		for _, k := range pts.AppendTo(nil) {
+			koff := nodeid(k) + nodeid(c.offset)
+			edge(c.dst, koff) //k may leave pts(c.dst)
+			edge(c.src, koff)
+		}
+	case *offsetAddrConstraint:
+		edge(c.src, c.dst)
+	case *typeFilterConstraint:
+		edge(c.src, c.dst)
+	case *unsafeConvConstraint:
+		edge(c.src, c.dst)
+	case *unsafeOffsetConstraint:
+		edge(c.src, c.dst)
+	case *untagConstraint:
+		edge(c.src, c.dst)
+		for _, k := range pts.AppendTo(nil) {
+			tDyn, v, _ := a.taggedValue(nodeid(k))
+			for i := uint32(0); i < a.sizeof(tDyn); i++ {
+				edge(v+nodeid(i), c.dst+nodeid(i))
+			}
+		}
+	case *invokeConstraint:
+		//the caller's block: identity, params and results
+		sig := c.method.Type().(*types.Signature)
+		paramsSize, resultsSize := a.sizeof(sig.Params()), a.sizeof(sig.Results())
+		for i := uint32(0); i <= paramsSize+resultsSize; i++ {
+			edge(c.iface, c.params+nodeid(i))
+		}
+		//the callees: the solved ones are in pts(c.params), the others may be any cgnode of the concrete method
+		callees := make(map[*ssa.Function][]nodeid)
+		for _, fnObj := range a.nodes[c.params].solve.pts.AppendTo(nil) {
+			if o := a.nodes[fnObj].obj; o != nil && o.cgn != nil {
+				callees[o.cgn.fn] = append(callees[o.cgn.fn], nodeid(fnObj))
+			}
+		}
+		prev := a.nodes[c.iface].solve.prevPTS
+		for _, k := range pts.AppendTo(nil) {
+			tDyn, v, _ := a.taggedValue(nodeid(k))
+			fn := a.prog.LookupMethod(tDyn, c.method.Pkg(), c.method.Name())
+			if fn == nil {
+				continue
+			}
+			fnObjs := callees[fn]
+			if !prev.Has(k) {
+				for _, idx := range a.fn2cgnodeIdx[fn] {
+					fnObjs = append(fnObjs, a.cgnodes[idx].obj)
+				}
+				if fnObj := a.globalobj[fn]; fnObj != 0 {
+					fnObjs = append(fnObjs, fnObj)
+				}
+			}
+			recvSize := a.sizeof(fn.Signature.Recv().Type())
+			for _, fnObj := range fnObjs {
+				arg0 := a.funcParams(fnObj)
+				for i := uint32(0); i < recvSize+paramsSize; i++ {
+					edge(c.iface, arg0+nodeid(i))
+				}
+				for i := uint32(0); i < recvSize; i++ {
+					edge(v+nodeid(i), arg0+nodeid(i))
+				}
+				for i := uint32(0); i < paramsSize; i++ {
+					edge(c.params+1+nodeid(i), arg0+nodeid(recvSize+i))
+				}
+				for i := uint32(0); i < resultsSize; i++ {
+					edge(arg0+nodeid(recvSize+paramsSize+i), c.params+1+nodeid(paramsSize+i))
+				}
+			}
+		}
+	case *objectCallConstraint:
+		//the caller's block: identity, params and results
+		sig := c.fn.Signature
+		recvSize, paramsSize, resultsSize := a.sizeof(sig.Recv().Type()), a.sizeof(sig.Params()), a.sizeof(sig.Results())
+		for i := uint32(0); i <= paramsSize+resultsSize; i++ {
+			edge(c.recv, c.params+nodeid(i))
+		}
+		//the callees: the solved ones are in pts(c.params), the others may be any cgnode of fn
+		var fnObjs []nodeid
+		for _, fnObj := range a.nodes[c.params].solve.pts.AppendTo(nil) {
+			if o := a.nodes[fnObj].obj; o != nil && o.cgn != nil && o.cgn.fn == c.fn {
+				fnObjs = append(fnObjs, nodeid(fnObj))
+			}
+		}
+		if !a.nodes[c.recv].solve.prevPTS.equals(pts) { //some labels are not solved yet
+			for _, idx := range a.fn2cgnodeIdx[c.fn] {
+				fnObjs = append(fnObjs, a.cgnodes[idx].obj)
+			}
+		}
+		for _, fnObj := range fnObjs {
+			arg0 := a.funcParams(fnObj)
+			for i := uint32(0); i < recvSize+paramsSize; i++ {
+				edge(c.recv, arg0+nodeid(i))
+			}
+			for i := uint32(0); i < paramsSize; i++ {
+				edge(c.params+1+nodeid(i), arg0+nodeid(recvSize+i))
+			}
+			for i := uint32(0); i < resultsSize; i++ {
+				edge(arg0+nodeid(recvSize+paramsSize+i), c.params+1+nodeid(paramsSize+i))
+			}
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+//bz: see objectCallConstraint
+func (c *objectCallConstraint) solve(a *analysis, delta *nodeset) {
+	sig := c.fn.Signature
+	recvSize, paramsSize, resultsSize := a.sizeof(sig.Recv().Type()), a.sizeof(sig.Params()), a.sizeof(sig.Results())
+	for _, x := range delta.AppendTo(a.deltaSpace) {
+		fnObj := a.objectCallee(c, nodeid(x))
+		arg0 := a.funcParams(fnObj)
+		if a.addLabel(arg0, nodeid(x)) { //the receiver only points to its object
+			a.addWork(arg0)
+		}
+		if !a.addLabel(c.params, fnObj) {
+			continue //the params/results are connected
+		}
+		a.onlineCopyN(arg0+nodeid(recvSize), c.params+1, paramsSize)
+		a.onlineCopyN(c.params+1+nodeid(paramsSize), arg0+nodeid(recvSize+paramsSize), resultsSize)
+	}
+}
+
 func (c *addrConstraint) solve(a *analysis, delta *nodeset) {
 	panic("addr is not a complex constraint")
 }
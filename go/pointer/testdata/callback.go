@@ -0,0 +1,23 @@
+// +build ignore
+
+package main
+
+// The bodies of after and rng do not invoke their callbacks: the callbacks are
+// reachable only if the analysis uses the summaries.
+
+var saved func()
+
+func after(f func()) { saved = f }
+
+type M struct{ m map[int]*int }
+
+func (m *M) rng(f func(k int, v *int) bool) {}
+
+var sink *int
+
+func main() {
+	x := new(int)
+	after(func() { sink = x })
+	m := &M{}
+	m.rng(func(k int, v *int) bool { sink = v; return true })
+}
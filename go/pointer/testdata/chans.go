@@ -0,0 +1,29 @@
+// +build ignore
+
+package main
+
+// mk is called in two origins: two channels of the same allocation site.
+
+func mk() chan int { return make(chan int, 1) }
+
+func main() {
+	ch := make(chan int)
+	done := make(chan bool)
+	go func() {
+		ch <- 1
+		own := mk()
+		own <- 2
+		<-own
+	}()
+	go func() {
+		select {
+		case v := <-ch:
+			_ = v
+		case <-done:
+		}
+	}()
+	<-ch
+	close(done)
+	mine := mk()
+	mine <- 3
+}
@@ -0,0 +1,15 @@
+// +build ignore
+
+package main
+
+// p points to the 3 objects in ts, which reaches the limit; u is another object of T, and i is not a T.
+
+type T struct{ f *int }
+
+func main() {
+	ts := []*T{&T{}, &T{}, &T{}}
+	p := ts[0]
+	u := &T{}
+	i := new(int)
+	println(p, u, i)
+}
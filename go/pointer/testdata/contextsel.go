@@ -0,0 +1,18 @@
+// +build ignore
+
+package main
+
+// b1.get() is called at two call sites, b2.get() at one.
+
+type Box struct{ p *int }
+
+func (b *Box) get() *int { return b.p }
+
+func main() {
+	b1 := &Box{p: new(int)}
+	b2 := &Box{p: new(int)}
+	x := b1.get()
+	y := b2.get()
+	z := b1.get()
+	_, _, _ = x, y, z
+}
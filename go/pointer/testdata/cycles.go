@@ -0,0 +1,21 @@
+// +build ignore
+
+package main
+
+// p and q are a cycle of copies (LCD); r, t.f and s are a cycle through a store and a load (HCD).
+
+type T struct{ f *int }
+
+func main() {
+	a, b, c := new(int), new(int), new(int)
+	p, q := a, b
+	r := c
+	t := &T{}
+	for i := 0; i < 10; i++ {
+		p, q = q, p
+		t.f = r
+		s := t.f
+		r = s
+	}
+	println(p, q, r)
+}
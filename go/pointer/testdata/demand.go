@@ -0,0 +1,33 @@
+// +build ignore
+
+package main
+
+// unrelated() builds pointers that the queries in main never need.
+
+type T struct{ f *int }
+
+func id(p *int) *int { return p }
+
+func unrelated() {
+	m := make(map[int]*T)
+	for i := 0; i < 3; i++ {
+		m[i] = &T{f: new(int)}
+	}
+	var l []*T
+	for _, t := range m {
+		l = append(l, t)
+	}
+	_ = l
+}
+
+func main() {
+	x := new(int)
+	t := &T{}
+	t.f = id(x)
+	c := make(chan *T, 1)
+	go func() { c <- t }()
+	r := <-c
+	q := r.f
+	_ = q
+	unrelated()
+}
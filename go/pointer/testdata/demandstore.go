@@ -0,0 +1,26 @@
+// +build ignore
+
+package main
+
+// The query x needs the invoke i.get() and the store a.p = p (of type *int), but not the store b.q = v
+// (of type *bool) in set.
+
+type I interface{ get() *int }
+
+type A struct{ p *int }
+
+func (a *A) get() *int { return a.p }
+
+type B struct{ q *bool }
+
+func (b *B) set(v *bool) { b.q = v }
+
+func main() {
+	a := &A{}
+	a.p = new(int)
+	var i I = a
+	x := i.get()
+	b := &B{}
+	b.set(new(bool))
+	_ = x
+}
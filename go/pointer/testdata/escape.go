@@ -0,0 +1,24 @@
+// +build ignore
+
+package main
+
+// The names of the variables say whether their objects escape.
+
+type T struct{ p *int }
+
+var global *int
+
+func work(shared *T) {
+	local := new(int)
+	*local = 1
+	shared.p = local // escapes: main may load it
+	escaped := new(int)
+	global = escaped
+}
+
+func main() {
+	shared := &T{}
+	go work(shared)
+	local := &T{p: new(int)}
+	println(*local.p, *shared.p)
+}
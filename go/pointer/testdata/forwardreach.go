@@ -0,0 +1,19 @@
+// +build ignore
+
+package main
+
+// q flows to s.f by a store and to p of (*A).m by an invoke: both are online copy edges.
+
+type I interface{ m(p *int) }
+
+type A struct{ f *int }
+
+func (a *A) m(p *int) { a.f = p }
+
+func main() {
+	var i I = &A{}
+	q := new(int)
+	i.m(q)
+	s := &A{}
+	s.f = q
+}
@@ -0,0 +1,25 @@
+// +build ignore
+
+package main
+
+type T struct{ f *int }
+
+var g *int
+
+func set(t *T, p *int) { t.f = p }
+
+func helper() *int {
+	return new(int)
+}
+
+func worker(t *T, c chan *int) { c <- t.f }
+
+func main() {
+	c := make(chan *int, 10)
+	for i := 0; i < 3; i++ {
+		t := &T{}
+		set(t, helper())
+		go worker(t, c)
+	}
+	g = <-c
+}
@@ -0,0 +1,25 @@
+// +build ignore
+
+package main
+
+// The receivers of get are passed as params, so the contexts of get come from the objects that they point to.
+
+type getter interface{ get() *int }
+
+type Box struct{ p *int }
+
+func (b *Box) get() *int { return b.p }
+
+func use(b *Box) *int { return b.get() }
+
+func useI(g getter) *int { return g.get() }
+
+func main() {
+	b1 := &Box{p: new(int)}
+	b2 := &Box{p: new(int)}
+	x := use(b1)
+	y := use(b2)
+	z := useI(b1)
+	w := useI(b2)
+	_, _, _, _ = x, y, z, w
+}
@@ -0,0 +1,29 @@
+// +build ignore
+
+package main
+
+// A small program without imports: the loader does not need to type-check
+// the standard library, which keeps these tests fast.
+
+type T struct{ f *int }
+
+func worker(t *T, c chan *int) {
+	c <- t.f
+}
+
+func alloc() *int {
+	return new(int)
+}
+
+func main() {
+	c := make(chan *int, 10)
+	for i := 0; i < 3; i++ {
+		x := new(int)
+		go worker(&T{f: x}, c)
+	}
+	y := alloc()
+	go func() {
+		c <- y
+	}()
+	<-c
+}
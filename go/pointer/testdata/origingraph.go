@@ -0,0 +1,23 @@
+// +build ignore
+
+package main
+
+// main creates the goroutines of a loop (two origins) and work, which creates another one; main also calls work,
+// so the goroutine of leaf has two parents.
+
+type T struct{ x *int }
+
+func leaf(t *T) { t.x = new(int) }
+
+func work(t *T) {
+	go leaf(t)
+}
+
+func main() {
+	t := &T{}
+	for i := 0; i < 3; i++ {
+		go func() { t.x = nil }()
+	}
+	go work(t)
+	work(t)
+}
@@ -0,0 +1,47 @@
+// +build ignore
+
+package main
+
+// Dynamic calls make the solver create cgnodes online, in a different
+// order than solveDefault().
+
+type I interface{ get() *int }
+
+type A struct{ p *int }
+
+func (a *A) get() *int { return a.p }
+
+type B struct{ q **int }
+
+func (b B) get() *int { return *b.q }
+
+type node struct {
+	next *node
+	val  I
+}
+
+var head *node
+
+func push(v I) {
+	head = &node{next: head, val: v}
+}
+
+func apply(f func(I) *int, v I) *int { return f(v) }
+
+func main() {
+	x, y := new(int), new(int)
+	push(&A{p: x})
+	push(B{q: &y})
+	m := map[string]I{"a": &A{p: y}}
+	s := []I{m["a"]}
+	c := make(chan *int, 1)
+	for n := head; n != nil; n = n.next {
+		s = append(s, n.val)
+	}
+	for _, v := range s {
+		go func(v I) {
+			c <- apply(func(i I) *int { return i.get() }, v)
+		}(v)
+	}
+	<-c
+}
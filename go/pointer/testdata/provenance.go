@@ -0,0 +1,17 @@
+// +build ignore
+
+package main
+
+// x flows into y through id, the field f of t and a load.
+
+type T struct{ f *int }
+
+func id(p *int) *int { return p }
+
+func main() {
+	x := new(int)
+	t := &T{}
+	t.f = id(x)
+	y := t.f
+	println(*y)
+}
@@ -0,0 +1,26 @@
+// +build ignore
+
+package main
+
+// The bodies of trim, mk and conv do not return anything, and leak does escape p:
+// the results are from the summaries.
+
+var global *int
+
+func trim(b []byte) []byte { return nil }
+
+func mk() *int { return nil }
+
+func leak(p *int) { global = p }
+
+func conv(p *int) *int { return p }
+
+func main() {
+	b := make([]byte, 4)
+	t := trim(b)
+	p := mk()
+	leak(new(int))
+	q := conv(new(int))
+	g := global
+	print(t, p, q, g)
+}
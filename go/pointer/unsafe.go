@@ -0,0 +1,240 @@
+package pointer
+
+// This file defines the sound model of unsafe.Pointer and uintptr, see Config.SoundUnsafe.
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
+)
+
+/*
+bz: by default, *T -> unsafe.Pointer and unsafe.Pointer <-> uintptr conversions are no-ops, and unsafe.Pointer -> *T
+  creates a fresh object, so the aliasing through unsafe.Pointer (e.g., atomic.Value, sync.Map) is lost. if
+  SoundUnsafe, unsafe.Pointer and uintptr are universal pointers:
+  - *T -> unsafe.Pointer, unsafe.Pointer <-> uintptr: copy the pts;
+  - unsafe.Pointer -> *T: unsafeConvConstraint; a label flows to the result if the layout of its object from the label
+    is compatible with T, i.e., the same kinds of nodes (pointer, interface, slice, basic, aggregate), or to the first
+    field (recursively) of the label that is compatible with T;
+  - uintptr + or - a constant (e.g., uintptr(p) + unsafe.Offsetof(s.f)): unsafeOffsetConstraint; a label flows to the
+    result as the node at that byte offset from the label in its object, computed by Config.Sizes;
+  - uintptr &, |, &^ or ^ (e.g., clearing the tag bits of a pointer): unsafeOffsetConstraint with mask, which cannot
+    be modeled.
+  a label that cannot be modeled is dropped (conversion) or replaced by all the nodes of its object (arithmetic), and
+  a warning is reported in Result.Warnings.
+*/
+
+//bz: the sizes for the byte offsets of fields if Config.Sizes is nil
+var defaultSizes = types.SizesFor("gc", "amd64")
+
+func (c *Config) sizes() types.Sizes {
+	if c.Sizes != nil {
+		return c.Sizes
+	}
+	return defaultSizes
+}
+
+//bz: generates unsafeOffsetConstraints for uintptr arithmetic instr
+func (a *analysis) genUnsafeOffset(instr *ssa.BinOp) {
+	if b, ok := instr.Type().Underlying().(*types.Basic); !ok || b.Kind() != types.Uintptr {
+		return
+	}
+	dst := a.valueNode(instr)
+	switch instr.Op {
+	case token.ADD:
+		if y, ok := instr.Y.(*ssa.Const); ok {
+			a.addUnsafeOffset(instr.X, &unsafeOffsetConstraint{offset: y.Int64(), known: true, dst: dst, pos: instr.Pos()})
+		} else if x, ok := instr.X.(*ssa.Const); ok {
+			a.addUnsafeOffset(instr.Y, &unsafeOffsetConstraint{offset: x.Int64(), known: true, dst: dst, pos: instr.Pos()})
+		} else {
+			a.addUnsafeOffset(instr.X, &unsafeOffsetConstraint{dst: dst, pos: instr.Pos()})
+			a.addUnsafeOffset(instr.Y, &unsafeOffsetConstraint{dst: dst, pos: instr.Pos()})
+		}
+
+	case token.SUB:
+		if y, ok := instr.Y.(*ssa.Const); ok {
+			a.addUnsafeOffset(instr.X, &unsafeOffsetConstraint{offset: -y.Int64(), known: true, dst: dst, pos: instr.Pos()})
+		} else {
+			a.addUnsafeOffset(instr.X, &unsafeOffsetConstraint{dst: dst, pos: instr.Pos()})
+		}
+
+	case token.AND, token.OR, token.AND_NOT, token.XOR: //masking: warned by the solver if a label flows
+		a.addUnsafeOffset(instr.X, &unsafeOffsetConstraint{mask: true, dst: dst, pos: instr.Pos()})
+		a.addUnsafeOffset(instr.Y, &unsafeOffsetConstraint{mask: true, dst: dst, pos: instr.Pos()})
+	}
+}
+
+//bz: adds c with src v unless v is a constant
+func (a *analysis) addUnsafeOffset(v ssa.Value, c *unsafeOffsetConstraint) {
+	if _, ok := v.(*ssa.Const); ok {
+		return
+	}
+	if c.src = a.valueNode(v); c.src != 0 && c.dst != 0 {
+		a.addConstraint(c)
+	}
+}
+
+//bz: the node that (*T)(unsafe.Pointer(&k)) points to: k or its first field (recursively) whose layout is compatible
+// with T; ok is false if none
+func (a *analysis) unsafeTarget(k nodeid, T types.Type) (nodeid, bool) {
+	base := a.enclosingObj(k)
+	obj := a.nodes[base].obj
+	if obj.flags&(otTagged|otFunction) != 0 {
+		return 0, false
+	}
+	end := base + nodeid(obj.size)
+	fl := a.flatten(T)
+	for ; k < end; k++ {
+		if k+nodeid(len(fl)) <= end && a.sameLayout(k, fl) {
+			return k, true
+		}
+		if layoutClass(a.nodes[k].typ) != 'a' {
+			break // not a struct/array: no first field
+		}
+	}
+	return 0, false
+}
+
+//bz: whether the nodes from k have the same kinds as fl
+func (a *analysis) sameLayout(k nodeid, fl []*fieldInfo) bool {
+	for i, fi := range fl {
+		if layoutClass(a.nodes[k+nodeid(i)].typ) != layoutClass(fi.typ) {
+			return false
+		}
+	}
+	return true
+}
+
+//bz: the kind of a node in the layout of an object
+func layoutClass(T types.Type) byte {
+	if CanHaveDynamicTypes(T) {
+		return 'i' // interface or reflect.Value
+	}
+	switch T := T.Underlying().(type) {
+	case *types.Struct, *types.Array:
+		return 'a'
+	case *types.Slice:
+		return 's'
+	case *types.Pointer, *types.Map, *types.Chan, *types.Signature:
+		return 'p'
+	case *types.Basic:
+		if T.Kind() == types.UnsafePointer {
+			return 'p'
+		}
+	}
+	return 'b'
+}
+
+//bz: the node at offset bytes from k in its object; ok is false if none
+func (a *analysis) unsafeOffset(k nodeid, offset int64) (nodeid, bool) {
+	base := a.enclosingObj(k)
+	obj := a.nodes[base].obj
+	if obj.flags&(otTagged|otFunction) != 0 {
+		return 0, false
+	}
+	T := a.nodes[base].typ
+	cur, ok := a.byteOffsetOf(T, uint32(k-base))
+	if !ok {
+		return 0, false
+	}
+	i, ok := a.nodeAtOffset(T, cur+offset)
+	if !ok || i >= obj.size {
+		return 0, false
+	}
+	return base + nodeid(i), true
+}
+
+//bz: the byte offset of the ith node of the flattened T (of the first element of arrays)
+func (a *analysis) byteOffsetOf(T types.Type, i uint32) (int64, bool) {
+	if i == 0 {
+		return 0, true
+	}
+	switch u := T.Underlying().(type) {
+	case *types.Struct:
+		offsets := a.config.sizes().Offsetsof(fieldsOf(u))
+		i-- // identity node
+		for j := 0; j < u.NumFields(); j++ {
+			f := u.Field(j)
+			n := a.sizeof(f.Type())
+			if i < n {
+				off, ok := a.byteOffsetOf(f.Type(), i)
+				return offsets[j] + off, ok
+			}
+			i -= n
+		}
+
+	case *types.Array:
+		return a.byteOffsetOf(u.Elem(), i-1)
+	}
+	return 0, false
+}
+
+//bz: the index of the node at offset bytes in the flattened T; all the elements of an array share nodes
+func (a *analysis) nodeAtOffset(T types.Type, offset int64) (uint32, bool) {
+	if offset == 0 {
+		return 0, true
+	}
+	switch u := T.Underlying().(type) {
+	case *types.Struct:
+		sizes := a.config.sizes()
+		fields := fieldsOf(u)
+		offsets := sizes.Offsetsof(fields)
+		i := uint32(1) // identity node
+		for j, f := range fields {
+			if size := sizes.Sizeof(f.Type()); offset >= offsets[j] && offset < offsets[j]+size {
+				n, ok := a.nodeAtOffset(f.Type(), offset-offsets[j])
+				return i + n, ok
+			}
+			i += a.sizeof(f.Type())
+		}
+
+	case *types.Array:
+		if size := a.config.sizes().Sizeof(u.Elem()); size > 0 && offset > 0 && offset < u.Len()*size {
+			n, ok := a.nodeAtOffset(u.Elem(), offset%size)
+			return 1 + n, ok
+		}
+	}
+	return 0, false
+}
+
+func fieldsOf(s *types.Struct) []*types.Var {
+	fields := make([]*types.Var, s.NumFields())
+	for i := range fields {
+		fields[i] = s.Field(i)
+	}
+	return fields
+}
+
+//bz: all the nodes of the object of k, i.e., the conservative result of uintptr arithmetic that cannot be modeled
+func (a *analysis) unsafeObjectNodes(k nodeid) []nodeid {
+	base := a.enclosingObj(k)
+	obj := a.nodes[base].obj
+	if obj.flags&(otTagged|otFunction) != 0 {
+		return []nodeid{k}
+	}
+	nodes := make([]nodeid, obj.size)
+	for i := range nodes {
+		nodes[i] = base + nodeid(i)
+	}
+	return nodes
+}
+
+//bz: warnf once for each warning; the solver may report the same one many times, e.g., for each context
+func (a *analysis) warnUnsafe(pos token.Pos, format string, args ...interface{}) {
+	w := Warning{pos, fmt.Sprintf(format, args...)}
+	if a.unsafeWarned[w] {
+		return
+	}
+	if a.unsafeWarned == nil {
+		a.unsafeWarned = make(map[Warning]bool)
+	}
+	a.unsafeWarned[w] = true
+	a.warnf(pos, "%s", w.Message)
+}
+
+func isUnsafePtrOrUintptr(T types.Type) bool {
+	b, ok := T.(*types.Basic)
+	return ok && (b.Kind() == types.UnsafePointer || b.Kind() == types.Uintptr)
+}
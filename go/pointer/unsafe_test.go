@@ -0,0 +1,192 @@
+package pointer
+
+import (
+	"go/types"
+	"strings"
+	"testing"
+
+	"github.com/april1989/origin-go-tools/go/buildutil"
+	"github.com/april1989/origin-go-tools/go/loader"
+	"github.com/april1989/origin-go-tools/go/ssa"
+	"github.com/april1989/origin-go-tools/go/ssa/ssautil"
+)
+
+// a *T stored in an unsafe.Pointer box (like atomic.Value), a field reached by unsafe.Offsetof, and a conversion
+// to a type with an incompatible layout.
+const unsafeTestProg = `
+package main
+
+import "unsafe"
+
+type T struct{ f *int }
+
+type S struct {
+	a int
+	b *int
+	c *T
+}
+
+var box unsafe.Pointer
+
+func store(t *T) { box = unsafe.Pointer(t) }
+
+func load() *T { return (*T)(box) }
+
+func field(s *S) **T {
+	return (**T)(unsafe.Pointer(uintptr(unsafe.Pointer(s)) + unsafe.Offsetof(s.c)))
+}
+
+func ptrs(s *S) *[2]*int { return (*[2]*int)(unsafe.Pointer(s)) }
+
+func use(t *T, p **T, q *[2]*int) {}
+
+func main() {
+	x := &T{}
+	store(x)
+	s := &S{c: x}
+	use(load(), field(s), ptrs(s))
+}
+`
+
+func TestSoundUnsafe(t *testing.T) {
+	conf := loader.Config{Build: buildutil.FakeContext(map[string]map[string]string{
+		"unsafe": {"unsafe.go": "package unsafe"}, //bz: the loader uses types.Unsafe, but needs to find it
+		"main":   {"main.go": unsafeTestProg},
+	})}
+	conf.Import("main")
+	iprog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog := ssautil.CreateProgram(iprog, 0)
+	prog.Build()
+	main := prog.Package(iprog.Imported["main"].Pkg)
+	use := main.Func("use")
+
+	labelsOf := func(result *Result, v ssa.Value) []*Label {
+		var labels []*Label
+		for _, ptr := range result.Queries[v] {
+			labels = append(labels, ptr.PointsTo().Labels()...)
+		}
+		return labels
+	}
+
+	//default: (*T)(box) is a fresh object, and the others are not modeled
+	config := originTestConfig(main)
+	result, err := Analyze(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if labels := labelsOf(result, use.Params[0]); len(labels) != 1 || labels[0].Value().Parent().Name() != "load" {
+		t.Errorf("default: pts(t) = %v, want the object of load", labels)
+	}
+	if len(result.Warnings) != 0 {
+		t.Errorf("default: got warnings %v", result.Warnings)
+	}
+
+	config = originTestConfig(main)
+	config.SoundUnsafe = true
+	result, err = Analyze(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if labels := labelsOf(result, use.Params[0]); len(labels) != 1 || labels[0].Value().Parent().Name() != "main" ||
+		labels[0].Path() != "" {
+		t.Errorf("pts(t) = %v, want x of main", labels)
+	}
+	if labels := labelsOf(result, use.Params[1]); len(labels) != 1 || labels[0].Value().Parent().Name() != "main" ||
+		labels[0].Path() != ".c" {
+		t.Errorf("pts(p) = %v, want s.c of main", labels)
+	}
+	if labels := labelsOf(result, use.Params[2]); len(labels) != 0 {
+		t.Errorf("pts(q) = %v, want empty", labels)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0].Message, "incompatible layout") {
+		t.Errorf("got warnings %v, want one of the incompatible layout", result.Warnings)
+	}
+}
+
+// the offset of S.c is 8 on 386, but of S.b on amd64; untag clears the tag bits of a pointer.
+const unsafeSizesTestProg = `
+package main
+
+import "unsafe"
+
+type S struct {
+	a int32
+	b *int
+	c *int
+}
+
+func field(s *S) **int {
+	return (**int)(unsafe.Pointer(uintptr(unsafe.Pointer(s)) + unsafe.Offsetof(s.c)))
+}
+
+func untag(s *S) *S { return (*S)(unsafe.Pointer(uintptr(unsafe.Pointer(s)) &^ 3)) }
+
+func use(p **int, q *S) {}
+
+func main() {
+	s := &S{}
+	use(field(s), untag(s))
+}
+`
+
+func TestSoundUnsafeSizes(t *testing.T) {
+	sizes := Platform{GOOS: "linux", GOARCH: "386"}.Sizes()
+	conf := loader.Config{Build: buildutil.FakeContext(map[string]map[string]string{
+		"unsafe": {"unsafe.go": "package unsafe"},
+		"main":   {"main.go": unsafeSizesTestProg},
+	})}
+	conf.TypeChecker.Sizes = sizes
+	conf.Import("main")
+	iprog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog := ssautil.CreateProgram(iprog, 0)
+	prog.Build()
+	main := prog.Package(iprog.Imported["main"].Pkg)
+	use := main.Func("use")
+
+	pathsOf := func(result *Result, v ssa.Value) []string {
+		var paths []string
+		for _, ptr := range result.Queries[v] {
+			for _, l := range ptr.PointsTo().Labels() {
+				paths = append(paths, l.Path())
+			}
+		}
+		return paths
+	}
+
+	for _, test := range []struct {
+		sizes types.Sizes
+		want  string
+	}{
+		{nil, ".b"}, //amd64
+		{sizes, ".c"},
+	} {
+		config := originTestConfig(main)
+		config.SoundUnsafe = true
+		config.Sizes = test.sizes
+		result, err := Analyze(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if paths := pathsOf(result, use.Params[0]); len(paths) != 1 || paths[0] != test.want {
+			t.Errorf("sizes %v: pts(p) = %v, want s%s", test.sizes, paths, test.want)
+		}
+
+		//the masked pointer: all the nodes of s, with a warning
+		if paths := pathsOf(result, use.Params[1]); len(paths) == 0 || paths[0] != "" {
+			t.Errorf("sizes %v: pts(q) = %v, want s", test.sizes, paths)
+		}
+		masked := false
+		for _, w := range result.Warnings {
+			masked = masked || strings.Contains(w.Message, "masking")
+		}
+		if !masked {
+			t.Errorf("sizes %v: got warnings %v, want one of the masking", test.sizes, result.Warnings)
+		}
+	}
+}
@@ -303,8 +303,18 @@ func (ns *nodeset) add(n nodeid) bool {
 	return ns.Sparse.Insert(int(n))
 }
 
-func (ns *nodeset) addAll(y *nodeset) bool {
-	return ns.UnionWith(&y.Sparse)
+func (ns *nodeset) addAll(y ptset) bool {
+	if y, ok := y.(*nodeset); ok {
+		return ns.UnionWith(&y.Sparse)
+	}
+	changed := false //bz: y is a pts of another representation, see ptset.go
+	var space [50]int
+	for _, x := range y.AppendTo(space[:0]) {
+		if ns.Insert(x) {
+			changed = true
+		}
+	}
+	return changed
 }
 
 // bz: Node set: SparseDense: NOT USED -------------------------------------------------------------------
@@ -331,18 +341,19 @@ func (ns *nodesdset) addAll(y *nodesdset) bool {
 
 // Profiling & debugging -------------------------------------------------------
 
-var timers = make(map[string]time.Time)
-
-func start(name string) {
+func (a *analysis) start(name string) {
 	if debugTimers {
-		timers[name] = time.Now()
+		if a.timers == nil {
+			a.timers = make(map[string]time.Time)
+		}
+		a.timers[name] = time.Now()
 		log.Printf("%s...\n", name)
 	}
 }
 
-func stop(name string) {
+func (a *analysis) stop(name string) {
 	if debugTimers {
-		log.Printf("%s took %s\n", name, time.Since(timers[name]))
+		log.Printf("%s took %s\n", name, time.Since(a.timers[name]))
 	}
 }
 
@@ -16,6 +16,7 @@ import (
 	"runtime"
 	"runtime/debug"
 	"sort"
+	"time"
 
 	"github.com/april1989/origin-go-tools/go/callgraph"
 	"github.com/april1989/origin-go-tools/go/ssa"
@@ -212,6 +213,14 @@ func (a *analysis) computeTrackBits() {
 
 var num_constraints int //bz:  performance
 
+//bz: record the cost of the phase that started at *start in Result.Phases, and restart the clock
+func (a *analysis) phase(name string, start *time.Time) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	a.result.Phases = append(a.result.Phases, Phase{Name: name, Time: time.Since(*start), HeapAlloc: mem.HeapAlloc})
+	*start = time.Now()
+}
+
 // Analyze runs the pointer analysis with the scope and options
 // specified by config, and returns the (synthetic) root of the callgraph.
 //
@@ -291,11 +300,14 @@ func Analyze(config *Config) (result *Result, err error) {
 	}
 	a.computeTrackBits()
 
+	phaseStart := time.Now() //bz: see Result.Phases
 	a.generate()
+	a.phase("generate", &phaseStart)
 	a.showCounts()
 
 	if optRenumber {
 		a.renumber()
+		a.phase("renumber", &phaseStart)
 	}
 
 	N := len(a.nodes) // excludes solver-created nodes
@@ -323,6 +335,7 @@ func Analyze(config *Config) (result *Result, err error) {
 		}
 
 		a.hvn()
+		a.phase("hvn", &phaseStart)
 	}
 
 	if debugHVNCrossCheck {
@@ -330,7 +343,9 @@ func Analyze(config *Config) (result *Result, err error) {
 		runtime.GC()
 	}
 
+	phaseStart = time.Now()
 	a.solve()
+	a.phase("solve", &phaseStart)
 
 	// Compare solutions.
 	if optHVN && debugHVNCrossCheck {
@@ -10,6 +10,7 @@ import (
 	"go/token"
 	"io"
 	"os"
+	"time"
 
 	"github.com/april1989/origin-go-tools/container/intsets"
 	"github.com/april1989/origin-go-tools/go/callgraph"
@@ -170,6 +171,14 @@ type Result struct {
 	Queries         map[ssa.Value]Pointer // pts(v) for each v in Config.Queries.
 	IndirectQueries map[ssa.Value]Pointer // pts(*v) for each v in Config.IndirectQueries.
 	Warnings        []Warning             // warnings of unsoundness
+	Phases          []Phase               // bz: the cost of each phase, in order
+}
+
+//bz: the cost of a phase of the analysis: "generate", "renumber", "hvn" and "solve"
+type Phase struct {
+	Name      string        `json:"name"`
+	Time      time.Duration `json:"time"`
+	HeapAlloc uint64        `json:"heapAlloc"` // bytes of allocated heap objects at the end of the phase
 }
 
 // A Pointer is an equivalence class of pointer-like values.
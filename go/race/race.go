@@ -0,0 +1,461 @@
+// Package race is a static data race detector on top of the origin-sensitive pointer analysis
+// (go/pointer with Config.Origin).
+//
+// An origin is the main goroutine, or the goroutines created by one go statement; the pointer analysis
+// analyzes the functions of each origin under its own context (with a loop ID if the go statement is in a
+// loop, so that the goroutines created by two iterations are two origins). The detector:
+//   - walks the origins of pointer.OriginGraph from main, with the call stacks and the locks held;
+//   - collects the reads and writes of fields, globals, maps and other heap locations in each origin, except
+//     the origin-local objects (see pointer.ThreadEscape);
+//   - pairs the accesses of two origins that may alias under their contexts, at least one a write;
+//   - prunes the pairs protected by a common lock (that must be the same mutex, see pointer.MutexSet), or
+//     ordered by happens-before (go statements, channel operations and sync.WaitGroup);
+//
+// and reports the remaining pairs as races, with the stacks of both accesses.
+//
+// Package initializers and the goroutines they create are not checked.
+package race // import "github.com/april1989/origin-go-tools/go/race"
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"github.com/april1989/origin-go-tools/go/pointer"
+	"github.com/april1989/origin-go-tools/go/ssa"
+)
+
+// An Access is a read or write of a memory location in an origin.
+type Access struct {
+	Instr  ssa.Instruction // *ssa.Store, *ssa.UnOp (load), or *ssa.MapUpdate, *ssa.Lookup, *ssa.Range or delete of a map
+	Addr   ssa.Value       // the address of the location, or the map
+	Write  bool
+	Origin *pointer.Origin
+	Stack  []ssa.CallInstruction // the calls from Origin.Entry to Instr
+	loc    *location
+	locks  lockset
+}
+
+// Pos returns the position of the access.
+func (a *Access) Pos() token.Pos {
+	if pos := a.Instr.Pos(); pos.IsValid() {
+		return pos
+	}
+	return a.Addr.Pos()
+}
+
+func (a *Access) String() string {
+	kind := "read"
+	if a.Write {
+		kind = "write"
+	}
+	fset := a.Instr.Parent().Prog.Fset
+	return fmt.Sprintf("%s of %s at %s in origin %s", kind, describe(a.Addr), fset.Position(a.Pos()), a.Origin)
+}
+
+// A Race is a pair of accesses to the same location from two origins, at least one of them a write, that
+// are neither protected by a common lock nor ordered by happens-before.
+type Race struct {
+	First, Second *Access
+}
+
+func (r *Race) String() string {
+	var buf bytes.Buffer
+	for i, a := range []*Access{r.First, r.Second} {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		buf.WriteString(a.String())
+		fset := a.Instr.Parent().Prog.Fset
+		for j := len(a.Stack) - 1; j >= 0; j-- {
+			site := a.Stack[j]
+			fmt.Fprintf(&buf, "\n\t%s at %s", site.Parent(), fset.Position(site.Pos()))
+		}
+		if a.Origin.Go != nil {
+			fmt.Fprintf(&buf, "\n\t%s at %s", a.Origin.Go.Parent(), fset.Position(a.Origin.Go.Pos()))
+		}
+	}
+	return buf.String()
+}
+
+//bz: a program point in an origin: instr reached through the calls in stack
+type point struct {
+	stack []ssa.CallInstruction
+	instr ssa.Instruction
+}
+
+type visitKey struct {
+	origin *pointer.Origin
+	node   *pointer.Node
+}
+
+type originFn struct {
+	origin *pointer.Origin
+	fn     *ssa.Function
+}
+
+type originValue struct {
+	origin *pointer.Origin
+	value  ssa.Value
+}
+
+type checker struct {
+	result   *pointer.Result
+	origins  []*pointer.Origin                         //the origins reached from main, in the order visited
+	spawns   map[*pointer.Origin][]point               //the go instructions that create an origin, in its parents
+	visited  map[visitKey]lockset                      //the locks held at the entry of a visited node
+	sites    map[originFn]map[ssa.CallInstruction]bool //the call sites of a function in an origin
+	accesses map[*pointer.Origin]map[ssa.Instruction]*Access
+	releases map[*pointer.Origin][]*event
+	acquires []*event
+	recorded map[eventKey]bool
+	locs     map[originValue]*location
+	reach    map[[2]*ssa.BasicBlock]bool
+	escape   *pointer.ThreadEscape
+}
+
+// Check reports the data races in the program whose main package is analyzed by result, computed with
+// Config.Origin and Config.BuildCallGraph. The races are sorted by their positions.
+func Check(result *pointer.Result) []*Race {
+	c := &checker{
+		result:   result,
+		spawns:   make(map[*pointer.Origin][]point),
+		visited:  make(map[visitKey]lockset),
+		sites:    make(map[originFn]map[ssa.CallInstruction]bool),
+		accesses: make(map[*pointer.Origin]map[ssa.Instruction]*Access),
+		releases: make(map[*pointer.Origin][]*event),
+		recorded: make(map[eventKey]bool),
+		locs:     make(map[originValue]*location),
+		reach:    make(map[[2]*ssa.BasicBlock]bool),
+		escape:   result.ThreadEscape(),
+	}
+	g := result.OriginGraph()
+	for _, n := range g.Root.Nodes {
+		fn := n.GetFunc()
+		if fn == nil || fn != g.Root.Entry || fn.Name() != "main" || fn.Pkg == nil || fn.Pkg.Pkg.Name() != "main" {
+			continue //package initializers
+		}
+		c.visit(g.Root, n, nil, nil)
+	}
+	return c.races()
+}
+
+//bz: the origin created by the go edge e in o, see pointer.OriginGraph
+func childOrigin(o *pointer.Origin, g *ssa.Go, e *pointer.Edge) *pointer.Origin {
+	loopID := 0
+	if ctx := e.Callee.GetContext(); len(ctx) > 0 && ctx[0].GetLoopID() > 0 {
+		loopID = ctx[0].GetLoopID()
+	}
+	for _, child := range o.Children {
+		if child.Go == g && child.LoopID == loopID && child.Entry == e.Callee.GetFunc() {
+			return child
+		}
+	}
+	return nil
+}
+
+//bz: visit node n in origin o, reached through the calls in stack with the locks held; a node is visited again
+// only if it is reached with fewer locks
+func (c *checker) visit(o *pointer.Origin, n *pointer.Node, stack []ssa.CallInstruction, held lockset) {
+	if c.accesses[o] == nil {
+		c.origins = append(c.origins, o)
+		c.accesses[o] = make(map[ssa.Instruction]*Access)
+	}
+	if len(stack) > 0 {
+		key := originFn{o, n.GetFunc()}
+		if c.sites[key] == nil {
+			c.sites[key] = make(map[ssa.CallInstruction]bool)
+		}
+		c.sites[key][stack[len(stack)-1]] = true
+	}
+	key := visitKey{o, n}
+	if prev, ok := c.visited[key]; ok {
+		if prev.subsetOf(held) {
+			return
+		}
+		held = prev.intersect(held)
+	}
+	c.visited[key] = held
+
+	locksAt := make(map[ssa.CallInstruction]lockset)
+	if fn := n.GetFunc(); fn != nil && fn.IsFromApp && fn.Blocks != nil {
+		locksAt = c.scan(o, fn, stack, held)
+	}
+
+	for _, e := range n.Out {
+		if g, ok := e.Site.(*ssa.Go); ok {
+			child := childOrigin(o, g, e)
+			if child == nil {
+				continue
+			}
+			c.spawns[child] = append(c.spawns[child], point{stack, g})
+			c.visit(child, e.Callee, nil, nil)
+			continue
+		}
+
+		next := stack
+		calleeHeld := held
+		if e.Site != nil {
+			next = append(stack[:len(stack):len(stack)], e.Site)
+			if locks, ok := locksAt[e.Site]; ok {
+				calleeHeld = locks
+			}
+		}
+		c.visit(o, e.Callee, next, calleeHeld)
+	}
+}
+
+//bz: record the accesses and synchronizations in fn, and return the locks held at each call
+func (c *checker) scan(o *pointer.Origin, fn *ssa.Function, stack []ssa.CallInstruction, held lockset) map[ssa.CallInstruction]lockset {
+	locksAt := make(map[ssa.CallInstruction]lockset)
+	in := c.locksets(o, fn, held)
+	for _, b := range fn.Blocks {
+		cur, ok := in[b]
+		if !ok {
+			continue //unreachable
+		}
+		for _, instr := range b.Instrs {
+			p := point{stack, instr}
+			switch instr := instr.(type) {
+			case *ssa.Store:
+				c.access(o, p, instr.Addr, true, cur)
+			case *ssa.MapUpdate:
+				c.access(o, p, instr.Map, true, cur)
+			case *ssa.Lookup:
+				if isMap(instr.X) {
+					c.access(o, p, instr.X, false, cur)
+				}
+			case *ssa.Range:
+				if isMap(instr.X) {
+					c.access(o, p, instr.X, false, cur)
+				}
+			case *ssa.UnOp:
+				switch instr.Op {
+				case token.MUL:
+					c.access(o, p, instr.X, false, cur)
+				case token.ARROW:
+					c.addEvent(o, p, false, chanSync, instr.X)
+				}
+			case *ssa.Send:
+				c.addEvent(o, p, true, chanSync, instr.Chan)
+			case *ssa.Select:
+				for _, state := range instr.States {
+					c.addEvent(o, p, state.Dir == types.SendOnly, chanSync, state.Chan)
+				}
+			case ssa.CallInstruction:
+				if m, ok := deletedMap(instr); ok {
+					c.access(o, p, m, true, cur)
+				}
+				locksAt[instr] = cur
+				c.callEvent(o, p, instr)
+				cur = c.transfer(o, instr, cur)
+			}
+		}
+	}
+	return locksAt
+}
+
+func isMap(v ssa.Value) bool {
+	_, ok := v.Type().Underlying().(*types.Map)
+	return ok
+}
+
+//bz: the map of delete(m, k)
+func deletedMap(call ssa.CallInstruction) (ssa.Value, bool) {
+	if _, ok := call.(*ssa.Call); !ok {
+		return nil, false
+	}
+	common := call.Common()
+	if b, ok := common.Value.(*ssa.Builtin); ok && b.Name() == "delete" && len(common.Args) == 2 {
+		return common.Args[0], true
+	}
+	return nil, false
+}
+
+func (c *checker) access(o *pointer.Origin, p point, addr ssa.Value, write bool, held lockset) {
+	if alloc, ok := addr.(*ssa.Alloc); ok && !alloc.Heap {
+		return //local
+	}
+	if a, ok := c.accesses[o][p.instr]; ok { //reached again with fewer locks
+		a.locks = a.locks.intersect(held)
+		return
+	}
+	loc := c.locate(o, addr)
+	if loc.local(c.escape) {
+		return
+	}
+	c.accesses[o][p.instr] = &Access{
+		Instr:  p.instr,
+		Addr:   addr,
+		Write:  write,
+		Origin: o,
+		Stack:  p.stack,
+		loc:    loc,
+		locks:  held,
+	}
+}
+
+//bz: the races between the accesses of each two origins
+func (c *checker) races() []*Race {
+	var races []*Race
+	reported := make(map[[2]token.Pos]bool) //e.g., the read and write of x++ have the same position
+	for i, x := range c.origins {
+		for _, y := range c.origins[i+1:] {
+			for _, a := range sortedAccesses(c.accesses[x]) {
+				for _, b := range sortedAccesses(c.accesses[y]) {
+					if !a.Write && !b.Write {
+						continue
+					}
+					key := [2]token.Pos{a.Pos(), b.Pos()}
+					if key[0] > key[1] {
+						key[0], key[1] = key[1], key[0]
+					}
+					if reported[key] || !a.loc.mayAlias(b.loc) || a.locks.protects(b.locks) ||
+						c.happensBefore(a, b) || c.happensBefore(b, a) {
+						continue
+					}
+					reported[key] = true
+					if a.Pos() > b.Pos() {
+						a, b = b, a
+					}
+					races = append(races, &Race{a, b})
+				}
+			}
+		}
+	}
+	sort.Slice(races, func(i, j int) bool {
+		if races[i].First.Pos() != races[j].First.Pos() {
+			return races[i].First.Pos() < races[j].First.Pos()
+		}
+		return races[i].Second.Pos() < races[j].Second.Pos()
+	})
+	return races
+}
+
+func sortedAccesses(m map[ssa.Instruction]*Access) []*Access {
+	accesses := make([]*Access, 0, len(m))
+	for _, a := range m {
+		accesses = append(accesses, a)
+	}
+	sort.Slice(accesses, func(i, j int) bool {
+		if accesses[i].Pos() != accesses[j].Pos() {
+			return accesses[i].Pos() < accesses[j].Pos()
+		}
+		return accesses[i].Write && !accesses[j].Write
+	})
+	return accesses
+}
+
+//bz: a location is the abstract memory location at an address: a global, or the objects and fields (e.g., the
+// field of a FieldAddr) pointed by the address
+type location struct {
+	global *ssa.Global
+	ptrs   []pointer.PointerWCtx
+	labels []*pointer.Label // pts of ptrs
+}
+
+func (c *checker) locate(o *pointer.Origin, addr ssa.Value) *location {
+	key := originValue{o, addr}
+	if loc, ok := c.locs[key]; ok {
+		return loc
+	}
+	var loc *location
+	switch addr := addr.(type) {
+	case *ssa.Global:
+		loc = &location{global: addr}
+	default:
+		loc = &location{ptrs: c.pointers(o, addr)}
+		for _, p := range loc.ptrs {
+			loc.labels = append(loc.labels, p.PointsTo().Labels()...)
+		}
+	}
+	c.locs[key] = loc
+	return loc
+}
+
+//bz: the pointers of v under the context of origin o; all pointers of v if none matches (e.g., shared contour);
+// the objects allocated by v are skipped
+func (c *checker) pointers(o *pointer.Origin, v ssa.Value) []pointer.PointerWCtx {
+	var ptrs []pointer.PointerWCtx
+	for _, p := range c.result.Queries[v] {
+		if !p.IsObject() {
+			ptrs = append(ptrs, p)
+		}
+	}
+	if ptrs == nil {
+		ptrs = c.result.IndirectQueries[v]
+	}
+	var matched []pointer.PointerWCtx
+	for _, p := range ptrs {
+		if p.MatchMyContextWithLoopID(o.Go, o.LoopID, nil) {
+			matched = append(matched, p)
+		}
+	}
+	if matched == nil {
+		return ptrs
+	}
+	return matched
+}
+
+//bz: whether the objects of l are origin-local (see pointer.ThreadEscape), which cannot race
+func (l *location) local(e *pointer.ThreadEscape) bool {
+	if l.global != nil || len(l.ptrs) == 0 {
+		return false
+	}
+	for _, p := range l.ptrs {
+		if !e.PointsToLocal(p) {
+			return false
+		}
+	}
+	return true
+}
+
+//bz: whether l and m may overlap: the same global, or a global and a pointer to (a field of) it, or pointers to
+// overlapping objects or fields, e.g., *p with p = &s.f and s.f
+func (l *location) mayAlias(m *location) bool {
+	switch {
+	case l.global != nil && m.global != nil:
+		return l.global == m.global
+	case l.global != nil:
+		return m.pointsInto(l.global)
+	case m.global != nil:
+		return l.pointsInto(m.global)
+	}
+	for _, x := range l.labels {
+		for _, y := range m.labels {
+			if x.Overlaps(*y) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (l *location) pointsInto(g *ssa.Global) bool {
+	for _, x := range l.labels {
+		if x.Value() == g {
+			return true
+		}
+	}
+	return false
+}
+
+//bz: a readable name of the location at addr; a map is named by where it is loaded from, e.g., map main.T.m
+func describe(addr ssa.Value) string {
+	if isMap(addr) {
+		if load, ok := addr.(*ssa.UnOp); ok && load.Op == token.MUL {
+			return "map " + describe(load.X)
+		}
+		return "map " + addr.Name()
+	}
+	switch addr := addr.(type) {
+	case *ssa.Global:
+		return addr.RelString(nil)
+	case *ssa.FieldAddr:
+		t := addr.X.Type().Underlying().(*types.Pointer).Elem()
+		return fmt.Sprintf("%s.%s", t, t.Underlying().(*types.Struct).Field(addr.Field).Name())
+	}
+	return "*" + addr.Name()
+}
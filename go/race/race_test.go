@@ -0,0 +1,212 @@
+package race
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/april1989/origin-go-tools/go/buildutil"
+	"github.com/april1989/origin-go-tools/go/loader"
+	"github.com/april1989/origin-go-tools/go/pointer"
+	"github.com/april1989/origin-go-tools/go/ssa"
+	"github.com/april1989/origin-go-tools/go/ssa/ssautil"
+)
+
+// A fake package sync: the analysis does not load the standard library.
+const fakeSync = `
+package sync
+
+type Mutex struct{ state int32 }
+
+func (m *Mutex) Lock()   {}
+func (m *Mutex) Unlock() {}
+
+type RWMutex struct{ w Mutex }
+
+func (rw *RWMutex) Lock()    {}
+func (rw *RWMutex) Unlock()  {}
+func (rw *RWMutex) RLock()   {}
+func (rw *RWMutex) RUnlock() {}
+
+type WaitGroup struct{ n int }
+
+func (wg *WaitGroup) Add(n int) {}
+func (wg *WaitGroup) Done()     {}
+func (wg *WaitGroup) Wait()     {}
+`
+
+// The field names say whether their accesses race.
+const raceTestProg = `
+package main
+
+import "sync"
+
+type T struct {
+	mu sync.Mutex
+	rw sync.RWMutex
+
+	race1, race2, race3, race4, race5, race8 int
+	locked, rlocked, waited, sent, spawned, closed int
+
+	race9, lockedm map[int]int
+}
+
+// The Ls are allocated in a loop, so l.mu may not be the same mutex.
+type L struct {
+	mu    sync.Mutex
+	race6 int
+}
+
+func newL() *L { return new(L) }
+
+func set(p *int) { *p = 1 }
+
+// The goroutine of read8 is created by main after the write, and by the first goroutine.
+func spawn8(t *T) { go read8(t) }
+
+func read8(t *T) { _ = t.race8 }
+
+var global, race7 int
+
+func (t *T) inc() {
+	t.mu.Lock()
+	t.locked++
+	t.mu.Unlock()
+}
+
+func main() {
+	t := &T{race9: make(map[int]int), lockedm: make(map[int]int)}
+	t.spawned = 1
+	t.race8 = 1
+	spawn8(t)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	ch := make(chan bool)
+	done := make(chan bool)
+	var ls []*L
+	for i := 0; i < 2; i++ {
+		ls = append(ls, newL())
+	}
+
+	go func() {
+		defer wg.Done()
+		spawn8(t)
+		t.race1 = 1
+		t.race9[1] = 1
+		t.mu.Lock()
+		delete(t.lockedm, 1)
+		t.mu.Unlock()
+		global = 1
+		set(&t.race5)
+		set(&race7)
+		l := ls[0]
+		l.mu.Lock()
+		l.race6 = 1
+		l.mu.Unlock()
+		t.inc()
+		t.rw.RLock()
+		_ = t.rlocked
+		t.rw.RUnlock()
+		t.waited = 1
+		t.sent = 1
+		ch <- true
+		_ = t.spawned
+		t.closed = 1
+		close(done)
+	}()
+	for i := 0; i < 2; i++ {
+		go func() {
+			t.race2++
+		}()
+	}
+	go func() {
+		t.race4 = 1
+	}()
+
+	_ = t.race1
+	_ = t.race9[1]
+	t.mu.Lock()
+	for k := range t.lockedm {
+		t.lockedm[k] = 2
+	}
+	t.mu.Unlock()
+	_ = global
+	_ = t.race5
+	_ = race7
+	l := ls[1]
+	l.mu.Lock()
+	_ = l.race6
+	l.mu.Unlock()
+	t.inc()
+	t.rw.Lock()
+	t.rlocked = 1
+	t.rw.Unlock()
+	<-ch
+	_ = t.sent
+	<-done
+	_ = t.closed
+	wg.Wait()
+	_ = t.waited
+	t.race4 = 2
+	t.race3 = 1
+	go func() {
+		_ = t.race3
+	}()
+	t.race3 = 2
+}
+`
+
+func TestCheck(t *testing.T) {
+	conf := loader.Config{Build: buildutil.FakeContext(map[string]map[string]string{
+		"sync": {"sync.go": fakeSync},
+		"main": {"main.go": raceTestProg},
+	})}
+	conf.Import("main")
+	iprog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog := ssautil.CreateProgram(iprog, 0)
+	prog.Build()
+	main := prog.Package(iprog.Imported["main"].Pkg)
+
+	result, err := pointer.Analyze(&pointer.Config{
+		Mains:          []*ssa.Package{main},
+		BuildCallGraph: true,
+		Origin:         true,
+		K:              1,
+		LimitScope:     true,
+		Scope:          []string{"main"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	races := Check(result)
+	var got []string
+	for _, r := range races {
+		if !strings.Contains(r.First.String(), " in origin ") || r.First.Origin == r.Second.Origin {
+			t.Errorf("bad race: %s", r)
+		}
+		got = append(got, describe(r.First.Addr)+" "+describe(r.Second.Addr))
+	}
+	sort.Strings(got)
+	want := []string{
+		"*p main.T.race5", //p = &t.race5
+		"*p main.race7",   //p = &race7
+		"main.L.race6 main.L.race6",
+		"main.T.race1 main.T.race1",
+		"main.T.race2 main.T.race2",
+		"main.T.race3 main.T.race3", //the second write
+		"main.T.race4 main.T.race4",
+		"main.T.race8 main.T.race8", //read8 has two parents
+		"main.global main.global",
+		"map main.T.race9 map main.T.race9",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("races:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+		for _, r := range races {
+			t.Log(r)
+		}
+	}
+}
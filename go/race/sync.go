@@ -0,0 +1,338 @@
+package race
+
+// This file defines the locksets and the happens-before relation used to prune the races.
+
+import (
+	"github.com/april1989/origin-go-tools/go/pointer"
+	"github.com/april1989/origin-go-tools/go/ssa"
+)
+
+//bz: a lock held: locked by value (the receiver of Lock/RLock)
+type heldLock struct {
+	value   ssa.Value
+	mutexes *pointer.MutexSet // the mutexes that value may point to
+	read    bool              // by RLock
+}
+
+//bz: the locks that must be held
+type lockset []heldLock
+
+func (s lockset) contains(l heldLock) bool {
+	for _, m := range s {
+		if m.value == l.value && m.read == l.read {
+			return true
+		}
+	}
+	return false
+}
+
+func (s lockset) add(l heldLock) lockset {
+	if s.contains(l) {
+		return s
+	}
+	return append(s[:len(s):len(s)], l)
+}
+
+//bz: unlock value: also remove the locks that may be the same
+func (s lockset) remove(value ssa.Value, mutexes *pointer.MutexSet) lockset {
+	var result lockset
+	for _, l := range s {
+		if l.value == value || l.mutexes.MayAlias(mutexes) {
+			continue
+		}
+		result = append(result, l)
+	}
+	return result
+}
+
+func (s lockset) intersect(t lockset) lockset {
+	var result lockset
+	for _, l := range s {
+		if t.contains(l) {
+			result = append(result, l)
+		}
+	}
+	return result
+}
+
+func (s lockset) subsetOf(t lockset) bool {
+	for _, l := range s {
+		if !t.contains(l) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s lockset) equal(t lockset) bool {
+	return len(s) == len(t) && s.subsetOf(t)
+}
+
+//bz: whether s and t have a common lock that excludes each other (not both read locks): the locks must be the same
+// mutex, may-alias is not enough
+func (s lockset) protects(t lockset) bool {
+	for _, l := range s {
+		for _, m := range t {
+			if (!l.read || !m.read) && l.mutexes.MustAlias(m.mutexes) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//bz: the locks held at the entry of each reachable block of fn in origin o, if held at the entry of fn
+func (c *checker) locksets(o *pointer.Origin, fn *ssa.Function, held lockset) map[*ssa.BasicBlock]lockset {
+	in := map[*ssa.BasicBlock]lockset{fn.Blocks[0]: held}
+	out := make(map[*ssa.BasicBlock]lockset)
+	for changed := true; changed; {
+		changed = false
+		for _, b := range fn.Blocks {
+			cur, ok := in[b]
+			if !ok {
+				continue
+			}
+			for _, instr := range b.Instrs {
+				if call, ok := instr.(ssa.CallInstruction); ok {
+					cur = c.transfer(o, call, cur)
+				}
+			}
+			if prev, ok := out[b]; ok && prev.equal(cur) {
+				continue
+			}
+			out[b] = cur
+			for _, succ := range b.Succs {
+				prev, ok := in[succ]
+				if !ok {
+					in[succ] = cur
+				} else if next := prev.intersect(cur); !next.equal(prev) {
+					in[succ] = next
+				} else {
+					continue
+				}
+				changed = true
+			}
+		}
+	}
+	return in
+}
+
+//bz: the locks held after call; a deferred Unlock is held until the function returns
+func (c *checker) transfer(o *pointer.Origin, call ssa.CallInstruction, held lockset) lockset {
+	if _, ok := call.(*ssa.Call); !ok {
+		return held //go or defer
+	}
+	typ, method, recv := pointer.SyncMethod(call.Common())
+	if typ != "Mutex" && typ != "RWMutex" {
+		return held
+	}
+	switch method {
+	case "Lock", "RLock", "Unlock", "RUnlock":
+	default:
+		return held
+	}
+	mutexes, err := c.result.MutexesOf(call, o.Go, o.LoopID)
+	if err != nil {
+		return held
+	}
+	if method == "Lock" || method == "RLock" {
+		return held.add(heldLock{value: recv, mutexes: mutexes, read: method == "RLock"})
+	}
+	return held.remove(recv, mutexes)
+}
+
+type syncKind int
+
+const (
+	chanSync      syncKind = iota // send/close -> receive
+	waitGroupSync                 // Done -> Wait
+)
+
+//bz: a synchronization: a release happens before the acquires of the same kind on the same location
+type event struct {
+	point
+	origin  *pointer.Origin
+	release bool
+	kind    syncKind
+	loc     *location
+}
+
+type eventKey struct {
+	origin *pointer.Origin
+	instr  ssa.Instruction
+	value  ssa.Value
+}
+
+func (c *checker) addEvent(o *pointer.Origin, p point, release bool, kind syncKind, value ssa.Value) {
+	key := eventKey{o, p.instr, value}
+	if c.recorded[key] {
+		return
+	}
+	c.recorded[key] = true
+	e := &event{point: p, origin: o, release: release, kind: kind, loc: c.locate(o, value)}
+	if release {
+		c.releases[o] = append(c.releases[o], e)
+	} else {
+		c.acquires = append(c.acquires, e)
+	}
+}
+
+//bz: record the synchronization of call (close, WaitGroup.Done/Wait), a deferred one included
+func (c *checker) callEvent(o *pointer.Origin, p point, call ssa.CallInstruction) {
+	if _, ok := call.(*ssa.Go); ok {
+		return
+	}
+	common := call.Common()
+	if b, ok := common.Value.(*ssa.Builtin); ok {
+		if b.Name() == "close" && len(common.Args) == 1 {
+			c.addEvent(o, p, true, chanSync, common.Args[0])
+		}
+		return
+	}
+	if typ, method, recv := pointer.SyncMethod(common); typ == "WaitGroup" {
+		switch method {
+		case "Done":
+			c.addEvent(o, p, true, waitGroupSync, recv)
+		case "Wait":
+			c.addEvent(o, p, false, waitGroupSync, recv)
+		}
+	}
+}
+
+//bz: whether a must happen before b: either
+//   - b is in a goroutine created (transitively) by the origin of a, after a; or
+//   - a is before a release (in its origin), whose acquire is before b or before the creation of the goroutine of b
+func (c *checker) happensBefore(a, b *Access) bool {
+	x, y := a.Origin, b.Origin
+	p, q := point{a.Stack, a.Instr}, point{b.Stack, b.Instr}
+	if z := childOf(x, y); z != nil && c.spawnedAfter(x, p, z) {
+		return true
+	}
+	for _, e := range c.releases[x] {
+		if !c.before(x, p, e.point) {
+			continue
+		}
+		for _, f := range c.acquires {
+			if f.kind != e.kind || f.origin == x || !e.loc.mayAlias(f.loc) {
+				continue
+			}
+			if f.origin == y && c.before(y, f.point, q) {
+				return true
+			}
+			if z := childOf(f.origin, y); z != nil && c.spawnedAfter(f.origin, f.point, z) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//bz: the child of x that is y or an ancestor of y; nil if y is not created by x, or may be created by another
+// origin, i.e., an origin from y up to x has many parents (see pointer.Origin.Parents)
+func childOf(x, y *pointer.Origin) *pointer.Origin {
+	for z := y; len(z.Parents) == 1; z = z.Parents[0] {
+		if z.Parents[0] == x {
+			return z
+		}
+	}
+	return nil
+}
+
+//bz: whether child z of x is created after p in x: p must happen before all the go instructions that create z
+func (c *checker) spawnedAfter(x *pointer.Origin, p point, z *pointer.Origin) bool {
+	spawns := c.spawns[z]
+	for _, s := range spawns {
+		if !c.before(x, p, s) {
+			return false
+		}
+	}
+	return len(spawns) > 0
+}
+
+//bz: whether p must happen before q in origin o: compare the instructions where their stacks diverge; the
+// functions below them must be called at only one call site in o, otherwise p or q has other stacks
+func (c *checker) before(o *pointer.Origin, p, q point) bool {
+	k := 0
+	for k < len(p.stack) && k < len(q.stack) && p.stack[k] == q.stack[k] {
+		k++
+	}
+	i, j := p.instr, q.instr
+	if k < len(p.stack) {
+		i = p.stack[k]
+	}
+	if k < len(q.stack) {
+		j = q.stack[k]
+	}
+	if !c.singleSite(o, p, k) || !c.singleSite(o, q, k) {
+		return false
+	}
+	return c.precedes(i, j)
+}
+
+func (c *checker) singleSite(o *pointer.Origin, p point, k int) bool {
+	for ; k < len(p.stack); k++ {
+		callee := p.instr.Parent()
+		if k+1 < len(p.stack) {
+			callee = p.stack[k+1].Parent()
+		}
+		if len(c.sites[originFn{o, callee}]) > 1 {
+			return false
+		}
+	}
+	return true
+}
+
+//bz: whether i must execute before j in the same function: i dominates j, and no path from j back to i (e.g., a loop);
+// a deferred call executes after the others, and the deferred calls in the reverse order
+func (c *checker) precedes(i, j ssa.Instruction) bool {
+	if i == j || i.Parent() != j.Parent() {
+		return false
+	}
+	_, di := i.(*ssa.Defer)
+	_, dj := j.(*ssa.Defer)
+	switch {
+	case di && dj:
+		i, j = j, i
+	case di:
+		return false
+	case dj:
+		return true
+	}
+	bi, bj := i.Block(), j.Block()
+	if bi == bj {
+		return index(i) < index(j) && !c.reaches(bj, bi)
+	}
+	return bi.Dominates(bj) && !c.reaches(bj, bi)
+}
+
+func index(instr ssa.Instruction) int {
+	for i, x := range instr.Block().Instrs {
+		if x == instr {
+			return i
+		}
+	}
+	return -1
+}
+
+//bz: whether there is a non-empty path from block from to block to
+func (c *checker) reaches(from, to *ssa.BasicBlock) bool {
+	key := [2]*ssa.BasicBlock{from, to}
+	if r, ok := c.reach[key]; ok {
+		return r
+	}
+	seen := make(map[*ssa.BasicBlock]bool)
+	stack := append([]*ssa.BasicBlock(nil), from.Succs...)
+	r := false
+	for len(stack) > 0 && !r {
+		b := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[b] {
+			continue
+		}
+		seen[b] = true
+		r = b == to
+		stack = append(stack, b.Succs...)
+	}
+	c.reach[key] = r
+	return r
+}
@@ -0,0 +1,281 @@
+package ssa
+
+// This file infers the summaries of library functions that invoke callbacks, and writes them as callback.yml.
+
+import (
+	"go/token"
+	"go/types"
+	"io"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+/*
+bz: an input of a function is a parameter (including the receiver) or a free variable; a function invokes an input if
+  it calls the input (a function value), or a method of the input (an interface value):
+    - directly: by a call/defer (mode call) or by a go instruction (mode go);
+    - transitively: by passing the input to a function that invokes it, or by binding the input to a closure that
+      invokes it, and calling (or going) the closure or passing it to a function that invokes it; the mode is go if
+      any step on the way is a go instruction.
+  we compute the modes of all inputs of all functions by a fixpoint, and summarize the exported library functions
+  that invoke their func or interface parameters. the result can be used as Config.Summaries of go/pointer, like
+  the summaries loaded from callback.yml.
+*/
+
+const (
+	modeCall = 1 << iota // invoked synchronously
+	modeGo               // invoked in a new goroutine
+)
+
+//bz: the mode of an invocation through a call instruction whose callee invokes with modes
+func through(instr CallInstruction, modes int) int {
+	if _, ok := instr.(*Go); ok && modes != 0 {
+		return modeGo
+	}
+	return modes
+}
+
+type inferrer struct {
+	modes   map[*Function][]int //the modes of each input, indexed by params then free vars
+	changed bool
+}
+
+//bz: the indexes of the inputs of fn that v may be, through phis, change types, and the cells of captured variables
+// (a closure captures a variable by the address of its cell, the *Alloc, and loads it from the free variable)
+func inputsOf(fn *Function, v Value, seen map[Value]bool) []int {
+	if seen[v] {
+		return nil
+	}
+	seen[v] = true
+	switch v := v.(type) {
+	case *Parameter:
+		for i, p := range fn.Params {
+			if p == v {
+				return []int{i}
+			}
+		}
+	case *FreeVar:
+		for i, fv := range fn.FreeVars {
+			if fv == v {
+				return []int{len(fn.Params) + i}
+			}
+		}
+	case *ChangeType:
+		return inputsOf(fn, v.X, seen)
+	case *ChangeInterface:
+		return inputsOf(fn, v.X, seen)
+	case *MakeInterface:
+		return inputsOf(fn, v.X, seen)
+	case *Phi:
+		var inputs []int
+		for _, e := range v.Edges {
+			inputs = append(inputs, inputsOf(fn, e, seen)...)
+		}
+		return inputs
+	case *UnOp:
+		if v.Op == token.MUL {
+			return inputsOf(fn, v.X, seen)
+		}
+	case *Alloc:
+		var inputs []int
+		for _, ref := range *v.Referrers() {
+			if store, ok := ref.(*Store); ok && store.Addr == v {
+				inputs = append(inputs, inputsOf(fn, store.Val, seen)...)
+			}
+		}
+		return inputs
+	}
+	return nil
+}
+
+func (inf *inferrer) add(fn *Function, input, modes int) {
+	if modes&^inf.modes[fn][input] != 0 {
+		inf.modes[fn][input] |= modes
+		inf.changed = true
+	}
+}
+
+//bz: the modes of input i of fn; 0 if unknown
+func (inf *inferrer) modesOf(fn *Function, i int) int {
+	if m := inf.modes[fn]; i < len(m) {
+		return m[i]
+	}
+	return 0
+}
+
+//bz: one pass over the call instructions of fn
+func (inf *inferrer) visit(fn *Function) {
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			call, ok := instr.(CallInstruction)
+			if !ok {
+				continue
+			}
+			common := call.Common()
+			for _, i := range inputsOf(fn, common.Value, make(map[Value]bool)) { //calls the input
+				inf.add(fn, i, through(call, modeCall))
+			}
+
+			callee := common.StaticCallee()
+			if callee == nil {
+				continue
+			}
+			if mc, ok := common.Value.(*MakeClosure); ok { //calls a closure binding the inputs
+				for k, binding := range mc.Bindings {
+					m := through(call, inf.modesOf(callee, len(callee.Params)+k))
+					for _, i := range inputsOf(fn, binding, make(map[Value]bool)) {
+						inf.add(fn, i, m)
+					}
+				}
+			}
+			for j, arg := range common.Args {
+				outer := through(call, inf.modesOf(callee, j))
+				if outer == 0 {
+					continue
+				}
+				for _, i := range inputsOf(fn, arg, make(map[Value]bool)) { //passes the input
+					inf.add(fn, i, outer)
+				}
+				if mc, ok := arg.(*MakeClosure); ok { //passes a closure binding the inputs
+					for k, binding := range mc.Bindings {
+						inner := inf.modesOf(mc.Fn.(*Function), len(mc.Fn.(*Function).Params)+k)
+						m := inner
+						if outer&modeGo != 0 && inner != 0 {
+							m = modeGo
+						}
+						for _, i := range inputsOf(fn, binding, make(map[Value]bool)) {
+							inf.add(fn, i, m)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// InferSummaries infers the summaries of the functions in fns (e.g., ssautil.AllFunctions(prog)) for which lib
+// returns true: an exported library function is summarized if it (transitively) calls a function parameter, or a
+// method of an interface parameter, synchronously or from a new goroutine.
+func InferSummaries(fns map[*Function]bool, lib func(*Function) bool) *Summaries {
+	inf := &inferrer{modes: make(map[*Function][]int)}
+	var all []*Function
+	var add func(fn *Function)
+	add = func(fn *Function) {
+		if inf.modes[fn] != nil {
+			return
+		}
+		inf.modes[fn] = make([]int, len(fn.Params)+len(fn.FreeVars))
+		all = append(all, fn)
+		for _, anon := range fn.AnonFuncs {
+			add(anon)
+		}
+	}
+	for fn := range fns {
+		add(fn)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].String() < all[j].String() }) //deterministic
+	for inf.changed = true; inf.changed; {
+		inf.changed = false
+		for _, fn := range all {
+			inf.visit(fn)
+		}
+	}
+
+	ss := NewSummaries()
+	for _, fn := range all {
+		if s := inf.summarize(fn, lib); s != nil {
+			ss.Add(s)
+		}
+	}
+	return ss
+}
+
+//bz: the summary of fn, or nil if fn is not an exported library function that invokes a parameter
+func (inf *inferrer) summarize(fn *Function, lib func(*Function) bool) *Summary {
+	obj, ok := fn.Object().(*types.Func)
+	if !ok || fn.Synthetic != "" || fn.Pkg == nil || !obj.Exported() || !lib(fn) {
+		return nil
+	}
+	qual := types.RelativeTo(fn.Pkg.Pkg)
+	s := &Summary{
+		Package:    fn.Pkg.Pkg.Path(),
+		Name:       fn.Name(),
+		Descriptor: strings.TrimPrefix(types.TypeString(fn.Signature, qual), "func"),
+	}
+	skip := 0
+	if recv := fn.Signature.Recv(); recv != nil {
+		skip = 1
+		s.Receiver = "(" + types.TypeString(recv.Type(), qual) + ")"
+		if recv.Name() != "" && recv.Name() != "_" {
+			s.Receiver = "(" + recv.Name() + " " + types.TypeString(recv.Type(), qual) + ")"
+		}
+	}
+	for i := skip; i < len(fn.Params); i++ {
+		switch fn.Params[i].Type().Underlying().(type) {
+		case *types.Signature, *types.Interface:
+		default:
+			continue
+		}
+		modes := inf.modes[fn][i]
+		if modes&modeGo != 0 {
+			s.Invokes = append(s.Invokes, Invoke{Param: i - skip, Go: true})
+		}
+		if modes&modeCall != 0 {
+			s.Invokes = append(s.Invokes, Invoke{Param: i - skip})
+		}
+	}
+	if len(s.Invokes) == 0 {
+		return nil
+	}
+	return s
+}
+
+// Merge adds the summaries in other of the functions without a summary in ss.
+func (ss *Summaries) Merge(other *Summaries) {
+	for _, s := range other.All() {
+		if _, ok := ss.summaries[s.Key()]; !ok {
+			ss.Add(s)
+		}
+	}
+}
+
+// WriteYaml writes ss to w in the format of callback.yml, see LoadSummaries.
+func (ss *Summaries) WriteYaml(w io.Writer) error {
+	var spec callbackSpec
+	pkgs := make(map[string]int) //package path -> index in spec
+	for _, s := range ss.All() {
+		i, ok := pkgs[s.Package]
+		if !ok {
+			i = len(spec.Packages)
+			pkgs[s.Package] = i
+			spec.Packages = append(spec.Packages, specPackage{Name: s.Package})
+		}
+		m := specMethod{Name: s.Name, Receiver: s.Receiver, Descriptor: s.Descriptor}
+		if m.Receiver == "" {
+			m.Receiver = "nil"
+		}
+		for _, inv := range s.Invokes {
+			if inv.Param < 0 { //any param in a new goroutine: the old meaning of callback.yml without invoke
+				m.Invokes = nil
+				break
+			}
+			param, mode := inv.Param, "call"
+			if inv.Go {
+				mode = "go"
+			}
+			m.Invokes = append(m.Invokes, specInvoke{Param: &param, Mode: mode})
+		}
+		spec.Packages[i].Methods = append(spec.Packages[i].Methods, m)
+	}
+	data, err := yaml.Marshal(&spec)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, "# callback functions inferred by ssa.InferSummaries, see go/ssa/summary.go\n"); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
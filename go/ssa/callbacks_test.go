@@ -0,0 +1,106 @@
+package ssa_test
+
+import (
+	"bytes"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
+	"github.com/april1989/origin-go-tools/go/ssa/ssautil"
+)
+
+// The comments say how the functions invoke their callbacks.
+const callbacksTestLib = `
+package lib
+
+type Handler interface{ Serve(int) }
+
+var saved func()
+
+func Async(f func())          { go f() }
+func Sync(f func())           { defer f() }
+func Wrapped(d int, f func()) { go func() { f() }() }
+func Via(f func())            { Async(f) }         // go
+func Later(f func())          { run(func() { f() }) } // go
+func Both(f func())           { f(); Async(f) }       // go and call
+func Serve(h Handler)         { h.Serve(1) }
+func Store(f func())          { saved = f } // none
+func run(g func())            { go g() }   // unexported
+
+type Server struct{}
+
+func (s *Server) Go(h Handler) { go s.loop(h) }
+func (s *Server) loop(h Handler) {
+	for {
+		h.Serve(2)
+	}
+}
+`
+
+func TestInferSummaries(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "lib.go", callbacksTestLib, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := types.NewPackage("lib", "")
+	lib, _, err := ssautil.BuildPackage(&types.Config{Importer: importer.Default()}, fset, pkg, []*ast.File{f}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ss := ssa.InferSummaries(ssautil.AllFunctions(lib.Prog), func(fn *ssa.Function) bool {
+		return fn.Pkg == lib
+	})
+	want := "(*lib.Server).Go: go param 0; lib.Async: go param 0; lib.Both: go param 0, call param 0; " +
+		"lib.Later: go param 0; lib.Serve: call param 0; lib.Sync: call param 0; lib.Via: go param 0; " +
+		"lib.Wrapped: go param 1"
+	if got := dumpSummaries(ss); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if s := ss.All()[0]; s.Receiver != "(s *Server)" || s.Descriptor != "(h Handler)" {
+		t.Errorf("(*lib.Server).Go: receiver %q, descriptor %q", s.Receiver, s.Descriptor)
+	}
+	if none := ssa.InferSummaries(ssautil.AllFunctions(lib.Prog), func(*ssa.Function) bool { return false }); none.Len() != 0 {
+		t.Errorf("not a library: got %s", dumpSummaries(none))
+	}
+
+	//the output of WriteYaml is a callback.yml
+	dir, err := ioutil.TempDir("", "callbacks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var buf bytes.Buffer
+	if err := ss.WriteYaml(&buf); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "callback.yml")
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := ssa.LoadSummaries(path)
+	if err != nil {
+		t.Fatalf("%v:\n%s", err, buf.String())
+	}
+	if got := dumpSummaries(loaded); got != want {
+		t.Errorf("reloaded: got %s, want %s", got, want)
+	}
+
+	loaded.Merge(ssa.NewSummaries())
+	native, err := ssa.LoadSummaries("native.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	native.Merge(loaded)
+	if native.Len() != ss.Len()+2 {
+		t.Errorf("merged: got %s", dumpSummaries(native))
+	}
+}
@@ -39,6 +39,11 @@ func NewProgram(fset *token.FileSet, mode BuilderMode) *Program {
 	return prog
 }
 
+// Mode returns the set of mode bits prog was built with.
+func (prog *Program) Mode() BuilderMode {
+	return prog.mode
+}
+
 // memberFromObject populates package pkg with a member for the
 // typechecker object obj.
 //
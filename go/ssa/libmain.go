@@ -0,0 +1,319 @@
+package ssa
+
+// CreateLibMainPackage synthesizes a main package that calls the
+// exported API of a library package, so that a whole-program analysis
+// (e.g., go/pointer) can analyze a library without a main.
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"github.com/april1989/origin-go-tools/go/types/typeutil"
+)
+
+/*
+bz: the harness main() of a library package pkg:
+  - calls every exported function of pkg, and every exported method of each exported named (non-interface) type T
+    of pkg on a *T, with abstract arguments;
+  - an abstract *T is the load of a slot of type **T that holds a fresh allocation of T and every *T returned by a
+    call of the harness (e.g., from a constructor), so all the calls on a *T share the same objects, as the clients of
+    pkg do; the exported fields of a fresh allocation are initialized by abstract values (up to maxLibDepth levels);
+  - an abstract I (interface) is the load of a slot that holds every named type of pkg (T or *T) implementing I;
+    abstract slices, maps and chans are made with an abstract element; others are zero values;
+  - the exported function-typed fields of the abstract objects are callbacks: main() loads and calls them with
+    abstract arguments, so the functions stored by pkg (e.g., default handlers) are analyzed.
+  all the slots are heap allocs, so the order of the instructions does not matter to a flow-insensitive analysis.
+*/
+
+//bz: the max depth of abstract values, e.g., the fields of the fields of a fresh allocation
+const maxLibDepth = 3
+
+// CreateLibMainPackage creates and returns a synthetic "libmain"
+// package for the library package pkg if pkg has exported functions
+// or methods, or nil otherwise.  The new package is named "main" and
+// provides a function named "main" that calls them with abstract
+// arguments, and an "init" that initializes pkg.
+//
+// The package pkg must belong to the program prog and be built.
+func (prog *Program) CreateLibMainPackage(pkg *Package) *Package {
+	if pkg.Prog != prog {
+		panic("Package does not belong to Program")
+	}
+
+	path := pkg.Pkg.Path() + "$libmain"
+	tpkg := types.NewPackage(path, "main")
+	tpkg.SetImports([]*types.Package{pkg.Pkg})
+	tpkg.MarkComplete()
+	p := &Package{
+		Prog:    prog,
+		Members: make(map[string]Member),
+		values:  make(map[types.Object]Value),
+		Pkg:     tpkg,
+	}
+
+	main := &Function{
+		name:      "main",
+		Signature: new(types.Signature),
+		Synthetic: "library harness",
+		Pkg:       p,
+		Prog:      prog,
+	}
+	main.startBody()
+	h := &libHarness{fn: main, pkg: pkg}
+	if !h.callAll() {
+		return nil
+	}
+	main.emit(new(Return))
+	//bz: not finishBody(): lifting would replace the load of a slot by the value stored before it
+	main.objects = nil
+	main.currentBlock = nil
+	buildReferrers(main)
+	buildDomTree(main)
+	numberRegisters(main)
+	p.Members[main.name] = main
+
+	p.init = &Function{
+		name:      "init",
+		Signature: new(types.Signature),
+		Synthetic: "package initializer",
+		Pkg:       p,
+		Prog:      prog,
+	}
+	p.init.startBody()
+	var v Call
+	v.Call.Value = pkg.init
+	v.setType(types.NewTuple())
+	p.init.emit(&v)
+	p.init.emit(new(Return))
+	p.init.finishBody()
+	p.Members[p.init.name] = p.init
+
+	prog.packages[tpkg] = p
+	return p
+}
+
+//bz: the state to generate the harness main()
+type libHarness struct {
+	fn        *Function
+	pkg       *Package
+	slots     typeutil.Map // T -> the slot (an Alloc of **T) of the abstract *T
+	structs   []types.Type // the struct types T in slots, in order
+	callbacks map[types.Type][]int
+}
+
+//bz: generate the calls of main(); return false if there is no exported function or method
+func (h *libHarness) callAll() bool {
+	prog := h.pkg.Prog
+	var names []string
+	for name := range h.pkg.Members {
+		if ast.IsExported(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	calls := 0
+	for _, name := range names {
+		if fn, ok := h.pkg.Members[name].(*Function); ok && fn.Pkg == h.pkg {
+			h.call(fn, fn.Signature, nil)
+			calls++
+		}
+	}
+	for _, name := range names {
+		t, ok := h.pkg.Members[name].(*Type)
+		if !ok || types.IsInterface(t.Type()) {
+			continue
+		}
+		ptr := types.NewPointer(t.Type())
+		mset := prog.MethodSets.MethodSet(ptr)
+		for i := 0; i < mset.Len(); i++ {
+			sel := mset.At(i)
+			if !sel.Obj().Exported() {
+				continue
+			}
+			if fn := prog.MethodValue(sel); fn != nil {
+				h.call(fn, fn.Signature, h.value(ptr, 0))
+				calls++
+			}
+		}
+	}
+	if calls == 0 {
+		return false
+	}
+
+	//callbacks: h.structs may grow
+	for i := 0; i < len(h.structs); i++ {
+		T := h.structs[i]
+		for _, f := range h.callbacks[T] {
+			ptr := emitLoad(h.fn, h.slot(T, 0))
+			field := T.Underlying().(*types.Struct).Field(f)
+			addr := &FieldAddr{X: ptr, Field: f}
+			addr.setType(types.NewPointer(field.Type()))
+			h.fn.emit(addr)
+			h.call(emitLoad(h.fn, addr), field.Type().Underlying().(*types.Signature), nil)
+		}
+	}
+	return true
+}
+
+//bz: emit a call of callee with abstract arguments; recv is the receiver if callee is a method
+func (h *libHarness) call(callee Value, sig *types.Signature, recv Value) {
+	var c Call
+	c.Call.Value = callee
+	if recv != nil {
+		c.Call.Args = append(c.Call.Args, recv)
+	}
+	params := sig.Params()
+	for i := 0; i < params.Len(); i++ {
+		c.Call.Args = append(c.Call.Args, h.value(params.At(i).Type(), 0))
+	}
+	results := sig.Results()
+	if results.Len() == 1 {
+		c.setType(results.At(0).Type())
+	} else {
+		c.setType(results)
+	}
+	h.fn.emit(&c)
+
+	//the *T returned by a call, e.g., from a constructor, are also abstract *T
+	for i := 0; i < results.Len(); i++ {
+		ptr, ok := results.At(i).Type().Underlying().(*types.Pointer)
+		if !ok {
+			continue
+		}
+		if named, ok := ptr.Elem().(*types.Named); !ok || named.Obj().Pkg() != h.pkg.Pkg {
+			continue
+		}
+		v := Value(&c)
+		if results.Len() > 1 {
+			v = emitExtract(h.fn, &c, i)
+		}
+		emitStore(h.fn, h.slot(ptr.Elem(), 0), v, token.NoPos)
+	}
+}
+
+//bz: the slot of the abstract *T, which holds a fresh allocation of T; its fields are abstract values at depth+1
+func (h *libHarness) slot(T types.Type, depth int) *Alloc {
+	if slot, ok := h.slots.At(T).(*Alloc); ok {
+		return slot
+	}
+	slot := emitNew(h.fn, types.NewPointer(T), token.NoPos)
+	h.slots.Set(T, slot)
+	obj := emitNew(h.fn, T, token.NoPos)
+	emitStore(h.fn, slot, obj, token.NoPos)
+
+	if st, ok := T.Underlying().(*types.Struct); ok {
+		h.structs = append(h.structs, T)
+		for i := 0; i < st.NumFields(); i++ {
+			field := st.Field(i)
+			if !field.Exported() {
+				continue //initialized by pkg
+			}
+			if _, ok := field.Type().Underlying().(*types.Signature); ok {
+				if h.callbacks == nil {
+					h.callbacks = make(map[types.Type][]int)
+				}
+				h.callbacks[T] = append(h.callbacks[T], i)
+				continue //set by the clients
+			}
+			if depth >= maxLibDepth || isBasic(field.Type()) {
+				continue
+			}
+			addr := &FieldAddr{X: obj, Field: i}
+			addr.setType(types.NewPointer(field.Type()))
+			h.fn.emit(addr)
+			emitStore(h.fn, addr, h.value(field.Type(), depth+1), token.NoPos)
+		}
+	}
+	return slot
+}
+
+//bz: an abstract value of type T at depth
+func (h *libHarness) value(T types.Type, depth int) Value {
+	if depth > maxLibDepth {
+		return h.zero(T)
+	}
+	switch t := T.Underlying().(type) {
+	case *types.Pointer:
+		return emitLoad(h.fn, h.slot(t.Elem(), depth))
+
+	case *types.Struct, *types.Array:
+		return emitLoad(h.fn, emitLoad(h.fn, h.slot(T, depth)))
+
+	case *types.Interface:
+		impls := h.impls(t)
+		if len(impls) == 0 {
+			return h.zero(T)
+		}
+		slot := emitNew(h.fn, T, token.NoPos)
+		for _, impl := range impls {
+			emitStore(h.fn, slot, emitConv(h.fn, h.value(impl, depth+1), T), token.NoPos)
+		}
+		return emitLoad(h.fn, slot)
+
+	case *types.Slice:
+		arr := emitNew(h.fn, types.NewArray(t.Elem(), 1), token.NoPos)
+		if !isBasic(t.Elem()) {
+			elem := &IndexAddr{X: arr, Index: intConst(0)}
+			elem.setType(types.NewPointer(t.Elem()))
+			h.fn.emit(elem)
+			emitStore(h.fn, elem, h.value(t.Elem(), depth+1), token.NoPos)
+		}
+		s := &Slice{X: arr}
+		s.setType(T)
+		h.fn.emit(s)
+		return s
+
+	case *types.Map:
+		m := &MakeMap{}
+		m.setType(T)
+		h.fn.emit(m)
+		h.fn.emit(&MapUpdate{
+			Map:   m,
+			Key:   h.value(t.Key(), depth+1),
+			Value: h.value(t.Elem(), depth+1),
+		})
+		return m
+
+	case *types.Chan:
+		ch := &MakeChan{Size: intConst(0)}
+		ch.setType(T)
+		h.fn.emit(ch)
+		return ch
+	}
+	return h.zero(T)
+}
+
+//bz: the named types of pkg (T, or *T if only *T) that implement iface
+func (h *libHarness) impls(iface *types.Interface) []types.Type {
+	var impls []types.Type
+	scope := h.pkg.Pkg.Scope()
+	for _, name := range scope.Names() {
+		tn, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || types.IsInterface(tn.Type()) {
+			continue
+		}
+		if T := tn.Type(); types.Implements(T, iface) {
+			impls = append(impls, T)
+		} else if ptr := types.NewPointer(T); types.Implements(ptr, iface) {
+			impls = append(impls, ptr)
+		}
+	}
+	return impls
+}
+
+//bz: the zero value of T
+func (h *libHarness) zero(T types.Type) Value {
+	switch T.Underlying().(type) {
+	case *types.Struct, *types.Array:
+		return emitLoad(h.fn, emitNew(h.fn, T, token.NoPos))
+	}
+	return zeroConst(T)
+}
+
+func isBasic(T types.Type) bool {
+	_, ok := T.Underlying().(*types.Basic)
+	return ok
+}
@@ -0,0 +1,111 @@
+package ssa_test
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
+	"github.com/april1989/origin-go-tools/go/ssa/ssautil"
+)
+
+const libmainTestLib = `
+package lib
+
+type Handler interface{ Serve(r *Request) }
+
+type Request struct{ Body *string }
+
+type Server struct {
+	OnError  func(err error)
+	handlers []Handler
+}
+
+func NewServer() *Server { return &Server{OnError: logError} }
+
+func logError(err error) {}
+
+func (s *Server) Handle(h Handler) { s.handlers = append(s.handlers, h) }
+
+func (s *Server) Serve(r *Request) {
+	for _, h := range s.handlers {
+		go h.Serve(r)
+	}
+}
+
+type echo struct{ out *string }
+
+func (e *echo) Serve(r *Request) { e.out = r.Body }
+`
+
+func buildLib(t *testing.T, src string) *ssa.Package {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "lib.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := types.NewPackage("lib", "")
+	lib, _, err := ssautil.BuildPackage(&types.Config{Importer: importer.Default()}, fset, pkg, []*ast.File{f}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lib
+}
+
+func TestCreateLibMainPackage(t *testing.T) {
+	lib := buildLib(t, libmainTestLib)
+	libmain := lib.Prog.CreateLibMainPackage(lib)
+	if libmain == nil {
+		t.Fatal("no libmain")
+	}
+	if libmain.Pkg.Name() != "main" || libmain.Pkg.Path() != "lib$libmain" {
+		t.Errorf("libmain package: %s %s", libmain.Pkg.Name(), libmain.Pkg.Path())
+	}
+	main := libmain.Func("main")
+	var callees []string
+	var callbacks, implsEcho int
+	for _, b := range main.Blocks {
+		for _, instr := range b.Instrs {
+			switch instr := instr.(type) {
+			case *ssa.Call:
+				if callee := instr.Call.StaticCallee(); callee != nil {
+					callees = append(callees, callee.String())
+				} else if load, ok := instr.Call.Value.(*ssa.UnOp); ok {
+					if _, ok := load.X.(*ssa.FieldAddr); ok {
+						callbacks++
+					}
+				}
+			case *ssa.MakeInterface:
+				if instr.X.Type().String() == "*lib.echo" {
+					implsEcho++
+				}
+			}
+		}
+	}
+	sort.Strings(callees)
+	if got, want := strings.Join(callees, " "), "(*lib.Server).Handle (*lib.Server).Serve lib.NewServer"; got != want {
+		t.Errorf("callees: got %s, want %s", got, want)
+	}
+	if callbacks != 1 || implsEcho != 1 {
+		t.Errorf("got %d calls of Server.OnError and %d *echo for Handler, want 1 and 1:\n%s", callbacks, implsEcho, dumpFunc(main))
+	}
+	if init := libmain.Func("init"); init == nil || len(init.Blocks[0].Instrs) != 2 {
+		t.Errorf("init does not initialize lib")
+	}
+
+	none := buildLib(t, "package lib\n\ntype t struct{}\n\nfunc f() {}\n")
+	if libmain := none.Prog.CreateLibMainPackage(none); libmain != nil {
+		t.Errorf("no exported function: got %s", libmain.Func("main"))
+	}
+}
+
+func dumpFunc(fn *ssa.Function) string {
+	var buf strings.Builder
+	fn.WriteTo(&buf)
+	return buf.String()
+}
@@ -0,0 +1,428 @@
+package ssa
+
+// This file defines the summaries of library functions (native.xml, native.json, callback.yml).
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+/*
+bz: a summary tells what a library function does with its callback parameters, so we do not need to analyze
+    the library to find out: e.g., time.AfterFunc(d, f) invokes f in a new goroutine; (*sync.Map).Range(f) invokes f.
+    the pointer analysis uses the summary to create a synthetic function for the library function, whose body only
+    invokes the callbacks (see CreateSyntheticCallForCallBack()), alongside the ordinary call of the library function.
+    three formats with the same content:
+    native.xml:
+      <summary-spec>
+        <package name="time">
+          <method name="AfterFunc" descriptor="(d Duration, f func()) *Timer">
+            <invoke param="1" mode="go"/>
+            <body>return new</body>
+          </method>
+        </package>
+      </summary-spec>
+    native.json:
+      {"package": [{"name": "time", "method": [{"name": "AfterFunc", "descriptor": "(d Duration, f func()) *Timer",
+                    "invoke": [{"param": 1, "mode": "go"}], "body": "return new"}]}]}
+    callback.yml:
+      callbackcfgs:
+        - package: time
+          method:
+            - name: AfterFunc
+              receiver: nil
+              invoke:
+                - param: 1
+                  mode: go
+    param is the index of the callback in the parameters of descriptor (the receiver is not counted);
+    mode is "go" (invoked in a new goroutine) or "call" (invoked synchronously).
+    body is an optional synthetic SSA body of the function, statements separated by ";" or new lines:
+      "go pN()"       -> invokes param N in a new goroutine, same as <invoke param="N" mode="go"/>;
+      "pN()"          -> invokes param N synchronously, same as <invoke param="N" mode="call"/>;
+      "return X, ..." -> the results, each X is "new" (a new object), "pN" (param N) or "nil".
+    the results of a function without body are computed by analyzing the function as usual, unless it is excluded
+    from the analysis; then they are nil.
+    if descriptor is present, the params in invoke and body must be in its range and the number of returned values
+    must match its results.
+    for callback.yml, a method without invoke means the old meaning of callback.yml: one of its parameters
+    is a callback (see pointer.hasFuncParam()) that is invoked in a new goroutine, and the receiver is a pointer.
+*/
+
+// An Invoke describes a callback invoked by a library function.
+type Invoke struct {
+	Param int  // index of the callback in the parameters; -1 if any parameter with a callback
+	Go    bool // whether it is invoked in a new goroutine
+}
+
+// A Result describes a result returned by the synthetic body of a library function.
+type Result struct {
+	Param int // index of the returned parameter; ResultNew or ResultNil otherwise
+}
+
+const (
+	ResultNew = -1 // the result is a new object allocated by the function
+	ResultNil = -2 // the result is nil
+)
+
+func (r Result) String() string {
+	switch r.Param {
+	case ResultNew:
+		return "new"
+	case ResultNil:
+		return "nil"
+	}
+	return "p" + strconv.Itoa(r.Param)
+}
+
+// A Summary describes the callbacks invoked by a library function.
+type Summary struct {
+	Package    string // package path
+	Receiver   string // e.g., "(m *Map)"; "" for functions
+	Name       string
+	Descriptor string // e.g., "(d Duration, f func()) *Timer"; checked against the params and results
+	Invokes    []Invoke
+	Body       string   // the synthetic body, if any
+	Results    []Result // the results returned by Body; nil if Body returns nothing
+}
+
+// Key returns the string of the summarized function, i.e., Function.String().
+func (s *Summary) Key() string {
+	if s.Receiver == "" {
+		return s.Package + "." + s.Name
+	}
+	typ, ok := receiverType(s.Receiver)
+	if !ok { //malformed: matches no function, rejected by the loader
+		return s.Package + "." + s.Receiver + "." + s.Name
+	}
+	ptr := strings.HasPrefix(typ, "*")
+	typ = strings.TrimPrefix(typ, "*")
+	if !strings.Contains(typ, ".") {
+		typ = s.Package + "." + typ
+	}
+	if ptr {
+		typ = "*" + typ
+	}
+	return "(" + typ + ")." + s.Name
+}
+
+//bz: the type of recv, e.g., "*Map" of "(m *Map)"; ok is false if recv is not "([name] type)"
+func receiverType(recv string) (typ string, ok bool) {
+	if !strings.HasPrefix(recv, "(") || !strings.HasSuffix(recv, ")") {
+		return "", false
+	}
+	fields := strings.Fields(recv[1 : len(recv)-1])
+	if len(fields) != 1 && len(fields) != 2 {
+		return "", false
+	}
+	typ = fields[len(fields)-1] //the name of the receiver is optional
+	return typ, strings.TrimPrefix(typ, "*") != ""
+}
+
+func (s *Summary) String() string {
+	var invokes []string
+	for _, inv := range s.Invokes {
+		mode := "call"
+		if inv.Go {
+			mode = "go"
+		}
+		param := "any"
+		if inv.Param >= 0 {
+			param = strconv.Itoa(inv.Param)
+		}
+		invokes = append(invokes, mode+" param "+param)
+	}
+	if len(s.Results) > 0 {
+		var results []string
+		for _, r := range s.Results {
+			results = append(results, r.String())
+		}
+		invokes = append(invokes, "return "+strings.Join(results, ", "))
+	}
+	return s.Key() + ": " + strings.Join(invokes, ", ")
+}
+
+// Summaries is a set of summaries, indexed by Function.String().
+// A nil *Summaries has no summary.
+type Summaries struct {
+	summaries map[string]*Summary
+}
+
+// NewSummaries returns an empty set of summaries.
+func NewSummaries() *Summaries {
+	return &Summaries{summaries: make(map[string]*Summary)}
+}
+
+// Add adds s, and replaces the summary of the same function if any.
+func (ss *Summaries) Add(s *Summary) {
+	ss.summaries[s.Key()] = s
+}
+
+// Len returns the number of summaries.
+func (ss *Summaries) Len() int {
+	if ss == nil {
+		return 0
+	}
+	return len(ss.summaries)
+}
+
+// All returns all summaries, ordered by their keys.
+func (ss *Summaries) All() []*Summary {
+	if ss == nil {
+		return nil
+	}
+	var all []*Summary
+	for _, s := range ss.summaries {
+		all = append(all, s)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Key() < all[j].Key() })
+	return all
+}
+
+// Lookup returns the summary of fn, or nil if none.
+func (ss *Summaries) Lookup(fn *Function) *Summary {
+	if ss == nil || len(ss.summaries) == 0 {
+		return nil
+	}
+	return ss.summaries[fn.String()]
+}
+
+// LoadSummaries reads the summaries in the file at path: the format is decided by its
+// extension, .xml, .json, .yml or .yaml.
+func LoadSummaries(path string) (*Summaries, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ss := NewSummaries()
+	switch ext := filepath.Ext(path); ext {
+	case ".xml":
+		err = ss.decodeXML(data)
+	case ".json":
+		err = ss.decodeJSON(data)
+	case ".yml", ".yaml":
+		err = ss.decodeYaml(data)
+	default:
+		return nil, fmt.Errorf("%s: unknown format of summaries: %q", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return ss, nil
+}
+
+//bz: the followings are the formats of files, see above
+type specInvoke struct {
+	Param *int   `xml:"param,attr" json:"param" yaml:"param"`
+	Mode  string `xml:"mode,attr" json:"mode" yaml:"mode"`
+}
+
+type specMethod struct {
+	Name       string       `xml:"name,attr" json:"name" yaml:"name"`
+	Receiver   string       `xml:"receiver,attr" json:"receiver" yaml:"receiver"`
+	Descriptor string       `xml:"descriptor,attr" json:"descriptor" yaml:"descriptor"`
+	Invokes    []specInvoke `xml:"invoke" json:"invoke" yaml:"invoke"`
+	Body       string       `xml:"body" json:"body" yaml:"body"`
+}
+
+type specPackage struct {
+	Name    string       `xml:"name,attr" json:"name" yaml:"package"`
+	Methods []specMethod `xml:"method" json:"method" yaml:"method"`
+}
+
+type summarySpec struct {
+	Packages []specPackage `xml:"package" json:"package"`
+}
+
+type callbackSpec struct { //callback.yml
+	Packages []specPackage `yaml:"callbackcfgs"`
+}
+
+func (ss *Summaries) decodeXML(data []byte) error {
+	var spec summarySpec
+	if err := xml.Unmarshal(data, &spec); err != nil {
+		return err
+	}
+	return ss.addAll(spec.Packages, false)
+}
+
+func (ss *Summaries) decodeJSON(data []byte) error {
+	var spec summarySpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return err
+	}
+	return ss.addAll(spec.Packages, false)
+}
+
+func (ss *Summaries) decodeYaml(data []byte) error {
+	var spec callbackSpec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return err
+	}
+	return ss.addAll(spec.Packages, true)
+}
+
+//bz: isYml -> use the old meaning of callback.yml for methods without invoke
+func (ss *Summaries) addAll(pkgs []specPackage, isYml bool) error {
+	for _, pkg := range pkgs {
+		if pkg.Name == "" {
+			return fmt.Errorf("package without name")
+		}
+		for _, m := range pkg.Methods {
+			if m.Name == "" {
+				return fmt.Errorf("method without name in package %s", pkg.Name)
+			}
+			s := &Summary{
+				Package:    pkg.Name,
+				Receiver:   m.Receiver,
+				Name:       m.Name,
+				Descriptor: m.Descriptor,
+			}
+			if isYml {
+				switch {
+				case s.Receiver == "nil":
+					s.Receiver = ""
+				case s.Receiver != "" && !strings.HasPrefix(s.Receiver, "("):
+					s.Receiver = "(*" + strings.TrimPrefix(s.Receiver, "*") + ")"
+				}
+				if len(m.Invokes) == 0 && m.Body == "" {
+					s.Invokes = []Invoke{{Param: -1, Go: true}}
+				}
+			}
+			if _, ok := receiverType(s.Receiver); s.Receiver != "" && !ok {
+				return fmt.Errorf("%s.%s: invalid receiver %q", pkg.Name, m.Name, m.Receiver)
+			}
+			if m.Body != "" {
+				if err := s.parseBody(m.Body); err != nil {
+					return err
+				}
+			}
+			for _, inv := range m.Invokes {
+				if inv.Param == nil || *inv.Param < 0 {
+					return fmt.Errorf("%s: invoke without a valid param", s.Key())
+				}
+				switch inv.Mode {
+				case "go", "call":
+				default:
+					return fmt.Errorf("%s: unknown mode of invoke: %q", s.Key(), inv.Mode)
+				}
+				s.Invokes = append(s.Invokes, Invoke{Param: *inv.Param, Go: inv.Mode == "go"})
+			}
+			if len(s.Invokes) == 0 && s.Body == "" {
+				return fmt.Errorf("%s: no invoke", s.Key())
+			}
+			if err := s.checkDescriptor(); err != nil {
+				return err
+			}
+			ss.Add(s)
+		}
+	}
+	return nil
+}
+
+//bz: parse the synthetic body of s, see above
+func (s *Summary) parseBody(body string) error {
+	param := func(p string) (int, bool) {
+		if !strings.HasPrefix(p, "p") {
+			return 0, false
+		}
+		i, err := strconv.Atoi(p[1:])
+		return i, err == nil && i >= 0
+	}
+
+	s.Body = strings.TrimSpace(body)
+	for _, stmt := range strings.FieldsFunc(body, func(r rune) bool { return r == ';' || r == '\n' }) {
+		stmt = strings.TrimSpace(stmt)
+		switch {
+		case stmt == "":
+		case strings.HasPrefix(stmt, "return "):
+			if s.Results != nil {
+				return fmt.Errorf("%s: more than one return in body", s.Key())
+			}
+			for _, x := range strings.Split(strings.TrimPrefix(stmt, "return "), ",") {
+				switch x = strings.TrimSpace(x); x {
+				case "new":
+					s.Results = append(s.Results, Result{Param: ResultNew})
+				case "nil":
+					s.Results = append(s.Results, Result{Param: ResultNil})
+				default:
+					i, ok := param(x)
+					if !ok {
+						return fmt.Errorf("%s: invalid result %q in body", s.Key(), x)
+					}
+					s.Results = append(s.Results, Result{Param: i})
+				}
+			}
+		case strings.HasSuffix(stmt, "()"):
+			p := strings.TrimSuffix(stmt, "()")
+			isGo := strings.HasPrefix(p, "go ")
+			if isGo {
+				p = strings.TrimSpace(strings.TrimPrefix(p, "go "))
+			}
+			i, ok := param(p)
+			if !ok {
+				return fmt.Errorf("%s: invalid invoke %q in body", s.Key(), stmt)
+			}
+			s.Invokes = append(s.Invokes, Invoke{Param: i, Go: isGo})
+		default:
+			return fmt.Errorf("%s: invalid statement %q in body", s.Key(), stmt)
+		}
+	}
+	return nil
+}
+
+//bz: check the params of the invokes and the results of the body against the descriptor of s, if any
+func (s *Summary) checkDescriptor() error {
+	if s.Descriptor == "" {
+		return nil
+	}
+	expr, err := parser.ParseExpr("func" + s.Descriptor)
+	if err != nil {
+		return fmt.Errorf("%s: invalid descriptor %q: %v", s.Key(), s.Descriptor, err)
+	}
+	sig, ok := expr.(*ast.FuncType)
+	if !ok {
+		return fmt.Errorf("%s: invalid descriptor %q", s.Key(), s.Descriptor)
+	}
+	count := func(fields *ast.FieldList) int {
+		n := 0
+		if fields != nil {
+			for _, f := range fields.List {
+				if len(f.Names) == 0 {
+					n++
+				} else {
+					n += len(f.Names)
+				}
+			}
+		}
+		return n
+	}
+
+	params := count(sig.Params)
+	for _, inv := range s.Invokes {
+		if inv.Param >= params {
+			return fmt.Errorf("%s: param %d out of range: %s has %d params", s.Key(), inv.Param, s.Descriptor, params)
+		}
+	}
+	if s.Results == nil {
+		return nil
+	}
+	if results := count(sig.Results); len(s.Results) != results {
+		return fmt.Errorf("%s: body returns %d values, but %s has %d results", s.Key(), len(s.Results), s.Descriptor, results)
+	}
+	for _, r := range s.Results {
+		if r.Param >= params {
+			return fmt.Errorf("%s: param %d out of range: %s has %d params", s.Key(), r.Param, s.Descriptor, params)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package ssa_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/april1989/origin-go-tools/go/ssa"
+)
+
+func dumpSummaries(ss *ssa.Summaries) string {
+	var all []string
+	for _, s := range ss.All() {
+		all = append(all, s.String())
+	}
+	return strings.Join(all, "; ")
+}
+
+// native.xml and native.json have the same content.
+func TestLoadSummaries(t *testing.T) {
+	want := "(*sync.Map).Range: call param 0; time.AfterFunc: go param 1, return new"
+	for _, path := range []string{"native.xml", "native.json"} {
+		ss, err := ssa.LoadSummaries(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := dumpSummaries(ss); got != want {
+			t.Errorf("%s: got %s, want %s", path, got, want)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "summary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, test := range []struct {
+		yml, want string
+	}{
+		{ // old callback.yml: any param, in a new goroutine
+			`
+callbackcfgs:
+  - package: time
+    method:
+      - name: AfterFunc
+        receiver: nil
+  - package: google.golang.org/grpc
+    method:
+      - name: Serve
+        receiver: grpc.Server
+`,
+			"(*grpc.Server).Serve: go param any; time.AfterFunc: go param any",
+		},
+		{
+			`
+callbackcfgs:
+  - package: sync
+    method:
+      - name: Range
+        receiver: (m *Map)
+        invoke:
+          - param: 0
+            mode: call
+`,
+			"(*sync.Map).Range: call param 0",
+		},
+		{
+			`
+callbackcfgs:
+  - package: sync
+    method:
+      - name: Range
+        receiver: (m *Map)
+        invoke:
+          - mode: call
+`,
+			"error: (*sync.Map).Range: invoke without a valid param",
+		},
+		{
+			`
+callbackcfgs:
+  - package: time
+    method:
+      - name: AfterFunc
+        invoke:
+          - param: 1
+            mode: defer
+`,
+			`error: time.AfterFunc: unknown mode of invoke: "defer"`,
+		},
+		{ // synthetic body
+			`
+callbackcfgs:
+  - package: time
+    method:
+      - name: AfterFunc
+        receiver: nil
+        descriptor: (d Duration, f func()) *Timer
+        body: go p1(); return new
+  - package: bytes
+    method:
+      - name: TrimSpace
+        receiver: nil
+        descriptor: (s []byte) []byte
+        body: return p0
+`,
+			"bytes.TrimSpace: return p0; time.AfterFunc: go param 1, return new",
+		},
+		{
+			`
+callbackcfgs:
+  - package: time
+    method:
+      - name: AfterFunc
+        descriptor: (d Duration, f func()) *Timer
+        invoke:
+          - param: 2
+            mode: go
+`,
+			"error: time.AfterFunc: param 2 out of range: (d Duration, f func()) *Timer has 2 params",
+		},
+		{
+			`
+callbackcfgs:
+  - package: time
+    method:
+      - name: AfterFunc
+        descriptor: (d Duration, f func()) *Timer
+        body: go p1(); return new, nil
+`,
+			"error: time.AfterFunc: body returns 2 values, but (d Duration, f func()) *Timer has 1 results",
+		},
+		{
+			`
+callbackcfgs:
+  - package: time
+    method:
+      - name: AfterFunc
+        body: defer p1()
+`,
+			`error: time.AfterFunc: invalid invoke "defer p1()" in body`,
+		},
+		{
+			`
+callbackcfgs:
+  - package: sync
+    method:
+      - name: Range
+        receiver: "()"
+        invoke:
+          - param: 0
+            mode: call
+`,
+			`error: sync.Range: invalid receiver "()"`,
+		},
+		{
+			`
+callbackcfgs:
+  - package: sync
+    method:
+      - name: Range
+        receiver: " "
+`,
+			`error: sync.Range: invalid receiver " "`,
+		},
+	} {
+		path := filepath.Join(dir, "callback.yml")
+		if err := ioutil.WriteFile(path, []byte(test.yml), 0644); err != nil {
+			t.Fatal(err)
+		}
+		var got string
+		ss, err := ssa.LoadSummaries(path)
+		if err != nil {
+			got = "error: " + strings.TrimPrefix(err.Error(), path+": ")
+		} else {
+			got = dumpSummaries(ss)
+		}
+		if got != test.want {
+			t.Errorf("got %s, want %s", got, test.want)
+		}
+	}
+
+	txt := filepath.Join(dir, "native.txt")
+	if err := ioutil.WriteFile(txt, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ssa.LoadSummaries(txt); err == nil || !strings.Contains(err.Error(), "unknown format") {
+		t.Errorf("LoadSummaries(native.txt): got %v, want an unknown format error", err)
+	}
+	bad := &ssa.Summary{Package: "sync", Receiver: "()", Name: "Range"}
+	if key := bad.Key(); key != "sync.().Range" {
+		t.Errorf("Key of receiver (): got %s", key)
+	}
+	var nilSummaries *ssa.Summaries
+	if n := nilSummaries.Len(); n != 0 {
+		t.Errorf("nil summaries: got %d summaries", n)
+	}
+}
@@ -28,7 +28,7 @@ func main() {
 	}else{
 		//DoSameRoot and DoParallel cannot both be true
 		if flags.DoParallel {
-			myutil.DoParallel(mains) //bz: --> fatal error: concurrent map writes!! discarded
+			myutil.DoParallel(mains) //bz: each analysis has its own config and states now, see pointer.Config
 		} else {
 			if flags.DoSameRoot {
 				myutil.DoSameRoot(mains)